@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/semka95/payment-service/payment/apikey"
+	"github.com/semka95/payment-service/payment/audit"
+	paymentStore "github.com/semka95/payment-service/payment/repository"
+)
+
+// apiKeyUsage describes api key command
+const apiKeyUsage = `usage:
+  apikey issue -merchant ID -name NAME -scopes payments:create,payments:read
+  apikey rotate -merchant ID -id ID
+  apikey revoke -merchant ID -id ID
+  apikey list -merchant ID`
+
+// RunAPIKeyCommand issues, rotates, revokes and lists api keys of merchant, result is written to out as json
+func RunAPIKeyCommand(config *Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(apiKeyUsage)
+	}
+
+	fs := flag.NewFlagSet("apikey "+args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	name := fs.String("name", "", "api key name")
+	scopes := fs.String("scopes", "", "comma separated api key scopes")
+	id := fs.Int64("id", 0, "api key id")
+	merchant := fs.Int64("merchant", 0, "merchant id")
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%w\n%s", err, apiKeyUsage)
+	}
+	if *merchant < 1 {
+		return fmt.Errorf("merchant is required\n%s", apiKeyUsage)
+	}
+
+	db, err := sql.Open(config.DBDriver, config.DBSource)
+	if err != nil {
+		return fmt.Errorf("can't open database connection: %w", err)
+	}
+	defer db.Close()
+
+	// change is recorded in audit log in the same transaction
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("can't start transaction: %w", err)
+	}
+	defer tx.Rollback()
+	store := paymentStore.WithTx(paymentStore.New(db), tx)
+	keys := apikey.NewManager(store)
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+
+	switch args[0] {
+	case "issue":
+		var ss []string
+		if *scopes != "" {
+			ss = strings.Split(*scopes, ",")
+		}
+		k, key, issueErr := keys.Issue(ctx, *merchant, *name, ss)
+		if issueErr != nil {
+			return issueErr
+		}
+		if err = commitChange(ctx, tx, store, audit.APIKeyCommand, *merchant, audit.ActionIssueAPIKey, audit.APIKey(k.ID)); err != nil {
+			return err
+		}
+		return enc.Encode(map[string]interface{}{"id": k.ID, "name": k.Name, "scopes": k.Scopes, "key": key})
+	case "rotate":
+		k, key, rotateErr := keys.Rotate(ctx, *merchant, *id)
+		if rotateErr != nil {
+			return rotateErr
+		}
+		if err = commitChange(ctx, tx, store, audit.APIKeyCommand, *merchant, audit.ActionRotateAPIKey, audit.APIKey(k.ID)); err != nil {
+			return err
+		}
+		return enc.Encode(map[string]interface{}{"id": k.ID, "name": k.Name, "scopes": k.Scopes, "key": key})
+	case "revoke":
+		if err = keys.Revoke(ctx, *merchant, *id); err != nil {
+			return err
+		}
+		return commitChange(ctx, tx, store, audit.APIKeyCommand, *merchant, audit.ActionRevokeAPIKey, audit.APIKey(*id))
+	case "list":
+		ks, listErr := keys.List(ctx, *merchant)
+		if listErr != nil {
+			return listErr
+		}
+		for _, k := range ks {
+			if err = enc.Encode(map[string]interface{}{"id": k.ID, "name": k.Name, "prefix": k.Prefix, "scopes": k.Scopes, "revoked_at": k.RevokedAt}); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], apiKeyUsage)
+	}
+}
+
+// commitChange records audit log entry of change made by command and commits transaction the change is made in
+func commitChange(ctx context.Context, tx *sql.Tx, store paymentStore.Querier, actor audit.Actor, merchantID int64, action, target string) error {
+	if err := audit.Record(ctx, store, actor, merchantID, action, target); err != nil {
+		return fmt.Errorf("can't record audit log: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -2,8 +2,11 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/sethvargo/go-envconfig"
+
+	"github.com/semka95/payment-service/payment/simulator"
 )
 
 // Config stores app configuration
@@ -13,10 +16,118 @@ type Config struct {
 	HTTPServerAddress string  `env:"HTTP_SERVER_ADDRESS,default=0.0.0.0:8080"`
 	ReadTimeout       int     `env:"READ_TIMEOUT,default=5"`
 	IdleTimeout       int     `env:"IDLE_TIMEOUT,default=30"`
+	WriteTimeout      int     `env:"WRITE_TIMEOUT,default=0"`
 	ShutdownTimeout   int     `env:"SHUTDOWN_TIMEOUT,default=10"`
 	ErrorChance       float64 `env:"ERROR_CHANCE,default=0.1"`
-	UpdateUser        string  `env:"UPDATE_USER,default=admin"`
-	UpdatePass        string  `env:"UPDATE_PASS,default=pass"`
+	Simulator         SimulatorConfig
+	Expiry            ExpiryConfig
+	Risk              RiskConfig
+	JWT               JWTConfig
+	Callback          CallbackConfig
+	TLS               TLSConfig
+	RateLimit         RateLimitConfig
+	Report            ReportConfig
+	Events            EventsConfig
+}
+
+// SimulatorConfig stores payment system simulator configuration, delays are in seconds
+type SimulatorConfig struct {
+	Enabled      bool    `env:"SIMULATOR_ENABLED,default=false"`
+	Interval     int     `env:"SIMULATOR_INTERVAL,default=1"`
+	MinDelay     int     `env:"SIMULATOR_MIN_DELAY,default=5"`
+	MaxDelay     int     `env:"SIMULATOR_MAX_DELAY,default=30"`
+	Distribution string  `env:"SIMULATOR_DISTRIBUTION,default=uniform"`
+	SuccessRatio float64 `env:"SIMULATOR_SUCCESS_RATIO,default=0.8"`
+	BatchSize    int32   `env:"SIMULATOR_BATCH_SIZE,default=100"`
+}
+
+// ExpiryConfig stores payment expiration configuration, TTL and interval are in seconds,
+// CurrencyTTL overrides TTL for specific currency, e.g. "usd:3600,eur:7200"
+type ExpiryConfig struct {
+	TTL         int            `env:"EXPIRY_TTL,default=0"`
+	CurrencyTTL map[string]int `env:"EXPIRY_CURRENCY_TTL"`
+	Interval    int            `env:"EXPIRY_INTERVAL,default=10"`
+	BatchSize   int32          `env:"EXPIRY_BATCH_SIZE,default=100"`
+}
+
+// RiskConfig stores fraud screening configuration, velocity window is in seconds,
+// AmountThresholds sets maximum amount per currency, e.g. "usd:10000,eur:10000"
+type RiskConfig struct {
+	Enabled             bool              `env:"RISK_ENABLED,default=false"`
+	ReviewThreshold     int32             `env:"RISK_REVIEW_THRESHOLD,default=50"`
+	RejectThreshold     int32             `env:"RISK_REJECT_THRESHOLD,default=100"`
+	VelocityWindow      int               `env:"RISK_VELOCITY_WINDOW,default=3600"`
+	VelocityMax         int64             `env:"RISK_VELOCITY_MAX,default=10"`
+	VelocityScore       int32             `env:"RISK_VELOCITY_SCORE,default=60"`
+	AmountThresholds    map[string]string `env:"RISK_AMOUNT_THRESHOLDS"`
+	AmountScore         int32             `env:"RISK_AMOUNT_SCORE,default=60"`
+	BlockedDomains      []string          `env:"RISK_BLOCKED_DOMAINS"`
+	BlockedDomainsScore int32             `env:"RISK_BLOCKED_DOMAINS_SCORE,default=110"`
+}
+
+// JWTConfig stores bearer token configuration, JWKS is file path or url of key set,
+// empty JWKS disables bearer tokens, key set is reloaded every Refresh seconds
+type JWTConfig struct {
+	JWKS     string `env:"JWT_JWKS"`
+	Refresh  int    `env:"JWT_JWKS_REFRESH,default=300"`
+	Issuer   string `env:"JWT_ISSUER"`
+	Audience string `env:"JWT_AUDIENCE"`
+}
+
+// CallbackConfig stores signed callbacks configuration, callback may be signed by any of Secrets, each secret
+// is bound to merchant, e.g. "secret1:1,secret2:1", empty Secrets disables signed callbacks,
+// Tolerance is maximum callback age in seconds
+type CallbackConfig struct {
+	Secrets   map[string]int64 `env:"CALLBACK_SECRETS"`
+	Tolerance int              `env:"CALLBACK_TOLERANCE,default=300"`
+}
+
+// TLSConfig stores https configuration, empty CertFile means plain http, client certificates are
+// verified against ClientCAFile if it is set, ProviderIdentities are common or dns names of payment system
+// certificates bound to merchants, e.g. "provider.example.com:1", ReloadInterval is certificate files check
+// interval in seconds
+type TLSConfig struct {
+	CertFile                  string           `env:"TLS_CERT_FILE"`
+	KeyFile                   string           `env:"TLS_KEY_FILE"`
+	MinVersion                string           `env:"TLS_MIN_VERSION,default=1.2"`
+	ClientCAFile              string           `env:"TLS_CLIENT_CA_FILE"`
+	RequireClientCert         bool             `env:"TLS_REQUIRE_CLIENT_CERT,default=false"`
+	ProviderIdentities        map[string]int64 `env:"TLS_PROVIDER_IDENTITIES"`
+	ProviderRequireClientCert bool             `env:"TLS_PROVIDER_REQUIRE_CLIENT_CERT,default=false"`
+	ReloadInterval            int              `env:"TLS_RELOAD_INTERVAL,default=10"`
+}
+
+// RateLimitConfig stores rate limits of route groups in requests per minute, zero disables limit,
+// burst is number of requests allowed at once, idle buckets are removed every CleanupInterval seconds
+type RateLimitConfig struct {
+	IP              int `env:"RATE_LIMIT_IP,default=0"`
+	IPBurst         int `env:"RATE_LIMIT_IP_BURST,default=50"`
+	Create          int `env:"RATE_LIMIT_CREATE,default=0"`
+	CreateBurst     int `env:"RATE_LIMIT_CREATE_BURST,default=10"`
+	Read            int `env:"RATE_LIMIT_READ,default=0"`
+	ReadBurst       int `env:"RATE_LIMIT_READ_BURST,default=20"`
+	List            int `env:"RATE_LIMIT_LIST,default=0"`
+	ListBurst       int `env:"RATE_LIMIT_LIST_BURST,default=5"`
+	Update          int `env:"RATE_LIMIT_UPDATE,default=0"`
+	UpdateBurst     int `env:"RATE_LIMIT_UPDATE_BURST,default=20"`
+	CleanupInterval int `env:"RATE_LIMIT_CLEANUP_INTERVAL,default=60"`
+}
+
+// ReportConfig stores payment reports configuration, daily summary is rebuilt on start,
+// then last SummaryDays days are refreshed every SummaryInterval seconds
+type ReportConfig struct {
+	SummaryEnabled  bool `env:"REPORT_SUMMARY_ENABLED,default=false"`
+	SummaryInterval int  `env:"REPORT_SUMMARY_INTERVAL,default=300"`
+	SummaryDays     int  `env:"REPORT_SUMMARY_DAYS,default=2"`
+}
+
+// EventsConfig stores payment event streams configuration, NotifyEnabled shares events between service
+// instances with postgres NOTIFY, Heartbeat is stream heartbeat interval in seconds, Buffer is number
+// of events stream may fall behind by before it is closed
+type EventsConfig struct {
+	NotifyEnabled bool `env:"EVENTS_NOTIFY_ENABLED,default=false"`
+	Heartbeat     int  `env:"EVENTS_HEARTBEAT,default=15"`
+	Buffer        int  `env:"EVENTS_BUFFER,default=64"`
 }
 
 // NewConfig reads config from env and creates config struct
@@ -26,6 +137,69 @@ func NewConfig() (*Config, error) {
 	if err := envconfig.Process(ctx, &c); err != nil {
 		return nil, err
 	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
 
 	return &c, nil
 }
+
+// validate checks settings of enabled background workers, zero interval can't be used by ticker,
+// zero callback tolerance would accept callbacks of any age and zero event buffer would drop every stream,
+// simulator success ratio is probability and its distribution must be known, risk review threshold must be
+// below reject threshold, so payments can be held for review, callback secrets and provider identities must
+// be bound to merchant
+func (c *Config) validate() error {
+	settings := []struct {
+		name    string
+		value   int
+		enabled bool
+	}{
+		{"SIMULATOR_INTERVAL", c.Simulator.Interval, c.Simulator.Enabled},
+		{"SIMULATOR_BATCH_SIZE", int(c.Simulator.BatchSize), c.Simulator.Enabled},
+		{"EXPIRY_INTERVAL", c.Expiry.Interval, c.Expiry.TTL > 0 || len(c.Expiry.CurrencyTTL) > 0},
+		{"CALLBACK_TOLERANCE", c.Callback.Tolerance, len(c.Callback.Secrets) > 0},
+		{"TLS_RELOAD_INTERVAL", c.TLS.ReloadInterval, c.TLS.CertFile != ""},
+		{"RATE_LIMIT_CLEANUP_INTERVAL", c.RateLimit.CleanupInterval, true},
+		{"REPORT_SUMMARY_INTERVAL", c.Report.SummaryInterval, c.Report.SummaryEnabled},
+		{"EVENTS_HEARTBEAT", c.Events.Heartbeat, true},
+		{"EVENTS_BUFFER", c.Events.Buffer, true},
+	}
+	for _, setting := range settings {
+		if setting.enabled && setting.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %d", setting.name, setting.value)
+		}
+	}
+
+	if c.Simulator.Enabled {
+		if c.Simulator.SuccessRatio < 0 || c.Simulator.SuccessRatio > 1 {
+			return fmt.Errorf("SIMULATOR_SUCCESS_RATIO must be from 0 to 1, got %g", c.Simulator.SuccessRatio)
+		}
+		switch c.Simulator.Distribution {
+		case simulator.DistributionUniform, simulator.DistributionExponential:
+		default:
+			return fmt.Errorf("SIMULATOR_DISTRIBUTION must be %s or %s, got %q", simulator.DistributionUniform, simulator.DistributionExponential, c.Simulator.Distribution)
+		}
+	}
+
+	if c.Risk.Enabled && c.Risk.ReviewThreshold >= c.Risk.RejectThreshold {
+		return fmt.Errorf("RISK_REVIEW_THRESHOLD must be less than RISK_REJECT_THRESHOLD, got %d and %d", c.Risk.ReviewThreshold, c.Risk.RejectThreshold)
+	}
+
+	bindings := []struct {
+		name     string
+		merchant map[string]int64
+	}{
+		{"CALLBACK_SECRETS", c.Callback.Secrets},
+		{"TLS_PROVIDER_IDENTITIES", c.TLS.ProviderIdentities},
+	}
+	for _, binding := range bindings {
+		for key, merchantID := range binding.merchant {
+			if key == "" || merchantID <= 0 {
+				return fmt.Errorf("%s must bind each entry to positive merchant id, e.g. name:1", binding.name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/lib/pq"
+
+	"github.com/semka95/payment-service/payment/audit"
+	paymentStore "github.com/semka95/payment-service/payment/repository"
+)
+
+// merchantUsage describes merchant command
+const merchantUsage = `usage:
+  merchant add -name NAME
+  merchant list`
+
+// RunMerchantCommand adds and lists merchants, result is written to out as json
+func RunMerchantCommand(config *Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(merchantUsage)
+	}
+
+	fs := flag.NewFlagSet("merchant "+args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	name := fs.String("name", "", "merchant name")
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%w\n%s", err, merchantUsage)
+	}
+
+	db, err := sql.Open(config.DBDriver, config.DBSource)
+	if err != nil {
+		return fmt.Errorf("can't open database connection: %w", err)
+	}
+	defer db.Close()
+
+	// new merchant is recorded in audit log in the same transaction
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("can't start transaction: %w", err)
+	}
+	defer tx.Rollback()
+	store := paymentStore.WithTx(paymentStore.New(db), tx)
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+
+	switch args[0] {
+	case "add":
+		if *name == "" {
+			return fmt.Errorf("name is required\n%s", merchantUsage)
+		}
+		m, addErr := store.CreateMerchant(ctx, *name)
+		var pqErr *pq.Error
+		if errors.As(addErr, &pqErr) && pqErr.Code == paymentStore.UniqueViolation {
+			return fmt.Errorf("merchant %q already exists", *name)
+		}
+		if addErr != nil {
+			return addErr
+		}
+		if err = commitChange(ctx, tx, store, audit.MerchantCommand, m.ID, audit.ActionAddMerchant, audit.Merchant(m.ID)); err != nil {
+			return err
+		}
+		return enc.Encode(m)
+	case "list":
+		ms, listErr := store.ListMerchants(ctx)
+		if listErr != nil {
+			return listErr
+		}
+		for _, m := range ms {
+			if err = enc.Encode(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], merchantUsage)
+	}
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/semka95/payment-service/payment/audit"
+	"github.com/semka95/payment-service/payment/event"
+	"github.com/semka95/payment-service/payment/processor"
+	"github.com/semka95/payment-service/payment/reconcile"
+	paymentStore "github.com/semka95/payment-service/payment/repository"
+)
+
+// reconcileUsage describes reconcile command
+const reconcileUsage = `usage:
+  reconcile -merchant ID -file settlement.csv -from 2022-06-01T00:00:00Z -to 2022-06-02T00:00:00Z [-apply]`
+
+// RunReconcileCommand reconciles settlement file against payments of merchant, stored run is written to out as json
+func RunReconcileCommand(config *Config, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String("file", "", "settlement csv file")
+	from := fs.String("from", "", "start of settlement period in RFC 3339 format")
+	to := fs.String("to", "", "end of settlement period in RFC 3339 format")
+	apply := fs.Bool("apply", false, "apply provider statuses")
+	merchant := fs.Int64("merchant", 0, "merchant id")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w\n%s", err, reconcileUsage)
+	}
+	if *file == "" {
+		return fmt.Errorf("file is required\n%s", reconcileUsage)
+	}
+	if *merchant < 1 {
+		return fmt.Errorf("merchant is required\n%s", reconcileUsage)
+	}
+
+	opts := reconcile.Options{MerchantID: *merchant, Source: filepath.Base(*file), Actor: audit.Reconcile, Apply: *apply}
+	var err error
+	if opts.From, err = time.Parse(time.RFC3339, *from); err != nil {
+		return fmt.Errorf("invalid from: %w\n%s", err, reconcileUsage)
+	}
+	if opts.To, err = time.Parse(time.RFC3339, *to); err != nil {
+		return fmt.Errorf("invalid to: %w\n%s", err, reconcileUsage)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return fmt.Errorf("can't open settlement file: %w", err)
+	}
+	defer f.Close()
+	lines, err := reconcile.ParseSettlement(f)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open(config.DBDriver, config.DBSource)
+	if err != nil {
+		return fmt.Errorf("can't open database connection: %w", err)
+	}
+	defer db.Close()
+
+	// applied statuses are sent to event streams of running servers
+	var notifier event.Notifier
+	if *apply && config.Events.NotifyEnabled {
+		relay, relayErr := event.NewRelay(config.DBSource, zap.L())
+		if relayErr != nil {
+			return fmt.Errorf("can't create event relay: %w", relayErr)
+		}
+		notifier = relay
+	}
+
+	store := paymentStore.New(db)
+	result, err := reconcile.New(store, db, processor.New(store, db, nil, notifier)).Run(context.Background(), opts, lines)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
@@ -3,16 +3,29 @@ package cmd
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 
 	paymentAPI "github.com/semka95/payment-service/payment/api"
+	"github.com/semka95/payment-service/payment/callback"
+	"github.com/semka95/payment-service/payment/certs"
+	"github.com/semka95/payment-service/payment/event"
+	"github.com/semka95/payment-service/payment/expiry"
+	"github.com/semka95/payment-service/payment/processor"
+	"github.com/semka95/payment-service/payment/ratelimit"
+	"github.com/semka95/payment-service/payment/report"
 	paymentStore "github.com/semka95/payment-service/payment/repository"
+	"github.com/semka95/payment-service/payment/risk"
+	"github.com/semka95/payment-service/payment/simulator"
+	"github.com/semka95/payment-service/payment/token"
 )
 
 // RestServer represents rest server
@@ -46,21 +59,161 @@ func (s *RestServer) RunServer() {
 
 	// init router
 	store := paymentStore.New(db)
+	bus := event.NewBus()
+	bus.Subscribe(func(ctx context.Context, e event.Event) {
+		s.logger.Info("payment event", zap.String("type", string(e.Type)), zap.Int64("payment id", e.PaymentID), zap.String("status", string(e.Status)))
+	})
+	broadcaster := event.NewBroadcaster(s.config.Events.Buffer)
+	bus.Subscribe(broadcaster.Publish)
+	var relay *event.Relay
+	if s.config.Events.NotifyEnabled {
+		relay, err = event.NewRelay(s.config.DBSource, s.logger)
+		if err != nil {
+			s.logger.Error("can't create event relay", zap.Error(err))
+			return
+		}
+	}
+	var notifier event.Notifier
+	if relay != nil {
+		notifier = relay
+	}
+	proc := processor.New(store, db, bus, notifier)
+	policy := s.expiryPolicy()
+	riskEngine, err := s.riskEngine(store)
+	if err != nil {
+		s.logger.Error("can't create risk engine", zap.Error(err))
+		return
+	}
 	api := paymentAPI.API{}
-	creds := map[string]string{s.config.UpdateUser: s.config.UpdatePass}
-	router := api.NewRouter(store, db, s.config.ErrorChance, creds)
+	var callbacks *callback.Verifier
+	if len(s.config.Callback.Secrets) > 0 {
+		callbacks = callback.NewVerifier(store, s.logger, s.config.Callback.Secrets, time.Duration(s.config.Callback.Tolerance)*time.Second)
+	}
+	clientCerts := paymentAPI.ClientCertPolicy{
+		Identities: s.config.TLS.ProviderIdentities,
+		Required:   s.config.TLS.ProviderRequireClientCert,
+	}
+	limitStore := ratelimit.NewMemoryStore()
+	limits := paymentAPI.RateLimits{
+		Store:  limitStore,
+		IP:     ratelimit.PerMinute(s.config.RateLimit.IP, s.config.RateLimit.IPBurst),
+		Create: ratelimit.PerMinute(s.config.RateLimit.Create, s.config.RateLimit.CreateBurst),
+		Read:   ratelimit.PerMinute(s.config.RateLimit.Read, s.config.RateLimit.ReadBurst),
+		List:   ratelimit.PerMinute(s.config.RateLimit.List, s.config.RateLimit.ListBurst),
+		Update: ratelimit.PerMinute(s.config.RateLimit.Update, s.config.RateLimit.UpdateBurst),
+	}
+	streams := paymentAPI.Streams{
+		Broadcaster:  broadcaster,
+		Heartbeat:    time.Duration(s.config.Events.Heartbeat) * time.Second,
+		WriteTimeout: time.Duration(s.config.WriteTimeout) * time.Second,
+	}
+	router := api.NewRouter(store, db, proc, policy, riskEngine, report.NewReporter(store, s.config.Report.SummaryEnabled), s.tokenVerifier(), callbacks, clientCerts, limits, streams, s.config.ErrorChance)
+
+	// run background workers
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	if s.config.Simulator.Enabled {
+		sim := simulator.New(store, proc, s.logger, simulator.Config{
+			Interval:     time.Duration(s.config.Simulator.Interval) * time.Second,
+			MinDelay:     time.Duration(s.config.Simulator.MinDelay) * time.Second,
+			MaxDelay:     time.Duration(s.config.Simulator.MaxDelay) * time.Second,
+			Distribution: s.config.Simulator.Distribution,
+			SuccessRatio: s.config.Simulator.SuccessRatio,
+			BatchSize:    s.config.Simulator.BatchSize,
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sim.Run(ctx)
+		}()
+	}
+
+	if policy.TTL > 0 || len(policy.CurrencyTTL) > 0 {
+		sweeper := expiry.NewSweeper(store, db, bus, notifier, s.logger, time.Duration(s.config.Expiry.Interval)*time.Second, s.config.Expiry.BatchSize)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sweeper.Run(ctx)
+		}()
+	}
+
+	if callbacks != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			callbacks.Run(ctx)
+		}()
+	}
+
+	if s.config.Report.SummaryEnabled {
+		refresher := report.NewRefresher(store, db, s.logger, time.Duration(s.config.Report.SummaryInterval)*time.Second, s.config.Report.SummaryDays)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			refresher.Run(ctx)
+		}()
+	}
+
+	if relay != nil {
+		// events of other instances go to local streams only, so they aren't sent back
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			relay.Run(ctx, broadcaster)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		limitStore.Run(ctx, time.Duration(s.config.RateLimit.CleanupInterval)*time.Second)
+	}()
 
 	// init http server
 	srv := &http.Server{
-		Addr:        s.config.HTTPServerAddress,
-		Handler:     router,
-		ReadTimeout: time.Duration(s.config.ReadTimeout) * time.Second,
-		IdleTimeout: time.Duration(s.config.IdleTimeout) * time.Second,
+		Addr:         s.config.HTTPServerAddress,
+		Handler:      router,
+		ReadTimeout:  time.Duration(s.config.ReadTimeout) * time.Second,
+		IdleTimeout:  time.Duration(s.config.IdleTimeout) * time.Second,
+		WriteTimeout: time.Duration(s.config.WriteTimeout) * time.Second,
+		// event streams clear write deadline of their connections
+		ConnContext: paymentAPI.ConnContext,
+	}
+	// shutdown waits for active requests, so event streams are ended when it starts
+	srv.RegisterOnShutdown(broadcaster.Close)
+	if s.config.TLS.CertFile != "" {
+		reloader, tlsErr := certs.NewReloader(certs.Options{
+			CertFile:          s.config.TLS.CertFile,
+			KeyFile:           s.config.TLS.KeyFile,
+			ClientCAFile:      s.config.TLS.ClientCAFile,
+			MinVersion:        s.config.TLS.MinVersion,
+			RequireClientCert: s.config.TLS.RequireClientCert,
+		}, s.logger)
+		if tlsErr != nil {
+			s.logger.Error("can't load tls certificates", zap.Error(tlsErr))
+			return
+		}
+		srv.TLSConfig = reloader.TLSConfig()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reloader.Run(ctx, time.Duration(s.config.TLS.ReloadInterval)*time.Second)
+		}()
 	}
 
 	// run server
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if srv.TLSConfig != nil {
+			// certificates are served by tls config
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			s.logger.Fatal("can't start server", zap.Error(err), zap.String("server address", s.config.HTTPServerAddress))
 		}
 	}()
@@ -69,9 +222,54 @@ func (s *RestServer) RunServer() {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	<-stop
-	timeout, cancel := context.WithTimeout(context.Background(), time.Duration(s.config.ShutdownTimeout)*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(timeout); err != nil {
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Duration(s.config.ShutdownTimeout)*time.Second)
+	defer shutdownCancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
 		s.logger.Error("can't shutdown http server", zap.Error(err))
 	}
 }
+
+// expiryPolicy creates payment expiration policy from config
+func (s *RestServer) expiryPolicy() expiry.Policy {
+	policy := expiry.Policy{
+		TTL:         time.Duration(s.config.Expiry.TTL) * time.Second,
+		CurrencyTTL: make(map[paymentStore.ValidCurrency]time.Duration, len(s.config.Expiry.CurrencyTTL)),
+	}
+	for currency, ttl := range s.config.Expiry.CurrencyTTL {
+		policy.CurrencyTTL[paymentStore.ValidCurrency(currency)] = time.Duration(ttl) * time.Second
+	}
+
+	return policy
+}
+
+// tokenVerifier creates bearer token verifier from config, nil verifier means bearer tokens are disabled
+func (s *RestServer) tokenVerifier() *token.Verifier {
+	if s.config.JWT.JWKS == "" {
+		return nil
+	}
+
+	keys := token.NewKeySet(s.config.JWT.JWKS, time.Duration(s.config.JWT.Refresh)*time.Second)
+	return token.NewVerifier(keys, s.config.JWT.Issuer, s.config.JWT.Audience)
+}
+
+// riskEngine creates fraud screening engine from config, nil engine means screening is disabled
+func (s *RestServer) riskEngine(store paymentStore.Querier) (*risk.Engine, error) {
+	if !s.config.Risk.Enabled {
+		return nil, nil
+	}
+
+	thresholds := make(map[paymentStore.ValidCurrency]decimal.Decimal, len(s.config.Risk.AmountThresholds))
+	for currency, amount := range s.config.Risk.AmountThresholds {
+		threshold, err := decimal.NewFromString(amount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s amount threshold: %w", currency, err)
+		}
+		thresholds[paymentStore.ValidCurrency(currency)] = threshold
+	}
+
+	return risk.NewEngine(s.config.Risk.ReviewThreshold, s.config.Risk.RejectThreshold,
+		risk.NewVelocityRule(store, time.Duration(s.config.Risk.VelocityWindow)*time.Second, s.config.Risk.VelocityMax, s.config.Risk.VelocityScore),
+		risk.NewAmountRule(thresholds, s.config.Risk.AmountScore),
+		risk.NewEmailDomainRule(s.config.Risk.BlockedDomains, s.config.Risk.BlockedDomainsScore),
+	), nil
+}
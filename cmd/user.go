@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/semka95/payment-service/payment/audit"
+	paymentStore "github.com/semka95/payment-service/payment/repository"
+	"github.com/semka95/payment-service/payment/user"
+)
+
+// userUsage describes user command
+const userUsage = `usage:
+  user add -merchant ID -name NAME -role provider|operator|admin|readonly < PASSWORD
+  user passwd -name NAME < PASSWORD
+  user role -name NAME -role ROLE
+  user delete -name NAME
+  user list
+password is read from USER_PASSWORD env or the first line of stdin`
+
+// passwordEnv is env variable with password of new user or new password, password isn't passed
+// as argument, so it doesn't get to shell history and process list
+const passwordEnv = "USER_PASSWORD"
+
+// RunUserCommand adds, changes, deletes and lists users, password is read from env or in,
+// result is written to out as json
+func RunUserCommand(config *Config, args []string, in io.Reader, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(userUsage)
+	}
+
+	fs := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	name := fs.String("name", "", "user name")
+	role := fs.String("role", "", "user role")
+	merchant := fs.Int64("merchant", 0, "merchant id of new user")
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%w\n%s", err, userUsage)
+	}
+	var password string
+	if args[0] == "add" || args[0] == "passwd" {
+		var err error
+		if password, err = readPassword(in); err != nil {
+			return err
+		}
+	}
+
+	db, err := sql.Open(config.DBDriver, config.DBSource)
+	if err != nil {
+		return fmt.Errorf("can't open database connection: %w", err)
+	}
+	defer db.Close()
+
+	// change is recorded in audit log in the same transaction
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("can't start transaction: %w", err)
+	}
+	defer tx.Rollback()
+	store := paymentStore.WithTx(paymentStore.New(db), tx)
+	users := user.NewManager(store)
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+
+	switch args[0] {
+	case "add":
+		if *merchant < 1 {
+			return fmt.Errorf("merchant is required\n%s", userUsage)
+		}
+		u, addErr := users.Create(ctx, *merchant, *name, password, paymentStore.UserRole(*role))
+		if addErr != nil {
+			return addErr
+		}
+		if err = commitChange(ctx, tx, store, audit.UserCommand, u.MerchantID, audit.ActionAddUser, audit.User(u.ID)); err != nil {
+			return err
+		}
+		return enc.Encode(map[string]interface{}{"id": u.ID, "merchant_id": u.MerchantID, "name": u.Name, "role": u.Role})
+	case "passwd", "role", "delete":
+		u, findErr := findUser(ctx, store, *name)
+		if findErr != nil {
+			return findErr
+		}
+		var action string
+		switch args[0] {
+		case "passwd":
+			action, err = audit.ActionSetUserPassword, users.SetPassword(ctx, *name, password)
+		case "role":
+			action, err = audit.ActionSetUserRole, users.SetRole(ctx, *name, paymentStore.UserRole(*role))
+		default:
+			action, err = audit.ActionDeleteUser, users.Delete(ctx, *name)
+		}
+		if err != nil {
+			return err
+		}
+		return commitChange(ctx, tx, store, audit.UserCommand, u.MerchantID, action, audit.User(u.ID))
+	case "list":
+		us, listErr := users.List(ctx)
+		if listErr != nil {
+			return listErr
+		}
+		for _, u := range us {
+			if err = enc.Encode(map[string]interface{}{"id": u.ID, "merchant_id": u.MerchantID, "name": u.Name, "role": u.Role, "created_at": u.CreatedAt}); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], userUsage)
+	}
+}
+
+// findUser finds user changed by command, its id and merchant are recorded in audit log
+func findUser(ctx context.Context, store paymentStore.Querier, name string) (paymentStore.User, error) {
+	u, err := store.GetUserByName(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return paymentStore.User{}, user.ErrNotFound
+	}
+
+	return u, err
+}
+
+// readPassword reads password from env or the first line of in
+func readPassword(in io.Reader) (string, error) {
+	if password, ok := os.LookupEnv(passwordEnv); ok {
+		return password, nil
+	}
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("can't read password: %w", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -7,11 +7,13 @@ require (
 	github.com/go-chi/chi/v5 v5.0.7
 	github.com/go-chi/cors v1.2.1
 	github.com/go-chi/render v1.0.1
+	github.com/golang-jwt/jwt/v4 v4.4.2
 	github.com/lib/pq v1.10.6
 	github.com/sethvargo/go-envconfig v0.6.2
 	github.com/shopspring/decimal v1.3.1
 	github.com/stretchr/testify v1.7.2
 	go.uber.org/zap v1.21.0
+	golang.org/x/crypto v0.0.0-20191011191535-87dc89f01550
 )
 
 require (
@@ -27,6 +27,38 @@ func main() {
 		return
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "merchant" {
+		if err = cmd.RunMerchantCommand(config, os.Args[2:], os.Stdout); err != nil {
+			logger.Error("can't run merchant command", zap.Error(err))
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		if err = cmd.RunAPIKeyCommand(config, os.Args[2:], os.Stdout); err != nil {
+			logger.Error("can't run api key command", zap.Error(err))
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "user" {
+		if err = cmd.RunUserCommand(config, os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			logger.Error("can't run user command", zap.Error(err))
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		if err = cmd.RunReconcileCommand(config, os.Args[2:], os.Stdout); err != nil {
+			logger.Error("can't run reconcile command", zap.Error(err))
+			os.Exit(1)
+		}
+		return
+	}
+
 	srv := cmd.NewServer(logger, config)
 	srv.RunServer()
 }
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/semka95/payment-service/payment/apikey"
+	"github.com/semka95/payment-service/payment/audit"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// apiKeyRequest is issue api key request body
+type apiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// apiKeyResponse is api key without hash, plain key is set only when key is issued or rotated
+type apiKeyResponse struct {
+	ID         int64      `json:"id"`
+	MerchantID int64      `json:"merchant_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	Key        string     `json:"key,omitempty"`
+}
+
+func newAPIKeyResponse(k paymentModel.ApiKey, key string) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         k.ID,
+		MerchantID: k.MerchantID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		CreatedAt:  k.CreatedAt,
+		UpdatedAt:  k.UpdatedAt,
+		RevokedAt:  k.RevokedAt,
+		Key:        key,
+	}
+}
+
+// GET /admin/api-keys - returns all api keys of caller's merchant
+func (a *API) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	p, _ := principalFrom(r.Context())
+	ks, err := a.keys.List(r.Context(), p.MerchantID)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't find api keys")
+		return
+	}
+
+	resp := make([]apiKeyResponse, 0, len(ks))
+	for _, k := range ks {
+		resp = append(resp, newAPIKeyResponse(k, ""))
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, resp)
+}
+
+// POST /admin/api-keys - issues new api key of caller's merchant
+func (a *API) issueAPIKey(w http.ResponseWriter, r *http.Request) {
+	kr := apiKeyRequest{}
+	if err := render.DecodeJSON(r.Body, &kr); err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid request body, can't decode it to api key")
+		return
+	}
+
+	tx, err := a.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't start transaction")
+		return
+	}
+	defer tx.Rollback()
+	store := paymentModel.WithTx(a.paymentStore, tx)
+
+	p, _ := principalFrom(r.Context())
+	k, key, err := apikey.NewManager(store).Issue(r.Context(), p.MerchantID, kr.Name, kr.Scopes)
+	if errors.Is(err, apikey.ErrNoName) || errors.Is(err, apikey.ErrInvalidScope) {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid api key")
+		return
+	}
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't issue api key")
+		return
+	}
+	if err = a.commitAPIKeyChange(r, tx, store, audit.ActionIssueAPIKey, k.ID); err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't issue api key")
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, newAPIKeyResponse(k, key))
+}
+
+// POST /admin/api-keys/{id}/rotate - replaces api key with new one
+func (a *API) rotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid api key id")
+		return
+	}
+
+	tx, err := a.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't start transaction")
+		return
+	}
+	defer tx.Rollback()
+	store := paymentModel.WithTx(a.paymentStore, tx)
+
+	p, _ := principalFrom(r.Context())
+	k, key, err := apikey.NewManager(store).Rotate(r.Context(), p.MerchantID, id)
+	if errors.Is(err, apikey.ErrNotFound) {
+		SendErrorJSON(w, r, http.StatusNotFound, err, "api key not found")
+		return
+	}
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't rotate api key")
+		return
+	}
+	if err = a.commitAPIKeyChange(r, tx, store, audit.ActionRotateAPIKey, id); err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't rotate api key")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, newAPIKeyResponse(k, key))
+}
+
+// DELETE /admin/api-keys/{id} - revokes api key
+func (a *API) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid api key id")
+		return
+	}
+
+	tx, err := a.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't start transaction")
+		return
+	}
+	defer tx.Rollback()
+	store := paymentModel.WithTx(a.paymentStore, tx)
+
+	p, _ := principalFrom(r.Context())
+	err = apikey.NewManager(store).Revoke(r.Context(), p.MerchantID, id)
+	if errors.Is(err, apikey.ErrNotFound) {
+		SendErrorJSON(w, r, http.StatusNotFound, err, "api key not found")
+		return
+	}
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't revoke api key")
+		return
+	}
+	if err = a.commitAPIKeyChange(r, tx, store, audit.ActionRevokeAPIKey, id); err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't revoke api key")
+		return
+	}
+
+	render.NoContent(w, r)
+}
+
+// commitAPIKeyChange records audit log of api key change and commits transaction the change is made in
+func (a *API) commitAPIKeyChange(r *http.Request, tx *sql.Tx, store paymentModel.Querier, action string, id int64) error {
+	p, _ := principalFrom(r.Context())
+	if err := audit.Record(r.Context(), store, actorOf(r), p.MerchantID, action, audit.APIKey(id)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
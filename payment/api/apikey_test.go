@@ -0,0 +1,203 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/semka95/payment-service/payment/apikey"
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	mockedStore := &postgres.QuerierMock{
+		GetAPIKeyByHashFunc: func(ctx context.Context, keyHash string) (postgres.ApiKey, error) {
+			switch keyHash {
+			case apikey.Hash("psk_read"):
+				return postgres.ApiKey{ID: 1, Scopes: []string{"payments:read"}}, nil
+			case apikey.Hash("psk_error"):
+				return postgres.ApiKey{}, fmt.Errorf("server error")
+			}
+			return postgres.ApiKey{}, sql.ErrNoRows
+		},
+	}
+	api := API{keys: apikey.NewManager(mockedStore)}
+	handler := api.authenticate(requireScope(apikey.ScopePaymentsRead)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	cases := []struct {
+		description string
+		key         string
+		scope       apikey.Scope
+		code        int
+		details     string
+	}{
+		{description: "success", key: "psk_read", scope: apikey.ScopePaymentsRead, code: http.StatusOK},
+		{description: "no api key", code: http.StatusUnauthorized, details: "unauthorized"},
+		{description: "unknown api key", key: "psk_unknown", code: http.StatusUnauthorized, details: "unauthorized"},
+		{description: "repository server error", key: "psk_error", code: http.StatusInternalServerError, details: "can't check api key"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/payment/1", http.NoBody)
+			if tc.key != "" {
+				req.Header.Set(apiKeyHeader, tc.key)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.code, rec.Code)
+			if tc.details != "" {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, tc.details, jsonErr.Details)
+			}
+		})
+	}
+
+	t.Run("missing scope", func(t *testing.T) {
+		h := api.authenticate(requireScope(apikey.ScopePaymentsCancel)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})))
+		req := httptest.NewRequest("DELETE", "/payment/1", http.NoBody)
+		req.Header.Set(apiKeyHeader, "psk_read")
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		jsonErr := new(jsonError)
+		err := json.NewDecoder(rec.Body).Decode(jsonErr)
+		require.NoError(t, err)
+		assert.Equal(t, "no payments:cancel scope granted", jsonErr.Error)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+func TestIssueAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	cases := []struct {
+		description    string
+		mockedStore    *postgres.QuerierMock
+		reqBody        string
+		expectSQL      func(mock sqlmock.Sqlmock)
+		checkMockCalls func(tr *postgres.QuerierMock)
+		checkResponse  func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "success",
+			mockedStore: &postgres.QuerierMock{
+				CreateAPIKeyFunc: func(ctx context.Context, arg postgres.CreateAPIKeyParams) (postgres.ApiKey, error) {
+					return postgres.ApiKey{ID: 1, Name: arg.Name, Prefix: arg.Prefix, KeyHash: arg.KeyHash, Scopes: arg.Scopes}, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			reqBody: `{"name":"shop","scopes":["payments:create"]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.CreateAuditLogCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, postgres.CreateAuditLogParams{
+					Actor:      "api_key:1",
+					Action:     "api_key.issue",
+					Target:     "api_key:1",
+					Method:     http.MethodPost,
+					Path:       "/admin/api-keys",
+					MerchantID: 1,
+				}, calls[0].Arg)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := make(map[string]interface{})
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, "shop", result["name"])
+				assert.NotEmpty(t, result["key"])
+				assert.NotContains(t, result, "key_hash")
+				assert.Equal(t, http.StatusCreated, rec.Code)
+			},
+		},
+		{
+			description: "invalid scope",
+			mockedStore: &postgres.QuerierMock{},
+			reqBody:     `{"name":"shop","scopes":["payments:delete"]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.CreateAuditLogCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid scope: payments:delete", jsonErr.Error)
+				assert.Equal(t, "invalid api key", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "repository server error",
+			mockedStore: &postgres.QuerierMock{
+				CreateAPIKeyFunc: func(ctx context.Context, arg postgres.CreateAPIKeyParams) (postgres.ApiKey, error) {
+					return postgres.ApiKey{}, fmt.Errorf("server error")
+				},
+			},
+			reqBody: `{"name":"shop","scopes":["payments:create"]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.CreateAuditLogCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't issue api key", jsonErr.Details)
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api := API{paymentStore: tc.mockedStore, db: db}
+
+			req := httptest.NewRequest("POST", "/admin/api-keys", bytes.NewBufferString(tc.reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}))
+
+			tc.expectSQL(mock)
+
+			rec := httptest.NewRecorder()
+			api.issueAPIKey(rec, req)
+
+			tc.checkMockCalls(tc.mockedStore)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			tc.checkResponse(rec)
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/semka95/payment-service/payment/apikey"
+	"github.com/semka95/payment-service/payment/audit"
+	"github.com/semka95/payment-service/payment/callback"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+	"github.com/semka95/payment-service/payment/user"
+)
+
+// apiKeyHeader is header api key is sent in
+const apiKeyHeader = "X-API-Key"
+
+// maxCallbackSize limits signed callback body size
+const maxCallbackSize = 1 << 20
+
+type ctxKey int
+
+const (
+	principalCtxKey ctxKey = iota
+	connCtxKey
+)
+
+// Authentication methods of principal
+const (
+	methodAPIKey     = "api_key"
+	methodBearer     = "bearer"
+	methodCallback   = "callback"
+	methodClientCert = "client_cert"
+	methodUser       = "user"
+)
+
+// principal is authenticated api client, zero user id means client isn't bound to user
+// and may access any user payments, role is set only for users authenticated by password.
+// Client sees only payments of its merchant, payment system is bound to merchant too
+type principal struct {
+	Method     string
+	Subject    string
+	MerchantID int64
+	UserID     int64
+	Role       paymentModel.UserRole
+	Scopes     []string
+}
+
+// actor identifies principal in audit log
+func (p principal) actor() string {
+	return p.Method + ":" + p.Subject
+}
+
+// ClientCertPolicy sets which verified client certificates identify payment system, certificate
+// identity is its common name or dns name, Identities maps identity to merchant whose payments it may change,
+// Required makes provider routes accept only client certificates
+type ClientCertPolicy struct {
+	Identities map[string]int64
+	Required   bool
+}
+
+// identity returns identity of verified client certificate allowed by policy and merchant it is bound to
+func (c ClientCertPolicy) identity(state *tls.ConnectionState) (string, int64, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", 0, false
+	}
+	leaf := state.VerifiedChains[0][0]
+	for _, id := range append([]string{leaf.Subject.CommonName}, leaf.DNSNames...) {
+		if merchantID, ok := c.Identities[id]; ok && id != "" {
+			return id, merchantID, true
+		}
+	}
+
+	return "", 0, false
+}
+
+// can reports whether principal is granted scope
+func (p principal) can(scope apikey.Scope) bool {
+	for _, s := range p.Scopes {
+		if apikey.Scope(s) == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// principalFrom returns principal authenticated by middleware
+func principalFrom(ctx context.Context) (principal, bool) {
+	p, ok := ctx.Value(principalCtxKey).(principal)
+	return p, ok
+}
+
+// authenticate checks client certificate, signed callback, bearer token, user password or api key and puts
+// principal to request context, signed callbacks and bearer tokens are checked only if their verifiers are configured
+func (a *API) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p principal
+		if id, merchantID, ok := a.clientCerts.identity(r.TLS); ok {
+			// payment system identified by client certificate may only change payment statuses of its merchant
+			p = principal{Method: methodClientCert, Subject: id, MerchantID: merchantID, Scopes: []string{string(apikey.ScopePaymentsUpdateStatus)}}
+		} else if signature := r.Header.Get(callback.HeaderSignature); a.callbacks != nil && signature != "" {
+			// one byte over the limit is read to tell too large body from body of maximum size
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackSize+1))
+			if err != nil {
+				SendErrorJSON(w, r, http.StatusBadRequest, err, "can't read callback body")
+				return
+			}
+			if len(body) > maxCallbackSize {
+				SendErrorJSON(w, r, http.StatusRequestEntityTooLarge, fmt.Errorf("callback body is larger than %d bytes", maxCallbackSize), "can't read callback body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			merchantID, err := a.callbacks.Verify(r.Context(), r.Method, r.URL.Path, r.Header.Get(callback.HeaderTimestamp), r.Header.Get(callback.HeaderNonce), signature, body)
+			if errors.Is(err, callback.ErrInvalidSignature) || errors.Is(err, callback.ErrStale) || errors.Is(err, callback.ErrReplay) {
+				SendErrorJSON(w, r, http.StatusUnauthorized, err, "unauthorized")
+				return
+			}
+			if err != nil {
+				SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't check callback signature")
+				return
+			}
+			// signed callback may only change payment statuses of merchant its secret is bound to
+			p = principal{Method: methodCallback, Subject: "callback", MerchantID: merchantID, Scopes: []string{string(apikey.ScopePaymentsUpdateStatus)}}
+		} else if bearer := r.Header.Get("Authorization"); a.tokens != nil && strings.HasPrefix(bearer, "Bearer ") {
+			claims, err := a.tokens.Verify(r.Context(), strings.TrimPrefix(bearer, "Bearer "))
+			if err != nil {
+				SendErrorJSON(w, r, http.StatusUnauthorized, err, "unauthorized")
+				return
+			}
+			if claims.MerchantID < 1 {
+				SendErrorJSON(w, r, http.StatusUnauthorized, errors.New("token has no merchant"), "unauthorized")
+				return
+			}
+			p = principal{Method: methodBearer, Subject: claims.Subject, MerchantID: claims.MerchantID, UserID: claims.UserID, Scopes: claims.Scopes}
+		} else if name, password, ok := r.BasicAuth(); ok {
+			u, err := a.users.Authenticate(r.Context(), name, password)
+			if errors.Is(err, user.ErrNotFound) {
+				SendErrorJSON(w, r, http.StatusUnauthorized, errors.New("invalid user name or password"), "unauthorized")
+				return
+			}
+			if err != nil {
+				SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't check user")
+				return
+			}
+			p = principal{Method: methodUser, Subject: u.Name, MerchantID: u.MerchantID, Role: u.Role, Scopes: user.Scopes(u.Role)}
+		} else {
+			key := r.Header.Get(apiKeyHeader)
+			if key == "" {
+				SendErrorJSON(w, r, http.StatusUnauthorized, errors.New("no api key provided"), "unauthorized")
+				return
+			}
+
+			k, err := a.keys.Authenticate(r.Context(), key)
+			if errors.Is(err, apikey.ErrNotFound) {
+				SendErrorJSON(w, r, http.StatusUnauthorized, errors.New("invalid api key"), "unauthorized")
+				return
+			}
+			if err != nil {
+				SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't check api key")
+				return
+			}
+			p = principal{Method: methodAPIKey, Subject: strconv.FormatInt(k.ID, 10), MerchantID: k.MerchantID, Scopes: k.Scopes}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalCtxKey, p)))
+	})
+}
+
+// requireScope allows request only if principal from context has scope
+func requireScope(scope apikey.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := principalFrom(r.Context())
+			if !ok || !p.can(scope) {
+				SendErrorJSON(w, r, http.StatusForbidden, fmt.Errorf("no %s scope granted", scope), "forbidden")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireRole allows request only if principal from context is user with one of roles
+func requireRole(roles ...paymentModel.UserRole) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, _ := principalFrom(r.Context())
+			for _, role := range roles {
+				if p.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			if p.Role == "" {
+				SendErrorJSON(w, r, http.StatusForbidden, errors.New("user credentials required"), "forbidden")
+				return
+			}
+			SendErrorJSON(w, r, http.StatusForbidden, fmt.Errorf("%s role is not allowed", p.Role), "forbidden")
+		})
+	}
+}
+
+// actorOf returns principal of request as actor of change made by request
+func actorOf(r *http.Request) audit.Actor {
+	p, _ := principalFrom(r.Context())
+	return audit.Actor{Name: p.actor(), Method: r.Method, Path: r.URL.Path}
+}
+
+// requireClientCert allows provider request only if principal is identified by client certificate,
+// it does nothing unless policy requires client certificates
+func (a *API) requireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := principalFrom(r.Context())
+		if a.clientCerts.Required && (!ok || p.Method != methodClientCert) {
+			SendErrorJSON(w, r, http.StatusForbidden, errors.New("client certificate required"), "forbidden")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canAccessUser reports whether principal from context may read payments of user,
+// request without principal may read nothing
+func canAccessUser(ctx context.Context, userID int64) bool {
+	p, ok := principalFrom(ctx)
+	return ok && (p.UserID == 0 || p.UserID == userID)
+}
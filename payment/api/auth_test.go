@@ -0,0 +1,444 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/semka95/payment-service/payment/apikey"
+	"github.com/semka95/payment-service/payment/audit"
+	"github.com/semka95/payment-service/payment/callback"
+	"github.com/semka95/payment-service/payment/expiry"
+	postgres "github.com/semka95/payment-service/payment/repository"
+	"github.com/semka95/payment-service/payment/token"
+	"github.com/semka95/payment-service/payment/user"
+)
+
+func TestBearerUserAccess(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks, err := json.Marshal(JSON{"keys": []JSON{{
+		"kty": "RSA",
+		"kid": "gw",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwks, 0o600))
+
+	sign := func(merchantID, userID int64, scopes ...string) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, token.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   fmt.Sprintf("user-%d", userID),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			UserID:     userID,
+			MerchantID: merchantID,
+			Scopes:     scopes,
+		})
+		tok.Header["kid"] = "gw"
+		raw, signErr := tok.SignedString(key)
+		require.NoError(t, signErr)
+		return raw
+	}
+
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	// payment 1 belongs to user 2
+	mockedStore := &postgres.QuerierMock{
+		GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+			return postgres.Payment{ID: 1, MerchantID: 1, UserID: 2, PaymentStatus: postgres.ValidStatusNew}, nil
+		},
+		DiscardPaymentFunc: func(ctx context.Context, arg postgres.DiscardPaymentParams) (int64, error) {
+			return 1, nil
+		},
+		CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+			return nil
+		},
+	}
+	api := API{paymentStore: mockedStore, db: db, tokens: token.NewVerifier(token.NewKeySet(path, time.Minute), "", "")}
+	r := chi.NewRouter()
+	r.Group(func(rk chi.Router) {
+		rk.Use(api.authenticate)
+		rk.With(requireScope(apikey.ScopePaymentsRead)).Get("/user/{user_id}/payment", api.getUserPaymentsByID)
+		rk.With(requireScope(apikey.ScopePaymentsRead)).Get("/user/payment", api.getUserPaymentsByEmail)
+		rk.With(requireScope(apikey.ScopePaymentsRead)).Get("/payment/{id}/status", api.getStatus)
+		rk.With(requireScope(apikey.ScopePaymentsCancel)).Delete("/payment/{id}", api.cancelPayment)
+	})
+
+	cases := []struct {
+		description string
+		method      string
+		url         string
+		bearer      string
+		expectSQL   func(mock sqlmock.Sqlmock)
+		code        int
+		listed      bool
+	}{
+		{description: "own payments", url: "/user/2/payment", bearer: sign(1, 2, "payments:read"), code: http.StatusOK, listed: true},
+		{description: "other user payments", url: "/user/3/payment", bearer: sign(1, 2, "payments:read"), code: http.StatusForbidden},
+		{description: "payments by email", url: "/user/payment?email=test@example.com", bearer: sign(1, 2, "payments:read"), code: http.StatusForbidden},
+		{description: "service token", url: "/user/3/payment", bearer: sign(1, 0, "payments:read"), code: http.StatusOK, listed: true},
+		{description: "no scope", url: "/user/2/payment", bearer: sign(1, 2), code: http.StatusForbidden},
+		{description: "no merchant", url: "/user/2/payment", bearer: sign(0, 2, "payments:read"), code: http.StatusUnauthorized},
+		{description: "invalid token", url: "/user/2/payment", bearer: "bad", code: http.StatusUnauthorized},
+		{description: "own payment status", url: "/payment/1/status", bearer: sign(1, 2, "payments:read"), code: http.StatusOK},
+		{description: "other user payment status", url: "/payment/1/status", bearer: sign(1, 3, "payments:read"), code: http.StatusNotFound},
+		{description: "service token payment status", url: "/payment/1/status", bearer: sign(1, 0, "payments:read"), code: http.StatusOK},
+		{
+			description: "cancel own payment", method: "DELETE", url: "/payment/1", bearer: sign(1, 2, "payments:cancel"),
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			code: http.StatusOK,
+		},
+		{
+			description: "cancel other user payment", method: "DELETE", url: "/payment/1", bearer: sign(1, 3, "payments:cancel"),
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			code: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = "GET"
+			}
+			req := httptest.NewRequest(method, tc.url, http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+tc.bearer)
+			if tc.expectSQL != nil {
+				tc.expectSQL(mock)
+			}
+			if tc.listed {
+				// payments are always listed for merchant of token
+				mock.ExpectQuery("WHERE merchant_id = \\$1 AND user_id = \\$2").WithArgs(int64(1), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnRows(paymentRows(tPayments...))
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.code, rec.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSignedCallback(t *testing.T) {
+	mockedStore := &postgres.QuerierMock{
+		CreateCallbackNonceFunc: func(ctx context.Context, nonce string) (int64, error) {
+			return 1, nil
+		},
+	}
+	api := API{callbacks: callback.NewVerifier(mockedStore, zap.NewNop(), map[string]int64{"secret": 1, "partner": 2}, time.Minute)}
+	var gotBody string
+	var gotMerchant int64
+	handler := api.authenticate(requireScope(apikey.ScopePaymentsUpdateStatus)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		p, _ := principalFrom(r.Context())
+		gotMerchant = p.MerchantID
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	body := `{"payment_status":"success"}`
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	large := `{"payment_status":"success","padding":"` + strings.Repeat("a", maxCallbackSize) + `"}`
+	cases := []struct {
+		description string
+		url         string
+		body        string
+		signature   string
+		code        int
+		merchantID  int64
+	}{
+		{description: "valid signature", url: "/payment/1", body: body, signature: callback.Sign("secret", "PUT", "/payment/1", ts, "n1", []byte(body)), code: http.StatusNoContent, merchantID: 1},
+		{description: "secret of other merchant", url: "/payment/1", body: body, signature: callback.Sign("partner", "PUT", "/payment/1", ts, "n1", []byte(body)), code: http.StatusNoContent, merchantID: 2},
+		{description: "invalid signature", url: "/payment/1", body: body, signature: callback.Sign("other", "PUT", "/payment/1", ts, "n1", []byte(body)), code: http.StatusUnauthorized},
+		{description: "signature of other payment", url: "/payment/2", body: body, signature: callback.Sign("secret", "PUT", "/payment/1", ts, "n1", []byte(body)), code: http.StatusUnauthorized},
+		{description: "body too large", url: "/payment/1", body: large, signature: callback.Sign("secret", "PUT", "/payment/1", ts, "n1", []byte(large)), code: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			gotBody, gotMerchant = "", 0
+			req := httptest.NewRequest("PUT", tc.url, bytes.NewBufferString(tc.body))
+			req.Header.Set(callback.HeaderTimestamp, ts)
+			req.Header.Set(callback.HeaderNonce, "n1")
+			req.Header.Set(callback.HeaderSignature, tc.signature)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.code, rec.Code)
+			if tc.code == http.StatusNoContent {
+				assert.Equal(t, tc.body, gotBody)
+				assert.Equal(t, tc.merchantID, gotMerchant)
+			}
+		})
+	}
+}
+
+func TestClientCertPolicy(t *testing.T) {
+	provider := &x509.Certificate{Subject: pkix.Name{CommonName: "provider"}}
+	other := &x509.Certificate{Subject: pkix.Name{CommonName: "other"}, DNSNames: []string{"other.example.com"}}
+	mockedStore := &postgres.QuerierMock{
+		GetAPIKeyByHashFunc: func(ctx context.Context, keyHash string) (postgres.ApiKey, error) {
+			return postgres.ApiKey{ID: 1, Scopes: []string{"payments:update_status"}, MerchantID: 3}, nil
+		},
+	}
+
+	cases := []struct {
+		description string
+		policy      ClientCertPolicy
+		state       *tls.ConnectionState
+		apiKey      string
+		code        int
+		merchantID  int64
+	}{
+		{
+			description: "provider certificate",
+			policy:      ClientCertPolicy{Identities: map[string]int64{"provider": 1}, Required: true},
+			state:       &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{provider}}},
+			code:        http.StatusNoContent,
+			merchantID:  1,
+		},
+		{
+			description: "provider dns name",
+			policy:      ClientCertPolicy{Identities: map[string]int64{"other.example.com": 2}, Required: true},
+			state:       &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{other}}},
+			code:        http.StatusNoContent,
+			merchantID:  2,
+		},
+		{
+			description: "unknown certificate",
+			policy:      ClientCertPolicy{Identities: map[string]int64{"provider": 1}, Required: true},
+			state:       &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{other}}},
+			code:        http.StatusUnauthorized,
+		},
+		{
+			description: "unverified certificate",
+			policy:      ClientCertPolicy{Identities: map[string]int64{"provider": 1}, Required: true},
+			state:       &tls.ConnectionState{PeerCertificates: []*x509.Certificate{provider}},
+			code:        http.StatusUnauthorized,
+		},
+		{
+			description: "api key when certificate is required",
+			policy:      ClientCertPolicy{Identities: map[string]int64{"provider": 1}, Required: true},
+			apiKey:      "psk_update",
+			code:        http.StatusForbidden,
+		},
+		{
+			description: "api key when certificate is optional",
+			policy:      ClientCertPolicy{Identities: map[string]int64{"provider": 1}},
+			apiKey:      "psk_update",
+			code:        http.StatusNoContent,
+			merchantID:  3,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api := API{keys: apikey.NewManager(mockedStore), clientCerts: tc.policy}
+			var gotMerchant int64
+			handler := api.authenticate(requireScope(apikey.ScopePaymentsUpdateStatus)(api.requireClientCert(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				p, _ := principalFrom(r.Context())
+				gotMerchant = p.MerchantID
+				w.WriteHeader(http.StatusNoContent)
+			}))))
+
+			req := httptest.NewRequest("PUT", "/payment/1", http.NoBody)
+			req.TLS = tc.state
+			if tc.apiKey != "" {
+				req.Header.Set(apiKeyHeader, tc.apiKey)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.code, rec.Code)
+			assert.Equal(t, tc.merchantID, gotMerchant)
+		})
+	}
+}
+
+func TestUserRolesAndAudit(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password1"), bcrypt.MinCost)
+	require.NoError(t, err)
+	mockedStore := &postgres.QuerierMock{
+		GetUserByNameFunc: func(ctx context.Context, name string) (postgres.User, error) {
+			roles := map[string]postgres.UserRole{"alice": postgres.UserRoleOperator, "rob": postgres.UserRoleReadonly}
+			if _, ok := roles[name]; !ok {
+				return postgres.User{}, sql.ErrNoRows
+			}
+			return postgres.User{Name: name, PasswordHash: string(hash), Role: roles[name], MerchantID: 1}, nil
+		},
+		GetAPIKeyByHashFunc: func(ctx context.Context, keyHash string) (postgres.ApiKey, error) {
+			return postgres.ApiKey{ID: 7, Scopes: []string{"payments:read"}}, nil
+		},
+		CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+			return nil
+		},
+	}
+	api := API{paymentStore: mockedStore, keys: apikey.NewManager(mockedStore), users: user.NewManager(mockedStore)}
+	r := chi.NewRouter()
+	r.Use(api.authenticate)
+	r.With(requireRole(postgres.UserRoleOperator, postgres.UserRoleReadonly)).Get("/reviews", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	r.With(requireRole(postgres.UserRoleOperator)).Post("/reviews/{id}/approve", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "id") == "0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		p, _ := principalFrom(r.Context())
+		if err := audit.Record(r.Context(), mockedStore, actorOf(r), p.MerchantID, audit.ActionApprovePayment, audit.Payment(2)); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	cases := []struct {
+		description string
+		method      string
+		url         string
+		user        string
+		password    string
+		apiKey      string
+		code        int
+		audited     *postgres.CreateAuditLogParams
+	}{
+		{
+			description: "operator approves",
+			method:      "POST",
+			url:         "/reviews/2/approve",
+			user:        "alice",
+			password:    "password1",
+			code:        http.StatusOK,
+			audited:     &postgres.CreateAuditLogParams{Actor: "user:alice", Action: "payment.approve", Target: "payment:2", Method: "POST", Path: "/reviews/2/approve", MerchantID: 1},
+		},
+		{description: "readonly lists", method: "GET", url: "/reviews", user: "rob", password: "password1", code: http.StatusOK},
+		{description: "readonly can't approve", method: "POST", url: "/reviews/2/approve", user: "rob", password: "password1", code: http.StatusForbidden},
+		{description: "failed mutation", method: "POST", url: "/reviews/0/approve", user: "alice", password: "password1", code: http.StatusNotFound},
+		{description: "wrong password", method: "GET", url: "/reviews", user: "alice", password: "password2", code: http.StatusUnauthorized},
+		{description: "api key has no role", method: "GET", url: "/reviews", apiKey: "psk_read", code: http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			before := len(mockedStore.CreateAuditLogCalls())
+			req := httptest.NewRequest(tc.method, tc.url, http.NoBody)
+			if tc.user != "" {
+				req.SetBasicAuth(tc.user, tc.password)
+			}
+			if tc.apiKey != "" {
+				req.Header.Set(apiKeyHeader, tc.apiKey)
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.code, rec.Code)
+			calls := mockedStore.CreateAuditLogCalls()[before:]
+			if tc.audited == nil {
+				assert.Equal(t, 0, len(calls))
+				return
+			}
+			require.Equal(t, 1, len(calls))
+			assert.Equal(t, *tc.audited, calls[0].Arg)
+		})
+	}
+}
+
+func TestDebugVarsRequireAdmin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password1"), bcrypt.MinCost)
+	require.NoError(t, err)
+	mockedStore := &postgres.QuerierMock{
+		GetUserByNameFunc: func(ctx context.Context, name string) (postgres.User, error) {
+			roles := map[string]postgres.UserRole{"root": postgres.UserRoleAdmin, "alice": postgres.UserRoleOperator}
+			if _, ok := roles[name]; !ok {
+				return postgres.User{}, sql.ErrNoRows
+			}
+			return postgres.User{Name: name, PasswordHash: string(hash), Role: roles[name]}, nil
+		},
+	}
+	api := API{}
+	r := api.NewRouter(mockedStore, nil, nil, expiry.Policy{}, nil, nil, nil, nil, ClientCertPolicy{}, RateLimits{}, Streams{}, 0)
+
+	cases := []struct {
+		description string
+		user        string
+		code        int
+	}{
+		{description: "admin", user: "root", code: http.StatusOK},
+		{description: "operator", user: "alice", code: http.StatusForbidden},
+		{description: "anonymous", code: http.StatusUnauthorized},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/debug/vars", http.NoBody)
+			if tc.user != "" {
+				req.SetBasicAuth(tc.user, "password1")
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.code, rec.Code)
+		})
+	}
+}
+
+func TestCanAccessUser(t *testing.T) {
+	cases := []struct {
+		description string
+		ctx         context.Context
+		expected    bool
+	}{
+		{description: "no principal", ctx: context.Background(), expected: false},
+		{description: "api key", ctx: context.WithValue(context.Background(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}), expected: true},
+		{description: "same user", ctx: context.WithValue(context.Background(), principalCtxKey, principal{Method: methodBearer, Subject: "2", UserID: 2}), expected: true},
+		{description: "other user", ctx: context.WithValue(context.Background(), principalCtxKey, principal{Method: methodBearer, Subject: "3", UserID: 3}), expected: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, canAccessUser(tc.ctx, 2))
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/shopspring/decimal"
+
+	"github.com/semka95/payment-service/payment/processor"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+	"github.com/semka95/payment-service/payment/risk"
+)
+
+// Batch modes, atomic batch is created only if all items are valid,
+// partial batch creates valid items and reports invalid ones
+const (
+	batchAtomic  = "atomic"
+	batchPartial = "partial"
+)
+
+// Batch item statuses
+const (
+	itemCreated  = "created"
+	itemReplayed = "replayed"
+	itemFailed   = "failed"
+	itemSkipped  = "skipped"
+)
+
+// Batch limits, key length follows idempotency keys table column
+const (
+	maxBatchSize         = 1000
+	maxIdempotencyKeyLen = 255
+)
+
+type batchItem struct {
+	IdempotencyKey    string                     `json:"idempotency_key"`
+	UserID            int64                      `json:"user_id"`
+	Email             string                     `json:"email"`
+	Amount            decimal.Decimal            `json:"amount"`
+	Currency          paymentModel.ValidCurrency `json:"currency"`
+	Description       string                     `json:"description"`
+	MerchantReference string                     `json:"merchant_reference"`
+	Metadata          json.RawMessage            `json:"metadata"`
+}
+
+type batchRequest struct {
+	Mode  string      `json:"mode"`
+	Items []batchItem `json:"items"`
+}
+
+// batchItemResult is result of batch item, Index is item position in request
+type batchItemResult struct {
+	Index          int                   `json:"index"`
+	IdempotencyKey string                `json:"idempotency_key,omitempty"`
+	Status         string                `json:"status"`
+	Payment        *paymentModel.Payment `json:"payment,omitempty"`
+	Error          string                `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Mode     string            `json:"mode"`
+	Created  int               `json:"created"`
+	Replayed int               `json:"replayed"`
+	Failed   int               `json:"failed"`
+	Items    []batchItemResult `json:"items"`
+}
+
+// POST /payments:batch - creates payments in batch, responds 201 if all items are created,
+// 207 if partial batch has failed items and 422 if nothing is created
+func (a *API) createPayments(w http.ResponseWriter, r *http.Request) {
+	req := batchRequest{}
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid request body, can't decode it to payment batch")
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = batchAtomic
+	}
+	if req.Mode != batchAtomic && req.Mode != batchPartial {
+		SendErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("unknown batch mode %q", req.Mode), "invalid batch")
+		return
+	}
+	if len(req.Items) == 0 || len(req.Items) > maxBatchSize {
+		SendErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("batch must have from 1 to %d items, got %d", maxBatchSize, len(req.Items)), "invalid batch")
+		return
+	}
+
+	p, _ := principalFrom(r.Context())
+	tx, err := a.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't start transaction")
+		return
+	}
+	defer tx.Rollback()
+	// payments of retried batch are replayed as they are, so they aren't screened and can't fail again
+	itemKeys := make([]string, 0, len(req.Items))
+	for _, item := range req.Items {
+		if item.IdempotencyKey != "" {
+			itemKeys = append(itemKeys, item.IdempotencyKey)
+		}
+	}
+	known, err := a.processor.IdempotentPayments(r.Context(), tx, p.MerchantID, p.actor(), itemKeys)
+	if err != nil {
+		SendProcessorErrorJSON(w, r, err)
+		return
+	}
+
+	resp := batchResponse{Mode: req.Mode, Items: make([]batchItemResult, len(req.Items))}
+	payments := make([]processor.NewPayment, 0, len(req.Items))
+	accepted := make([]paymentModel.CreatePaymentParams, 0, len(req.Items))
+	pending := make([]int, 0, len(req.Items))
+	keys := make(map[string]int)
+	references := make(map[string]int)
+	now := time.Now()
+	for i, item := range req.Items {
+		resp.Items[i] = batchItemResult{Index: i, IdempotencyKey: item.IdempotencyKey}
+
+		createPayment := paymentModel.CreatePaymentParams{
+			UserID:            item.UserID,
+			Email:             item.Email,
+			Amount:            item.Amount,
+			Currency:          item.Currency,
+			MerchantID:        p.MerchantID,
+			Description:       item.Description,
+			MerchantReference: item.MerchantReference,
+			Metadata:          item.Metadata,
+		}
+		err := validateBatchItem(item)
+		if err == nil {
+			err = validatePayment(&createPayment)
+		}
+		if err == nil && !canAccessUser(r.Context(), item.UserID) {
+			err = errors.New("payments of other users can't be created")
+		}
+		if first, ok := keys[item.IdempotencyKey]; err == nil && ok {
+			err = fmt.Errorf("idempotency key is used by item %d", first)
+		}
+		if first, ok := references[item.MerchantReference]; err == nil && ok {
+			err = fmt.Errorf("merchant reference is used by item %d", first)
+		}
+		if err != nil {
+			resp.Items[i].Status = itemFailed
+			resp.Items[i].Error = err.Error()
+			resp.Failed++
+			continue
+		}
+		if item.IdempotencyKey != "" {
+			keys[item.IdempotencyKey] = i
+		}
+		if item.MerchantReference != "" {
+			references[item.MerchantReference] = i
+		}
+		if _, ok := known[item.IdempotencyKey]; ok && item.IdempotencyKey != "" {
+			payments = append(payments, processor.NewPayment{CreatePaymentParams: createPayment, IdempotencyKey: item.IdempotencyKey})
+			pending = append(pending, i)
+			continue
+		}
+
+		screening := risk.Result{Decision: paymentModel.RiskDecisionApprove, Rules: []string{}}
+		if a.risk != nil {
+			// earlier items of the batch count toward velocity of user
+			screening, err = a.risk.Screen(r.Context(), createPayment, accepted)
+			if err != nil {
+				SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't screen payment")
+				return
+			}
+		}
+		if screening.Decision == paymentModel.RiskDecisionReject {
+			resp.Items[i].Status = itemFailed
+			resp.Items[i].Error = fmt.Sprintf("payment rejected by risk rules: %s", strings.Join(screening.Rules, ", "))
+			resp.Failed++
+			continue
+		}
+		createPayment.RiskScore = screening.Score
+		createPayment.RiskDecision = screening.Decision
+		createPayment.RiskRules = screening.Rules
+
+		createPayment.PaymentStatus = paymentModel.ValidStatusNew
+		createPayment.ExpiresAt = a.expiry.ExpiresAt(createPayment.Currency, now)
+		// payment held for review isn't sent to payment system, so it can't fail there
+		switch {
+		case screening.Decision == paymentModel.RiskDecisionReview:
+			createPayment.PaymentStatus = paymentModel.ValidStatusReview
+		case 1-rand.Float64() <= a.errorChance:
+			createPayment.PaymentStatus = paymentModel.ValidStatusError
+			createPayment.ExpiresAt = nil
+		}
+
+		payments = append(payments, processor.NewPayment{CreatePaymentParams: createPayment, IdempotencyKey: item.IdempotencyKey})
+		accepted = append(accepted, createPayment)
+		pending = append(pending, i)
+	}
+
+	if (resp.Failed > 0 && req.Mode == batchAtomic) || len(payments) == 0 {
+		for _, i := range pending {
+			resp.Items[i].Status = itemSkipped
+		}
+		render.Status(r, http.StatusUnprocessableEntity)
+		render.JSON(w, r, resp)
+		return
+	}
+
+	created, err := a.processor.CreatePaymentsTx(r.Context(), tx, actorOf(r), p.MerchantID, p.actor(), payments, known, req.Mode == batchPartial)
+	if err != nil {
+		SendProcessorErrorJSON(w, r, err)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't commit payments")
+		return
+	}
+	for n, i := range pending {
+		switch {
+		case created[n].Err != nil:
+			resp.Items[i].Status = itemFailed
+			resp.Items[i].Error = created[n].Err.Error()
+			resp.Failed++
+		case created[n].Replayed:
+			resp.Items[i].Payment = &created[n].Payment
+			resp.Items[i].Status = itemReplayed
+			resp.Replayed++
+		default:
+			resp.Items[i].Payment = &created[n].Payment
+			resp.Items[i].Status = itemCreated
+			resp.Created++
+		}
+	}
+
+	switch {
+	case resp.Created+resp.Replayed == 0:
+		render.Status(r, http.StatusUnprocessableEntity)
+	case resp.Failed > 0:
+		render.Status(r, http.StatusMultiStatus)
+	default:
+		render.Status(r, http.StatusCreated)
+	}
+	render.JSON(w, r, resp)
+}
+
+// PUT /payments/status:batch - updates statuses of payments in one transaction, every updated payment
+// is recorded in audit log
+func (a *API) updateStatuses(w http.ResponseWriter, r *http.Request) {
+	updates := make([]processor.StatusUpdate, 0)
+	if err := render.DecodeJSON(r.Body, &updates); err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid request body, can't decode it to status updates")
+		return
+	}
+	if len(updates) == 0 || len(updates) > maxBatchSize {
+		SendErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("batch must have from 1 to %d items, got %d", maxBatchSize, len(updates)), "invalid batch")
+		return
+	}
+
+	p, _ := principalFrom(r.Context())
+	outcomes, err := a.processor.UpdateStatuses(r.Context(), actorOf(r), p.MerchantID, updates)
+	if err != nil {
+		SendProcessorErrorJSON(w, r, err)
+		return
+	}
+
+	resp := statusBatchResponse{Items: outcomes}
+	for _, o := range outcomes {
+		switch o.Outcome {
+		case processor.OutcomeUpdated:
+			resp.Updated++
+		case processor.OutcomeNotFound:
+			resp.NotFound++
+		case processor.OutcomeInvalidTransition:
+			resp.InvalidTransition++
+		}
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, resp)
+}
+
+type statusBatchResponse struct {
+	Updated           int                       `json:"updated"`
+	NotFound          int                       `json:"not_found"`
+	InvalidTransition int                       `json:"invalid_transition"`
+	Items             []processor.StatusOutcome `json:"items"`
+}
+
+// validateBatchItem checks item fields that single payment doesn't have, payment fields are checked
+// by validatePayment
+func validateBatchItem(item batchItem) error {
+	if len(item.IdempotencyKey) > maxIdempotencyKeyLen {
+		return fmt.Errorf("idempotency key is longer than %d characters", maxIdempotencyKeyLen)
+	}
+
+	return nil
+}
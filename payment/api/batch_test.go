@@ -0,0 +1,897 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/semka95/payment-service/payment/processor"
+	postgres "github.com/semka95/payment-service/payment/repository"
+	"github.com/semka95/payment-service/payment/risk"
+)
+
+func TestCreatePayments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	api := API{db: db}
+
+	// reservePaymentIDs reserves ids from 10 in request order
+	reservePaymentIDs := func(ctx context.Context, count int32) ([]int64, error) {
+		ids := make([]int64, 0, count)
+		for i := int32(0); i < count; i++ {
+			ids = append(ids, int64(10+i))
+		}
+		return ids, nil
+	}
+	// createPayments returns payments in reverse order, so they must be matched to items by reserved id
+	createPayments := func(ctx context.Context, payments json.RawMessage) ([]postgres.Payment, error) {
+		params := make([]struct {
+			ID int64 `json:"id"`
+			postgres.CreatePaymentParams
+		}, 0)
+		if err := json.Unmarshal(payments, &params); err != nil {
+			return nil, err
+		}
+		created := make([]postgres.Payment, 0, len(params))
+		for i := len(params) - 1; i >= 0; i-- {
+			p := params[i]
+			created = append(created, postgres.Payment{
+				ID:            p.ID,
+				UserID:        p.UserID,
+				Email:         p.Email,
+				Amount:        p.Amount,
+				Currency:      p.Currency,
+				PaymentStatus: p.PaymentStatus,
+				RiskRules:     p.RiskRules,
+			})
+		}
+		return created, nil
+	}
+	replayedHash, err := processor.NewPayment{CreatePaymentParams: postgres.CreatePaymentParams{
+		UserID:   2,
+		Email:    "test@example.com",
+		Amount:   decimal.RequireFromString("123.42"),
+		Currency: postgres.ValidCurrencyRub,
+		Metadata: json.RawMessage("{}"),
+	}}.RequestHash()
+	require.NoError(t, err)
+	// no stored payments are recent, velocity of batch comes from its own items
+	velocityStore := &postgres.QuerierMock{
+		CountRecentPaymentsFunc: func(ctx context.Context, arg postgres.CountRecentPaymentsParams) (int64, error) {
+			return 0, nil
+		},
+	}
+	decode := func(rec *httptest.ResponseRecorder) batchResponse {
+		result := batchResponse{}
+		err := json.NewDecoder(rec.Body).Decode(&result)
+		require.NoError(t, err)
+		return result
+	}
+
+	cases := []struct {
+		description    string
+		mockedStore    *postgres.QuerierMock
+		principal      principal
+		errorChance    float64
+		risk           *risk.Engine
+		reqBody        string
+		expectSQL      func(mock sqlmock.Sqlmock)
+		checkMockCalls func(tr *postgres.QuerierMock)
+		checkResponse  func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "atomic batch with replayed key",
+			mockedStore: &postgres.QuerierMock{
+				ListIdempotencyKeysFunc: func(ctx context.Context, arg postgres.ListIdempotencyKeysParams) ([]postgres.IdempotencyKey, error) {
+					return []postgres.IdempotencyKey{{Owner: arg.Owner, IdempotencyKey: "k1", RequestHash: replayedHash, PaymentID: 3}}, nil
+				},
+				ListPaymentsByIDsFunc: func(ctx context.Context, arg postgres.ListPaymentsByIDsParams) ([]postgres.Payment, error) {
+					return []postgres.Payment{tPayments[2]}, nil
+				},
+				ReservePaymentIDsFunc: reservePaymentIDs,
+				CreatePaymentsFunc:    createPayments,
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreateIdempotencyKeysFunc: func(ctx context.Context, arg postgres.CreateIdempotencyKeysParams) error {
+					return nil
+				},
+			},
+			principal: principal{Method: methodAPIKey, Subject: "batch"},
+			reqBody: `{"items":[
+				{"idempotency_key":"k1","user_id":2,"email":"test@example.com","amount":"123.42","currency":"rub"},
+				{"idempotency_key":"k2","user_id":2,"email":"test@example.com","amount":"0.10","currency":"usd"},
+				{"user_id":3,"email":"user@example.com","amount":"5","currency":"eur"}
+			]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				keyCalls := tr.ListIdempotencyKeysCalls()
+				require.Equal(t, 1, len(keyCalls))
+				assert.Equal(t, postgres.ListIdempotencyKeysParams{Owner: "api_key:batch", Keys: []string{"k1", "k2"}}, keyCalls[0].Arg)
+
+				calls := tr.CreatePaymentsCalls()
+				require.Equal(t, 1, len(calls))
+				params := make([]postgres.CreatePaymentParams, 0)
+				require.NoError(t, json.Unmarshal(calls[0].Payments, &params))
+				require.Equal(t, 2, len(params))
+				assert.Equal(t, "0.1", params[0].Amount.String())
+				assert.Equal(t, postgres.ValidStatusNew, params[0].PaymentStatus)
+				assert.Equal(t, postgres.RiskDecisionApprove, params[0].RiskDecision)
+				assert.Equal(t, int64(3), params[1].UserID)
+
+				newKeys := tr.CreateIdempotencyKeysCalls()
+				require.Equal(t, 1, len(newKeys))
+				assert.Equal(t, "api_key:batch", newKeys[0].Arg.Owner)
+				assert.Equal(t, []string{"k2"}, newKeys[0].Arg.Keys)
+				assert.Equal(t, []int64{10}, newKeys[0].Arg.PaymentIds)
+				require.Equal(t, 1, len(newKeys[0].Arg.RequestHashes))
+				assert.Len(t, newKeys[0].Arg.RequestHashes[0], 64)
+
+				logs := tr.CreateAuditLogCalls()
+				require.Equal(t, 2, len(logs))
+				assert.Equal(t, postgres.CreateAuditLogParams{Actor: "api_key:batch", Action: "payment.create", Target: "payment:10", Method: http.MethodPost, Path: "/payments:batch"}, logs[0].Arg)
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := decode(rec)
+				assert.Equal(t, batchAtomic, result.Mode)
+				assert.Equal(t, 2, result.Created)
+				assert.Equal(t, 1, result.Replayed)
+				require.Equal(t, 3, len(result.Items))
+				assert.Equal(t, itemReplayed, result.Items[0].Status)
+				assert.Equal(t, int64(3), result.Items[0].Payment.ID)
+				assert.Equal(t, itemCreated, result.Items[1].Status)
+				assert.Equal(t, int64(10), result.Items[1].Payment.ID)
+				assert.Equal(t, itemCreated, result.Items[2].Status)
+				assert.Equal(t, int64(11), result.Items[2].Payment.ID)
+				assert.Equal(t, http.StatusCreated, rec.Code)
+			},
+		},
+		{
+			description: "atomic batch with invalid items",
+			mockedStore: &postgres.QuerierMock{},
+			reqBody: `{"mode":"atomic","items":[
+				{"user_id":2,"email":"test@example.com","amount":"1.005","currency":"usd"},
+				{"user_id":2,"email":"test@example.com","amount":"10","currency":"usd"},
+				{"user_id":2,"email":"test@example.com","amount":"10","currency":"gbp"}
+			]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.CreatePaymentsCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := decode(rec)
+				assert.Equal(t, 0, result.Created)
+				assert.Equal(t, 2, result.Failed)
+				assert.Equal(t, batchItemResult{Index: 0, Status: itemFailed, Error: "amount must have at most 2 decimal places"}, result.Items[0])
+				assert.Equal(t, batchItemResult{Index: 1, Status: itemSkipped}, result.Items[1])
+				assert.Equal(t, batchItemResult{Index: 2, Status: itemFailed, Error: `unknown currency "gbp"`}, result.Items[2])
+				assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+			},
+		},
+		{
+			description: "partial batch with invalid items",
+			mockedStore: &postgres.QuerierMock{
+				ListIdempotencyKeysFunc: func(ctx context.Context, arg postgres.ListIdempotencyKeysParams) ([]postgres.IdempotencyKey, error) {
+					return nil, nil
+				},
+				ReservePaymentIDsFunc: reservePaymentIDs,
+				CreatePaymentsFunc:    createPayments,
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreateIdempotencyKeysFunc: func(ctx context.Context, arg postgres.CreateIdempotencyKeysParams) error {
+					return nil
+				},
+			},
+			reqBody: `{"mode":"partial","items":[
+				{"user_id":0,"email":"test@example.com","amount":"10","currency":"usd"},
+				{"idempotency_key":"k1","user_id":2,"email":"test@example.com","amount":"10","currency":"usd"},
+				{"idempotency_key":"k1","user_id":2,"email":"test@example.com","amount":"10","currency":"usd"},
+				{"user_id":2,"email":"very.long.email@example.com","amount":"10","currency":"usd"},
+				{"user_id":2,"email":"test@example.com","amount":"-1","currency":"usd"}
+			]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 1, len(tr.CreatePaymentsCalls()))
+				assert.Equal(t, 1, len(tr.CreateIdempotencyKeysCalls()))
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := decode(rec)
+				assert.Equal(t, 1, result.Created)
+				assert.Equal(t, 4, result.Failed)
+				assert.Equal(t, "user id must be positive", result.Items[0].Error)
+				assert.Equal(t, itemCreated, result.Items[1].Status)
+				assert.Equal(t, "idempotency key is used by item 1", result.Items[2].Error)
+				assert.Equal(t, "email is longer than 20 characters", result.Items[3].Error)
+				assert.Equal(t, "amount must be positive", result.Items[4].Error)
+				assert.Equal(t, http.StatusMultiStatus, rec.Code)
+			},
+		},
+		{
+			description: "error chance is applied per item",
+			mockedStore: &postgres.QuerierMock{
+				ReservePaymentIDsFunc: reservePaymentIDs,
+				CreatePaymentsFunc:    createPayments,
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			errorChance: 1,
+			risk:        risk.NewEngine(50, 100, risk.NewAmountRule(map[postgres.ValidCurrency]decimal.Decimal{postgres.ValidCurrencyUsd: decimal.NewFromInt(100)}, 60)),
+			reqBody: `{"items":[
+				{"user_id":2,"email":"test@example.com","amount":"10","currency":"usd"},
+				{"user_id":2,"email":"test@example.com","amount":"20","currency":"usd"},
+				{"user_id":2,"email":"test@example.com","amount":"200","currency":"usd"}
+			]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.ListIdempotencyKeysCalls()))
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := decode(rec)
+				require.Equal(t, 3, len(result.Items))
+				assert.Equal(t, postgres.ValidStatusError, result.Items[0].Payment.PaymentStatus)
+				assert.Equal(t, postgres.ValidStatusError, result.Items[1].Payment.PaymentStatus)
+				// payment held for review isn't failed by error chance
+				assert.Equal(t, postgres.ValidStatusReview, result.Items[2].Payment.PaymentStatus)
+				assert.Equal(t, http.StatusCreated, rec.Code)
+			},
+		},
+		{
+			description: "earlier items of batch count toward velocity",
+			mockedStore: &postgres.QuerierMock{
+				ReservePaymentIDsFunc: reservePaymentIDs,
+				CreatePaymentsFunc:    createPayments,
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			risk: risk.NewEngine(50, 100, risk.NewVelocityRule(velocityStore, time.Hour, 2, 110)),
+			reqBody: `{"mode":"partial","items":[
+				{"user_id":2,"email":"test@example.com","amount":"10","currency":"usd"},
+				{"user_id":3,"email":"other@example.com","amount":"10","currency":"usd"},
+				{"user_id":4,"email":"test@example.com","amount":"10","currency":"usd"},
+				{"user_id":2,"email":"other@example.com","amount":"10","currency":"usd"}
+			]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := decode(rec)
+				assert.Equal(t, 3, result.Created)
+				assert.Equal(t, 1, result.Failed)
+				// last item matches item 0 by user id and item 1 by email, item 2 matches only item 0 by email
+				assert.Equal(t, itemFailed, result.Items[3].Status)
+				assert.Equal(t, "payment rejected by risk rules: velocity", result.Items[3].Error)
+				assert.Equal(t, http.StatusMultiStatus, rec.Code)
+			},
+		},
+		{
+			description: "payment of other user",
+			mockedStore: &postgres.QuerierMock{},
+			principal:   principal{Method: methodBearer, Subject: "2", UserID: 2},
+			reqBody:     `{"items":[{"user_id":3,"email":"test@example.com","amount":"10","currency":"usd"}]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.CreatePaymentsCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := decode(rec)
+				assert.Equal(t, "payments of other users can't be created", result.Items[0].Error)
+				assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+			},
+		},
+		{
+			description: "idempotency key is reused with other payment details",
+			mockedStore: &postgres.QuerierMock{
+				ListIdempotencyKeysFunc: func(ctx context.Context, arg postgres.ListIdempotencyKeysParams) ([]postgres.IdempotencyKey, error) {
+					return []postgres.IdempotencyKey{{Owner: arg.Owner, IdempotencyKey: "k1", RequestHash: replayedHash, PaymentID: 3}}, nil
+				},
+				ListPaymentsByIDsFunc: func(ctx context.Context, arg postgres.ListPaymentsByIDsParams) ([]postgres.Payment, error) {
+					return []postgres.Payment{tPayments[2]}, nil
+				},
+			},
+			reqBody: `{"items":[{"idempotency_key":"k1","user_id":2,"email":"test@example.com","amount":"123.43","currency":"rub"}]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.CreatePaymentsCalls()))
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, `idempotency key "k1" is used with other payment details`, jsonErr.Error)
+				assert.Equal(t, http.StatusConflict, rec.Code)
+			},
+		},
+		{
+			description: "idempotency key is used by concurrent request",
+			mockedStore: &postgres.QuerierMock{
+				ListIdempotencyKeysFunc: func(ctx context.Context, arg postgres.ListIdempotencyKeysParams) ([]postgres.IdempotencyKey, error) {
+					return nil, nil
+				},
+				ReservePaymentIDsFunc: reservePaymentIDs,
+				CreatePaymentsFunc:    createPayments,
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreateIdempotencyKeysFunc: func(ctx context.Context, arg postgres.CreateIdempotencyKeysParams) error {
+					return &pq.Error{Code: postgres.UniqueViolation}
+				},
+			},
+			reqBody: `{"items":[{"idempotency_key":"k1","user_id":2,"email":"test@example.com","amount":"10","currency":"usd"}]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "idempotency key is used by concurrent request", jsonErr.Error)
+				assert.Equal(t, http.StatusConflict, rec.Code)
+			},
+		},
+		{
+			description: "partial batch with reused merchant reference",
+			mockedStore: &postgres.QuerierMock{
+				ListUsedMerchantReferencesFunc: func(ctx context.Context, arg postgres.ListUsedMerchantReferencesParams) ([]string, error) {
+					return nil, nil
+				},
+				ReservePaymentIDsFunc: reservePaymentIDs,
+				CreatePaymentsFunc:    createPayments,
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			reqBody: `{"mode":"partial","items":[
+				{"user_id":2,"email":"test@example.com","amount":"10","currency":"usd","merchant_reference":"order-1","metadata":{"channel":"web"}},
+				{"user_id":2,"email":"test@example.com","amount":"10","currency":"usd","merchant_reference":"order-1"},
+				{"user_id":2,"email":"test@example.com","amount":"10","currency":"usd","metadata":["web"]}
+			]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.CreatePaymentsCalls()
+				require.Equal(t, 1, len(calls))
+				params := make([]postgres.CreatePaymentParams, 0)
+				err = json.Unmarshal(calls[0].Payments, &params)
+				require.NoError(t, err)
+				require.Equal(t, 1, len(params))
+				assert.Equal(t, "order-1", params[0].MerchantReference)
+				assert.JSONEq(t, `{"channel":"web"}`, string(params[0].Metadata))
+
+				refCalls := tr.ListUsedMerchantReferencesCalls()
+				require.Equal(t, 1, len(refCalls))
+				assert.Equal(t, []string{"order-1"}, refCalls[0].Arg.MerchantReferences)
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := decode(rec)
+				assert.Equal(t, 1, result.Created)
+				assert.Equal(t, "merchant reference is used by item 0", result.Items[1].Error)
+				assert.Equal(t, "metadata must be json object with string values", result.Items[2].Error)
+				assert.Equal(t, http.StatusMultiStatus, rec.Code)
+			},
+		},
+		{
+			description: "partial batch with idempotency key reused with other payment details",
+			mockedStore: &postgres.QuerierMock{
+				ListIdempotencyKeysFunc: func(ctx context.Context, arg postgres.ListIdempotencyKeysParams) ([]postgres.IdempotencyKey, error) {
+					return []postgres.IdempotencyKey{{Owner: arg.Owner, IdempotencyKey: "k1", RequestHash: replayedHash, PaymentID: 3}}, nil
+				},
+				ListPaymentsByIDsFunc: func(ctx context.Context, arg postgres.ListPaymentsByIDsParams) ([]postgres.Payment, error) {
+					return []postgres.Payment{tPayments[2]}, nil
+				},
+				ReservePaymentIDsFunc: reservePaymentIDs,
+				CreatePaymentsFunc:    createPayments,
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreateIdempotencyKeysFunc: func(ctx context.Context, arg postgres.CreateIdempotencyKeysParams) error {
+					return nil
+				},
+			},
+			reqBody: `{"mode":"partial","items":[
+				{"idempotency_key":"k1","user_id":2,"email":"test@example.com","amount":"123.43","currency":"rub"},
+				{"idempotency_key":"k2","user_id":2,"email":"test@example.com","amount":"10","currency":"usd"}
+			]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.CreatePaymentsCalls()
+				require.Equal(t, 1, len(calls))
+				params := make([]postgres.CreatePaymentParams, 0)
+				err = json.Unmarshal(calls[0].Payments, &params)
+				require.NoError(t, err)
+				require.Equal(t, 1, len(params))
+				assert.Equal(t, "10", params[0].Amount.String())
+
+				keyCalls := tr.CreateIdempotencyKeysCalls()
+				require.Equal(t, 1, len(keyCalls))
+				assert.Equal(t, []string{"k2"}, keyCalls[0].Arg.Keys)
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := decode(rec)
+				assert.Equal(t, 1, result.Created)
+				assert.Equal(t, 1, result.Failed)
+				assert.Equal(t, itemFailed, result.Items[0].Status)
+				assert.Equal(t, `idempotency key "k1" is used with other payment details`, result.Items[0].Error)
+				assert.Nil(t, result.Items[0].Payment)
+				assert.Equal(t, itemCreated, result.Items[1].Status)
+				assert.Equal(t, http.StatusMultiStatus, rec.Code)
+			},
+		},
+		{
+			description: "partial batch with merchant reference of earlier payment",
+			mockedStore: &postgres.QuerierMock{
+				ListUsedMerchantReferencesFunc: func(ctx context.Context, arg postgres.ListUsedMerchantReferencesParams) ([]string, error) {
+					return []string{"order-1"}, nil
+				},
+				ReservePaymentIDsFunc: reservePaymentIDs,
+				CreatePaymentsFunc:    createPayments,
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			principal: principal{Method: methodAPIKey, Subject: "batch", MerchantID: 1},
+			reqBody: `{"mode":"partial","items":[
+				{"user_id":2,"email":"test@example.com","amount":"10","currency":"usd","merchant_reference":"order-1"},
+				{"user_id":2,"email":"test@example.com","amount":"10","currency":"usd","merchant_reference":"order-2"}
+			]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				refCalls := tr.ListUsedMerchantReferencesCalls()
+				require.Equal(t, 1, len(refCalls))
+				assert.Equal(t, postgres.ListUsedMerchantReferencesParams{MerchantID: 1, MerchantReferences: []string{"order-1", "order-2"}}, refCalls[0].Arg)
+
+				calls := tr.CreatePaymentsCalls()
+				require.Equal(t, 1, len(calls))
+				params := make([]postgres.CreatePaymentParams, 0)
+				err = json.Unmarshal(calls[0].Payments, &params)
+				require.NoError(t, err)
+				require.Equal(t, 1, len(params))
+				assert.Equal(t, "order-2", params[0].MerchantReference)
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := decode(rec)
+				assert.Equal(t, 1, result.Created)
+				assert.Equal(t, 1, result.Failed)
+				assert.Equal(t, itemFailed, result.Items[0].Status)
+				assert.Equal(t, "merchant reference is already used", result.Items[0].Error)
+				assert.Equal(t, itemCreated, result.Items[1].Status)
+				assert.Equal(t, http.StatusMultiStatus, rec.Code)
+			},
+		},
+		{
+			description: "merchant reference is used by earlier payment",
+			mockedStore: &postgres.QuerierMock{
+				ReservePaymentIDsFunc: reservePaymentIDs,
+				CreatePaymentsFunc: func(ctx context.Context, payments json.RawMessage) ([]postgres.Payment, error) {
+					return nil, &pq.Error{Code: postgres.UniqueViolation}
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			reqBody: `{"items":[{"user_id":2,"email":"test@example.com","amount":"10","currency":"usd","merchant_reference":"order-1"}]}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "merchant reference is already used", jsonErr.Error)
+				assert.Equal(t, http.StatusConflict, rec.Code)
+			},
+		},
+		{
+			description:    "unknown mode",
+			mockedStore:    &postgres.QuerierMock{},
+			reqBody:        `{"mode":"best_effort","items":[{"user_id":2,"email":"test@example.com","amount":"10","currency":"usd"}]}`,
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid batch", jsonErr.Details)
+				assert.Equal(t, `unknown batch mode "best_effort"`, jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "empty batch",
+			mockedStore:    &postgres.QuerierMock{},
+			reqBody:        `{"items":[]}`,
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "batch must have from 1 to 1000 items, got 0", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "bad json",
+			mockedStore:    &postgres.QuerierMock{},
+			reqBody:        `{"items":`,
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid request body, can't decode it to payment batch", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api.paymentStore = tc.mockedStore
+			api.processor = processor.New(tc.mockedStore, db, nil, nil)
+			api.errorChance = tc.errorChance
+			api.risk = tc.risk
+
+			req := httptest.NewRequest("POST", "/payments:batch", bytes.NewBufferString(tc.reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			// handlers are served behind authentication, so request always has principal
+			p := tc.principal
+			if p.Method == "" {
+				p = principal{Method: methodAPIKey, Subject: "batch"}
+			}
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, p))
+
+			tc.expectSQL(mock)
+
+			rec := httptest.NewRecorder()
+			api.createPayments(rec, req)
+
+			tc.checkMockCalls(tc.mockedStore)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
+
+func TestCreatePaymentsRetry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	// store keeps created payments and idempotency keys, so retry sees payments of first attempt
+	payments := make(map[int64]postgres.Payment)
+	keys := make(map[string]postgres.IdempotencyKey)
+	store := &postgres.QuerierMock{
+		CountRecentPaymentsFunc: func(ctx context.Context, arg postgres.CountRecentPaymentsParams) (int64, error) {
+			return int64(len(payments)), nil
+		},
+		ListIdempotencyKeysFunc: func(ctx context.Context, arg postgres.ListIdempotencyKeysParams) ([]postgres.IdempotencyKey, error) {
+			found := make([]postgres.IdempotencyKey, 0)
+			for _, key := range arg.Keys {
+				if k, ok := keys[key]; ok {
+					found = append(found, k)
+				}
+			}
+			return found, nil
+		},
+		ListPaymentsByIDsFunc: func(ctx context.Context, arg postgres.ListPaymentsByIDsParams) ([]postgres.Payment, error) {
+			found := make([]postgres.Payment, 0)
+			for _, id := range arg.Ids {
+				found = append(found, payments[id])
+			}
+			return found, nil
+		},
+		ReservePaymentIDsFunc: func(ctx context.Context, count int32) ([]int64, error) {
+			ids := make([]int64, 0, count)
+			for i := int32(0); i < count; i++ {
+				ids = append(ids, int64(len(payments))+int64(i)+1)
+			}
+			return ids, nil
+		},
+		CreatePaymentsFunc: func(ctx context.Context, rows json.RawMessage) ([]postgres.Payment, error) {
+			params := make([]struct {
+				ID int64 `json:"id"`
+				postgres.CreatePaymentParams
+			}, 0)
+			if err := json.Unmarshal(rows, &params); err != nil {
+				return nil, err
+			}
+			created := make([]postgres.Payment, 0, len(params))
+			for _, p := range params {
+				created = append(created, postgres.Payment{ID: p.ID, UserID: p.UserID, Email: p.Email, Amount: p.Amount, Currency: p.Currency, PaymentStatus: p.PaymentStatus})
+			}
+			for _, p := range created {
+				payments[p.ID] = p
+			}
+			return created, nil
+		},
+		CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+			return nil
+		},
+		CreateIdempotencyKeysFunc: func(ctx context.Context, arg postgres.CreateIdempotencyKeysParams) error {
+			for i, key := range arg.Keys {
+				keys[key] = postgres.IdempotencyKey{Owner: arg.Owner, IdempotencyKey: key, RequestHash: arg.RequestHashes[i], PaymentID: arg.PaymentIds[i]}
+			}
+			return nil
+		},
+	}
+	api := API{
+		db:           db,
+		paymentStore: store,
+		processor:    processor.New(store, db, nil, nil),
+		// third payment of user is rejected, so payments of first attempt would reject retry if they were screened
+		risk: risk.NewEngine(50, 100, risk.NewVelocityRule(store, time.Hour, 3, 110)),
+	}
+	body := `{"items":[
+		{"idempotency_key":"k1","user_id":2,"email":"test@example.com","amount":"10","currency":"usd"},
+		{"idempotency_key":"k2","user_id":2,"email":"test@example.com","amount":"20","currency":"usd"},
+		{"idempotency_key":"k3","user_id":2,"email":"test@example.com","amount":"30","currency":"usd"}
+	]}`
+	submit := func() batchResponse {
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+		req := httptest.NewRequest("POST", "/payments:batch", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "batch", MerchantID: 1}))
+		rec := httptest.NewRecorder()
+		api.createPayments(rec, req)
+		require.NoError(t, mock.ExpectationsWereMet())
+		require.Equal(t, http.StatusCreated, rec.Code)
+		result := batchResponse{}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&result))
+		return result
+	}
+
+	first := submit()
+	assert.Equal(t, 3, first.Created)
+
+	// error chance isn't rolled again for replayed payments
+	api.errorChance = 1
+	second := submit()
+	assert.Equal(t, 0, second.Created)
+	assert.Equal(t, 3, second.Replayed)
+	for i, item := range second.Items {
+		assert.Equal(t, itemReplayed, item.Status)
+		assert.Equal(t, first.Items[i].Payment, item.Payment)
+		assert.Equal(t, postgres.ValidStatusNew, item.Payment.PaymentStatus)
+	}
+}
+
+func TestUpdateStatuses(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	api := API{}
+
+	cases := []struct {
+		description    string
+		mockedStore    *postgres.QuerierMock
+		reqBody        string
+		expectSQL      func(mock sqlmock.Sqlmock)
+		checkMockCalls func(tr *postgres.QuerierMock)
+		checkResponse  func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "outcome per id",
+			mockedStore: &postgres.QuerierMock{
+				ListPaymentStatusesFunc: func(ctx context.Context, arg postgres.ListPaymentStatusesParams) ([]postgres.ListPaymentStatusesRow, error) {
+					return []postgres.ListPaymentStatusesRow{
+						{ID: 1, PaymentStatus: postgres.ValidStatusNew},
+						{ID: 2, PaymentStatus: postgres.ValidStatusSuccess},
+					}, nil
+				},
+				UpdatePaymentStatusFunc: func(ctx context.Context, arg postgres.UpdatePaymentStatusParams) (int64, error) {
+					if arg.ID == 1 {
+						return 1, nil
+					}
+					return 0, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+					return nil, nil
+				},
+			},
+			reqBody: `[{"id":1,"status":"success"},{"id":2,"status":"failure"},{"id":5,"status":"success"},{"id":1,"status":"paid"}]`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.ListPaymentStatusesCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, postgres.ListPaymentStatusesParams{Ids: []int64{1, 2, 5, 1}, MerchantID: 1}, calls[0].Arg)
+				assert.Equal(t, 2, len(tr.UpdatePaymentStatusCalls()))
+
+				audit := tr.CreateAuditLogCalls()
+				require.Equal(t, 1, len(audit))
+				assert.Equal(t, postgres.CreateAuditLogParams{
+					Actor:      "client_cert:provider",
+					Action:     "payment.update_status",
+					Target:     "payment:1",
+					Method:     http.MethodPut,
+					Path:       "/api/v1/payments/status:batch",
+					MerchantID: 1,
+				}, audit[0].Arg)
+
+				events := tr.CreatePaymentEventsCalls()
+				require.Equal(t, 1, len(events))
+				assert.Equal(t, []int64{1}, events[0].Ids)
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := statusBatchResponse{}
+				err = json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, statusBatchResponse{
+					Updated:           1,
+					NotFound:          1,
+					InvalidTransition: 2,
+					Items: []processor.StatusOutcome{
+						{ID: 1, Status: postgres.ValidStatusSuccess, Outcome: processor.OutcomeUpdated},
+						{ID: 2, Status: postgres.ValidStatusFailure, Outcome: processor.OutcomeInvalidTransition, Error: "can't update from success status to failure status"},
+						{ID: 5, Status: postgres.ValidStatusSuccess, Outcome: processor.OutcomeNotFound, Error: "payment not found"},
+						{ID: 1, Status: "paid", Outcome: processor.OutcomeInvalidTransition, Error: `can't update to "paid" status`},
+					},
+				}, result)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "repository server error",
+			mockedStore: &postgres.QuerierMock{
+				ListPaymentStatusesFunc: func(ctx context.Context, arg postgres.ListPaymentStatusesParams) ([]postgres.ListPaymentStatusesRow, error) {
+					return []postgres.ListPaymentStatusesRow{{ID: 1, PaymentStatus: postgres.ValidStatusNew}}, nil
+				},
+				UpdatePaymentStatusFunc: func(ctx context.Context, arg postgres.UpdatePaymentStatusParams) (int64, error) {
+					return 0, fmt.Errorf("server error")
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+					return nil, nil
+				},
+			},
+			reqBody: `[{"id":1,"status":"success"}]`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.CreateAuditLogCalls()))
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't update payments", jsonErr.Details)
+				assert.Equal(t, "server error", jsonErr.Error)
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+		{
+			description:    "empty batch",
+			mockedStore:    &postgres.QuerierMock{},
+			reqBody:        `[]`,
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid batch", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "bad json",
+			mockedStore:    &postgres.QuerierMock{},
+			reqBody:        `{"id":1}`,
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid request body, can't decode it to status updates", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api.paymentStore = tc.mockedStore
+			api.processor = processor.New(tc.mockedStore, db, nil, nil)
+
+			req := httptest.NewRequest("PUT", "/api/v1/payments/status:batch", bytes.NewBufferString(tc.reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodClientCert, Subject: "provider", MerchantID: 1}))
+
+			tc.expectSQL(mock)
+
+			rec := httptest.NewRecorder()
+			api.updateStatuses(rec, req)
+
+			tc.checkMockCalls(tc.mockedStore)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// maxDisputeReasonLen is dispute reason column size
+const maxDisputeReasonLen = 255
+
+// disputeRequest is dispute request body
+type disputeRequest struct {
+	Reason   string          `json:"reason"`
+	Evidence json.RawMessage `json:"evidence"`
+}
+
+// resolveDisputeRequest is dispute resolution request body
+type resolveDisputeRequest struct {
+	DisputeStatus paymentModel.DisputeStatus `json:"dispute_status"`
+}
+
+// POST /payment/{id}/disputes - opens dispute on successful payment
+func (a *API) openDispute(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid payment id")
+		return
+	}
+
+	d := disputeRequest{}
+	if err = render.DecodeJSON(r.Body, &d); err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid request body, can't decode it to dispute")
+		return
+	}
+	if d.Reason == "" {
+		SendErrorJSON(w, r, http.StatusBadRequest, errors.New("no reason provided"), "invalid dispute reason")
+		return
+	}
+	if utf8.RuneCountInString(d.Reason) > maxDisputeReasonLen {
+		SendErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("reason is longer than %d characters", maxDisputeReasonLen), "invalid dispute reason")
+		return
+	}
+	evidence, err := evidenceObject(d.Evidence)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid dispute evidence")
+		return
+	}
+
+	p, _ := principalFrom(r.Context())
+	dispute, err := a.processor.OpenDispute(r.Context(), actorOf(r), p.MerchantID, int64(paymentID), d.Reason, evidence)
+	if err != nil {
+		SendProcessorErrorJSON(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, &dispute)
+}
+
+// POST /payment/{id}/disputes/{dispute_id}/evidence - attaches evidence metadata to open dispute
+func (a *API) addDisputeEvidence(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid payment id")
+		return
+	}
+	disputeID, err := strconv.Atoi(chi.URLParam(r, "dispute_id"))
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid dispute id")
+		return
+	}
+
+	d := disputeRequest{}
+	if err = render.DecodeJSON(r.Body, &d); err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid request body, can't decode it to dispute")
+		return
+	}
+	evidence, err := evidenceObject(d.Evidence)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid dispute evidence")
+		return
+	}
+
+	p, _ := principalFrom(r.Context())
+	dispute, err := a.processor.AddDisputeEvidence(r.Context(), actorOf(r), p.MerchantID, int64(paymentID), int64(disputeID), evidence)
+	if err != nil {
+		SendProcessorErrorJSON(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, &dispute)
+}
+
+// PUT /payment/{id}/disputes/{dispute_id} - resolves dispute as won or lost
+func (a *API) resolveDispute(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid payment id")
+		return
+	}
+	disputeID, err := strconv.Atoi(chi.URLParam(r, "dispute_id"))
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid dispute id")
+		return
+	}
+
+	d := resolveDisputeRequest{}
+	if err = render.DecodeJSON(r.Body, &d); err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid request body, can't decode it to dispute")
+		return
+	}
+
+	p, _ := principalFrom(r.Context())
+	dispute, err := a.processor.ResolveDispute(r.Context(), actorOf(r), p.MerchantID, int64(paymentID), int64(disputeID), d.DisputeStatus)
+	if err != nil {
+		SendProcessorErrorJSON(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, &dispute)
+}
+
+// GET /payment/{id}/disputes - returns payment disputes
+func (a *API) getPaymentDisputes(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid payment id")
+		return
+	}
+
+	p, _ := principalFrom(r.Context())
+	payment, err := a.paymentStore.GetPaymentByID(r.Context(), paymentModel.GetPaymentByIDParams{ID: int64(paymentID), MerchantID: p.MerchantID})
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !canAccessUser(r.Context(), payment.UserID)) {
+		SendErrorJSON(w, r, http.StatusNotFound, fmt.Errorf("payment %d not found", paymentID), "payment not found")
+		return
+	}
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't get payment")
+		return
+	}
+
+	disputes, err := a.paymentStore.ListPaymentDisputes(r.Context(), paymentModel.ListPaymentDisputesParams{PaymentID: int64(paymentID), MerchantID: p.MerchantID})
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't find disputes")
+		return
+	}
+	if disputes == nil {
+		disputes = []paymentModel.Dispute{}
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, disputes)
+}
+
+// evidenceObject checks that evidence is json object, empty evidence is replaced with empty object
+func evidenceObject(evidence json.RawMessage) (json.RawMessage, error) {
+	if len(evidence) == 0 || string(evidence) == "null" {
+		return json.RawMessage("{}"), nil
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(evidence, &m); err != nil {
+		return nil, errors.New("evidence must be json object")
+	}
+
+	return evidence, nil
+}
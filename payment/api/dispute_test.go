@@ -0,0 +1,610 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/semka95/payment-service/payment/processor"
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+var tDispute = postgres.Dispute{
+	ID:            1,
+	PaymentID:     2,
+	DisputeStatus: postgres.DisputeStatusOpen,
+	Reason:        "fraud",
+	Evidence:      json.RawMessage(`{"receipt":"r-1"}`),
+	CreatedAt:     time.Now().Truncate(time.Millisecond).UTC(),
+	UpdatedAt:     time.Now().Truncate(time.Millisecond).UTC(),
+}
+
+func TestOpenDispute(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	api := API{}
+	req := new(http.Request)
+	c := chi.NewRouteContext()
+
+	cases := []struct {
+		description    string
+		mockedStore    *postgres.QuerierMock
+		reqBody        string
+		id             string
+		expectSQL      func(mock sqlmock.Sqlmock)
+		checkMockCalls func(tr *postgres.QuerierMock)
+		checkResponse  func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "success",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
+					return postgres.ValidStatusSuccess, nil
+				},
+				IsPaymentReversedFunc: func(ctx context.Context, paymentID int64) (bool, error) {
+					return false, nil
+				},
+				CreateDisputeFunc: func(ctx context.Context, arg postgres.CreateDisputeParams) (postgres.Dispute, error) {
+					return tDispute, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			reqBody: `{"reason":"fraud","evidence":{"receipt":"r-1"}}`,
+			id:      "2",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.CreateDisputeCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, int64(2), calls[0].Arg.PaymentID)
+				assert.Equal(t, int64(1), calls[0].Arg.MerchantID)
+				assert.Equal(t, "fraud", calls[0].Arg.Reason)
+				assert.JSONEq(t, `{"receipt":"r-1"}`, string(calls[0].Arg.Evidence))
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := postgres.Dispute{}
+				err = json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.EqualValues(t, tDispute, result)
+				assert.Equal(t, http.StatusCreated, rec.Code)
+			},
+		},
+		{
+			description:    "empty reason",
+			mockedStore:    &postgres.QuerierMock{},
+			reqBody:        `{"evidence":{}}`,
+			id:             "2",
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid dispute reason", jsonErr.Details)
+				assert.Equal(t, "no reason provided", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "reason is too long",
+			mockedStore:    &postgres.QuerierMock{},
+			reqBody:        `{"reason":"` + strings.Repeat("я", maxDisputeReasonLen+1) + `"}`,
+			id:             "2",
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid dispute reason", jsonErr.Details)
+				assert.Equal(t, "reason is longer than 255 characters", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "evidence is not object",
+			mockedStore:    &postgres.QuerierMock{},
+			reqBody:        `{"reason":"fraud","evidence":[1]}`,
+			id:             "2",
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid dispute evidence", jsonErr.Details)
+				assert.Equal(t, "evidence must be json object", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "payment not found",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
+					return "", sql.ErrNoRows
+				},
+			},
+			reqBody: `{"reason":"fraud"}`,
+			id:      "2",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "payment not found", jsonErr.Details)
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+		{
+			description: "payment is not successful",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
+					return postgres.ValidStatusNew, nil
+				},
+			},
+			reqBody: `{"reason":"fraud"}`,
+			id:      "2",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.CreateDisputeCalls()))
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't open dispute", jsonErr.Details)
+				assert.Equal(t, "can't open dispute for payment with new status", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "payment already reversed",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
+					return postgres.ValidStatusSuccess, nil
+				},
+				IsPaymentReversedFunc: func(ctx context.Context, paymentID int64) (bool, error) {
+					return true, nil
+				},
+			},
+			reqBody: `{"reason":"fraud"}`,
+			id:      "2",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.CreateDisputeCalls()))
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't open dispute", jsonErr.Details)
+				assert.Equal(t, "payment is already reversed", jsonErr.Error)
+				assert.Equal(t, http.StatusConflict, rec.Code)
+			},
+		},
+		{
+			description: "dispute already open",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
+					return postgres.ValidStatusSuccess, nil
+				},
+				IsPaymentReversedFunc: func(ctx context.Context, paymentID int64) (bool, error) {
+					return false, nil
+				},
+				CreateDisputeFunc: func(ctx context.Context, arg postgres.CreateDisputeParams) (postgres.Dispute, error) {
+					return postgres.Dispute{}, &pq.Error{Code: postgres.UniqueViolation}
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			reqBody: `{"reason":"fraud"}`,
+			id:      "2",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't open dispute", jsonErr.Details)
+				assert.Equal(t, "payment already has open dispute", jsonErr.Error)
+				assert.Equal(t, http.StatusConflict, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api.processor = processor.New(tc.mockedStore, db, nil, nil)
+
+			req = httptest.NewRequest("POST", "/payment/{id}/disputes", bytes.NewBufferString(tc.reqBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			c.Reset()
+			c.URLParams.Add("id", tc.id)
+			req = req.WithContext((context.WithValue(req.Context(), chi.RouteCtxKey, c)))
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}))
+
+			tc.expectSQL(mock)
+
+			rec := httptest.NewRecorder()
+			api.openDispute(rec, req)
+
+			tc.checkMockCalls(tc.mockedStore)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
+
+func TestResolveDispute(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	api := API{}
+	req := new(http.Request)
+	c := chi.NewRouteContext()
+
+	cases := []struct {
+		description    string
+		mockedStore    *postgres.QuerierMock
+		reqBody        string
+		expectSQL      func(mock sqlmock.Sqlmock)
+		checkMockCalls func(tr *postgres.QuerierMock)
+		checkResponse  func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "lost dispute records reversal",
+			mockedStore: &postgres.QuerierMock{
+				ResolveDisputeFunc: func(ctx context.Context, arg postgres.ResolveDisputeParams) (postgres.Dispute, error) {
+					d := tDispute
+					d.DisputeStatus = arg.DisputeStatus
+					return d, nil
+				},
+				CreateReversalFunc: func(ctx context.Context, arg postgres.CreateReversalParams) (postgres.Reversal, error) {
+					return postgres.Reversal{ID: 1, PaymentID: 2, DisputeID: arg.ID, Amount: decimal.NewFromInt(10), Currency: postgres.ValidCurrencyUsd}, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			reqBody: `{"dispute_status":"lost"}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.CreateReversalCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, postgres.CreateReversalParams{ID: 1, MerchantID: 1}, calls[0].Arg)
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := postgres.Dispute{}
+				err = json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, postgres.DisputeStatusLost, result.DisputeStatus)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "won dispute",
+			mockedStore: &postgres.QuerierMock{
+				ResolveDisputeFunc: func(ctx context.Context, arg postgres.ResolveDisputeParams) (postgres.Dispute, error) {
+					d := tDispute
+					d.DisputeStatus = arg.DisputeStatus
+					return d, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			reqBody: `{"dispute_status":"won"}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.CreateReversalCalls()))
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description:    "invalid status",
+			mockedStore:    &postgres.QuerierMock{},
+			reqBody:        `{"dispute_status":"open"}`,
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't resolve dispute", jsonErr.Details)
+				assert.Equal(t, "dispute can't be resolved with \"open\" status", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "already resolved",
+			mockedStore: &postgres.QuerierMock{
+				ResolveDisputeFunc: func(ctx context.Context, arg postgres.ResolveDisputeParams) (postgres.Dispute, error) {
+					return postgres.Dispute{}, sql.ErrNoRows
+				},
+				GetDisputeStatusFunc: func(ctx context.Context, arg postgres.GetDisputeStatusParams) (postgres.DisputeStatus, error) {
+					return postgres.DisputeStatusWon, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			reqBody: `{"dispute_status":"lost"}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't resolve dispute", jsonErr.Details)
+				assert.Equal(t, "dispute is already resolved, it has won status", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "dispute not found",
+			mockedStore: &postgres.QuerierMock{
+				ResolveDisputeFunc: func(ctx context.Context, arg postgres.ResolveDisputeParams) (postgres.Dispute, error) {
+					return postgres.Dispute{}, sql.ErrNoRows
+				},
+				GetDisputeStatusFunc: func(ctx context.Context, arg postgres.GetDisputeStatusParams) (postgres.DisputeStatus, error) {
+					return "", sql.ErrNoRows
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			reqBody: `{"dispute_status":"lost"}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "dispute not found", jsonErr.Details)
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+		{
+			description: "reversal server error",
+			mockedStore: &postgres.QuerierMock{
+				ResolveDisputeFunc: func(ctx context.Context, arg postgres.ResolveDisputeParams) (postgres.Dispute, error) {
+					return tDispute, nil
+				},
+				CreateReversalFunc: func(ctx context.Context, arg postgres.CreateReversalParams) (postgres.Reversal, error) {
+					return postgres.Reversal{}, fmt.Errorf("server error")
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			reqBody: `{"dispute_status":"lost"}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't record reversal", jsonErr.Details)
+				assert.Equal(t, "server error", jsonErr.Error)
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api.processor = processor.New(tc.mockedStore, db, nil, nil)
+
+			req = httptest.NewRequest("PUT", "/payment/{id}/disputes/{dispute_id}", bytes.NewBufferString(tc.reqBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			c.Reset()
+			c.URLParams.Add("id", "2")
+			c.URLParams.Add("dispute_id", "1")
+			req = req.WithContext((context.WithValue(req.Context(), chi.RouteCtxKey, c)))
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}))
+
+			tc.expectSQL(mock)
+
+			rec := httptest.NewRecorder()
+			api.resolveDispute(rec, req)
+
+			tc.checkMockCalls(tc.mockedStore)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
+
+func TestGetPaymentDisputes(t *testing.T) {
+	api := API{}
+	req := new(http.Request)
+	c := chi.NewRouteContext()
+
+	cases := []struct {
+		description   string
+		principal     principal
+		mockedStore   *postgres.QuerierMock
+		checkResponse func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "success",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, UserID: 1}, nil
+				},
+				ListPaymentDisputesFunc: func(ctx context.Context, arg postgres.ListPaymentDisputesParams) ([]postgres.Dispute, error) {
+					return []postgres.Dispute{tDispute}, nil
+				},
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := make([]postgres.Dispute, 0)
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, []postgres.Dispute{tDispute}, result)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "no disputes",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, UserID: 1}, nil
+				},
+				ListPaymentDisputesFunc: func(ctx context.Context, arg postgres.ListPaymentDisputesParams) ([]postgres.Dispute, error) {
+					return nil, nil
+				},
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.JSONEq(t, "[]", rec.Body.String())
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "payment not found",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{}, sql.ErrNoRows
+				},
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "payment not found", jsonErr.Details)
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+		{
+			description: "payment of other user",
+			principal:   principal{Method: methodBearer, Subject: "7", MerchantID: 1, UserID: 7},
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, UserID: 1}, nil
+				},
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "payment not found", jsonErr.Details)
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+		{
+			description: "repository server error",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, UserID: 1}, nil
+				},
+				ListPaymentDisputesFunc: func(ctx context.Context, arg postgres.ListPaymentDisputesParams) ([]postgres.Dispute, error) {
+					return nil, fmt.Errorf("server error")
+				},
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't find disputes", jsonErr.Details)
+				assert.Equal(t, "server error", jsonErr.Error)
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api.paymentStore = tc.mockedStore
+
+			req = httptest.NewRequest("GET", "/payment/{id}/disputes", http.NoBody)
+
+			c.Reset()
+			c.URLParams.Add("id", "2")
+			req = req.WithContext((context.WithValue(req.Context(), chi.RouteCtxKey, c)))
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, tc.principal))
+
+			rec := httptest.NewRecorder()
+			api.getPaymentDisputes(rec, req)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
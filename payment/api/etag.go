@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errVersionMismatch is returned for If-Match tag that can't be tag of any payment version
+var errVersionMismatch = errors.New("entity tag doesn't match payment version")
+
+// versionETag returns entity tag of payment version, full representation gets strong tag, sparse fieldset
+// gets weak tag, since its bytes differ from full representation of the same version
+func versionETag(version int64, sparse bool) string {
+	tag := `"` + strconv.FormatInt(version, 10) + `"`
+	if sparse {
+		return "W/" + tag
+	}
+
+	return tag
+}
+
+// ifNoneMatch reports whether If-None-Match header of request matches tag, tags are compared weakly
+func ifNoneMatch(r *http.Request, tag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ifMatch reads payment version from If-Match header, zero version means that header is missing
+// or matches any version, weak tags never match
+func ifMatch(r *http.Request) (int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	if strings.Contains(header, ",") {
+		return 0, errors.New("only one entity tag is allowed in If-Match")
+	}
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, errVersionMismatch
+	}
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version < 1 {
+		return 0, errVersionMismatch
+	}
+
+	return version, nil
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+	"github.com/semka95/payment-service/payment/search"
+)
+
+// Export formats
+const (
+	exportCSV    = "csv"
+	exportNDJSON = "ndjson"
+)
+
+// exportBatchSize is number of rows fetched from database cursor at once
+const exportBatchSize = 500
+
+var exportColumns = []string{"id", "user_id", "email", "amount", "currency", "payment_status", "created_at", "updated_at", "expires_at", "risk_score", "risk_decision", "risk_rules", "merchant_id", "description", "merchant_reference", "metadata", "version"}
+
+// GET /admin/payments/export?format=csv&user_id=2&status=success&created_from=2022-06-01T00:00:00Z - streams payments of all users of caller's merchant ordered by id
+func (a *API) exportPayments(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportCSV
+	}
+	if format != exportCSV && format != exportNDJSON {
+		SendErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("unknown export format %q", format), "invalid format")
+		return
+	}
+	f, err := searchFilterRequest(r)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid filter")
+		return
+	}
+	p, _ := principalFrom(r.Context())
+	f.MerchantID = p.MerchantID
+
+	var enc paymentEncoder
+	if format == exportCSV {
+		enc = &csvEncoder{w: csv.NewWriter(w)}
+	} else {
+		enc = &ndjsonEncoder{enc: json.NewEncoder(w)}
+	}
+	// headers are sent with the first row, so failure before it is still reported as error
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		w.Header().Set("Content-Type", enc.contentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="payments.%s"`, format))
+		w.WriteHeader(http.StatusOK)
+		return enc.header()
+	}
+	flush := func() error {
+		if err := enc.flush(); err != nil {
+			return err
+		}
+		if fl, ok := w.(http.Flusher); ok {
+			fl.Flush()
+		}
+		return nil
+	}
+
+	err = search.Export(r.Context(), a.db, search.Query{Filter: f, Sort: search.SortID}, exportBatchSize, func(p paymentModel.Payment) error {
+		if err := start(); err != nil {
+			return err
+		}
+		return enc.encode(p)
+	}, flush)
+	if err == nil {
+		err = start()
+	}
+	if err == nil {
+		err = flush()
+	}
+	if err != nil && !started {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't export payments")
+		return
+	}
+	if err != nil {
+		// response is already sent partially, it can be only cut off
+		zap.L().Error("can't export payments", zap.Error(err), zap.String("url", r.URL.String()))
+	}
+}
+
+// paymentEncoder writes payments in export format
+type paymentEncoder interface {
+	contentType() string
+	header() error
+	encode(p paymentModel.Payment) error
+	flush() error
+}
+
+// csvEncoder writes payments as csv rows, amounts are exact decimal strings, times are in RFC 3339 format in UTC
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) contentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (e *csvEncoder) header() error {
+	return e.w.Write(exportColumns)
+}
+
+func (e *csvEncoder) encode(p paymentModel.Payment) error {
+	expiresAt := ""
+	if p.ExpiresAt != nil {
+		expiresAt = p.ExpiresAt.UTC().Format(time.RFC3339Nano)
+	}
+	return e.w.Write([]string{
+		strconv.FormatInt(p.ID, 10),
+		strconv.FormatInt(p.UserID, 10),
+		p.Email,
+		p.Amount.String(),
+		string(p.Currency),
+		string(p.PaymentStatus),
+		p.CreatedAt.UTC().Format(time.RFC3339Nano),
+		p.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		expiresAt,
+		strconv.FormatInt(int64(p.RiskScore), 10),
+		string(p.RiskDecision),
+		strings.Join(p.RiskRules, ","),
+		strconv.FormatInt(p.MerchantID, 10),
+		p.Description,
+		p.MerchantReference,
+		string(p.Metadata),
+		strconv.FormatInt(p.Version, 10),
+	})
+}
+
+// flush writes buffered rows, csv writer keeps write error, so it is returned here
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// ndjsonEncoder writes payments as json objects separated by newlines, amounts are json strings
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) contentType() string {
+	return "application/x-ndjson"
+}
+
+func (e *ndjsonEncoder) header() error {
+	return nil
+}
+
+func (e *ndjsonEncoder) encode(p paymentModel.Payment) error {
+	return e.enc.Encode(p)
+}
+
+func (e *ndjsonEncoder) flush() error {
+	return nil
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+func TestExportPayments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	api := API{db: db}
+
+	created := time.Date(2022, 6, 1, 10, 0, 0, 500000000, time.UTC)
+	columns := []string{"id", "user_id", "email", "amount", "currency", "payment_status", "created_at", "updated_at", "expires_at", "risk_score", "risk_decision", "risk_rules", "merchant_id", "description", "merchant_reference", "metadata", "version"}
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows(columns).
+			AddRow(1, 2, "test@example.com", "123.42", "usd", "success", created, created, nil, 0, "approve", "{}", 1, "", "", []byte("{}"), 1).
+			AddRow(2, 2, "test@example.com", "0.10", "eur", "review", created, created, created, 70, "review", "{velocity,amount}", 1, "Order 42", "order-42", []byte(`{"channel":"web"}`), 2)
+	}
+	expectExport := func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectExec("DECLARE export_payments").
+			WithArgs(int64(1), "{2}", "test%").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("FETCH FORWARD 500 FROM export_payments").WillReturnRows(rows())
+		mock.ExpectQuery("FETCH FORWARD 500 FROM export_payments").WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectCommit()
+	}
+
+	cases := []struct {
+		description   string
+		query         string
+		gzip          bool
+		expectSQL     func(mock sqlmock.Sqlmock)
+		checkResponse func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "csv",
+			query:       "?user_id=2&email_prefix=test",
+			expectSQL:   expectExport,
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, "id,user_id,email,amount,currency,payment_status,created_at,updated_at,expires_at,risk_score,risk_decision,risk_rules,merchant_id,description,merchant_reference,metadata,version\n"+
+					"1,2,test@example.com,123.42,usd,success,2022-06-01T10:00:00.5Z,2022-06-01T10:00:00.5Z,,0,approve,,1,,,{},1\n"+
+					"2,2,test@example.com,0.1,eur,review,2022-06-01T10:00:00.5Z,2022-06-01T10:00:00.5Z,2022-06-01T10:00:00.5Z,70,review,\"velocity,amount\",1,Order 42,order-42,\"{\"\"channel\"\":\"\"web\"\"}\",2\n",
+					rec.Body.String())
+				assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+				assert.Equal(t, `attachment; filename="payments.csv"`, rec.Header().Get("Content-Disposition"))
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "ndjson",
+			query:       "?format=ndjson&user_id=2&email_prefix=test",
+			expectSQL:   expectExport,
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+				require.Equal(t, 2, len(lines))
+				item := map[string]interface{}{}
+				err = json.Unmarshal([]byte(lines[1]), &item)
+				require.NoError(t, err)
+				assert.Equal(t, float64(2), item["id"])
+				assert.Equal(t, "0.1", item["amount"])
+				assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "gzip",
+			query:       "?user_id=2&email_prefix=test",
+			gzip:        true,
+			expectSQL:   expectExport,
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+				zr, err := gzip.NewReader(rec.Body)
+				require.NoError(t, err)
+				body, err := io.ReadAll(zr)
+				require.NoError(t, err)
+				assert.Equal(t, 3, strings.Count(string(body), "\n"))
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "empty export has csv header",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("DECLARE export_payments").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("FETCH FORWARD 500 FROM export_payments").WillReturnRows(sqlmock.NewRows(columns))
+				mock.ExpectCommit()
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, strings.Join(exportColumns, ",")+"\n", rec.Body.String())
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "unknown format",
+			query:       "?format=xml",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid format", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "bad filter",
+			query:       "?id=bad",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid filter", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "database error before first row",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("DECLARE export_payments").WillReturnError(fmt.Errorf("server error"))
+				mock.ExpectRollback()
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't export payments", jsonErr.Details)
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/payments/export"+tc.query, http.NoBody)
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodUser, Subject: "admin", MerchantID: 1, Role: postgres.UserRoleAdmin}))
+			handler := http.Handler(http.HandlerFunc(api.exportPayments))
+			if tc.gzip {
+				req.Header.Set("Accept-Encoding", "gzip")
+				handler = middleware.Compress(5, "text/csv", "application/x-ndjson")(handler)
+			}
+
+			tc.expectSQL(mock)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			tc.checkResponse(rec)
+		})
+	}
+}
+
+// failingWriter is response writer which connection is gone
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestExportPaymentsWriteError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	api := API{db: db}
+
+	columns := []string{"id", "user_id", "email", "amount", "currency", "payment_status", "created_at", "updated_at", "expires_at", "risk_score", "risk_decision", "risk_rules", "merchant_id", "description", "merchant_reference", "metadata", "version"}
+	mock.ExpectBegin()
+	mock.ExpectExec("DECLARE export_payments").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FETCH FORWARD 500 FROM export_payments").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(1, 2, "test@example.com", "123.42", "usd", "success", time.Now(), time.Now(), nil, 0, "approve", "{}", 1, "", "", []byte("{}"), 1))
+	// export stops when buffered rows can't be written, next batch isn't fetched
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("GET", "/admin/payments/export", http.NoBody)
+	req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodUser, Subject: "admin", MerchantID: 1, Role: postgres.UserRoleAdmin}))
+	rec := failingWriter{httptest.NewRecorder()}
+	api.exportPayments(rec, req)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.False(t, rec.Flushed)
+}
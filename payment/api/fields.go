@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// queryFields reads sparse fieldset from comma separated fields parameter, every field must be
+// json field of resource, no fields means whole resource
+func queryFields(query url.Values, resource interface{}) ([]string, error) {
+	v := query.Get("fields")
+	if v == "" {
+		return nil, nil
+	}
+	known, err := jsonObject(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0)
+	for _, f := range strings.Split(v, ",") {
+		if _, ok := known[f]; !ok {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+// selectFields returns resource with given fields only, resource is returned as is if fields are empty
+func selectFields(resource interface{}, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return resource, nil
+	}
+	all, err := jsonObject(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, f := range fields {
+		selected[f] = all[f]
+	}
+
+	return selected, nil
+}
+
+// jsonObject returns json fields of resource
+func jsonObject(resource interface{}) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
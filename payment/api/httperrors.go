@@ -1,10 +1,13 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/go-chi/render"
 	"go.uber.org/zap"
+
+	"github.com/semka95/payment-service/payment/processor"
 )
 
 // JSON is a map alias
@@ -16,3 +19,25 @@ func SendErrorJSON(w http.ResponseWriter, r *http.Request, httpStatusCode int, e
 	render.Status(r, httpStatusCode)
 	render.JSON(w, r, JSON{"error": err.Error(), "details": details})
 }
+
+// SendProcessorErrorJSON sends payment processor error as json
+func SendProcessorErrorJSON(w http.ResponseWriter, r *http.Request, err error) {
+	var pErr *processor.Error
+	if !errors.As(err, &pErr) {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't update payment")
+		return
+	}
+
+	httpStatusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(pErr, processor.ErrNotFound):
+		httpStatusCode = http.StatusNotFound
+	case errors.Is(pErr, processor.ErrTransition):
+		httpStatusCode = http.StatusBadRequest
+	case errors.Is(pErr, processor.ErrConflict):
+		httpStatusCode = http.StatusConflict
+	case errors.Is(pErr, processor.ErrPrecondition):
+		httpStatusCode = http.StatusPreconditionFailed
+	}
+	SendErrorJSON(w, r, httpStatusCode, pErr.Err, pErr.Details)
+}
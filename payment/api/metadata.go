@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Payment limits, lengths and amount follow payments table columns
+const (
+	maxEmailLen             = 20
+	maxDescriptionLen       = 1000
+	maxMerchantReferenceLen = 255
+	maxMetadataKeys         = 20
+	maxMetadataKeyLen       = 40
+	maxMetadataValueLen     = 500
+)
+
+var maxPaymentAmount = decimal.New(1, 8)
+
+// validatePayment checks fields of new payment before they get to database, it's used by single
+// and batch payment creation
+func validatePayment(p *paymentModel.CreatePaymentParams) error {
+	switch {
+	case p.UserID < 1:
+		return errors.New("user id must be positive")
+	case p.Email == "" || !strings.Contains(p.Email, "@"):
+		return fmt.Errorf("invalid email %q", p.Email)
+	case len(p.Email) > maxEmailLen:
+		return fmt.Errorf("email is longer than %d characters", maxEmailLen)
+	case !p.Amount.IsPositive():
+		return errors.New("amount must be positive")
+	case p.Amount.Exponent() < -2 && !p.Amount.Equal(p.Amount.Truncate(2)):
+		return errors.New("amount must have at most 2 decimal places")
+	case p.Amount.GreaterThanOrEqual(maxPaymentAmount):
+		return fmt.Errorf("amount must be less than %s", maxPaymentAmount)
+	}
+	switch p.Currency {
+	case paymentModel.ValidCurrencyUsd, paymentModel.ValidCurrencyEur, paymentModel.ValidCurrencyRub:
+	default:
+		return fmt.Errorf("unknown currency %q", p.Currency)
+	}
+
+	return validatePaymentDetails(p)
+}
+
+// validatePaymentDetails checks description, merchant reference and metadata of new payment,
+// metadata is json object with string values, missing metadata is saved as empty object
+func validatePaymentDetails(p *paymentModel.CreatePaymentParams) error {
+	if utf8.RuneCountInString(p.Description) > maxDescriptionLen {
+		return fmt.Errorf("description is longer than %d characters", maxDescriptionLen)
+	}
+	if utf8.RuneCountInString(p.MerchantReference) > maxMerchantReferenceLen {
+		return fmt.Errorf("merchant reference is longer than %d characters", maxMerchantReferenceLen)
+	}
+	if len(p.Metadata) == 0 || string(p.Metadata) == "null" {
+		p.Metadata = json.RawMessage("{}")
+		return nil
+	}
+
+	var m map[string]string
+	if err := json.Unmarshal(p.Metadata, &m); err != nil {
+		return errors.New("metadata must be json object with string values")
+	}
+	if err := validateMetadata(m); err != nil {
+		return err
+	}
+	metadata, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	p.Metadata = metadata
+
+	return nil
+}
+
+// validateMetadata checks metadata keys and values against limits
+func validateMetadata(m map[string]string) error {
+	if len(m) > maxMetadataKeys {
+		return fmt.Errorf("metadata has more than %d keys", maxMetadataKeys)
+	}
+	for k, v := range m {
+		if k == "" {
+			return errors.New("metadata key can't be empty")
+		}
+		if utf8.RuneCountInString(k) > maxMetadataKeyLen {
+			return fmt.Errorf("metadata key %q is longer than %d characters", k, maxMetadataKeyLen)
+		}
+		if utf8.RuneCountInString(v) > maxMetadataValueLen {
+			return fmt.Errorf("metadata value of %q key is longer than %d characters", k, maxMetadataValueLen)
+		}
+	}
+
+	return nil
+}
+
+// queryMetadata reads metadata filter from query, every metadata[key]=value pair must match
+func queryMetadata(query url.Values) (map[string]string, error) {
+	var m map[string]string
+	for name, values := range query {
+		if !strings.HasPrefix(name, "metadata[") || !strings.HasSuffix(name, "]") {
+			continue
+		}
+		key := strings.TrimSuffix(strings.TrimPrefix(name, "metadata["), "]")
+		if len(values) > 1 {
+			return nil, fmt.Errorf("metadata key %q is repeated", key)
+		}
+		if m == nil {
+			m = make(map[string]string)
+		}
+		m[key] = values[0]
+	}
+	if err := validateMetadata(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+func TestValidatePaymentDetails(t *testing.T) {
+	tooManyKeys := make(map[string]string)
+	for i := 0; i <= maxMetadataKeys; i++ {
+		tooManyKeys[fmt.Sprintf("key%d", i)] = "v"
+	}
+	manyKeys, err := json.Marshal(tooManyKeys)
+	require.NoError(t, err)
+
+	cases := []struct {
+		description string
+		params      postgres.CreatePaymentParams
+		metadata    string
+		err         string
+	}{
+		{description: "no details", metadata: "{}"},
+		{description: "null metadata", params: postgres.CreatePaymentParams{Metadata: json.RawMessage("null")}, metadata: "{}"},
+		{description: "metadata is compacted", params: postgres.CreatePaymentParams{Metadata: json.RawMessage(`{ "order" : "42" }`)}, metadata: `{"order":"42"}`},
+		{description: "long description", params: postgres.CreatePaymentParams{Description: strings.Repeat("d", maxDescriptionLen+1)}, err: "description is longer than 1000 characters"},
+		{description: "long merchant reference", params: postgres.CreatePaymentParams{MerchantReference: strings.Repeat("r", maxMerchantReferenceLen+1)}, err: "merchant reference is longer than 255 characters"},
+		{description: "metadata is array", params: postgres.CreatePaymentParams{Metadata: json.RawMessage(`["order"]`)}, err: "metadata must be json object with string values"},
+		{description: "too many keys", params: postgres.CreatePaymentParams{Metadata: manyKeys}, err: "metadata has more than 20 keys"},
+		{description: "empty key", params: postgres.CreatePaymentParams{Metadata: json.RawMessage(`{"":"42"}`)}, err: "metadata key can't be empty"},
+		{description: "long value", params: postgres.CreatePaymentParams{Metadata: json.RawMessage(fmt.Sprintf(`{"order":%q}`, strings.Repeat("v", maxMetadataValueLen+1)))}, err: `metadata value of "order" key is longer than 500 characters`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := validatePaymentDetails(&tc.params)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.metadata, string(tc.params.Metadata))
+		})
+	}
+}
+
+func TestQueryMetadata(t *testing.T) {
+	cases := []struct {
+		description string
+		query       string
+		metadata    map[string]string
+		err         string
+	}{
+		{description: "no filter", query: "status=new"},
+		{description: "pairs", query: "metadata[order]=42&metadata[channel]=web&status=new", metadata: map[string]string{"order": "42", "channel": "web"}},
+		{description: "repeated key", query: "metadata[order]=42&metadata[order]=43", err: `metadata key "order" is repeated`},
+		{description: "empty key", query: "metadata[]=42", err: "metadata key can't be empty"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			query, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+			m, err := queryMetadata(query)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.metadata, m)
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/shopspring/decimal"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+	"github.com/semka95/payment-service/payment/search"
+)
+
+// Page size limits
+const (
+	defaultPageLimit = 10
+	maxPageLimit     = 100
+)
+
+// Errors of page request
+var (
+	errMalformedCursor = errors.New("malformed cursor")
+	errCursorSort      = errors.New("cursor doesn't match sort")
+)
+
+// cursor points to page boundary in sort order, page after cursor has following payments,
+// page before cursor has preceding payments, it is sent to client base64 encoded
+type cursor struct {
+	ID     int64  `json:"id"`
+	Value  string `json:"value,omitempty"`
+	Sort   string `json:"sort,omitempty"`
+	Desc   bool   `json:"desc,omitempty"`
+	Before bool   `json:"before,omitempty"`
+	Incl   bool   `json:"incl,omitempty"`
+}
+
+func (c cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	if s == "" {
+		return c, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, errMalformedCursor
+	}
+	if err = json.Unmarshal(b, &c); err != nil || c.ID < 0 {
+		return cursor{}, errMalformedCursor
+	}
+
+	return c, nil
+}
+
+// pageRequest reads limit, sort and cursor from query, limit above maximum is capped,
+// sort is field name with optional "-" prefix for descending order, payments are sorted by id by default
+func pageRequest(r *http.Request) (search.Query, error) {
+	query := r.URL.Query()
+	q := search.Query{Sort: search.SortID}
+	limit, err := pageLimit(query)
+	if err != nil {
+		return q, err
+	}
+	q.Limit = limit
+	if s := query.Get("sort"); s != "" {
+		q.Desc = strings.HasPrefix(s, "-")
+		q.Sort = strings.TrimPrefix(s, "-")
+		if !search.ValidSort(q.Sort) {
+			return q, fmt.Errorf("can't sort by %q", q.Sort)
+		}
+	}
+
+	c, err := decodeCursor(query.Get("cursor"))
+	if err != nil {
+		return q, err
+	}
+	if query.Get("cursor") != "" {
+		sort := c.Sort
+		if sort == "" {
+			sort = search.SortID
+		}
+		if sort != q.Sort || c.Desc != q.Desc {
+			return q, errCursorSort
+		}
+		q.Key = &search.Key{ID: c.ID, Value: c.Value}
+		q.Before = c.Before
+		q.Inclusive = c.Incl
+	}
+
+	return q, nil
+}
+
+// pageLimit reads page size from limit parameter, limit above maximum is capped
+func pageLimit(query url.Values) (int, error) {
+	l := query.Get("limit")
+	if l == "" {
+		return defaultPageLimit, nil
+	}
+	n, err := strconv.Atoi(l)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("limit must be positive integer, got %q", l)
+	}
+	if n > maxPageLimit {
+		n = maxPageLimit
+	}
+
+	return n, nil
+}
+
+// filterRequest reads payment filters from query, status may be repeated or comma separated,
+// time ranges are in RFC 3339 format
+func filterRequest(r *http.Request) (search.Filter, error) {
+	query := r.URL.Query()
+	f := search.Filter{}
+	for _, v := range query["status"] {
+		for _, s := range strings.Split(v, ",") {
+			status := paymentModel.ValidStatus(s)
+			switch status {
+			case paymentModel.ValidStatusNew, paymentModel.ValidStatusSuccess, paymentModel.ValidStatusFailure,
+				paymentModel.ValidStatusError, paymentModel.ValidStatusExpired, paymentModel.ValidStatusReview:
+				f.Statuses = append(f.Statuses, status)
+			default:
+				return f, fmt.Errorf("unknown status %q", s)
+			}
+		}
+	}
+	if c := query.Get("currency"); c != "" {
+		f.Currency = paymentModel.ValidCurrency(c)
+		switch f.Currency {
+		case paymentModel.ValidCurrencyUsd, paymentModel.ValidCurrencyEur, paymentModel.ValidCurrencyRub:
+		default:
+			return f, fmt.Errorf("unknown currency %q", c)
+		}
+	}
+
+	var err error
+	if f.MinAmount, err = queryDecimal(query, "min_amount"); err != nil {
+		return f, err
+	}
+	if f.MaxAmount, err = queryDecimal(query, "max_amount"); err != nil {
+		return f, err
+	}
+	if f.CreatedFrom, err = queryTime(query, "created_from"); err != nil {
+		return f, err
+	}
+	if f.CreatedTo, err = queryTime(query, "created_to"); err != nil {
+		return f, err
+	}
+	if f.UpdatedFrom, err = queryTime(query, "updated_from"); err != nil {
+		return f, err
+	}
+	if f.UpdatedTo, err = queryTime(query, "updated_to"); err != nil {
+		return f, err
+	}
+	if f.Metadata, err = queryMetadata(query); err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+func queryDecimal(query url.Values, name string) (*decimal.Decimal, error) {
+	v := query.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	d, err := decimal.NewFromString(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be decimal, got %q", name, v)
+	}
+
+	return &d, nil
+}
+
+func queryTime(query url.Values, name string) (*time.Time, error) {
+	v := query.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be RFC 3339 time, got %q", name, v)
+	}
+
+	return &t, nil
+}
+
+// paymentPage is page of payments in sort order, cursor is nil if there is no adjacent page
+type paymentPage struct {
+	Items      []paymentModel.Payment `json:"items"`
+	NextCursor *string                `json:"next_cursor"`
+	PrevCursor *string                `json:"prev_cursor"`
+}
+
+// newPaymentPage makes page of payments requested with limit + 1 rows, extra row means
+// there are more payments in requested direction
+func newPaymentPage(payments []paymentModel.Payment, q search.Query) paymentPage {
+	more := len(payments) > q.Limit
+	if more {
+		// extra row of page before cursor is the first one
+		if q.Before {
+			payments = payments[1:]
+		} else {
+			payments = payments[:q.Limit]
+		}
+	}
+
+	page := paymentPage{Items: payments}
+	if page.Items == nil {
+		page.Items = []paymentModel.Payment{}
+	}
+	at := func(p paymentModel.Payment, before bool) *cursor {
+		k := search.KeyOf(p, q.Sort)
+		return &cursor{ID: k.ID, Value: k.Value, Before: before}
+	}
+	var next, prev *cursor
+	switch {
+	case len(payments) == 0 && q.Key != nil:
+		// empty page turns back to cursor including payment at it
+		c := &cursor{ID: q.Key.ID, Value: q.Key.Value, Before: !q.Before, Incl: true}
+		if q.Before {
+			next = c
+		} else {
+			prev = c
+		}
+	case len(payments) == 0:
+	case q.Before:
+		next = at(payments[len(payments)-1], false)
+		if more {
+			prev = at(payments[0], true)
+		}
+	default:
+		if q.Key != nil {
+			prev = at(payments[0], true)
+		}
+		if more {
+			next = at(payments[len(payments)-1], false)
+		}
+	}
+	for _, c := range []struct {
+		cursor *cursor
+		dst    **string
+	}{{next, &page.NextCursor}, {prev, &page.PrevCursor}} {
+		if c.cursor == nil {
+			continue
+		}
+		if q.Sort != search.SortID {
+			c.cursor.Sort = q.Sort
+		}
+		c.cursor.Desc = q.Desc
+		s := c.cursor.encode()
+		*c.dst = &s
+	}
+
+	return page
+}
+
+// sendPaymentPage sends page with Link header pointing to adjacent pages
+func sendPaymentPage(w http.ResponseWriter, r *http.Request, page paymentPage) {
+	setPageLinks(w, r, page.NextCursor, page.PrevCursor)
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, page)
+}
+
+// setPageLinks sets Link header with urls of request with next and previous page cursors
+func setPageLinks(w http.ResponseWriter, r *http.Request, next, prev *string) {
+	links := make([]string, 0, 2)
+	for _, l := range []struct {
+		rel    string
+		cursor *string
+	}{{"next", next}, {"prev", prev}} {
+		if l.cursor == nil {
+			continue
+		}
+		u := *r.URL
+		q := u.Query()
+		q.Set("cursor", *l.cursor)
+		u.RawQuery = q.Encode()
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), l.rel))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
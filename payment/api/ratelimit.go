@@ -0,0 +1,144 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/semka95/payment-service/payment/ratelimit"
+)
+
+// RateLimits sets request limits of route groups, nil Store disables limits, IP limits all requests
+// of client ip before they are authenticated
+type RateLimits struct {
+	Store  ratelimit.Store
+	IP     ratelimit.Limit
+	Create ratelimit.Limit
+	Read   ratelimit.Limit
+	List   ratelimit.Limit
+	Update ratelimit.Limit
+}
+
+// rateLimit limits requests of route group by token bucket of authenticated client and by token bucket
+// of user from path, so client can't get around its limit by changing user id, remaining limit of the
+// most used bucket is sent in RateLimit-* headers
+func (a *API) rateLimit(group string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+	return a.limitBy(limit, func(r *http.Request) []string {
+		keys := []string{group + ":" + rateKey(r)}
+		if userKey := userRateKey(r); userKey != "" {
+			keys = append(keys, group+":"+userKey)
+		}
+		return keys
+	})
+}
+
+// ipRateLimit limits requests by token bucket of client ip, it runs before authentication,
+// so requests with wrong credentials are limited too
+func (a *API) ipRateLimit(limit ratelimit.Limit) func(http.Handler) http.Handler {
+	return a.limitBy(limit, func(r *http.Request) []string {
+		return []string{"ip:" + clientIP(r)}
+	})
+}
+
+// limitBy limits requests by token buckets of keys, request is rejected by the first bucket without tokens,
+// tokens taken from previous buckets are given back, so rejected request isn't charged
+func (a *API) limitBy(limit ratelimit.Limit, keys func(r *http.Request) []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if a.limits.Store == nil || !limit.Enabled() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var res ratelimit.Result
+			var takenKeys []string
+			taken := false
+			for _, key := range keys(r) {
+				keyRes, err := a.limits.Store.Take(r.Context(), key, limit)
+				if err != nil {
+					// broken store shouldn't stop payments
+					zap.L().Error("can't check rate limit", zap.Error(err), zap.String("key", key))
+					continue
+				}
+				if !taken || !keyRes.Allowed || keyRes.Remaining < res.Remaining {
+					res = keyRes
+					taken = true
+				}
+				if !keyRes.Allowed {
+					a.refundRate(r, takenKeys, limit)
+					break
+				}
+				takenKeys = append(takenKeys, key)
+			}
+			if !taken {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				SendErrorJSON(w, r, http.StatusTooManyRequests, errors.New("rate limit exceeded"), "too many requests")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// refundRate gives back tokens taken from buckets of keys
+func (a *API) refundRate(r *http.Request, keys []string, limit ratelimit.Limit) {
+	for _, key := range keys {
+		if err := a.limits.Store.Refund(r.Context(), key, limit); err != nil {
+			zap.L().Error("can't refund rate limit", zap.Error(err), zap.String("key", key))
+		}
+	}
+}
+
+// rateKey returns merchant and principal of request, so one client doesn't use up limit of others,
+// request without principal is counted by client ip
+func rateKey(r *http.Request) string {
+	p, ok := principalFrom(r.Context())
+	if !ok {
+		return "ip:" + clientIP(r)
+	}
+
+	return "merchant:" + strconv.FormatInt(p.MerchantID, 10) + ":" + p.actor()
+}
+
+// userRateKey returns merchant and user id from path, it's shared by all clients requesting the user,
+// empty key is returned for routes without user id
+func userRateKey(r *http.Request) string {
+	userID := chi.URLParam(r, "user_id")
+	if userID == "" {
+		return ""
+	}
+	var merchantID int64
+	if p, ok := principalFrom(r.Context()); ok {
+		merchantID = p.MerchantID
+	}
+
+	return "merchant:" + strconv.FormatInt(merchantID, 10) + ":user_id:" + userID
+}
+
+// clientIP returns ip of client that sent request
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
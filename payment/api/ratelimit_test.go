@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/semka95/payment-service/payment/ratelimit"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store is down")
+}
+
+func (failingStore) Refund(ctx context.Context, key string, limit ratelimit.Limit) error {
+	return errors.New("store is down")
+}
+
+func TestRateLimit(t *testing.T) {
+	api := API{limits: RateLimits{Store: ratelimit.NewMemoryStore()}}
+	r := chi.NewRouter()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	r.With(api.rateLimit("list", ratelimit.PerMinute(1, 1))).Get("/user/{user_id}/payment", ok)
+	r.With(api.rateLimit("create", ratelimit.PerMinute(60, 2))).Post("/payment", ok)
+
+	send := func(method, url string, p *principal) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, http.NoBody)
+		if p != nil {
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, *p))
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	shop := &principal{Method: methodAPIKey, Subject: "1"}
+	other := &principal{Method: methodAPIKey, Subject: "2"}
+	for i := 0; i < 2; i++ {
+		rec := send("POST", "/payment", shop)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	}
+	rec := send("POST", "/payment", shop)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, send("POST", "/payment", other).Code)
+	assert.Equal(t, http.StatusOK, send("POST", "/payment", nil).Code)
+
+	// list is limited per client and per user from path
+	assert.Equal(t, http.StatusOK, send("GET", "/user/2/payment", shop).Code)
+	rec = send("GET", "/user/2/payment", shop)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusTooManyRequests, send("GET", "/user/3/payment", shop).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("GET", "/user/2/payment", other).Code)
+	// request rejected by user bucket doesn't use up limit of client
+	assert.Equal(t, http.StatusOK, send("GET", "/user/4/payment", other).Code)
+	assert.Equal(t, http.StatusOK, send("GET", "/user/3/payment", &principal{Method: methodAPIKey, Subject: "3"}).Code)
+
+	// users of different merchants have separate buckets
+	user := &principal{Method: methodBearer, Subject: "7", MerchantID: 1}
+	assert.Equal(t, http.StatusOK, send("GET", "/user/7/payment", user).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("GET", "/user/7/payment", user).Code)
+	assert.Equal(t, http.StatusOK, send("GET", "/user/7/payment", &principal{Method: methodBearer, Subject: "7", MerchantID: 2}).Code)
+
+	api.limits.Store = failingStore{}
+	assert.Equal(t, http.StatusOK, send("POST", "/payment", shop).Code)
+}
+
+func TestRateLimitVaryingUser(t *testing.T) {
+	api := API{limits: RateLimits{Store: ratelimit.NewMemoryStore()}}
+	r := chi.NewRouter()
+	r.With(api.rateLimit("list", ratelimit.PerMinute(60, 3))).Get("/user/{user_id}/payment", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	shop := principal{Method: methodAPIKey, Subject: "1"}
+	codes := make([]int, 0, 5)
+	for i := 1; i <= 5; i++ {
+		req := httptest.NewRequest("GET", "/user/"+strconv.Itoa(i)+"/payment", http.NoBody)
+		req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, shop))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+	}
+
+	// every user has tokens left, but limit of api key is used up
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}, codes)
+}
+
+func TestIPRateLimit(t *testing.T) {
+	api := API{limits: RateLimits{Store: ratelimit.NewMemoryStore()}}
+	r := chi.NewRouter()
+	r.Use(api.ipRateLimit(ratelimit.PerMinute(60, 2)))
+	r.Get("/payment/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	send := func(addr string) int {
+		req := httptest.NewRequest("GET", "/payment/1", http.NoBody)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// requests with wrong credentials use up limit of client ip
+	assert.Equal(t, http.StatusUnauthorized, send("10.0.0.1:1000"))
+	assert.Equal(t, http.StatusUnauthorized, send("10.0.0.1:1001"))
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.1:1002"))
+	assert.Equal(t, http.StatusUnauthorized, send("10.0.0.2:1000"))
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/semka95/payment-service/payment/reconcile"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// maxSettlementSize is size limit of uploaded settlement file
+const maxSettlementSize = 10 << 20
+
+// POST /admin/reconciliations?from=2022-06-01T00:00:00Z&to=2022-06-02T00:00:00Z&apply=true - reconciles uploaded settlement file against payments
+func (a *API) reconcilePayments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	p, _ := principalFrom(r.Context())
+	opts := reconcile.Options{MerchantID: p.MerchantID, Source: query.Get("source"), Actor: actorOf(r)}
+
+	var err error
+	if opts.From, err = time.Parse(time.RFC3339, query.Get("from")); err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid period")
+		return
+	}
+	if opts.To, err = time.Parse(time.RFC3339, query.Get("to")); err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid period")
+		return
+	}
+	if v := query.Get("apply"); v != "" {
+		if opts.Apply, err = strconv.ParseBool(v); err != nil {
+			SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid apply flag")
+			return
+		}
+	}
+
+	// settlement is sent as form file or as request body
+	r.Body = http.MaxBytesReader(w, r.Body, maxSettlementSize)
+	var file io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		f, header, fileErr := r.FormFile("file")
+		if fileErr != nil {
+			SendErrorJSON(w, r, http.StatusBadRequest, fileErr, "invalid settlement file")
+			return
+		}
+		defer f.Close()
+		file = f
+		if opts.Source == "" {
+			opts.Source = header.Filename
+		}
+	}
+	if opts.Source == "" {
+		opts.Source = "upload"
+	}
+
+	lines, err := reconcile.ParseSettlement(file)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid settlement file")
+		return
+	}
+
+	result, err := a.reconciler.Run(r.Context(), opts, lines)
+	if errors.Is(err, reconcile.ErrInvalidPeriod) {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid period")
+		return
+	}
+	if err != nil {
+		SendProcessorErrorJSON(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, result)
+}
+
+// GET /admin/reconciliations?limit=5&cursor=10 - returns reconciliation runs, newest first
+func (a *API) listReconciliations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := paymentModel.ListReconciliationRunsParams{RowLimit: 10}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			SendErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d, got %q", maxPageLimit, v), "invalid limit")
+			return
+		}
+		params.RowLimit = int32(limit)
+	}
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || cursor < 1 {
+			SendErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("cursor must be positive integer, got %q", v), "invalid cursor")
+			return
+		}
+		params.BeforeID = cursor
+	}
+
+	p, _ := principalFrom(r.Context())
+	params.MerchantID = p.MerchantID
+	runs, err := a.paymentStore.ListReconciliationRuns(r.Context(), params)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't find reconciliations")
+		return
+	}
+	if runs == nil {
+		runs = []paymentModel.ReconciliationRun{}
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, runs)
+}
+
+// GET /admin/reconciliations/{id} - returns reconciliation run with its discrepancies
+func (a *API) getReconciliation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid reconciliation id")
+		return
+	}
+
+	p, _ := principalFrom(r.Context())
+	run, err := a.paymentStore.GetReconciliationRun(r.Context(), paymentModel.GetReconciliationRunParams{ID: id, MerchantID: p.MerchantID})
+	if errors.Is(err, sql.ErrNoRows) {
+		SendErrorJSON(w, r, http.StatusNotFound, err, "reconciliation not found")
+		return
+	}
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't find reconciliation")
+		return
+	}
+	discrepancies, err := a.paymentStore.ListReconciliationDiscrepancies(r.Context(), paymentModel.ListReconciliationDiscrepanciesParams{RunID: id, MerchantID: p.MerchantID})
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't find reconciliation")
+		return
+	}
+	if discrepancies == nil {
+		discrepancies = []paymentModel.ReconciliationDiscrepancy{}
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, reconcile.Result{Run: run, Discrepancies: discrepancies})
+}
@@ -0,0 +1,350 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/semka95/payment-service/payment/processor"
+	"github.com/semka95/payment-service/payment/reconcile"
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+func TestReconcilePayments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	api := API{}
+	settlement := "id,amount,currency,status\n1,10,usd,success\n"
+	newStore := func() *postgres.QuerierMock {
+		return &postgres.QuerierMock{
+			ListPaymentsByIDsFunc: func(ctx context.Context, arg postgres.ListPaymentsByIDsParams) ([]postgres.Payment, error) {
+				return []postgres.Payment{{ID: 1, Amount: decimal.RequireFromString("10.00"), Currency: postgres.ValidCurrencyUsd, PaymentStatus: postgres.ValidStatusNew}}, nil
+			},
+			ListUnsettledPaymentsFunc: func(ctx context.Context, arg postgres.ListUnsettledPaymentsParams) ([]postgres.Payment, error) {
+				return nil, nil
+			},
+			CreateReconciliationRunFunc: func(ctx context.Context, arg postgres.CreateReconciliationRunParams) (postgres.ReconciliationRun, error) {
+				return postgres.ReconciliationRun{ID: 1, Source: arg.Source, Actor: arg.Actor, LineCount: arg.LineCount, DiscrepancyCount: arg.DiscrepancyCount}, nil
+			},
+			CreateReconciliationDiscrepanciesFunc: func(ctx context.Context, arg postgres.CreateReconciliationDiscrepanciesParams) ([]postgres.ReconciliationDiscrepancy, error) {
+				return []postgres.ReconciliationDiscrepancy{{ID: 1, RunID: arg.RunID, PaymentID: 1, Kind: postgres.DiscrepancyKindStatus, ProviderValue: "success", PaymentValue: "new"}}, nil
+			},
+			CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+				return nil
+			},
+		}
+	}
+	multipartBody := func() (string, string) {
+		body := new(bytes.Buffer)
+		mw := multipart.NewWriter(body)
+		fw, err := mw.CreateFormFile("file", "settlement-2022-06-01.csv")
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(settlement))
+		require.NoError(t, err)
+		require.NoError(t, mw.Close())
+		return body.String(), mw.FormDataContentType()
+	}
+
+	cases := []struct {
+		description    string
+		mockedStore    *postgres.QuerierMock
+		query          string
+		body           func() (string, string)
+		expectSQL      func(mock sqlmock.Sqlmock)
+		checkMockCalls func(tr *postgres.QuerierMock)
+		checkResponse  func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "csv body",
+			mockedStore: newStore(),
+			query:       "?from=2022-06-01T00:00:00Z&to=2022-06-02T00:00:00Z&source=june.csv",
+			body:        func() (string, string) { return settlement, "text/csv" },
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.CreateReconciliationRunCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, "june.csv", calls[0].Arg.Source)
+				assert.Equal(t, "user:operator", calls[0].Arg.Actor)
+				assert.False(t, calls[0].Arg.AutoApply)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := reconcile.Result{}
+				err = json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, int32(1), result.Run.DiscrepancyCount)
+				require.Equal(t, 1, len(result.Discrepancies))
+				assert.Equal(t, postgres.DiscrepancyKindStatus, result.Discrepancies[0].Kind)
+				assert.Equal(t, http.StatusCreated, rec.Code)
+			},
+		},
+		{
+			description: "form file",
+			mockedStore: newStore(),
+			query:       "?from=2022-06-01T00:00:00Z&to=2022-06-02T00:00:00Z",
+			body:        multipartBody,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.CreateReconciliationRunCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, "settlement-2022-06-01.csv", calls[0].Arg.Source)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusCreated, rec.Code)
+			},
+		},
+		{
+			description:    "invalid settlement",
+			mockedStore:    &postgres.QuerierMock{},
+			query:          "?from=2022-06-01T00:00:00Z&to=2022-06-02T00:00:00Z",
+			body:           func() (string, string) { return "id,amount\n1,10\n", "text/csv" },
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid settlement file", jsonErr.Details)
+				assert.Equal(t, "invalid settlement file: currency column is missing", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "missing period",
+			mockedStore:    &postgres.QuerierMock{},
+			query:          "?from=2022-06-01T00:00:00Z",
+			body:           func() (string, string) { return settlement, "text/csv" },
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid period", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "empty period",
+			mockedStore:    &postgres.QuerierMock{},
+			query:          "?from=2022-06-01T00:00:00Z&to=2022-06-01T00:00:00Z",
+			body:           func() (string, string) { return settlement, "text/csv" },
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "repository server error",
+			mockedStore: &postgres.QuerierMock{
+				ListPaymentsByIDsFunc: func(ctx context.Context, arg postgres.ListPaymentsByIDsParams) ([]postgres.Payment, error) {
+					return nil, fmt.Errorf("server error")
+				},
+			},
+			query:          "?from=2022-06-01T00:00:00Z&to=2022-06-02T00:00:00Z",
+			body:           func() (string, string) { return settlement, "text/csv" },
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't find payments", jsonErr.Details)
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api.paymentStore = tc.mockedStore
+			api.reconciler = reconcile.New(tc.mockedStore, db, processor.New(tc.mockedStore, db, nil, nil))
+
+			body, contentType := tc.body()
+			req := httptest.NewRequest("POST", "/admin/reconciliations"+tc.query, strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodUser, Subject: "operator", MerchantID: 1, Role: postgres.UserRoleOperator}))
+
+			tc.expectSQL(mock)
+
+			rec := httptest.NewRecorder()
+			api.reconcilePayments(rec, req)
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+			tc.checkMockCalls(tc.mockedStore)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
+
+func TestGetReconciliation(t *testing.T) {
+	api := API{}
+
+	cases := []struct {
+		description   string
+		mockedStore   *postgres.QuerierMock
+		id            string
+		checkResponse func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "success",
+			mockedStore: &postgres.QuerierMock{
+				GetReconciliationRunFunc: func(ctx context.Context, arg postgres.GetReconciliationRunParams) (postgres.ReconciliationRun, error) {
+					return postgres.ReconciliationRun{ID: arg.ID, DiscrepancyCount: 1}, nil
+				},
+				ListReconciliationDiscrepanciesFunc: func(ctx context.Context, arg postgres.ListReconciliationDiscrepanciesParams) ([]postgres.ReconciliationDiscrepancy, error) {
+					return []postgres.ReconciliationDiscrepancy{{ID: 1, RunID: arg.RunID, PaymentID: 4, Kind: postgres.DiscrepancyKindMissingSettlement, PaymentValue: "1.5 rub new"}}, nil
+				},
+			},
+			id: "3",
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := reconcile.Result{}
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, int64(3), result.Run.ID)
+				require.Equal(t, 1, len(result.Discrepancies))
+				assert.Equal(t, int64(4), result.Discrepancies[0].PaymentID)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "not found",
+			mockedStore: &postgres.QuerierMock{
+				GetReconciliationRunFunc: func(ctx context.Context, arg postgres.GetReconciliationRunParams) (postgres.ReconciliationRun, error) {
+					return postgres.ReconciliationRun{}, sql.ErrNoRows
+				},
+			},
+			id: "3",
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "reconciliation not found", jsonErr.Details)
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+		{
+			description: "bad id",
+			mockedStore: &postgres.QuerierMock{},
+			id:          "bad",
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api.paymentStore = tc.mockedStore
+
+			c := chi.NewRouteContext()
+			c.URLParams.Add("id", tc.id)
+			req := httptest.NewRequest("GET", "/admin/reconciliations/"+tc.id, http.NoBody)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, c))
+
+			rec := httptest.NewRecorder()
+			api.getReconciliation(rec, req)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
+
+func TestListReconciliations(t *testing.T) {
+	api := API{}
+
+	cases := []struct {
+		description    string
+		mockedStore    *postgres.QuerierMock
+		query          string
+		checkMockCalls func(tr *postgres.QuerierMock)
+		checkResponse  func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "success",
+			mockedStore: &postgres.QuerierMock{
+				ListReconciliationRunsFunc: func(ctx context.Context, arg postgres.ListReconciliationRunsParams) ([]postgres.ReconciliationRun, error) {
+					return []postgres.ReconciliationRun{{ID: 4}, {ID: 3}}, nil
+				},
+			},
+			query: "?limit=2&cursor=5",
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.ListReconciliationRunsCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, postgres.ListReconciliationRunsParams{BeforeID: 5, RowLimit: 2}, calls[0].Arg)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := make([]postgres.ReconciliationRun, 0)
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, 2, len(result))
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "no runs",
+			mockedStore: &postgres.QuerierMock{
+				ListReconciliationRunsFunc: func(ctx context.Context, arg postgres.ListReconciliationRunsParams) ([]postgres.ReconciliationRun, error) {
+					return nil, nil
+				},
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, postgres.ListReconciliationRunsParams{RowLimit: 10}, tr.ListReconciliationRunsCalls()[0].Arg)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.JSONEq(t, "[]", rec.Body.String())
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description:    "bad limit",
+			mockedStore:    &postgres.QuerierMock{},
+			query:          "?limit=1000",
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid limit", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api.paymentStore = tc.mockedStore
+
+			req := httptest.NewRequest("GET", "/admin/reconciliations"+tc.query, http.NoBody)
+
+			rec := httptest.NewRecorder()
+			api.listReconciliations(rec, req)
+
+			tc.checkMockCalls(tc.mockedStore)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/semka95/payment-service/payment/report"
+)
+
+// reportResponse is payments report, rows are ordered by bucket, currency and status,
+// source is payments or summary table report is aggregated from
+type reportResponse struct {
+	From   time.Time    `json:"from"`
+	To     time.Time    `json:"to"`
+	Bucket string       `json:"bucket"`
+	UserID int64        `json:"user_id,omitempty"`
+	Source string       `json:"source"`
+	Rows   []report.Row `json:"rows"`
+}
+
+// GET /admin/reports/payments?from=2022-06-01T00:00:00Z&to=2022-07-01T00:00:00Z&bucket=day&user_id=2 - returns number and sum of payments grouped by bucket, currency and status
+func (a *API) reportPayments(w http.ResponseWriter, r *http.Request) {
+	q, err := reportRequest(r)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid report query")
+		return
+	}
+	p, _ := principalFrom(r.Context())
+	q.MerchantID = p.MerchantID
+
+	rows, source, err := a.reporter.Report(r.Context(), q)
+	if errors.Is(err, report.ErrInvalidQuery) {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid report query")
+		return
+	}
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't build report")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, reportResponse{
+		From:   q.From.UTC(),
+		To:     q.To.UTC(),
+		Bucket: q.Bucket,
+		UserID: q.UserID,
+		Source: source,
+		Rows:   rows,
+	})
+}
+
+func reportRequest(r *http.Request) (report.Query, error) {
+	query := r.URL.Query()
+	q := report.Query{Bucket: query.Get("bucket")}
+	if q.Bucket == "" {
+		q.Bucket = report.BucketDay
+	}
+
+	var err error
+	if q.From, err = time.Parse(time.RFC3339, query.Get("from")); err != nil {
+		return q, fmt.Errorf("from must be RFC 3339 time: %w", err)
+	}
+	if q.To, err = time.Parse(time.RFC3339, query.Get("to")); err != nil {
+		return q, fmt.Errorf("to must be RFC 3339 time: %w", err)
+	}
+	if v := query.Get("user_id"); v != "" {
+		q.UserID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || q.UserID < 1 {
+			return q, fmt.Errorf("user_id must be positive integer, got %q", v)
+		}
+	}
+
+	return q, nil
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/semka95/payment-service/payment/report"
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+func TestReportPayments(t *testing.T) {
+	api := API{}
+	day := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		description    string
+		mockedStore    *postgres.QuerierMock
+		query          string
+		checkMockCalls func(tr *postgres.QuerierMock)
+		checkResponse  func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "success",
+			mockedStore: &postgres.QuerierMock{
+				ReportPaymentsFunc: func(ctx context.Context, arg postgres.ReportPaymentsParams) ([]postgres.ReportPaymentsRow, error) {
+					return []postgres.ReportPaymentsRow{
+						{Bucket: day, Currency: postgres.ValidCurrencyUsd, PaymentStatus: postgres.ValidStatusSuccess, PaymentCount: 3, Amount: decimal.RequireFromString("0.30")},
+						{Bucket: day, Currency: postgres.ValidCurrencyUsd, PaymentStatus: postgres.ValidStatusFailure, PaymentCount: 1, Amount: decimal.RequireFromString("12345678901234567890.01")},
+					}, nil
+				},
+			},
+			query: "?from=2022-06-01T03:00:00%2B03:00&to=2022-06-02T00:00:00Z&bucket=hour&user_id=2",
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.ReportPaymentsCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, postgres.ReportPaymentsParams{Bucket: "hour", CreatedFrom: day, CreatedTo: day.AddDate(0, 0, 1), UserID: 2}, calls[0].Arg)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.JSONEq(t, `{
+					"from": "2022-06-01T00:00:00Z",
+					"to": "2022-06-02T00:00:00Z",
+					"bucket": "hour",
+					"user_id": 2,
+					"source": "payments",
+					"rows": [
+						{"bucket": "2022-06-01T00:00:00Z", "currency": "usd", "status": "success", "count": 3, "amount": "0.3"},
+						{"bucket": "2022-06-01T00:00:00Z", "currency": "usd", "status": "failure", "count": 1, "amount": "12345678901234567890.01"}
+					]
+				}`, rec.Body.String())
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "default bucket, no payments",
+			mockedStore: &postgres.QuerierMock{
+				ReportPaymentsFunc: func(ctx context.Context, arg postgres.ReportPaymentsParams) ([]postgres.ReportPaymentsRow, error) {
+					return nil, nil
+				},
+			},
+			query: "?from=2022-06-01T00:00:00Z&to=2022-07-01T00:00:00Z",
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.ReportPaymentsCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, "day", calls[0].Arg.Bucket)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := map[string]interface{}{}
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, []interface{}{}, result["rows"])
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description:    "bad time",
+			mockedStore:    &postgres.QuerierMock{},
+			query:          "?from=2022-06-01&to=2022-07-01T00:00:00Z",
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid report query", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "bad user id",
+			mockedStore:    &postgres.QuerierMock{},
+			query:          "?from=2022-06-01T00:00:00Z&to=2022-07-01T00:00:00Z&user_id=0",
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "unknown bucket",
+			mockedStore:    &postgres.QuerierMock{},
+			query:          "?from=2022-06-01T00:00:00Z&to=2022-07-01T00:00:00Z&bucket=week",
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid report query", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "to before from",
+			mockedStore:    &postgres.QuerierMock{},
+			query:          "?from=2022-07-01T00:00:00Z&to=2022-06-01T00:00:00Z",
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "repository server error",
+			mockedStore: &postgres.QuerierMock{
+				ReportPaymentsFunc: func(ctx context.Context, arg postgres.ReportPaymentsParams) ([]postgres.ReportPaymentsRow, error) {
+					return nil, fmt.Errorf("server error")
+				},
+			},
+			query:          "?from=2022-06-01T00:00:00Z&to=2022-07-01T00:00:00Z",
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't build report", jsonErr.Details)
+				assert.Equal(t, "server error", jsonErr.Error)
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api.reporter = report.NewReporter(tc.mockedStore, false)
+
+			req := httptest.NewRequest("GET", "/admin/reports/payments"+tc.query, http.NoBody)
+
+			rec := httptest.NewRecorder()
+			api.reportPayments(rec, req)
+
+			tc.checkMockCalls(tc.mockedStore)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
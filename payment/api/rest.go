@@ -3,17 +3,33 @@ package api
 import (
 	"database/sql"
 	"errors"
+	"expvar"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/go-chi/render"
-
+	"github.com/lib/pq"
+
+	"github.com/semka95/payment-service/payment/apikey"
+	"github.com/semka95/payment-service/payment/audit"
+	"github.com/semka95/payment-service/payment/callback"
+	"github.com/semka95/payment-service/payment/event"
+	"github.com/semka95/payment-service/payment/expiry"
+	"github.com/semka95/payment-service/payment/processor"
+	"github.com/semka95/payment-service/payment/reconcile"
+	"github.com/semka95/payment-service/payment/report"
 	paymentModel "github.com/semka95/payment-service/payment/repository"
+	"github.com/semka95/payment-service/payment/risk"
+	"github.com/semka95/payment-service/payment/search"
+	"github.com/semka95/payment-service/payment/token"
+	"github.com/semka95/payment-service/payment/user"
 )
 
 // API represents payment rest api
@@ -21,34 +37,96 @@ type API struct {
 	paymentStore paymentModel.Querier
 	db           *sql.DB
 	errorChance  float64
-	creds        map[string]string
+	keys         *apikey.Manager
+	users        *user.Manager
+	tokens       *token.Verifier
+	callbacks    *callback.Verifier
+	clientCerts  ClientCertPolicy
+	limits       RateLimits
+	streams      Streams
+	processor    *processor.Processor
+	expiry       expiry.Policy
+	risk         *risk.Engine
+	reporter     *report.Reporter
+	reconciler   *reconcile.Reconciler
 }
 
 // NewRouter creates payment api router
-func (a *API) NewRouter(paymentStore paymentModel.Querier, db *sql.DB, errorChance float64, creds map[string]string) chi.Router {
+func (a *API) NewRouter(paymentStore paymentModel.Querier, db *sql.DB, proc *processor.Processor, policy expiry.Policy, riskEngine *risk.Engine, reporter *report.Reporter, tokens *token.Verifier, callbacks *callback.Verifier, clientCerts ClientCertPolicy, limits RateLimits, streams Streams, errorChance float64) chi.Router {
 	a.paymentStore = paymentStore
 	a.db = db
+	a.processor = proc
+	a.expiry = policy
+	a.risk = riskEngine
+	a.reporter = reporter
 	a.errorChance = errorChance
-	a.creds = creds
+	a.keys = apikey.NewManager(paymentStore)
+	a.users = user.NewManager(paymentStore)
+	a.reconciler = reconcile.New(paymentStore, db, proc)
+	a.tokens = tokens
+	a.callbacks = callbacks
+	a.clientCerts = clientCerts
+	a.limits = limits
+	a.streams = streams
 
 	r := chi.NewRouter()
 	corsMiddleware := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
-		AllowedHeaders: []string{"Accept", "Content-Type"},
+		AllowedHeaders: []string{"Accept", "Content-Type", "Authorization", "Last-Event-ID", apiKeyHeader, callback.HeaderTimestamp, callback.HeaderNonce, callback.HeaderSignature},
 	})
 	r.Use(middleware.Recoverer, corsMiddleware.Handler)
+	// metrics expose memory stats and command line of service, so only admin may read them
+	r.With(a.authenticate, requireRole(paymentModel.UserRoleAdmin)).Handle("/debug/vars", expvar.Handler())
 
 	r.Route("/api/v1", func(rapi chi.Router) {
-		rapi.Post("/payment", a.createPayment)
-		rapi.Route("/payment/{id}", func(ru chi.Router) {
-			ru.Use(middleware.BasicAuth("update", a.creds))
-			ru.Put("/", a.updateStatus)
+		rapi.Use(a.ipRateLimit(limits.IP))
+		rapi.Group(func(rk chi.Router) {
+			rk.Use(a.authenticate)
+			rk.With(requireScope(apikey.ScopePaymentsCreate), a.rateLimit("create", limits.Create)).Post("/payment", a.createPayment)
+			rk.With(requireScope(apikey.ScopePaymentsRead), a.rateLimit("read", limits.Read)).Get("/payment", a.getPaymentByReference)
+			rk.With(requireScope(apikey.ScopePaymentsCreate), a.rateLimit("create", limits.Create)).Post("/payments:batch", a.createPayments)
+			rk.With(requireScope(apikey.ScopePaymentsUpdateStatus), a.requireClientCert, a.rateLimit("update", limits.Update)).Put("/payments/status:batch", a.updateStatuses)
+			rk.Route("/payment/{id}", func(rp chi.Router) {
+				rp.With(requireScope(apikey.ScopePaymentsRead), a.rateLimit("read", limits.Read)).Get("/", a.getPayment)
+				rp.With(requireScope(apikey.ScopePaymentsRead), a.rateLimit("read", limits.Read)).Get("/status", a.getStatus)
+				rp.With(requireScope(apikey.ScopePaymentsCancel), a.rateLimit("update", limits.Update)).Delete("/", a.cancelPayment)
+				rp.With(requireScope(apikey.ScopePaymentsRead), a.rateLimit("read", limits.Read)).Get("/disputes", a.getPaymentDisputes)
+				rp.With(requireScope(apikey.ScopePaymentsRead), a.rateLimit("read", limits.Read)).Get("/events/stream", a.streamPaymentEvents)
+				rp.Group(func(ru chi.Router) {
+					ru.Use(requireScope(apikey.ScopePaymentsUpdateStatus), a.requireClientCert, a.rateLimit("update", limits.Update))
+					ru.Put("/", a.updateStatus)
+					ru.Post("/disputes", a.openDispute)
+					ru.Post("/disputes/{dispute_id}/evidence", a.addDisputeEvidence)
+					ru.Put("/disputes/{dispute_id}", a.resolveDispute)
+				})
+			})
+			rk.With(requireScope(apikey.ScopePaymentsRead), a.rateLimit("list", limits.List)).Get("/user/{user_id}/payment", a.getUserPaymentsByID)
+			rk.With(requireScope(apikey.ScopePaymentsRead), a.rateLimit("list", limits.List)).Get("/user/{user_id}/payment/events/stream", a.streamUserEvents)
+			rk.With(requireScope(apikey.ScopePaymentsRead), a.rateLimit("list", limits.List)).Get("/user/payment", a.getUserPaymentsByEmail)
+		})
+		rapi.Route("/admin", func(ra chi.Router) {
+			ra.Use(a.authenticate)
+			ra.With(requireRole(paymentModel.UserRoleOperator, paymentModel.UserRoleAdmin, paymentModel.UserRoleReadonly)).Get("/reviews", a.listReviews)
+			ra.With(requireRole(paymentModel.UserRoleOperator, paymentModel.UserRoleAdmin, paymentModel.UserRoleReadonly)).Get("/reports/payments", a.reportPayments)
+			ra.With(requireRole(paymentModel.UserRoleOperator, paymentModel.UserRoleAdmin, paymentModel.UserRoleReadonly)).Get("/reconciliations", a.listReconciliations)
+			ra.With(requireRole(paymentModel.UserRoleOperator, paymentModel.UserRoleAdmin, paymentModel.UserRoleReadonly)).Get("/reconciliations/{id}", a.getReconciliation)
+			ra.Group(func(rr chi.Router) {
+				rr.Use(requireRole(paymentModel.UserRoleOperator, paymentModel.UserRoleAdmin))
+				rr.Post("/reviews/{id}/approve", a.approveReview)
+				rr.Post("/reviews/{id}/decline", a.declineReview)
+				rr.Post("/reconciliations", a.reconcilePayments)
+			})
+			ra.Group(func(rk chi.Router) {
+				rk.Use(requireRole(paymentModel.UserRoleAdmin))
+				rk.Get("/payments", a.searchPayments)
+				rk.With(middleware.Compress(5, "text/csv", "application/x-ndjson")).Get("/payments/export", a.exportPayments)
+				rk.Get("/api-keys", a.listAPIKeys)
+				rk.Post("/api-keys", a.issueAPIKey)
+				rk.Post("/api-keys/{id}/rotate", a.rotateAPIKey)
+				rk.Delete("/api-keys/{id}", a.revokeAPIKey)
+			})
 		})
-		rapi.Get("/payment/{id}", a.getStatus)
-		rapi.Delete("/payment/{id}", a.cancelPayment)
-		rapi.Get("/user/{user_id}/payment", a.getUserPaymentsByID)
-		rapi.Get("/user/payment", a.getUserPaymentsByEmail)
 	})
 
 	return r
@@ -62,29 +140,94 @@ func (a *API) createPayment(w http.ResponseWriter, r *http.Request) {
 		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid request body, can't decode it to payment")
 		return
 	}
+	if err := validatePayment(&createPayment); err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid payment details")
+		return
+	}
+	if !canAccessUser(r.Context(), createPayment.UserID) {
+		SendErrorJSON(w, r, http.StatusForbidden, fmt.Errorf("can't create payments of %d user id", createPayment.UserID), "forbidden")
+		return
+	}
+	// payment always belongs to merchant of caller
+	p, _ := principalFrom(r.Context())
+	createPayment.MerchantID = p.MerchantID
+
+	screening := risk.Result{Decision: paymentModel.RiskDecisionApprove, Rules: []string{}}
+	if a.risk != nil {
+		var err error
+		screening, err = a.risk.Screen(r.Context(), createPayment, nil)
+		if err != nil {
+			SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't screen payment")
+			return
+		}
+	}
+	if screening.Decision == paymentModel.RiskDecisionReject {
+		SendErrorJSON(w, r, http.StatusUnprocessableEntity, fmt.Errorf("payment rejected by risk rules: %s", strings.Join(screening.Rules, ", ")), "payment rejected")
+		return
+	}
+	createPayment.RiskScore = screening.Score
+	createPayment.RiskDecision = screening.Decision
+	createPayment.RiskRules = screening.Rules
 
 	createPayment.PaymentStatus = paymentModel.ValidStatusNew
-	if 1-rand.Float64() <= a.errorChance {
+	createPayment.ExpiresAt = a.expiry.ExpiresAt(createPayment.Currency, time.Now())
+	// payment held for review isn't sent to payment system, so it can't fail there
+	switch {
+	case screening.Decision == paymentModel.RiskDecisionReview:
+		createPayment.PaymentStatus = paymentModel.ValidStatusReview
+	case 1-rand.Float64() <= a.errorChance:
 		createPayment.PaymentStatus = paymentModel.ValidStatusError
+		createPayment.ExpiresAt = nil
+	}
+
+	tx, err := a.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't start transaction")
+		return
 	}
+	defer tx.Rollback()
+	store := paymentModel.WithTx(a.paymentStore, tx)
 
-	payment, err := a.paymentStore.CreatePayment(r.Context(), createPayment)
+	payment, err := store.CreatePayment(r.Context(), createPayment)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == paymentModel.UniqueViolation {
+		SendErrorJSON(w, r, http.StatusConflict, errors.New("merchant reference is already used"), "can't create payment record")
+		return
+	}
 	if err != nil {
 		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't create payment record")
 		return
 	}
+	if err = audit.Record(r.Context(), store, actorOf(r), payment.MerchantID, audit.ActionCreatePayment, audit.Payment(payment.ID)); err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't record audit log")
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't commit transaction")
+		return
+	}
 
 	render.Status(r, http.StatusCreated)
 	render.JSON(w, r, &payment)
 }
 
-// PUT /payment/{id} - updates payment status
+// PUT /payment/{id} - updates payment status, If-Match with ETag of payment requires payment to have the same version
 func (a *API) updateStatus(w http.ResponseWriter, r *http.Request) {
 	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
 		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid payment id")
 		return
 	}
+	version, err := ifMatch(r)
+	if errors.Is(err, errVersionMismatch) {
+		SendErrorJSON(w, r, http.StatusPreconditionFailed, err, "payment is changed")
+		return
+	}
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid If-Match")
+		return
+	}
 
 	s := paymentModel.UpdatePaymentStatusParams{}
 
@@ -93,42 +236,75 @@ func (a *API) updateStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx, err := a.db.BeginTx(r.Context(), nil)
+	p, _ := principalFrom(r.Context())
+	if err = a.processor.UpdateStatusIfMatch(r.Context(), actorOf(r), p.MerchantID, int64(paymentID), version, s.PaymentStatus); err != nil {
+		SendProcessorErrorJSON(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusNoContent)
+}
+
+// GET /payment/{id}?fields=id,amount,payment_status&wait=30s - returns payment, fields limit it to sparse fieldset,
+// response has ETag of payment version, weak one for sparse fieldset, and If-None-Match with the same tag gets 304,
+// wait holds response of payment in new status until its status is changed or wait elapses
+func (a *API) getPayment(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't start transaction")
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid payment id")
 		return
 	}
-	defer tx.Rollback()
-	status, err := a.paymentStore.GetPaymentStatusByID(r.Context(), int64(paymentID))
-	if errors.Is(err, sql.ErrNoRows) {
-		SendErrorJSON(w, r, http.StatusNotFound, err, "payment not found")
+	fields, err := queryFields(r.URL.Query(), paymentModel.Payment{})
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid fields")
 		return
 	}
+	wait, err := queryWait(r.URL.Query(), a.streams.waitLimit())
 	if err != nil {
-		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't update payment")
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid wait")
 		return
 	}
 
-	s.ID = int64(paymentID)
-	rows, err := a.paymentStore.UpdatePaymentStatus(r.Context(), s)
-	if err != nil {
-		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't update payment")
+	// status change is announced by event, so waiting doesn't read database again until it happens
+	var sub *event.Subscription
+	if wait > 0 && a.streams.Broadcaster != nil {
+		sub = a.streams.Broadcaster.Subscribe(func(e event.Event) bool {
+			return e.PaymentID == int64(paymentID)
+		})
+		defer sub.Close()
+	}
+
+	p, _ := principalFrom(r.Context())
+	payment, err := a.paymentStore.GetPaymentByID(r.Context(), paymentModel.GetPaymentByIDParams{ID: int64(paymentID), MerchantID: p.MerchantID})
+	if err == nil && sub != nil && payment.PaymentStatus == paymentModel.ValidStatusNew && canAccessUser(r.Context(), payment.UserID) {
+		payment, err = a.waitForChange(r.Context(), sub, payment, wait)
+	}
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !canAccessUser(r.Context(), payment.UserID)) {
+		SendErrorJSON(w, r, http.StatusNotFound, fmt.Errorf("payment %d not found", paymentID), "payment not found")
 		return
 	}
-	if rows == 0 {
-		SendErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("can't update from %s status to %s status", s.PaymentStatus, status), "can't update payment status")
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't get payment")
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't commit payment")
+	resource, err := selectFields(payment, fields)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't get payment")
+		return
+	}
+	tag := versionETag(payment.Version, len(fields) > 0)
+	w.Header().Set("ETag", tag)
+	if ifNoneMatch(r, tag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	render.Status(r, http.StatusNoContent)
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, resource)
 }
 
-// GET /payment/{id} - returns payment status
+// GET /payment/{id}/status - returns payment status
 func (a *API) getStatus(w http.ResponseWriter, r *http.Request) {
 	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
@@ -136,9 +312,10 @@ func (a *API) getStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	trStatus, err := a.paymentStore.GetPaymentStatusByID(r.Context(), int64(paymentID))
-	if errors.Is(err, sql.ErrNoRows) {
-		SendErrorJSON(w, r, http.StatusNotFound, err, "payment not found")
+	p, _ := principalFrom(r.Context())
+	payment, err := a.paymentStore.GetPaymentByID(r.Context(), paymentModel.GetPaymentByIDParams{ID: int64(paymentID), MerchantID: p.MerchantID})
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !canAccessUser(r.Context(), payment.UserID)) {
+		SendErrorJSON(w, r, http.StatusNotFound, fmt.Errorf("payment %d not found", paymentID), "payment not found")
 		return
 	}
 	if err != nil {
@@ -147,86 +324,109 @@ func (a *API) getStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, JSON{"status": trStatus})
+	render.JSON(w, r, JSON{"status": payment.PaymentStatus})
 }
 
-// GET /user/{id}/payment?limit=5&cursor=0 - returns payments by user id
-func (a *API) getUserPaymentsByID(w http.ResponseWriter, r *http.Request) {
-	userID, err := strconv.Atoi(chi.URLParam(r, "user_id"))
-	if err != nil {
-		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid user id")
+// GET /payment?merchant_reference=order-42 - returns payment by merchant reference
+func (a *API) getPaymentByReference(w http.ResponseWriter, r *http.Request) {
+	reference := r.URL.Query().Get("merchant_reference")
+	if reference == "" {
+		SendErrorJSON(w, r, http.StatusBadRequest, errors.New("no merchant reference provided"), "invalid merchant reference")
 		return
 	}
-	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
-	if err != nil {
-		limit = 10
+
+	p, _ := principalFrom(r.Context())
+	payment, err := a.paymentStore.GetPaymentByMerchantReference(r.Context(), paymentModel.GetPaymentByMerchantReferenceParams{MerchantID: p.MerchantID, MerchantReference: reference})
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !canAccessUser(r.Context(), payment.UserID)) {
+		SendErrorJSON(w, r, http.StatusNotFound, fmt.Errorf("payment with %q merchant reference not found", reference), "payment not found")
+		return
 	}
-	cursor, err := strconv.Atoi(r.URL.Query().Get("cursor"))
 	if err != nil {
-		cursor = 0
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't get payment")
+		return
 	}
 
-	params := paymentModel.ListUserPaymentsByIDParams{
-		UserID: int64(userID),
-		ID:     int64(cursor),
-		Limit:  int32(limit),
-	}
-	ts, err := a.paymentStore.ListUserPaymentsByID(r.Context(), params)
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, &payment)
+}
+
+// GET /user/{id}/payment?status=new,success&sort=-amount&limit=5&cursor=eyJpZCI6NX0 - returns page of payments by user id
+func (a *API) getUserPaymentsByID(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "user_id"))
 	if err != nil {
-		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't find payment")
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid user id")
 		return
 	}
-	if len(ts) == 0 {
-		SendErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("no payments was found for %d user id", userID), "no payments found")
+	if !canAccessUser(r.Context(), int64(userID)) {
+		SendErrorJSON(w, r, http.StatusForbidden, fmt.Errorf("can't access payments of %d user id", userID), "forbidden")
 		return
 	}
-
-	render.Status(r, http.StatusOK)
-	render.JSON(w, r, ts)
+	a.listPayments(w, r, search.Filter{UserID: int64(userID)})
 }
 
-// GET /user/payment?email=userEmail&limit=5&cursor=0 - returns payments by user email
+// GET /user/payment?email=userEmail&currency=usd&limit=5&cursor=eyJpZCI6NX0 - returns page of payments by user email
 func (a *API) getUserPaymentsByEmail(w http.ResponseWriter, r *http.Request) {
 	email := r.URL.Query().Get("email")
 	if email == "" {
 		SendErrorJSON(w, r, http.StatusBadRequest, errors.New("no email provided"), "invalid email")
 		return
 	}
-	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	// payments by email can't be checked for owner without reading them, user bound principal must use user id
+	if !canAccessUser(r.Context(), 0) {
+		SendErrorJSON(w, r, http.StatusForbidden, errors.New("can't list payments by email with user token"), "forbidden")
+		return
+	}
+	a.listPayments(w, r, search.Filter{Email: email})
+}
+
+// listPayments sends page of payments selected by owner filter and query filters
+func (a *API) listPayments(w http.ResponseWriter, r *http.Request, owner search.Filter) {
+	q, err := pageRequest(r)
 	if err != nil {
-		limit = 10
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid page")
+		return
 	}
-	cursor, err := strconv.Atoi(r.URL.Query().Get("cursor"))
+	q.Filter, err = filterRequest(r)
 	if err != nil {
-		cursor = 0
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid filter")
+		return
 	}
+	p, _ := principalFrom(r.Context())
+	q.MerchantID = p.MerchantID
+	q.UserID = owner.UserID
+	q.Email = owner.Email
 
-	params := paymentModel.ListUserPaymentsByEmailParams{
-		Email: email,
-		ID:    int64(cursor),
-		Limit: int32(limit),
+	rows := q
+	rows.Limit++
+	ts, err := search.List(r.Context(), a.db, rows)
+	if errors.Is(err, search.ErrInvalidKey) {
+		SendErrorJSON(w, r, http.StatusBadRequest, errMalformedCursor, "invalid page")
+		return
 	}
-	ts, err := a.paymentStore.ListUserPaymentsByEmail(r.Context(), params)
 	if err != nil {
 		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't find payment")
 		return
 	}
-	if len(ts) == 0 {
-		SendErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("no payments was found for %s email", email), "no payments found")
-		return
-	}
 
-	render.Status(r, http.StatusOK)
-	render.JSON(w, r, ts)
+	sendPaymentPage(w, r, newPaymentPage(ts, q))
 }
 
-// DELETE /payment/{id} - deletes payment
+// DELETE /payment/{id} - deletes payment, If-Match with ETag of payment requires payment to have the same version
 func (a *API) cancelPayment(w http.ResponseWriter, r *http.Request) {
 	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
 		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid payment id")
 		return
 	}
+	version, err := ifMatch(r)
+	if errors.Is(err, errVersionMismatch) {
+		SendErrorJSON(w, r, http.StatusPreconditionFailed, err, "payment is changed")
+		return
+	}
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid If-Match")
+		return
+	}
 
 	tx, err := a.db.BeginTx(r.Context(), nil)
 	if err != nil {
@@ -234,10 +434,12 @@ func (a *API) cancelPayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer tx.Rollback()
+	store := paymentModel.WithTx(a.paymentStore, tx)
 
-	status, err := a.paymentStore.GetPaymentStatusByID(r.Context(), int64(paymentID))
-	if errors.Is(err, sql.ErrNoRows) {
-		SendErrorJSON(w, r, http.StatusNotFound, err, "payment not found")
+	p, _ := principalFrom(r.Context())
+	payment, err := store.GetPaymentByID(r.Context(), paymentModel.GetPaymentByIDParams{ID: int64(paymentID), MerchantID: p.MerchantID})
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !canAccessUser(r.Context(), payment.UserID)) {
+		SendErrorJSON(w, r, http.StatusNotFound, fmt.Errorf("payment %d not found", paymentID), "payment not found")
 		return
 	}
 	if err != nil {
@@ -245,13 +447,28 @@ func (a *API) cancelPayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := a.paymentStore.DiscardPayment(r.Context(), int64(paymentID))
+	rows, err := store.DiscardPayment(r.Context(), paymentModel.DiscardPaymentParams{ID: int64(paymentID), MerchantID: p.MerchantID, Version: version})
 	if err != nil {
 		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't delete payment")
 		return
 	}
+	if rows == 0 && version != 0 {
+		current, err := store.GetPaymentByID(r.Context(), paymentModel.GetPaymentByIDParams{ID: int64(paymentID), MerchantID: p.MerchantID})
+		if err != nil {
+			SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't delete payment")
+			return
+		}
+		if current.Version != version {
+			SendErrorJSON(w, r, http.StatusPreconditionFailed, fmt.Errorf("payment has %d version, not %d", current.Version, version), "payment is changed")
+			return
+		}
+	}
 	if rows == 0 {
-		SendErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("can't discard payment, it has %s status", status), "can't discard payment, it has final status")
+		SendErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("can't discard payment, it has %s status", payment.PaymentStatus), "can't discard payment, it has final status")
+		return
+	}
+	if err = audit.Record(r.Context(), store, actorOf(r), payment.MerchantID, audit.ActionCancelPayment, audit.Payment(payment.ID)); err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't record audit log")
 		return
 	}
 
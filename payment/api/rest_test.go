@@ -8,13 +8,19 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/semka95/payment-service/payment/processor"
 	postgres "github.com/semka95/payment-service/payment/repository"
+	"github.com/semka95/payment-service/payment/risk"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -36,6 +42,7 @@ var tPayment = postgres.Payment{
 	CreatedAt:     time.Now().Truncate(time.Millisecond).UTC(),
 	UpdatedAt:     time.Now().Truncate(time.Millisecond).UTC(),
 	PaymentStatus: postgres.ValidStatusNew,
+	Metadata:      json.RawMessage("{}"),
 }
 
 var tPayments = []postgres.Payment{
@@ -48,6 +55,9 @@ var tPayments = []postgres.Payment{
 		CreatedAt:     time.Now().Truncate(time.Millisecond).UTC(),
 		UpdatedAt:     time.Now().Truncate(time.Millisecond).UTC(),
 		PaymentStatus: postgres.ValidStatusNew,
+		MerchantID:    1,
+		Metadata:      json.RawMessage("{}"),
+		Version:       1,
 	},
 	{
 		ID:            2,
@@ -58,6 +68,9 @@ var tPayments = []postgres.Payment{
 		CreatedAt:     time.Now().Truncate(time.Millisecond).UTC(),
 		UpdatedAt:     time.Now().Truncate(time.Millisecond).UTC(),
 		PaymentStatus: postgres.ValidStatusNew,
+		MerchantID:    1,
+		Metadata:      json.RawMessage("{}"),
+		Version:       1,
 	},
 	{
 		ID:            3,
@@ -68,6 +81,9 @@ var tPayments = []postgres.Payment{
 		CreatedAt:     time.Now().Truncate(time.Millisecond).UTC(),
 		UpdatedAt:     time.Now().Truncate(time.Millisecond).UTC(),
 		PaymentStatus: postgres.ValidStatusNew,
+		MerchantID:    1,
+		Metadata:      json.RawMessage("{}"),
+		Version:       1,
 	},
 }
 
@@ -82,7 +98,11 @@ type jsonError struct {
 }
 
 func TestCreatePayment(t *testing.T) {
-	api := API{}
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	api := API{db: db}
 	req := new(http.Request)
 	reqB, err := json.Marshal(tCreatePayment)
 	require.NoError(t, err)
@@ -90,7 +110,9 @@ func TestCreatePayment(t *testing.T) {
 	cases := []struct {
 		description    string
 		mockedStore    *postgres.QuerierMock
+		principal      principal
 		reqBody        *bytes.Buffer
+		expectSQL      func(mock sqlmock.Sqlmock)
 		checkMockCalls func(tr *postgres.QuerierMock)
 		checkResponse  func(rec *httptest.ResponseRecorder)
 	}{
@@ -107,14 +129,26 @@ func TestCreatePayment(t *testing.T) {
 						CreatedAt:     tPayment.CreatedAt,
 						UpdatedAt:     tPayment.UpdatedAt,
 						PaymentStatus: postgres.ValidStatusNew,
+						Metadata:      arg.Metadata,
 					}
 					return tr, nil
 				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
 			},
 			reqBody: bytes.NewBuffer(reqB),
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
 			checkMockCalls: func(tr *postgres.QuerierMock) {
 				calls := len(tr.CreatePaymentCalls())
 				assert.Equal(t, 1, calls)
+
+				logs := tr.CreateAuditLogCalls()
+				require.Equal(t, 1, len(logs))
+				assert.Equal(t, postgres.CreateAuditLogParams{Actor: "api_key:1", Action: "payment.create", Target: "payment:1", Method: http.MethodPost, Path: "/payment"}, logs[0].Arg)
 			},
 			checkResponse: func(rec *httptest.ResponseRecorder) {
 				result := postgres.Payment{}
@@ -128,6 +162,7 @@ func TestCreatePayment(t *testing.T) {
 			description:    "bad intput data",
 			mockedStore:    &postgres.QuerierMock{},
 			reqBody:        bytes.NewBuffer([]byte("bad data")),
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
 			checkMockCalls: func(tr *postgres.QuerierMock) {},
 			checkResponse: func(rec *httptest.ResponseRecorder) {
 				jsonErr := new(jsonError)
@@ -138,14 +173,112 @@ func TestCreatePayment(t *testing.T) {
 				assert.Equal(t, http.StatusBadRequest, rec.Code)
 			},
 		},
+		{
+			description:    "invalid metadata",
+			mockedStore:    &postgres.QuerierMock{},
+			reqBody:        bytes.NewBufferString(`{"user_id":2,"email":"test@example.com","amount":"1","currency":"usd","metadata":{"order":42}}`),
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid payment details", jsonErr.Details)
+				assert.Equal(t, "metadata must be json object with string values", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "unknown currency",
+			mockedStore:    &postgres.QuerierMock{},
+			reqBody:        bytes.NewBufferString(`{"user_id":2,"email":"test@example.com","amount":"1","currency":"gbp"}`),
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid payment details", jsonErr.Details)
+				assert.Equal(t, `unknown currency "gbp"`, jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "negative amount",
+			mockedStore:    &postgres.QuerierMock{},
+			reqBody:        bytes.NewBufferString(`{"user_id":2,"email":"test@example.com","amount":"-1","currency":"usd"}`),
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid payment details", jsonErr.Details)
+				assert.Equal(t, "amount must be positive", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "merchant reference is used",
+			mockedStore: &postgres.QuerierMock{
+				CreatePaymentFunc: func(ctx context.Context, arg postgres.CreatePaymentParams) (postgres.Payment, error) {
+					return postgres.Payment{}, &pq.Error{Code: postgres.UniqueViolation}
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			reqBody: bytes.NewBufferString(`{"user_id":2,"email":"test@example.com","amount":"1","currency":"usd","merchant_reference":"order-42"}`),
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.CreatePaymentCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, "order-42", calls[0].Arg.MerchantReference)
+				assert.Equal(t, json.RawMessage("{}"), calls[0].Arg.Metadata)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "merchant reference is already used", jsonErr.Error)
+				assert.Equal(t, http.StatusConflict, rec.Code)
+			},
+		},
+		{
+			description: "payment of other user",
+			mockedStore: &postgres.QuerierMock{},
+			principal:   principal{Method: methodBearer, Subject: "2", UserID: 2},
+			reqBody:     bytes.NewBuffer(reqB),
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.CreatePaymentCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't create payments of 1 user id", jsonErr.Error)
+				assert.Equal(t, http.StatusForbidden, rec.Code)
+			},
+		},
 		{
 			description: "repository server error",
 			mockedStore: &postgres.QuerierMock{
 				CreatePaymentFunc: func(ctx context.Context, arg postgres.CreatePaymentParams) (postgres.Payment, error) {
 					return postgres.Payment{}, fmt.Errorf("can't create record")
 				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
 			},
 			reqBody: bytes.NewBuffer(reqB),
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
 			checkMockCalls: func(tr *postgres.QuerierMock) {
 				calls := len(tr.CreatePaymentCalls())
 				assert.Equal(t, 1, calls)
@@ -167,11 +300,142 @@ func TestCreatePayment(t *testing.T) {
 
 			req = httptest.NewRequest("POST", "/payment", tc.reqBody)
 			req.Header.Set("Content-Type", "application/json")
+			p := tc.principal
+			if p.Method == "" {
+				p = principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}
+			}
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, p))
+
+			tc.expectSQL(mock)
+
+			rec := httptest.NewRecorder()
+			api.createPayment(rec, req)
+
+			tc.checkMockCalls(tc.mockedStore)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			tc.checkResponse(rec)
+		})
+	}
+}
+
+func TestCreatePaymentRisk(t *testing.T) {
+	req := new(http.Request)
+	reqB, err := json.Marshal(tCreatePayment)
+	require.NoError(t, err)
+
+	cases := []struct {
+		description    string
+		blocklist      []string
+		errorChance    float64
+		mockedStore    *postgres.QuerierMock
+		expectSQL      func(mock sqlmock.Sqlmock)
+		checkMockCalls func(tr *postgres.QuerierMock)
+		checkResponse  func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "review",
+			mockedStore: &postgres.QuerierMock{
+				CreatePaymentFunc: func(ctx context.Context, arg postgres.CreatePaymentParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 1, PaymentStatus: arg.PaymentStatus, RiskScore: arg.RiskScore, RiskDecision: arg.RiskDecision, RiskRules: arg.RiskRules}, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.CreatePaymentCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, postgres.ValidStatusReview, calls[0].Arg.PaymentStatus)
+				assert.Equal(t, postgres.RiskDecisionReview, calls[0].Arg.RiskDecision)
+				assert.Equal(t, int32(60), calls[0].Arg.RiskScore)
+				assert.Equal(t, []string{"amount"}, calls[0].Arg.RiskRules)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := postgres.Payment{}
+				err = json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, postgres.ValidStatusReview, result.PaymentStatus)
+				assert.Equal(t, []string{"amount"}, result.RiskRules)
+				assert.Equal(t, http.StatusCreated, rec.Code)
+			},
+		},
+		{
+			description: "review isn't replaced by error",
+			errorChance: 1,
+			mockedStore: &postgres.QuerierMock{
+				CreatePaymentFunc: func(ctx context.Context, arg postgres.CreatePaymentParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 1, PaymentStatus: arg.PaymentStatus, ExpiresAt: arg.ExpiresAt}, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.CreatePaymentCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, postgres.ValidStatusReview, calls[0].Arg.PaymentStatus)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := postgres.Payment{}
+				err = json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, postgres.ValidStatusReview, result.PaymentStatus)
+				assert.Equal(t, http.StatusCreated, rec.Code)
+			},
+		},
+		{
+			description:    "rejected",
+			blocklist:      []string{"example.com"},
+			mockedStore:    &postgres.QuerierMock{},
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "payment rejected", jsonErr.Details)
+				assert.Equal(t, "payment rejected by risk rules: amount, email_domain", jsonErr.Error)
+				assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+
+			api := API{
+				paymentStore: tc.mockedStore,
+				db:           db,
+				risk: risk.NewEngine(50, 100,
+					risk.NewAmountRule(map[postgres.ValidCurrency]decimal.Decimal{postgres.ValidCurrencyUsd: decimal.NewFromInt(100)}, 60),
+					risk.NewEmailDomainRule(tc.blocklist, 60),
+				),
+				errorChance: tc.errorChance,
+			}
+
+			req = httptest.NewRequest("POST", "/payment", bytes.NewBuffer(reqB))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}))
+
+			tc.expectSQL(mock)
 
 			rec := httptest.NewRecorder()
 			api.createPayment(rec, req)
 
 			tc.checkMockCalls(tc.mockedStore)
+			assert.NoError(t, mock.ExpectationsWereMet())
 
 			tc.checkResponse(rec)
 		})
@@ -193,13 +457,13 @@ func TestGetStatus(t *testing.T) {
 		{
 			description: "success",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
-					return postgres.ValidStatusNew, nil
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, PaymentStatus: postgres.ValidStatusNew}, nil
 				},
 			},
 			id: "2",
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.GetPaymentStatusByIDCalls())
+				calls := len(tr.GetPaymentByIDCalls())
 				assert.Equal(t, 1, calls)
 			},
 			checkResponse: func(rec *httptest.ResponseRecorder) {
@@ -215,8 +479,8 @@ func TestGetStatus(t *testing.T) {
 		{
 			description: "bad id",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
-					return postgres.ValidStatusNew, nil
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, PaymentStatus: postgres.ValidStatusNew}, nil
 				},
 			},
 			checkMockCalls: func(tr *postgres.QuerierMock) {},
@@ -233,13 +497,13 @@ func TestGetStatus(t *testing.T) {
 		{
 			description: "not found",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
-					return "", sql.ErrNoRows
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{}, sql.ErrNoRows
 				},
 			},
 			id: "2",
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.GetPaymentStatusByIDCalls())
+				calls := len(tr.GetPaymentByIDCalls())
 				assert.Equal(t, 1, calls)
 			},
 			checkResponse: func(rec *httptest.ResponseRecorder) {
@@ -247,20 +511,20 @@ func TestGetStatus(t *testing.T) {
 				err := json.NewDecoder(rec.Body).Decode(jsonErr)
 				require.NoError(t, err)
 				assert.Equal(t, "payment not found", jsonErr.Details)
-				assert.Equal(t, "sql: no rows in result set", jsonErr.Error)
+				assert.Equal(t, "payment 2 not found", jsonErr.Error)
 				assert.Equal(t, http.StatusNotFound, rec.Code)
 			},
 		},
 		{
 			description: "repository server error",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
-					return "", fmt.Errorf("server error")
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{}, fmt.Errorf("server error")
 				},
 			},
 			id: "2",
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.GetPaymentStatusByIDCalls())
+				calls := len(tr.GetPaymentByIDCalls())
 				assert.Equal(t, 1, calls)
 			},
 			checkResponse: func(rec *httptest.ResponseRecorder) {
@@ -278,12 +542,13 @@ func TestGetStatus(t *testing.T) {
 		t.Run(tc.description, func(t *testing.T) {
 			api.paymentStore = tc.mockedStore
 
-			req = httptest.NewRequest("GET", "/payment/{id}", http.NoBody)
+			req = httptest.NewRequest("GET", "/payment/{id}/status", http.NoBody)
 			req.Header.Set("Content-Type", "application/json")
 
 			c.Reset()
 			c.URLParams.Add("id", tc.id)
 			req = req.WithContext((context.WithValue(req.Context(), chi.RouteCtxKey, c)))
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}))
 
 			rec := httptest.NewRecorder()
 			api.getStatus(rec, req)
@@ -295,91 +560,178 @@ func TestGetStatus(t *testing.T) {
 	}
 }
 
-func TestGetUserPaymentsByID(t *testing.T) {
+// paymentRows returns payments as rows of payments table
+func paymentRows(ps ...postgres.Payment) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "email", "amount", "currency", "payment_status", "created_at", "updated_at", "expires_at", "risk_score", "risk_decision", "risk_rules", "merchant_id", "description", "merchant_reference", "metadata", "version"})
+	for _, p := range ps {
+		var rules interface{}
+		if p.RiskRules != nil {
+			rules = "{" + strings.Join(p.RiskRules, ",") + "}"
+		}
+		rows.AddRow(p.ID, p.UserID, p.Email, p.Amount.String(), p.Currency, p.PaymentStatus, p.CreatedAt, p.UpdatedAt, p.ExpiresAt, p.RiskScore, p.RiskDecision, rules, p.MerchantID, p.Description, p.MerchantReference, []byte(p.Metadata), p.Version)
+	}
+
+	return rows
+}
+
+func TestGetPayment(t *testing.T) {
 	api := API{}
-	req := new(http.Request)
-	c := chi.NewRouteContext()
+	found := func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+		return tPayments[0], nil
+	}
+	owner := principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}
+	tag := versionETag(1, false)
 
 	cases := []struct {
 		description    string
 		mockedStore    *postgres.QuerierMock
-		userID         string
+		id             string
+		query          string
+		ifNoneMatch    string
+		principal      principal
 		checkMockCalls func(tr *postgres.QuerierMock)
 		checkResponse  func(rec *httptest.ResponseRecorder)
 	}{
 		{
 			description: "success",
-			mockedStore: &postgres.QuerierMock{
-				ListUserPaymentsByIDFunc: func(ctx context.Context, arg postgres.ListUserPaymentsByIDParams) ([]postgres.Payment, error) {
-					return tPayments, nil
-				},
-			},
-			userID: "2",
+			mockedStore: &postgres.QuerierMock{GetPaymentByIDFunc: found},
+			id:          "1",
+			principal:   owner,
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.ListUserPaymentsByIDCalls())
-				assert.Equal(t, 1, calls)
+				calls := tr.GetPaymentByIDCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, postgres.GetPaymentByIDParams{ID: 1, MerchantID: 1}, calls[0].Arg)
 			},
 			checkResponse: func(rec *httptest.ResponseRecorder) {
-				result := make([]postgres.Payment, 0)
+				result := postgres.Payment{}
 				err := json.NewDecoder(rec.Body).Decode(&result)
 				require.NoError(t, err)
-				assert.ElementsMatch(t, tPayments, result)
+				assert.Equal(t, tPayments[0], result)
+				assert.Equal(t, tag, rec.Header().Get("ETag"))
 				assert.Equal(t, http.StatusOK, rec.Code)
 			},
 		},
 		{
-			description:    "bad user id",
+			description: "sparse fieldset",
+			mockedStore: &postgres.QuerierMock{GetPaymentByIDFunc: found},
+			id:          "1",
+			query:       "?fields=id,amount,payment_status",
+			principal:   owner,
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 1, len(tr.GetPaymentByIDCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.JSONEq(t, `{"id":1,"amount":"123.42","payment_status":"new"}`, rec.Body.String())
+				assert.Equal(t, `W/"1"`, rec.Header().Get("ETag"))
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "sparse fieldset not modified",
+			mockedStore: &postgres.QuerierMock{GetPaymentByIDFunc: found},
+			id:          "1",
+			query:       "?fields=id,payment_status",
+			ifNoneMatch: `W/"1"`,
+			principal:   owner,
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 1, len(tr.GetPaymentByIDCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, "", rec.Body.String())
+				assert.Equal(t, `W/"1"`, rec.Header().Get("ETag"))
+				assert.Equal(t, http.StatusNotModified, rec.Code)
+			},
+		},
+		{
+			description: "not modified",
+			mockedStore: &postgres.QuerierMock{GetPaymentByIDFunc: found},
+			id:          "1",
+			ifNoneMatch: `"other", W/` + tag,
+			principal:   owner,
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 1, len(tr.GetPaymentByIDCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, "", rec.Body.String())
+				assert.Equal(t, tag, rec.Header().Get("ETag"))
+				assert.Equal(t, http.StatusNotModified, rec.Code)
+			},
+		},
+		{
+			description:    "unknown field",
 			mockedStore:    &postgres.QuerierMock{},
-			userID:         "bad id",
+			id:             "1",
+			query:          "?fields=id,secret",
+			principal:      owner,
 			checkMockCalls: func(tr *postgres.QuerierMock) {},
 			checkResponse: func(rec *httptest.ResponseRecorder) {
 				jsonErr := new(jsonError)
 				err := json.NewDecoder(rec.Body).Decode(jsonErr)
 				require.NoError(t, err)
-				assert.Equal(t, "invalid user id", jsonErr.Details)
-				assert.Equal(t, "strconv.Atoi: parsing \"bad id\": invalid syntax", jsonErr.Error)
+				assert.Equal(t, "invalid fields", jsonErr.Details)
+				assert.Equal(t, `unknown field "secret"`, jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "bad id",
+			mockedStore:    &postgres.QuerierMock{},
+			id:             "bad id",
+			principal:      owner,
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
 				assert.Equal(t, http.StatusBadRequest, rec.Code)
 			},
 		},
 		{
 			description: "not found",
 			mockedStore: &postgres.QuerierMock{
-				ListUserPaymentsByIDFunc: func(ctx context.Context, arg postgres.ListUserPaymentsByIDParams) ([]postgres.Payment, error) {
-					return []postgres.Payment{}, nil
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{}, sql.ErrNoRows
 				},
 			},
-			userID: "2",
+			id:        "1",
+			principal: owner,
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.ListUserPaymentsByIDCalls())
-				assert.Equal(t, 1, calls)
+				assert.Equal(t, 1, len(tr.GetPaymentByIDCalls()))
 			},
 			checkResponse: func(rec *httptest.ResponseRecorder) {
 				jsonErr := new(jsonError)
 				err := json.NewDecoder(rec.Body).Decode(jsonErr)
 				require.NoError(t, err)
-				assert.Equal(t, "no payments found", jsonErr.Details)
-				assert.Equal(t, "no payments was found for 2 user id", jsonErr.Error)
-				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Equal(t, "payment not found", jsonErr.Details)
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+		{
+			description: "payment of other user",
+			mockedStore: &postgres.QuerierMock{GetPaymentByIDFunc: found},
+			id:          "1",
+			principal:   principal{Method: methodBearer, Subject: "7", MerchantID: 1, UserID: 7},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 1, len(tr.GetPaymentByIDCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, rec.Code)
 			},
 		},
 		{
 			description: "repository server error",
 			mockedStore: &postgres.QuerierMock{
-				ListUserPaymentsByIDFunc: func(ctx context.Context, arg postgres.ListUserPaymentsByIDParams) ([]postgres.Payment, error) {
-					return []postgres.Payment{}, fmt.Errorf("server error")
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{}, fmt.Errorf("server error")
 				},
 			},
-			userID: "2",
+			id:        "1",
+			principal: owner,
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.ListUserPaymentsByIDCalls())
-				assert.Equal(t, 1, calls)
+				assert.Equal(t, 1, len(tr.GetPaymentByIDCalls()))
 			},
 			checkResponse: func(rec *httptest.ResponseRecorder) {
 				jsonErr := new(jsonError)
 				err := json.NewDecoder(rec.Body).Decode(jsonErr)
 				require.NoError(t, err)
-				assert.Equal(t, "can't find payment", jsonErr.Details)
-				assert.Equal(t, "server error", jsonErr.Error)
+				assert.Equal(t, "can't get payment", jsonErr.Details)
 				assert.Equal(t, http.StatusInternalServerError, rec.Code)
 			},
 		},
@@ -389,15 +741,17 @@ func TestGetUserPaymentsByID(t *testing.T) {
 		t.Run(tc.description, func(t *testing.T) {
 			api.paymentStore = tc.mockedStore
 
-			req = httptest.NewRequest("GET", "/user/{user_id}/payment", http.NoBody)
-			req.Header.Set("Content-Type", "application/json")
-
-			c.Reset()
-			c.URLParams.Add("user_id", tc.userID)
-			req = req.WithContext((context.WithValue(req.Context(), chi.RouteCtxKey, c)))
+			req := httptest.NewRequest("GET", "/payment/{id}"+tc.query, http.NoBody)
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+			c := chi.NewRouteContext()
+			c.URLParams.Add("id", tc.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, c))
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, tc.principal))
 
 			rec := httptest.NewRecorder()
-			api.getUserPaymentsByID(rec, req)
+			api.getPayment(rec, req)
 
 			tc.checkMockCalls(tc.mockedStore)
 
@@ -406,83 +760,471 @@ func TestGetUserPaymentsByID(t *testing.T) {
 	}
 }
 
-func TestGetUserPaymentsByIEmail(t *testing.T) {
+func TestGetPaymentByReference(t *testing.T) {
 	api := API{}
-	req := new(http.Request)
+	found := func(ctx context.Context, arg postgres.GetPaymentByMerchantReferenceParams) (postgres.Payment, error) {
+		return tPayments[0], nil
+	}
 
 	cases := []struct {
 		description    string
 		mockedStore    *postgres.QuerierMock
-		email          string
+		query          string
+		principal      principal
 		checkMockCalls func(tr *postgres.QuerierMock)
 		checkResponse  func(rec *httptest.ResponseRecorder)
 	}{
 		{
 			description: "success",
-			mockedStore: &postgres.QuerierMock{
-				ListUserPaymentsByEmailFunc: func(ctx context.Context, arg postgres.ListUserPaymentsByEmailParams) ([]postgres.Payment, error) {
-					return tPayments, nil
-				},
-			},
-			email: "test@example.com",
+			mockedStore: &postgres.QuerierMock{GetPaymentByMerchantReferenceFunc: found},
+			query:       "?merchant_reference=order-42",
+			principal:   principal{Method: methodAPIKey, Subject: "1", MerchantID: 1},
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.ListUserPaymentsByEmailCalls())
-				assert.Equal(t, 1, calls)
+				calls := tr.GetPaymentByMerchantReferenceCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, postgres.GetPaymentByMerchantReferenceParams{MerchantID: 1, MerchantReference: "order-42"}, calls[0].Arg)
 			},
 			checkResponse: func(rec *httptest.ResponseRecorder) {
-				result := make([]postgres.Payment, 0)
+				result := postgres.Payment{}
 				err := json.NewDecoder(rec.Body).Decode(&result)
 				require.NoError(t, err)
-				assert.ElementsMatch(t, tPayments, result)
+				assert.Equal(t, tPayments[0], result)
 				assert.Equal(t, http.StatusOK, rec.Code)
 			},
 		},
 		{
-			description:    "empty email",
+			description:    "no reference",
 			mockedStore:    &postgres.QuerierMock{},
-			email:          "",
+			principal:      principal{Method: methodAPIKey, Subject: "1", MerchantID: 1},
 			checkMockCalls: func(tr *postgres.QuerierMock) {},
 			checkResponse: func(rec *httptest.ResponseRecorder) {
 				jsonErr := new(jsonError)
 				err := json.NewDecoder(rec.Body).Decode(jsonErr)
 				require.NoError(t, err)
-				assert.Equal(t, "invalid email", jsonErr.Details)
-				assert.Equal(t, "no email provided", jsonErr.Error)
+				assert.Equal(t, "invalid merchant reference", jsonErr.Details)
 				assert.Equal(t, http.StatusBadRequest, rec.Code)
 			},
 		},
 		{
 			description: "not found",
 			mockedStore: &postgres.QuerierMock{
-				ListUserPaymentsByEmailFunc: func(ctx context.Context, arg postgres.ListUserPaymentsByEmailParams) ([]postgres.Payment, error) {
-					return []postgres.Payment{}, nil
+				GetPaymentByMerchantReferenceFunc: func(ctx context.Context, arg postgres.GetPaymentByMerchantReferenceParams) (postgres.Payment, error) {
+					return postgres.Payment{}, sql.ErrNoRows
 				},
 			},
-			email: "test@example.com",
+			query:     "?merchant_reference=order-42",
+			principal: principal{Method: methodAPIKey, Subject: "1", MerchantID: 1},
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.ListUserPaymentsByEmailCalls())
-				assert.Equal(t, 1, calls)
+				assert.Equal(t, 1, len(tr.GetPaymentByMerchantReferenceCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "payment not found", jsonErr.Details)
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+		{
+			description: "payment of other user",
+			mockedStore: &postgres.QuerierMock{GetPaymentByMerchantReferenceFunc: found},
+			query:       "?merchant_reference=order-42",
+			principal:   principal{Method: methodBearer, Subject: "7", MerchantID: 1, UserID: 7},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 1, len(tr.GetPaymentByMerchantReferenceCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+		{
+			description: "repository server error",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentByMerchantReferenceFunc: func(ctx context.Context, arg postgres.GetPaymentByMerchantReferenceParams) (postgres.Payment, error) {
+					return postgres.Payment{}, fmt.Errorf("server error")
+				},
+			},
+			query:     "?merchant_reference=order-42",
+			principal: principal{Method: methodAPIKey, Subject: "1", MerchantID: 1},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 1, len(tr.GetPaymentByMerchantReferenceCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't get payment", jsonErr.Details)
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api.paymentStore = tc.mockedStore
+
+			req := httptest.NewRequest("GET", "/payment"+tc.query, http.NoBody)
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, tc.principal))
+
+			rec := httptest.NewRecorder()
+			api.getPaymentByReference(rec, req)
+
+			tc.checkMockCalls(tc.mockedStore)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
+
+func TestGetUserPaymentsByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	api := API{db: db}
+	req := new(http.Request)
+	c := chi.NewRouteContext()
+
+	cases := []struct {
+		description   string
+		userID        string
+		query         string
+		expectSQL     func(mock sqlmock.Sqlmock)
+		checkResponse func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "first page",
+			userID:      "2",
+			query:       "?limit=2",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE merchant_id = $1 AND user_id = $2 ORDER BY id ASC LIMIT $3")).
+					WithArgs(int64(1), int64(2), 3).
+					WillReturnRows(paymentRows(tPayments...))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := paymentPage{}
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, tPayments[:2], result.Items)
+				require.NotNil(t, result.NextCursor)
+				assert.Equal(t, cursor{ID: 2}.encode(), *result.NextCursor)
+				assert.Nil(t, result.PrevCursor)
+				assert.Equal(t, `</user/2/payment?cursor=eyJpZCI6Mn0&limit=2>; rel="next"`, rec.Header().Get("Link"))
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "page before cursor",
+			userID:      "2",
+			query:       "?limit=2&cursor=" + cursor{ID: 4, Before: true}.encode(),
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE merchant_id = $1 AND user_id = $2 AND id < $3 ORDER BY id DESC LIMIT $4")).
+					WithArgs(int64(1), int64(2), int64(4), 3).
+					WillReturnRows(paymentRows(tPayments[2], tPayments[1], tPayments[0]))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := paymentPage{}
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, []postgres.Payment{tPayments[1], tPayments[2]}, result.Items)
+				require.NotNil(t, result.NextCursor)
+				assert.Equal(t, cursor{ID: 3}.encode(), *result.NextCursor)
+				require.NotNil(t, result.PrevCursor)
+				assert.Equal(t, cursor{ID: 2, Before: true}.encode(), *result.PrevCursor)
+				assert.Contains(t, rec.Header().Get("Link"), `rel="prev"`)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "filters and sort",
+			userID:      "2",
+			query:       "?status=new&status=success,failure&currency=usd&min_amount=10&max_amount=200.5&created_from=2022-06-01T00:00:00Z&updated_to=2022-07-01T00:00:00%2B03:00&sort=-amount&limit=1",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE merchant_id = $1 AND user_id = $2 AND payment_status = ANY($3::valid_status[]) AND currency = $4 AND amount >= $5 AND amount <= $6 AND created_at >= $7 AND updated_at < $8 ORDER BY amount DESC, id DESC LIMIT $9")).
+					WithArgs(int64(1), int64(2), pq.Array([]string{"new", "success", "failure"}), postgres.ValidCurrencyUsd, decimal.RequireFromString("10"), decimal.RequireFromString("200.5"),
+						time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 6, 30, 21, 0, 0, 0, time.UTC), 2).
+					WillReturnRows(paymentRows(tPayments[0], tPayments[2]))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := paymentPage{}
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, tPayments[:1], result.Items)
+				require.NotNil(t, result.NextCursor)
+				assert.Equal(t, cursor{ID: 1, Value: "123.42", Sort: "amount", Desc: true}.encode(), *result.NextCursor)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "next page sorted by amount",
+			userID:      "2",
+			query:       "?sort=-amount&cursor=" + cursor{ID: 1, Value: "123.42", Sort: "amount", Desc: true}.encode(),
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE merchant_id = $1 AND user_id = $2 AND (amount, id) < ($3, $4) ORDER BY amount DESC, id DESC LIMIT $5")).
+					WithArgs(int64(1), int64(2), decimal.RequireFromString("123.42"), int64(1), 11).
+					WillReturnRows(paymentRows())
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := paymentPage{}
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, []postgres.Payment{}, result.Items)
+				require.NotNil(t, result.PrevCursor)
+				assert.Equal(t, cursor{ID: 1, Value: "123.42", Sort: "amount", Desc: true, Before: true, Incl: true}.encode(), *result.PrevCursor)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "limit is capped",
+			userID:      "2",
+			query:       "?limit=100000",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("LIMIT $3")).
+					WithArgs(int64(1), int64(2), maxPageLimit+1).
+					WillReturnRows(paymentRows(tPayments...))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, "", rec.Header().Get("Link"))
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "bad user id",
+			userID:      "bad id",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid user id", jsonErr.Details)
+				assert.Equal(t, "strconv.Atoi: parsing \"bad id\": invalid syntax", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "negative limit",
+			userID:      "2",
+			query:       "?limit=-1",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid page", jsonErr.Details)
+				assert.Equal(t, `limit must be positive integer, got "-1"`, jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "malformed cursor",
+			userID:      "2",
+			query:       "?cursor=5",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid page", jsonErr.Details)
+				assert.Equal(t, "malformed cursor", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "cursor of other sort",
+			userID:      "2",
+			query:       "?sort=created_at&cursor=" + cursor{ID: 1, Value: "123.42", Sort: "amount"}.encode(),
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "cursor doesn't match sort", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "unknown sort",
+			userID:      "2",
+			query:       "?sort=email",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid page", jsonErr.Details)
+				assert.Equal(t, `can't sort by "email"`, jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "unknown status",
+			userID:      "2",
+			query:       "?status=new,paid",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid filter", jsonErr.Details)
+				assert.Equal(t, `unknown status "paid"`, jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "bad time range",
+			userID:      "2",
+			query:       "?created_to=yesterday",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid filter", jsonErr.Details)
+				assert.Equal(t, `created_to must be RFC 3339 time, got "yesterday"`, jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "empty page",
+			userID:      "2",
+			query:       "?cursor=" + cursor{ID: 3}.encode(),
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE merchant_id = $1 AND user_id = $2 AND id > $3")).
+					WithArgs(int64(1), int64(2), int64(3), defaultPageLimit+1).
+					WillReturnRows(paymentRows())
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := paymentPage{}
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, []postgres.Payment{}, result.Items)
+				assert.Nil(t, result.NextCursor)
+				require.NotNil(t, result.PrevCursor)
+				assert.Equal(t, cursor{ID: 3, Before: true, Incl: true}.encode(), *result.PrevCursor)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "repository server error",
+			userID:      "2",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT").WillReturnError(fmt.Errorf("server error"))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't find payment", jsonErr.Details)
+				assert.Equal(t, "server error", jsonErr.Error)
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			req = httptest.NewRequest("GET", "/user/"+url.PathEscape(tc.userID)+"/payment"+tc.query, http.NoBody)
+			req.Header.Set("Content-Type", "application/json")
+
+			c.Reset()
+			c.URLParams.Add("user_id", tc.userID)
+			req = req.WithContext((context.WithValue(req.Context(), chi.RouteCtxKey, c)))
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}))
+
+			tc.expectSQL(mock)
+
+			rec := httptest.NewRecorder()
+			api.getUserPaymentsByID(rec, req)
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			tc.checkResponse(rec)
+		})
+	}
+}
+
+func TestGetUserPaymentsByIEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	api := API{db: db}
+	req := new(http.Request)
+
+	cases := []struct {
+		description   string
+		email         string
+		cursor        string
+		expectSQL     func(mock sqlmock.Sqlmock)
+		checkResponse func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "success",
+			email:       "test@example.com",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE merchant_id = $1 AND email = $2 ORDER BY id ASC LIMIT $3")).
+					WithArgs(int64(1), "test@example.com", defaultPageLimit+1).
+					WillReturnRows(paymentRows(tPayments...))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := paymentPage{}
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, tPayments, result.Items)
+				assert.Nil(t, result.NextCursor)
+				assert.Nil(t, result.PrevCursor)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "page before cursor",
+			email:       "test@example.com",
+			cursor:      cursor{ID: 3, Before: true}.encode(),
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE merchant_id = $1 AND email = $2 AND id < $3 ORDER BY id DESC LIMIT $4")).
+					WithArgs(int64(1), "test@example.com", int64(3), defaultPageLimit+1).
+					WillReturnRows(paymentRows(tPayments[1], tPayments[0]))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := paymentPage{}
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, tPayments[:2], result.Items)
+				require.NotNil(t, result.NextCursor)
+				assert.Equal(t, cursor{ID: 2}.encode(), *result.NextCursor)
+				assert.Nil(t, result.PrevCursor)
+				assert.Equal(t, http.StatusOK, rec.Code)
 			},
+		},
+		{
+			description: "empty email",
+			email:       "",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
 			checkResponse: func(rec *httptest.ResponseRecorder) {
 				jsonErr := new(jsonError)
 				err := json.NewDecoder(rec.Body).Decode(jsonErr)
 				require.NoError(t, err)
-				assert.Equal(t, "no payments found", jsonErr.Details)
-				assert.Equal(t, "no payments was found for test@example.com email", jsonErr.Error)
+				assert.Equal(t, "invalid email", jsonErr.Details)
+				assert.Equal(t, "no email provided", jsonErr.Error)
 				assert.Equal(t, http.StatusBadRequest, rec.Code)
 			},
 		},
 		{
-			description: "repository server error",
-			mockedStore: &postgres.QuerierMock{
-				ListUserPaymentsByEmailFunc: func(ctx context.Context, arg postgres.ListUserPaymentsByEmailParams) ([]postgres.Payment, error) {
-					return []postgres.Payment{}, fmt.Errorf("server error")
-				},
+			description: "empty page",
+			email:       "test@example.com",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT").WillReturnRows(paymentRows())
 			},
-			email: "test@example.com",
-			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.ListUserPaymentsByEmailCalls())
-				assert.Equal(t, 1, calls)
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.JSONEq(t, `{"items":[],"next_cursor":null,"prev_cursor":null}`, rec.Body.String())
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "repository server error",
+			email:       "test@example.com",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT").WillReturnError(fmt.Errorf("server error"))
 			},
 			checkResponse: func(rec *httptest.ResponseRecorder) {
 				jsonErr := new(jsonError)
@@ -497,18 +1239,22 @@ func TestGetUserPaymentsByIEmail(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.description, func(t *testing.T) {
-			api.paymentStore = tc.mockedStore
-
 			req = httptest.NewRequest("GET", "/user/payment", http.NoBody)
 			q := req.URL.Query()
 			q.Add("email", tc.email)
+			if tc.cursor != "" {
+				q.Add("cursor", tc.cursor)
+			}
 			req.URL.RawQuery = q.Encode()
 			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}))
+
+			tc.expectSQL(mock)
 
 			rec := httptest.NewRecorder()
 			api.getUserPaymentsByEmail(rec, req)
 
-			tc.checkMockCalls(tc.mockedStore)
+			assert.NoError(t, mock.ExpectationsWereMet())
 
 			tc.checkResponse(rec)
 		})
@@ -528,6 +1274,7 @@ func TestCancelPayment(t *testing.T) {
 		description    string
 		mockedStore    *postgres.QuerierMock
 		id             string
+		ifMatch        string
 		expectSQL      func(mock sqlmock.Sqlmock)
 		checkMockCalls func(tr *postgres.QuerierMock)
 		checkResponse  func(rec *httptest.ResponseRecorder)
@@ -535,12 +1282,15 @@ func TestCancelPayment(t *testing.T) {
 		{
 			description: "success",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
-					return postgres.ValidStatusNew, nil
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, PaymentStatus: postgres.ValidStatusNew}, nil
 				},
-				DiscardPaymentFunc: func(ctx context.Context, id int64) (int64, error) {
+				DiscardPaymentFunc: func(ctx context.Context, arg postgres.DiscardPaymentParams) (int64, error) {
 					return 1, nil
 				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
 			},
 			id: "2",
 			expectSQL: func(mock sqlmock.Sqlmock) {
@@ -548,7 +1298,7 @@ func TestCancelPayment(t *testing.T) {
 				mock.ExpectCommit()
 			},
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.GetPaymentStatusByIDCalls())
+				calls := len(tr.GetPaymentByIDCalls())
 				assert.Equal(t, 1, calls)
 				calls = len(tr.DiscardPaymentCalls())
 				assert.Equal(t, 1, calls)
@@ -597,8 +1347,8 @@ func TestCancelPayment(t *testing.T) {
 		{
 			description: "not found",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
-					return "", sql.ErrNoRows
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{}, sql.ErrNoRows
 				},
 			},
 			id: "2",
@@ -607,7 +1357,7 @@ func TestCancelPayment(t *testing.T) {
 				mock.ExpectRollback()
 			},
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.GetPaymentStatusByIDCalls())
+				calls := len(tr.GetPaymentByIDCalls())
 				assert.Equal(t, 1, calls)
 				err = mock.ExpectationsWereMet()
 				assert.NoError(t, err)
@@ -617,15 +1367,15 @@ func TestCancelPayment(t *testing.T) {
 				err = json.NewDecoder(rec.Body).Decode(jsonErr)
 				require.NoError(t, err)
 				assert.Equal(t, "payment not found", jsonErr.Details)
-				assert.Equal(t, sql.ErrNoRows.Error(), jsonErr.Error)
+				assert.Equal(t, "payment 2 not found", jsonErr.Error)
 				assert.Equal(t, http.StatusNotFound, rec.Code)
 			},
 		},
 		{
 			description: "get status server error",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
-					return "", fmt.Errorf("server error")
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{}, fmt.Errorf("server error")
 				},
 			},
 			id: "2",
@@ -634,7 +1384,7 @@ func TestCancelPayment(t *testing.T) {
 				mock.ExpectRollback()
 			},
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.GetPaymentStatusByIDCalls())
+				calls := len(tr.GetPaymentByIDCalls())
 				assert.Equal(t, 1, calls)
 				err = mock.ExpectationsWereMet()
 				assert.NoError(t, err)
@@ -651,12 +1401,15 @@ func TestCancelPayment(t *testing.T) {
 		{
 			description: "discard payment server error",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
-					return postgres.ValidStatusNew, nil
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, PaymentStatus: postgres.ValidStatusNew}, nil
 				},
-				DiscardPaymentFunc: func(ctx context.Context, id int64) (int64, error) {
+				DiscardPaymentFunc: func(ctx context.Context, arg postgres.DiscardPaymentParams) (int64, error) {
 					return -1, fmt.Errorf("server error")
 				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
 			},
 			id: "2",
 			expectSQL: func(mock sqlmock.Sqlmock) {
@@ -664,7 +1417,7 @@ func TestCancelPayment(t *testing.T) {
 				mock.ExpectRollback()
 			},
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.GetPaymentStatusByIDCalls())
+				calls := len(tr.GetPaymentByIDCalls())
 				assert.Equal(t, 1, calls)
 				calls = len(tr.DiscardPaymentCalls())
 				assert.Equal(t, 1, calls)
@@ -683,12 +1436,15 @@ func TestCancelPayment(t *testing.T) {
 		{
 			description: "final status",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
-					return postgres.ValidStatusSuccess, nil
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, PaymentStatus: postgres.ValidStatusSuccess}, nil
 				},
-				DiscardPaymentFunc: func(ctx context.Context, id int64) (int64, error) {
+				DiscardPaymentFunc: func(ctx context.Context, arg postgres.DiscardPaymentParams) (int64, error) {
 					return 0, nil
 				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
 			},
 			id: "2",
 			expectSQL: func(mock sqlmock.Sqlmock) {
@@ -696,7 +1452,7 @@ func TestCancelPayment(t *testing.T) {
 				mock.ExpectRollback()
 			},
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.GetPaymentStatusByIDCalls())
+				calls := len(tr.GetPaymentByIDCalls())
 				assert.Equal(t, 1, calls)
 				calls = len(tr.DiscardPaymentCalls())
 				assert.Equal(t, 1, calls)
@@ -712,15 +1468,109 @@ func TestCancelPayment(t *testing.T) {
 				assert.Equal(t, http.StatusBadRequest, rec.Code)
 			},
 		},
+		{
+			description: "matching version",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, PaymentStatus: postgres.ValidStatusNew}, nil
+				},
+				DiscardPaymentFunc: func(ctx context.Context, arg postgres.DiscardPaymentParams) (int64, error) {
+					return 1, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			},
+			id:      "2",
+			ifMatch: `"3"`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.DiscardPaymentCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, int64(3), calls[0].Arg.Version)
+				assert.Equal(t, 1, len(tr.GetPaymentByIDCalls()))
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "changed version",
+			mockedStore: &postgres.QuerierMock{
+				DiscardPaymentFunc: func(ctx context.Context, arg postgres.DiscardPaymentParams) (int64, error) {
+					return 0, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, PaymentStatus: postgres.ValidStatusNew, Version: 4}, nil
+				},
+			},
+			id:      "2",
+			ifMatch: `"3"`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 1, len(tr.DiscardPaymentCalls()))
+				assert.Equal(t, 2, len(tr.GetPaymentByIDCalls()))
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "payment is changed", jsonErr.Details)
+				assert.Equal(t, "payment has 4 version, not 3", jsonErr.Error)
+				assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+			},
+		},
+		{
+			description:    "weak If-Match tag",
+			mockedStore:    &postgres.QuerierMock{},
+			id:             "2",
+			ifMatch:        `W/"3"`,
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+			},
+		},
+		{
+			description:    "several If-Match tags",
+			mockedStore:    &postgres.QuerierMock{},
+			id:             "2",
+			ifMatch:        `"3", "4"`,
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid If-Match", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
 		{
 			description: "commit transaction error",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
-					return postgres.ValidStatusNew, nil
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, PaymentStatus: postgres.ValidStatusNew}, nil
 				},
-				DiscardPaymentFunc: func(ctx context.Context, id int64) (int64, error) {
+				DiscardPaymentFunc: func(ctx context.Context, arg postgres.DiscardPaymentParams) (int64, error) {
 					return 1, nil
 				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
 			},
 			id: "2",
 			expectSQL: func(mock sqlmock.Sqlmock) {
@@ -728,7 +1578,7 @@ func TestCancelPayment(t *testing.T) {
 				mock.ExpectCommit().WillReturnError(fmt.Errorf("can't commit transaction"))
 			},
 			checkMockCalls: func(tr *postgres.QuerierMock) {
-				calls := len(tr.GetPaymentStatusByIDCalls())
+				calls := len(tr.GetPaymentByIDCalls())
 				assert.Equal(t, 1, calls)
 				calls = len(tr.DiscardPaymentCalls())
 				assert.Equal(t, 1, calls)
@@ -752,10 +1602,14 @@ func TestCancelPayment(t *testing.T) {
 
 			req = httptest.NewRequest("DELETE", "/payment/{id}", http.NoBody)
 			req.Header.Set("Content-Type", "application/json")
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
 
 			c.Reset()
 			c.URLParams.Add("id", tc.id)
 			req = req.WithContext((context.WithValue(req.Context(), chi.RouteCtxKey, c)))
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}))
 
 			tc.expectSQL(mock)
 
@@ -774,7 +1628,7 @@ func TestUpdateStatus(t *testing.T) {
 	defer func() { _ = db.Close() }()
 	require.NoError(t, err)
 
-	api := API{db: db}
+	api := API{}
 	req := new(http.Request)
 	c := chi.NewRouteContext()
 	reqB, err := json.Marshal(tUpdate)
@@ -785,6 +1639,7 @@ func TestUpdateStatus(t *testing.T) {
 		mockedStore    *postgres.QuerierMock
 		reqBody        *bytes.Buffer
 		id             string
+		ifMatch        string
 		expectSQL      func(mock sqlmock.Sqlmock)
 		checkMockCalls func(tr *postgres.QuerierMock)
 		checkResponse  func(rec *httptest.ResponseRecorder)
@@ -792,12 +1647,18 @@ func TestUpdateStatus(t *testing.T) {
 		{
 			description: "success",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
 					return postgres.ValidStatusNew, nil
 				},
 				UpdatePaymentStatusFunc: func(ctx context.Context, arg postgres.UpdatePaymentStatusParams) (int64, error) {
 					return 1, nil
 				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+					return nil, nil
+				},
 			},
 			reqBody: bytes.NewBuffer(reqB),
 			id:      "2",
@@ -849,6 +1710,24 @@ func TestUpdateStatus(t *testing.T) {
 				assert.Equal(t, http.StatusBadRequest, rec.Code)
 			},
 		},
+		{
+			description: "status isn't set by payment system",
+			mockedStore: &postgres.QuerierMock{},
+			reqBody:     bytes.NewBufferString(`{"payment_status":"review"}`),
+			id:          "2",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.UpdatePaymentStatusCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't update payment status", jsonErr.Details)
+				assert.Equal(t, `can't update to "review" status`, jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
 		{
 			description: "begin transaction error",
 			mockedStore: &postgres.QuerierMock{},
@@ -873,7 +1752,7 @@ func TestUpdateStatus(t *testing.T) {
 		{
 			description: "not found",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
 					return "", sql.ErrNoRows
 				},
 			},
@@ -901,7 +1780,7 @@ func TestUpdateStatus(t *testing.T) {
 		{
 			description: "get status server error",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
 					return "", fmt.Errorf("server error")
 				},
 			},
@@ -929,12 +1808,18 @@ func TestUpdateStatus(t *testing.T) {
 		{
 			description: "update payment server error",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
 					return postgres.ValidStatusNew, nil
 				},
 				UpdatePaymentStatusFunc: func(ctx context.Context, arg postgres.UpdatePaymentStatusParams) (int64, error) {
 					return -1, fmt.Errorf("server error")
 				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+					return nil, nil
+				},
 			},
 			reqBody: bytes.NewBuffer(reqB),
 			id:      "2",
@@ -962,12 +1847,18 @@ func TestUpdateStatus(t *testing.T) {
 		{
 			description: "final status",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
 					return postgres.ValidStatusFailure, nil
 				},
 				UpdatePaymentStatusFunc: func(ctx context.Context, arg postgres.UpdatePaymentStatusParams) (int64, error) {
 					return 0, nil
 				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+					return nil, nil
+				},
 			},
 			reqBody: bytes.NewBuffer(reqB),
 			id:      "2",
@@ -988,19 +1879,122 @@ func TestUpdateStatus(t *testing.T) {
 				err = json.NewDecoder(rec.Body).Decode(jsonErr)
 				require.NoError(t, err)
 				assert.Equal(t, "can't update payment status", jsonErr.Details)
-				assert.Equal(t, "can't update from success status to failure status", jsonErr.Error)
+				assert.Equal(t, "can't update from failure status to success status", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "changed version",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
+					return postgres.ValidStatusNew, nil
+				},
+				UpdatePaymentStatusFunc: func(ctx context.Context, arg postgres.UpdatePaymentStatusParams) (int64, error) {
+					return 0, nil
+				},
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, PaymentStatus: postgres.ValidStatusNew, Version: 4}, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+					return nil, nil
+				},
+			},
+			reqBody: bytes.NewBuffer(reqB),
+			id:      "2",
+			ifMatch: `"3"`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.UpdatePaymentStatusCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, int64(3), calls[0].Arg.Version)
+				assert.Equal(t, 1, len(tr.GetPaymentByIDCalls()))
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "payment is changed", jsonErr.Details)
+				assert.Equal(t, "payment has 4 version, not 3", jsonErr.Error)
+				assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+			},
+		},
+		{
+			description: "final status of matching version",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
+					return postgres.ValidStatusSuccess, nil
+				},
+				UpdatePaymentStatusFunc: func(ctx context.Context, arg postgres.UpdatePaymentStatusParams) (int64, error) {
+					return 0, nil
+				},
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{ID: 2, PaymentStatus: postgres.ValidStatusSuccess, Version: 3}, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+					return nil, nil
+				},
+			},
+			reqBody: bytes.NewBuffer(reqB),
+			id:      "2",
+			ifMatch: `"3"`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't update payment status", jsonErr.Details)
 				assert.Equal(t, http.StatusBadRequest, rec.Code)
 			},
 		},
+		{
+			description:    "malformed If-Match tag",
+			mockedStore:    &postgres.QuerierMock{},
+			reqBody:        bytes.NewBuffer(reqB),
+			id:             "2",
+			ifMatch:        "3",
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "payment is changed", jsonErr.Details)
+				assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+			},
+		},
 		{
 			description: "commit transaction error",
 			mockedStore: &postgres.QuerierMock{
-				GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (postgres.ValidStatus, error) {
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
 					return postgres.ValidStatusNew, nil
 				},
 				UpdatePaymentStatusFunc: func(ctx context.Context, arg postgres.UpdatePaymentStatusParams) (int64, error) {
 					return 1, nil
 				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+					return nil, nil
+				},
 			},
 			reqBody: bytes.NewBuffer(reqB),
 			id:      "2",
@@ -1029,10 +2023,13 @@ func TestUpdateStatus(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.description, func(t *testing.T) {
-			api.paymentStore = tc.mockedStore
+			api.processor = processor.New(tc.mockedStore, db, nil, nil)
 
 			req = httptest.NewRequest("PUT", "/payment/{id}", tc.reqBody)
 			req.Header.Set("Content-Type", "application/json")
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
 
 			c.Reset()
 			c.URLParams.Add("id", tc.id)
@@ -1049,3 +2046,160 @@ func TestUpdateStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestMerchantIsolation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	// payment 1 and reconciliation 1 belong to merchant 1
+	owns := func(merchantID int64) bool {
+		return merchantID == 1
+	}
+	mockedStore := &postgres.QuerierMock{
+		CreatePaymentFunc: func(ctx context.Context, arg postgres.CreatePaymentParams) (postgres.Payment, error) {
+			return postgres.Payment{ID: 2, MerchantID: arg.MerchantID}, nil
+		},
+		CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+			return nil
+		},
+		GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+			if !owns(arg.MerchantID) {
+				return postgres.Payment{}, sql.ErrNoRows
+			}
+			return postgres.Payment{ID: 1, MerchantID: 1, PaymentStatus: postgres.ValidStatusNew}, nil
+		},
+		GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
+			if arg.ID != 1 || !owns(arg.MerchantID) {
+				return "", sql.ErrNoRows
+			}
+			return postgres.ValidStatusNew, nil
+		},
+		UpdatePaymentStatusFunc: func(ctx context.Context, arg postgres.UpdatePaymentStatusParams) (int64, error) {
+			if arg.ID != 1 || !owns(arg.MerchantID) {
+				return 0, nil
+			}
+			return 1, nil
+		},
+		DiscardPaymentFunc: func(ctx context.Context, arg postgres.DiscardPaymentParams) (int64, error) {
+			if arg.ID != 1 || arg.MerchantID != 1 {
+				return 0, nil
+			}
+			return 1, nil
+		},
+		ListPaymentDisputesFunc: func(ctx context.Context, arg postgres.ListPaymentDisputesParams) ([]postgres.Dispute, error) {
+			if arg.PaymentID != 1 || arg.MerchantID != 1 {
+				return nil, nil
+			}
+			return []postgres.Dispute{{ID: 1, PaymentID: 1}}, nil
+		},
+		GetReconciliationRunFunc: func(ctx context.Context, arg postgres.GetReconciliationRunParams) (postgres.ReconciliationRun, error) {
+			if arg.ID != 1 || arg.MerchantID != 1 {
+				return postgres.ReconciliationRun{}, sql.ErrNoRows
+			}
+			return postgres.ReconciliationRun{ID: 1, MerchantID: 1}, nil
+		},
+		ListReconciliationDiscrepanciesFunc: func(ctx context.Context, arg postgres.ListReconciliationDiscrepanciesParams) ([]postgres.ReconciliationDiscrepancy, error) {
+			return nil, nil
+		},
+		CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+			return nil, nil
+		},
+	}
+	api := API{paymentStore: mockedStore, db: db, processor: processor.New(mockedStore, db, nil, nil)}
+
+	r := chi.NewRouter()
+	r.Post("/payment", api.createPayment)
+	r.Get("/payment/{id}", api.getPayment)
+	r.Get("/payment/{id}/status", api.getStatus)
+	r.Put("/payment/{id}", api.updateStatus)
+	r.Delete("/payment/{id}", api.cancelPayment)
+	r.Get("/payment/{id}/disputes", api.getPaymentDisputes)
+	r.Get("/admin/reconciliations/{id}", api.getReconciliation)
+
+	owner := principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}
+	other := principal{Method: methodAPIKey, Subject: "2", MerchantID: 2}
+	provider := principal{Method: methodClientCert, Subject: "provider", MerchantID: 2}
+
+	cases := []struct {
+		description string
+		principal   principal
+		method      string
+		url         string
+		body        string
+		expectSQL   func(mock sqlmock.Sqlmock)
+		code        int
+		response    string
+	}{
+		{
+			description: "create in own merchant", principal: other, method: "POST", url: "/payment", body: `{"user_id":1,"email":"test@example.com","amount":"1","currency":"usd","merchant_id":1}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			code: http.StatusCreated, response: `"merchant_id":2`,
+		},
+		{description: "read own payment", principal: owner, method: "GET", url: "/payment/1", code: http.StatusOK, response: `"merchant_id":1`},
+		{description: "read payment of other merchant", principal: other, method: "GET", url: "/payment/1", code: http.StatusNotFound, response: `"payment not found"`},
+		{description: "read own payment status", principal: owner, method: "GET", url: "/payment/1/status", code: http.StatusOK, response: `{"status":"new"}`},
+		{description: "read payment status of other merchant", principal: other, method: "GET", url: "/payment/1/status", code: http.StatusNotFound, response: `"payment not found"`},
+		{
+			description: "update payment of other merchant", principal: other, method: "PUT", url: "/payment/1", body: `{"payment_status":"success"}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			code: http.StatusNotFound, response: `"payment not found"`,
+		},
+		{
+			description: "payment system updates payment of other merchant", principal: provider, method: "PUT", url: "/payment/1", body: `{"payment_status":"success"}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			code: http.StatusNotFound, response: `"payment not found"`,
+		},
+		{
+			description: "cancel payment of other merchant", principal: other, method: "DELETE", url: "/payment/1",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			code: http.StatusNotFound, response: `"payment not found"`,
+		},
+		{
+			description: "cancel own payment", principal: owner, method: "DELETE", url: "/payment/1",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			code: http.StatusOK,
+		},
+		{description: "disputes of other merchant payment", principal: other, method: "GET", url: "/payment/1/disputes", code: http.StatusNotFound, response: `"payment not found"`},
+		{description: "disputes of own payment", principal: owner, method: "GET", url: "/payment/1/disputes", code: http.StatusOK, response: `"payment_id":1`},
+		{description: "reconciliation of other merchant", principal: other, method: "GET", url: "/admin/reconciliations/1", code: http.StatusNotFound, response: `"reconciliation not found"`},
+		{description: "own reconciliation", principal: owner, method: "GET", url: "/admin/reconciliations/1", code: http.StatusOK, response: `"merchant_id":1`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, tc.principal))
+			if tc.expectSQL != nil {
+				tc.expectSQL(mock)
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.code, rec.Code)
+			assert.Contains(t, rec.Body.String(), tc.response)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	calls := mockedStore.CreatePaymentCalls()
+	require.Equal(t, 1, len(calls))
+	assert.Equal(t, int64(2), calls[0].Arg.MerchantID)
+}
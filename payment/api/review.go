@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// reviewRequest is review decision request body
+type reviewRequest struct {
+	Note string `json:"note"`
+}
+
+// GET /admin/reviews?user_id=1&email=userEmail&currency=usd&min_score=50&limit=5&cursor=0 - returns payments waiting for review
+func (a *API) listReviews(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	p, _ := principalFrom(r.Context())
+	params := paymentModel.ListReviewPaymentsParams{
+		MerchantID: p.MerchantID,
+		Email:      query.Get("email"),
+		Currency:   query.Get("currency"),
+	}
+
+	userID, err := strconv.ParseInt(query.Get("user_id"), 10, 64)
+	if err != nil && query.Get("user_id") != "" {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid user id")
+		return
+	}
+	params.UserID = userID
+	minScore, err := strconv.ParseInt(query.Get("min_score"), 10, 32)
+	if err != nil && query.Get("min_score") != "" {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid min score")
+		return
+	}
+	params.MinScore = int32(minScore)
+	limit, err := pageLimit(query)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid limit")
+		return
+	}
+	params.RowLimit = int32(limit)
+	if cursor, cursorErr := strconv.Atoi(query.Get("cursor")); cursorErr == nil {
+		params.ID = int64(cursor)
+	}
+
+	ps, err := a.paymentStore.ListReviewPayments(r.Context(), params)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't find payment")
+		return
+	}
+	if ps == nil {
+		ps = []paymentModel.Payment{}
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, ps)
+}
+
+// POST /admin/reviews/{id}/approve - approves payment, it goes back to new status
+func (a *API) approveReview(w http.ResponseWriter, r *http.Request) {
+	a.reviewPayment(w, r, paymentModel.ReviewDecisionApproved)
+}
+
+// POST /admin/reviews/{id}/decline - declines payment, it goes to failure status
+func (a *API) declineReview(w http.ResponseWriter, r *http.Request) {
+	a.reviewPayment(w, r, paymentModel.ReviewDecisionDeclined)
+}
+
+func (a *API) reviewPayment(w http.ResponseWriter, r *http.Request, decision paymentModel.ReviewDecision) {
+	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid payment id")
+		return
+	}
+
+	rr := reviewRequest{}
+	if err = render.DecodeJSON(r.Body, &rr); err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid request body, can't decode it to review")
+		return
+	}
+	if rr.Note == "" {
+		SendErrorJSON(w, r, http.StatusBadRequest, errors.New("no note provided"), "invalid review note")
+		return
+	}
+
+	reviewer, _ := principalFrom(r.Context())
+	review, err := a.processor.ReviewPayment(r.Context(), actorOf(r), reviewer.MerchantID, int64(paymentID), decision, reviewer.Subject, rr.Note)
+	if err != nil {
+		SendProcessorErrorJSON(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, &review)
+}
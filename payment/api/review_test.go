@@ -0,0 +1,369 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/semka95/payment-service/payment/processor"
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+func TestListReviews(t *testing.T) {
+	api := API{}
+	req := new(http.Request)
+
+	cases := []struct {
+		description    string
+		mockedStore    *postgres.QuerierMock
+		query          string
+		checkMockCalls func(tr *postgres.QuerierMock)
+		checkResponse  func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "success",
+			mockedStore: &postgres.QuerierMock{
+				ListReviewPaymentsFunc: func(ctx context.Context, arg postgres.ListReviewPaymentsParams) ([]postgres.Payment, error) {
+					return tPayments, nil
+				},
+			},
+			query: "?user_id=2&email=test@example.com&currency=usd&min_score=50&limit=5&cursor=3",
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.ListReviewPaymentsCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, postgres.ListReviewPaymentsParams{
+					UserID:   2,
+					Email:    "test@example.com",
+					Currency: "usd",
+					MinScore: 50,
+					ID:       3,
+					RowLimit: 5,
+				}, calls[0].Arg)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := make([]postgres.Payment, 0)
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.ElementsMatch(t, tPayments, result)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "no filters, empty queue",
+			mockedStore: &postgres.QuerierMock{
+				ListReviewPaymentsFunc: func(ctx context.Context, arg postgres.ListReviewPaymentsParams) ([]postgres.Payment, error) {
+					return nil, nil
+				},
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.ListReviewPaymentsCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, postgres.ListReviewPaymentsParams{RowLimit: 10}, calls[0].Arg)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.JSONEq(t, "[]", rec.Body.String())
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description:    "bad user id",
+			mockedStore:    &postgres.QuerierMock{},
+			query:          "?user_id=bad",
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid user id", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "limit above maximum",
+			mockedStore: &postgres.QuerierMock{
+				ListReviewPaymentsFunc: func(ctx context.Context, arg postgres.ListReviewPaymentsParams) ([]postgres.Payment, error) {
+					return nil, nil
+				},
+			},
+			query: "?limit=100000",
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.ListReviewPaymentsCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, int32(maxPageLimit), calls[0].Arg.RowLimit)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description:    "negative limit",
+			mockedStore:    &postgres.QuerierMock{},
+			query:          "?limit=-1",
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid limit", jsonErr.Details)
+				assert.Equal(t, `limit must be positive integer, got "-1"`, jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "repository server error",
+			mockedStore: &postgres.QuerierMock{
+				ListReviewPaymentsFunc: func(ctx context.Context, arg postgres.ListReviewPaymentsParams) ([]postgres.Payment, error) {
+					return nil, fmt.Errorf("server error")
+				},
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't find payment", jsonErr.Details)
+				assert.Equal(t, "server error", jsonErr.Error)
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api.paymentStore = tc.mockedStore
+
+			req = httptest.NewRequest("GET", "/admin/reviews"+tc.query, http.NoBody)
+
+			rec := httptest.NewRecorder()
+			api.listReviews(rec, req)
+
+			tc.checkMockCalls(tc.mockedStore)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
+
+func TestReviewPayment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	api := API{}
+	req := new(http.Request)
+	c := chi.NewRouteContext()
+
+	createReview := func(ctx context.Context, arg postgres.CreatePaymentReviewParams) (postgres.PaymentReview, error) {
+		return postgres.PaymentReview{
+			ID:             1,
+			PaymentID:      arg.PaymentID,
+			Reviewer:       arg.Reviewer,
+			ReviewDecision: arg.ReviewDecision,
+			Note:           arg.Note,
+			CreatedAt:      time.Now().Truncate(time.Millisecond).UTC(),
+		}, nil
+	}
+
+	cases := []struct {
+		description    string
+		mockedStore    *postgres.QuerierMock
+		handler        func(a *API) http.HandlerFunc
+		reqBody        string
+		expectSQL      func(mock sqlmock.Sqlmock)
+		checkMockCalls func(tr *postgres.QuerierMock)
+		checkResponse  func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "approve",
+			mockedStore: &postgres.QuerierMock{
+				ReviewPaymentFunc: func(ctx context.Context, arg postgres.ReviewPaymentParams) (int64, error) {
+					return 1, nil
+				},
+				CreatePaymentReviewFunc: createReview,
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+					return nil, nil
+				},
+			},
+			handler: func(a *API) http.HandlerFunc { return a.approveReview },
+			reqBody: `{"note":"customer verified"}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.ReviewPaymentCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, postgres.ReviewPaymentParams{ID: 2, PaymentStatus: postgres.ValidStatusNew, MerchantID: 1}, calls[0].Arg)
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := postgres.PaymentReview{}
+				err = json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, "operator", result.Reviewer)
+				assert.Equal(t, postgres.ReviewDecisionApproved, result.ReviewDecision)
+				assert.Equal(t, "customer verified", result.Note)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "decline",
+			mockedStore: &postgres.QuerierMock{
+				ReviewPaymentFunc: func(ctx context.Context, arg postgres.ReviewPaymentParams) (int64, error) {
+					return 1, nil
+				},
+				CreatePaymentReviewFunc: createReview,
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+					return nil, nil
+				},
+			},
+			handler: func(a *API) http.HandlerFunc { return a.declineReview },
+			reqBody: `{"note":"stolen card"}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.ReviewPaymentCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, postgres.ReviewPaymentParams{ID: 2, PaymentStatus: postgres.ValidStatusFailure, MerchantID: 1}, calls[0].Arg)
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := postgres.PaymentReview{}
+				err = json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, postgres.ReviewDecisionDeclined, result.ReviewDecision)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description:    "empty note",
+			mockedStore:    &postgres.QuerierMock{},
+			handler:        func(a *API) http.HandlerFunc { return a.approveReview },
+			reqBody:        `{}`,
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid review note", jsonErr.Details)
+				assert.Equal(t, "no note provided", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "payment is not in review",
+			mockedStore: &postgres.QuerierMock{
+				ReviewPaymentFunc: func(ctx context.Context, arg postgres.ReviewPaymentParams) (int64, error) {
+					return 0, nil
+				},
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
+					return postgres.ValidStatusSuccess, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+					return nil, nil
+				},
+			},
+			handler: func(a *API) http.HandlerFunc { return a.approveReview },
+			reqBody: `{"note":"customer verified"}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.CreatePaymentReviewCalls()))
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't review payment", jsonErr.Details)
+				assert.Equal(t, "payment is not in review, it has success status", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "payment not found",
+			mockedStore: &postgres.QuerierMock{
+				ReviewPaymentFunc: func(ctx context.Context, arg postgres.ReviewPaymentParams) (int64, error) {
+					return 0, nil
+				},
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
+					return "", sql.ErrNoRows
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+				CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+					return nil, nil
+				},
+			},
+			handler: func(a *API) http.HandlerFunc { return a.approveReview },
+			reqBody: `{"note":"customer verified"}`,
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				err = mock.ExpectationsWereMet()
+				assert.NoError(t, err)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err = json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "payment not found", jsonErr.Details)
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			api.processor = processor.New(tc.mockedStore, db, nil, nil)
+
+			req = httptest.NewRequest("POST", "/admin/reviews/{id}/approve", bytes.NewBufferString(tc.reqBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			c.Reset()
+			c.URLParams.Add("id", "2")
+			ctx := context.WithValue(req.Context(), principalCtxKey, principal{Method: methodUser, Subject: "operator", MerchantID: 1, Role: postgres.UserRoleOperator})
+			req = req.WithContext((context.WithValue(ctx, chi.RouteCtxKey, c)))
+
+			tc.expectSQL(mock)
+
+			rec := httptest.NewRecorder()
+			tc.handler(&api)(rec, req)
+
+			tc.checkMockCalls(tc.mockedStore)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
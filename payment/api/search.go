@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+
+	"github.com/semka95/payment-service/payment/search"
+)
+
+// Search limits, found payments are counted exactly up to searchCountLimit, total above it isn't counted
+const (
+	maxSearchIDs     = 100
+	searchCountLimit = 10000
+)
+
+// searchPage is page of found payments, Total is exact number of all found payments,
+// it is searchCountLimit lower bound if TotalExact is false
+type searchPage struct {
+	paymentPage
+	Total      int64 `json:"total"`
+	TotalExact bool  `json:"total_exact"`
+}
+
+// GET /admin/payments?id=1,2&user_id=2&email_prefix=test&status=new&currency=usd&min_amount=10&limit=5&cursor=eyJpZCI6NX0 - searches payments of all users of caller's merchant
+func (a *API) searchPayments(w http.ResponseWriter, r *http.Request) {
+	q, err := pageRequest(r)
+	if err == nil && (q.Sort != search.SortID || q.Desc) {
+		err = errors.New("search results are sorted by id")
+	}
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid page")
+		return
+	}
+	f, err := searchFilterRequest(r)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid filter")
+		return
+	}
+
+	p, _ := principalFrom(r.Context())
+	q.Filter = f
+	q.MerchantID = p.MerchantID
+
+	total, err := search.CountUpTo(r.Context(), a.db, q.Filter, searchCountLimit+1)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't count payments")
+		return
+	}
+
+	rows := q
+	rows.Limit++
+	ts, err := search.List(r.Context(), a.db, rows)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't find payment")
+		return
+	}
+
+	page := searchPage{
+		paymentPage: newPaymentPage(ts, q),
+		Total:       total,
+		TotalExact:  total <= searchCountLimit,
+	}
+	if !page.TotalExact {
+		page.Total = searchCountLimit
+	}
+	setPageLinks(w, r, page.NextCursor, page.PrevCursor)
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, page)
+}
+
+// searchFilterRequest reads payment filters and filters of all users payments from query
+func searchFilterRequest(r *http.Request) (search.Filter, error) {
+	f, err := filterRequest(r)
+	if err != nil {
+		return f, err
+	}
+	if f.IDs, err = queryIDs(r.URL.Query(), "id"); err != nil {
+		return f, err
+	}
+	if f.UserIDs, err = queryIDs(r.URL.Query(), "user_id"); err != nil {
+		return f, err
+	}
+	f.EmailPrefix = r.URL.Query().Get("email_prefix")
+
+	return f, nil
+}
+
+// queryIDs reads positive ids from query, parameter may be repeated or have comma separated ids
+func queryIDs(query url.Values, name string) ([]int64, error) {
+	ids := make([]int64, 0)
+	for _, v := range query[name] {
+		for _, s := range strings.Split(v, ",") {
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || id < 1 {
+				return nil, fmt.Errorf("%s must be positive integer, got %q", name, s)
+			}
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > maxSearchIDs {
+		return nil, fmt.Errorf("no more than %d %s values allowed", maxSearchIDs, name)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchPayments(t *testing.T) {
+	count := func(n int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"count"}).AddRow(n)
+	}
+	createdFrom := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		description   string
+		query         string
+		expectSQL     func(mock sqlmock.Sqlmock)
+		checkResponse func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "all filters",
+			query: "?id=1,2&id=3&user_id=2&email_prefix=te_st%25&status=new&currency=usd&min_amount=10.5" +
+				"&created_from=2022-05-01T03:00:00%2B03:00&metadata%5Border%5D=A-1&limit=5",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				filter := "WHERE merchant_id = $1 AND id = ANY($2) AND user_id = ANY($3) AND email LIKE $4 AND payment_status = ANY($5::valid_status[])" +
+					" AND currency = $6 AND amount >= $7 AND created_at >= $8 AND metadata @> $9::jsonb"
+				args := []driver.Value{int64(1), pq.Array([]int64{1, 2, 3}), pq.Array([]int64{2}), `te\_st\%%`, pq.Array([]string{"new"}),
+					"usd", decimal.RequireFromString("10.5"), createdFrom, `{"order":"A-1"}`}
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM (SELECT 1 FROM payments " + filter + " LIMIT $10) AS matched")).
+					WithArgs(append(args, searchCountLimit+1)...).
+					WillReturnRows(count(3))
+				mock.ExpectQuery(regexp.QuoteMeta(filter + " ORDER BY id ASC LIMIT $10")).
+					WithArgs(append(args, 6)...).
+					WillReturnRows(paymentRows(tPayments...))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := searchPage{}
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, tPayments, result.Items)
+				assert.Equal(t, int64(3), result.Total)
+				assert.True(t, result.TotalExact)
+				assert.Nil(t, result.NextCursor)
+				assert.Nil(t, result.PrevCursor)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "next page, total estimated",
+			query:       "?limit=2&cursor=" + cursor{ID: 7}.encode(),
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM (SELECT 1 FROM payments WHERE merchant_id = $1 LIMIT $2) AS matched")).
+					WithArgs(int64(1), searchCountLimit+1).
+					WillReturnRows(count(searchCountLimit + 1))
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE merchant_id = $1 AND id > $2 ORDER BY id ASC LIMIT $3")).
+					WithArgs(int64(1), int64(7), 3).
+					WillReturnRows(paymentRows(tPayments...))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := searchPage{}
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, tPayments[:2], result.Items)
+				assert.Equal(t, int64(searchCountLimit), result.Total)
+				assert.False(t, result.TotalExact)
+				require.NotNil(t, result.NextCursor)
+				assert.Equal(t, cursor{ID: 2}.encode(), *result.NextCursor)
+				require.NotNil(t, result.PrevCursor)
+				assert.Equal(t, cursor{ID: 1, Before: true}.encode(), *result.PrevCursor)
+				assert.Contains(t, rec.Header().Get("Link"), `rel="next"`)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "previous page",
+			query:       "?limit=2&cursor=" + cursor{ID: 4, Before: true, Incl: true}.encode(),
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*)")).WillReturnRows(count(10))
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE merchant_id = $1 AND id <= $2 ORDER BY id DESC LIMIT $3")).
+					WithArgs(int64(1), int64(4), 3).
+					WillReturnRows(paymentRows(tPayments[2], tPayments[1], tPayments[0]))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				result := searchPage{}
+				err := json.NewDecoder(rec.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, tPayments[1:], result.Items)
+				require.NotNil(t, result.PrevCursor)
+				assert.Equal(t, cursor{ID: 2, Before: true}.encode(), *result.PrevCursor)
+				require.NotNil(t, result.NextCursor)
+				assert.Equal(t, cursor{ID: 3}.encode(), *result.NextCursor)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "bad id",
+			query:       "?id=1,bad",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid filter", jsonErr.Details)
+				assert.Equal(t, `id must be positive integer, got "bad"`, jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "bad user id",
+			query:       "?user_id=0",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid filter", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "sort isn't supported",
+			query:       "?sort=-amount",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid page", jsonErr.Details)
+				assert.Equal(t, "search results are sorted by id", jsonErr.Error)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "bad status",
+			query:       "?status=unknown",
+			expectSQL:   func(mock sqlmock.Sqlmock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid filter", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description: "count server error",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*)")).WillReturnError(fmt.Errorf("server error"))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't count payments", jsonErr.Details)
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+		{
+			description: "search server error",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*)")).WillReturnRows(count(1))
+				mock.ExpectQuery(regexp.QuoteMeta("ORDER BY id ASC")).WillReturnError(fmt.Errorf("server error"))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't find payment", jsonErr.Details)
+				assert.Equal(t, "server error", jsonErr.Error)
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+			api := API{db: db}
+			tc.expectSQL(mock)
+
+			req := httptest.NewRequest("GET", "/admin/payments"+tc.query, http.NoBody)
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}))
+
+			rec := httptest.NewRecorder()
+			api.searchPayments(rec, req)
+
+			tc.checkResponse(rec)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/semka95/payment-service/payment/event"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Streams configures server-sent event streams and waits of payment change, Heartbeat is interval of comments
+// that keep idle stream open, WriteTimeout is server write timeout that wait and HTTP/2 stream must end before
+type Streams struct {
+	Broadcaster  *event.Broadcaster
+	Heartbeat    time.Duration
+	WriteTimeout time.Duration
+}
+
+// defaultHeartbeat is used when heartbeat interval isn't set
+const defaultHeartbeat = 15 * time.Second
+
+// resumePageSize is number of recorded events read at once when resumed stream is replayed
+const resumePageSize = 1000
+
+// GET /payment/{id}/events/stream - streams status changes of payment as server-sent events,
+// Last-Event-ID header or last_event_id parameter replays changes made after that event
+func (a *API) streamPaymentEvents(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid payment id")
+		return
+	}
+
+	p, _ := principalFrom(r.Context())
+	payment, err := a.paymentStore.GetPaymentByID(r.Context(), paymentModel.GetPaymentByIDParams{ID: int64(paymentID), MerchantID: p.MerchantID})
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !canAccessUser(r.Context(), payment.UserID)) {
+		SendErrorJSON(w, r, http.StatusNotFound, fmt.Errorf("payment %d not found", paymentID), "payment not found")
+		return
+	}
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't get payment")
+		return
+	}
+
+	recorded := paymentModel.ListPaymentEventsParams{PaymentID: payment.ID, MerchantID: p.MerchantID}
+	a.streamEvents(w, r, recorded, func(e event.Event) bool {
+		return e.PaymentID == payment.ID
+	})
+}
+
+// GET /user/{user_id}/payment/events/stream - streams status changes of user payments as server-sent events,
+// Last-Event-ID header or last_event_id parameter replays changes made after that event
+func (a *API) streamUserEvents(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "user_id"))
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid user id")
+		return
+	}
+	if !canAccessUser(r.Context(), int64(userID)) {
+		SendErrorJSON(w, r, http.StatusForbidden, fmt.Errorf("can't access payments of %d user id", userID), "forbidden")
+		return
+	}
+
+	p, _ := principalFrom(r.Context())
+	recorded := paymentModel.ListPaymentEventsParams{UserID: int64(userID), MerchantID: p.MerchantID}
+	a.streamEvents(w, r, recorded, func(e event.Event) bool {
+		return e.UserID == int64(userID) && e.MerchantID == p.MerchantID
+	})
+}
+
+// streamEvents writes events that match filter until client leaves or broadcaster is closed on shutdown,
+// events recorded after the last event are replayed first page by page, subscription is made when replay
+// catches up, so long replay doesn't overflow it, stream of slow client is closed, so it has to resume
+func (a *API) streamEvents(w http.ResponseWriter, r *http.Request, recorded paymentModel.ListPaymentEventsParams, filter func(event.Event) bool) {
+	if a.streams.Broadcaster == nil {
+		SendErrorJSON(w, r, http.StatusServiceUnavailable, errors.New("event streams are disabled"), "can't stream events")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		SendErrorJSON(w, r, http.StatusInternalServerError, errors.New("response can't be flushed"), "can't stream events")
+		return
+	}
+	lastID, resume, err := lastEventID(r)
+	if err != nil {
+		SendErrorJSON(w, r, http.StatusBadRequest, err, "invalid Last-Event-ID")
+		return
+	}
+
+	var sub *event.Subscription
+	defer func() {
+		if sub != nil {
+			sub.Close()
+		}
+	}()
+	var replay []paymentModel.PaymentEvent
+	if resume {
+		recorded.AfterID = lastID
+		recorded.RowLimit = resumePageSize
+		replay, err = a.paymentStore.ListPaymentEvents(r.Context(), recorded)
+		if err != nil {
+			SendErrorJSON(w, r, http.StatusInternalServerError, err, "can't get payment events")
+			return
+		}
+	} else {
+		sub = a.streams.Broadcaster.Subscribe(filter)
+	}
+
+	// stream that can't get rid of server write timeout is ended before it, so client resumes from the last event
+	var deadline <-chan time.Time
+	if a.streams.WriteTimeout > 0 && !clearWriteDeadline(r) {
+		timer := time.NewTimer(a.streams.WriteTimeout - waitMargin)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// the same event may be both replayed and published, it is sent once, event published after subscription
+	// is recorded just before it, so only the page read before subscription and pages read after it are kept
+	replayed := make(map[int64]bool)
+	for resume {
+		if sub == nil {
+			replayed = make(map[int64]bool, len(replay))
+		}
+		for _, e := range replay {
+			replayed[e.ID] = true
+			recorded.AfterID = e.ID
+			if err = writeEvent(w, event.PaymentEvent(e)); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		// short page means replay caught up, events recorded before subscription is made are read once more,
+		// events recorded later are published to subscription
+		if len(replay) < resumePageSize {
+			if sub != nil {
+				break
+			}
+			sub = a.streams.Broadcaster.Subscribe(filter)
+		}
+		select {
+		case <-deadline:
+			return
+		default:
+		}
+		// stream that can't read the next page is ended, so client resumes from the last replayed event
+		replay, err = a.paymentStore.ListPaymentEvents(r.Context(), recorded)
+		if err != nil {
+			return
+		}
+	}
+
+	interval := a.streams.Heartbeat
+	if interval <= 0 {
+		interval = defaultHeartbeat
+	}
+	heartbeat := time.NewTicker(interval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline:
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if replayed[e.ID] {
+				continue
+			}
+			err = writeEvent(w, e)
+		case <-heartbeat.C:
+			_, err = io.WriteString(w, ": heartbeat\n\n")
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes event in server-sent events format, event id is sequence number of change in event log
+func writeEvent(w io.Writer, e event.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+
+	return err
+}
+
+// lastEventID reads id of the last event client got from Last-Event-ID header or last_event_id parameter,
+// parameter is used by clients that can't set headers on the first connection
+func lastEventID(r *http.Request) (int64, bool, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+	if v == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, fmt.Errorf("event id %q is not id of payment event", v)
+	}
+
+	return id, true, nil
+}
+
+// ConnContext is http.Server ConnContext hook, it keeps connection in context of its requests,
+// so event stream can clear write deadline the server sets on it
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connCtxKey, c)
+}
+
+// clearWriteDeadline removes server write timeout from connection of HTTP/1 request, HTTP/2 stream
+// has its own write timer that can't be cleared, so false is returned for it
+func clearWriteDeadline(r *http.Request) bool {
+	c, ok := r.Context().Value(connCtxKey).(net.Conn)
+	if !ok || r.ProtoMajor != 1 {
+		return false
+	}
+
+	return c.SetWriteDeadline(time.Time{}) == nil
+}
@@ -0,0 +1,643 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/semka95/payment-service/payment/event"
+	"github.com/semka95/payment-service/payment/processor"
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+// eventText is event in server-sent events format
+func eventText(t *testing.T, e event.Event) string {
+	data, err := json.Marshal(e)
+	require.NoError(t, err)
+	return fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
+
+// changedPayment returns copy of payment in status with next version
+func changedPayment(p postgres.Payment, status postgres.ValidStatus, version int64) postgres.Payment {
+	p.PaymentStatus = status
+	p.Version = version
+	p.UpdatedAt = p.UpdatedAt.Add(time.Duration(version) * time.Second)
+	return p
+}
+
+// recordedEvent returns event of payment change with id in event log
+func recordedEvent(id int64, p postgres.Payment) event.Event {
+	t := event.TypeStatusChanged
+	if p.PaymentStatus == postgres.ValidStatusExpired {
+		t = event.TypeExpired
+	}
+	return event.PaymentEvent(postgres.PaymentEvent{
+		ID:            id,
+		EventType:     string(t),
+		PaymentID:     p.ID,
+		UserID:        p.UserID,
+		MerchantID:    p.MerchantID,
+		PaymentStatus: p.PaymentStatus,
+		Version:       p.Version,
+		CreatedAt:     p.UpdatedAt,
+	})
+}
+
+// eventRecord returns event log record of event
+func eventRecord(e event.Event) postgres.PaymentEvent {
+	return postgres.PaymentEvent{
+		ID:            e.ID,
+		EventType:     string(e.Type),
+		PaymentID:     e.PaymentID,
+		UserID:        e.UserID,
+		MerchantID:    e.MerchantID,
+		PaymentStatus: e.Status,
+		Version:       e.Version,
+		CreatedAt:     e.CreatedAt,
+	}
+}
+
+func TestStreamPaymentEvents(t *testing.T) {
+	api := API{}
+	owner := principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}
+	found := func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+		return tPayments[0], nil
+	}
+	success := recordedEvent(1001, changedPayment(tPayments[0], postgres.ValidStatusSuccess, 2))
+	failure := recordedEvent(1003, changedPayment(tPayments[0], postgres.ValidStatusFailure, 3))
+	other := recordedEvent(1002, changedPayment(tPayments[1], postgres.ValidStatusFailure, 2))
+
+	// full page of recorded events is followed by the next page
+	page := make([]postgres.PaymentEvent, resumePageSize)
+	var pageText strings.Builder
+	for i := range page {
+		e := recordedEvent(int64(2001+i), changedPayment(tPayments[0], postgres.ValidStatusSuccess, 2))
+		page[i] = eventRecord(e)
+		pageText.WriteString(eventText(t, e))
+	}
+	afterPage := recordedEvent(int64(2001+resumePageSize), changedPayment(tPayments[0], postgres.ValidStatusFailure, 3))
+
+	cases := []struct {
+		description    string
+		mockedStore    *postgres.QuerierMock
+		id             string
+		lastEventID    string
+		query          string
+		principal      principal
+		published      []event.Event
+		checkMockCalls func(tr *postgres.QuerierMock)
+		checkResponse  func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "resume",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentByIDFunc: found,
+				ListPaymentEventsFunc: func(ctx context.Context, arg postgres.ListPaymentEventsParams) ([]postgres.PaymentEvent, error) {
+					if arg.AfterID < success.ID {
+						return []postgres.PaymentEvent{eventRecord(success)}, nil
+					}
+					return nil, nil
+				},
+			},
+			id:          "1",
+			lastEventID: "1000",
+			principal:   owner,
+			// replayed change is published too, change of other payment isn't streamed
+			published: []event.Event{success, other, failure},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.ListPaymentEventsCalls()
+				require.Equal(t, 2, len(calls))
+				assert.Equal(t, postgres.ListPaymentEventsParams{
+					AfterID:    1000,
+					MerchantID: 1,
+					PaymentID:  1,
+					RowLimit:   resumePageSize,
+				}, calls[0].Arg)
+				// replay is read once more after subscription
+				assert.Equal(t, success.ID, calls[1].Arg.AfterID)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, eventText(t, success)+eventText(t, failure), rec.Body.String())
+				assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "resume over several pages",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentByIDFunc: found,
+				ListPaymentEventsFunc: func(ctx context.Context, arg postgres.ListPaymentEventsParams) ([]postgres.PaymentEvent, error) {
+					switch arg.AfterID {
+					case 2000:
+						return page, nil
+					case page[len(page)-1].ID:
+						return []postgres.PaymentEvent{eventRecord(afterPage)}, nil
+					}
+					return nil, nil
+				},
+			},
+			id:          "1",
+			lastEventID: "2000",
+			principal:   owner,
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				calls := tr.ListPaymentEventsCalls()
+				require.Equal(t, 3, len(calls))
+				assert.Equal(t, int64(2000), calls[0].Arg.AfterID)
+				assert.Equal(t, page[len(page)-1].ID, calls[1].Arg.AfterID)
+				assert.Equal(t, int32(resumePageSize), calls[1].Arg.RowLimit)
+				assert.Equal(t, afterPage.ID, calls[2].Arg.AfterID)
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, pageText.String()+eventText(t, afterPage), rec.Body.String())
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "next page error",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentByIDFunc: found,
+				ListPaymentEventsFunc: func(ctx context.Context, arg postgres.ListPaymentEventsParams) ([]postgres.PaymentEvent, error) {
+					if arg.AfterID == 2000 {
+						return page, nil
+					}
+					return nil, fmt.Errorf("server error")
+				},
+			},
+			id:          "1",
+			lastEventID: "2000",
+			principal:   owner,
+			// stream is ended after the replayed page, so client resumes from its last event
+			published: []event.Event{afterPage},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 2, len(tr.ListPaymentEventsCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, pageText.String(), rec.Body.String())
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "resume by parameter",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentByIDFunc: found,
+				ListPaymentEventsFunc: func(ctx context.Context, arg postgres.ListPaymentEventsParams) ([]postgres.PaymentEvent, error) {
+					return nil, nil
+				},
+			},
+			id:        "1",
+			query:     "?last_event_id=1000",
+			principal: owner,
+			published: []event.Event{success},
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 2, len(tr.ListPaymentEventsCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, eventText(t, success), rec.Body.String())
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "new stream",
+			mockedStore: &postgres.QuerierMock{GetPaymentByIDFunc: found},
+			id:          "1",
+			principal:   owner,
+			checkMockCalls: func(tr *postgres.QuerierMock) {
+				assert.Equal(t, 0, len(tr.ListPaymentEventsCalls()))
+			},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, "", rec.Body.String())
+				assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description:    "invalid last event id",
+			mockedStore:    &postgres.QuerierMock{GetPaymentByIDFunc: found},
+			id:             "1",
+			lastEventID:    "abc",
+			principal:      owner,
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "invalid Last-Event-ID", jsonErr.Details)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+		{
+			description:    "payment of other user",
+			mockedStore:    &postgres.QuerierMock{GetPaymentByIDFunc: found},
+			id:             "1",
+			principal:      principal{Method: methodBearer, Subject: "7", MerchantID: 1, UserID: 7},
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+		{
+			description: "not found",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+					return postgres.Payment{}, sql.ErrNoRows
+				},
+			},
+			id:             "1",
+			principal:      owner,
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+		{
+			description: "repository server error",
+			mockedStore: &postgres.QuerierMock{
+				GetPaymentByIDFunc: found,
+				ListPaymentEventsFunc: func(ctx context.Context, arg postgres.ListPaymentEventsParams) ([]postgres.PaymentEvent, error) {
+					return nil, fmt.Errorf("server error")
+				},
+			},
+			id:             "1",
+			lastEventID:    "1000",
+			principal:      owner,
+			checkMockCalls: func(tr *postgres.QuerierMock) {},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "can't get payment events", jsonErr.Details)
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			b := event.NewBroadcaster(10)
+			api.streams = Streams{Broadcaster: b}
+			api.paymentStore = tc.mockedStore
+			if list := tc.mockedStore.ListPaymentEventsFunc; list != nil {
+				// subscription is made after short page, events are published before replay is read once more,
+				// so they are streamed before broadcaster is closed
+				caughtUp := false
+				tc.mockedStore.ListPaymentEventsFunc = func(ctx context.Context, arg postgres.ListPaymentEventsParams) ([]postgres.PaymentEvent, error) {
+					if caughtUp {
+						for _, e := range tc.published {
+							b.Publish(ctx, e)
+						}
+						b.Close()
+					}
+					events, err := list(ctx, arg)
+					caughtUp = len(events) < resumePageSize
+					return events, err
+				}
+			} else {
+				b.Close()
+			}
+
+			req := httptest.NewRequest("GET", "/payment/{id}/events/stream"+tc.query, http.NoBody)
+			if tc.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tc.lastEventID)
+			}
+			c := chi.NewRouteContext()
+			c.URLParams.Add("id", tc.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, c))
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, tc.principal))
+
+			rec := httptest.NewRecorder()
+			api.streamPaymentEvents(rec, req)
+
+			tc.checkMockCalls(tc.mockedStore)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
+
+func TestStreamUserEvents(t *testing.T) {
+	api := API{}
+	success := recordedEvent(3, changedPayment(tPayments[0], postgres.ValidStatusSuccess, 2))
+	otherMerchant := recordedEvent(1, changedPayment(tPayments[1], postgres.ValidStatusFailure, 2))
+	otherMerchant.MerchantID = 2
+	otherUser := recordedEvent(2, changedPayment(tPayments[1], postgres.ValidStatusFailure, 2))
+	otherUser.UserID = 3
+
+	cases := []struct {
+		description   string
+		userID        string
+		principal     principal
+		published     []event.Event
+		checkResponse func(rec *httptest.ResponseRecorder)
+	}{
+		{
+			description: "success",
+			userID:      "2",
+			principal:   principal{Method: methodAPIKey, Subject: "1", MerchantID: 1},
+			published:   []event.Event{otherMerchant, otherUser, success},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, eventText(t, success), rec.Body.String())
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "other merchant",
+			userID:      "2",
+			principal:   principal{Method: methodAPIKey, Subject: "2", MerchantID: 2},
+			published:   []event.Event{otherMerchant, otherUser},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, eventText(t, otherMerchant), rec.Body.String())
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			description: "payments of other user",
+			userID:      "2",
+			principal:   principal{Method: methodBearer, Subject: "7", MerchantID: 1, UserID: 7},
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				jsonErr := new(jsonError)
+				err := json.NewDecoder(rec.Body).Decode(jsonErr)
+				require.NoError(t, err)
+				assert.Equal(t, "forbidden", jsonErr.Details)
+				assert.Equal(t, http.StatusForbidden, rec.Code)
+			},
+		},
+		{
+			description: "bad user id",
+			userID:      "bad id",
+			checkResponse: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			b := event.NewBroadcaster(10)
+			api.streams = Streams{Broadcaster: b}
+			calls := 0
+			mockedStore := &postgres.QuerierMock{
+				ListPaymentEventsFunc: func(ctx context.Context, arg postgres.ListPaymentEventsParams) ([]postgres.PaymentEvent, error) {
+					assert.Equal(t, int64(2), arg.UserID)
+					assert.Equal(t, tc.principal.MerchantID, arg.MerchantID)
+					// the second read is made after subscription
+					calls++
+					if calls == 2 {
+						for _, e := range tc.published {
+							b.Publish(ctx, e)
+						}
+						b.Close()
+					}
+					return nil, nil
+				},
+			}
+			api.paymentStore = mockedStore
+
+			req := httptest.NewRequest("GET", "/user/{user_id}/payment/events/stream", http.NoBody)
+			req.Header.Set("Last-Event-ID", "1000")
+			c := chi.NewRouteContext()
+			c.URLParams.Add("user_id", tc.userID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, c))
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, tc.principal))
+
+			rec := httptest.NewRecorder()
+			api.streamUserEvents(rec, req)
+
+			tc.checkResponse(rec)
+		})
+	}
+}
+
+func TestStreamLongReplay(t *testing.T) {
+	// replay is several times longer than subscription buffer and payment keeps changing meanwhile
+	b := event.NewBroadcaster(1)
+	recorded := make([]postgres.PaymentEvent, 0)
+	record := func() event.Event {
+		e := recordedEvent(int64(len(recorded)+1), changedPayment(tPayments[0], postgres.ValidStatusSuccess, 2))
+		recorded = append(recorded, eventRecord(e))
+		return e
+	}
+	for i := 0; i < 3*resumePageSize; i++ {
+		record()
+	}
+	live := recordedEvent(int64(3*resumePageSize+10), changedPayment(tPayments[0], postgres.ValidStatusFailure, 3))
+
+	api := API{
+		streams: Streams{Broadcaster: b},
+		paymentStore: &postgres.QuerierMock{
+			GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+				return tPayments[0], nil
+			},
+			ListPaymentEventsFunc: func(ctx context.Context, arg postgres.ListPaymentEventsParams) ([]postgres.PaymentEvent, error) {
+				switch {
+				case arg.AfterID < 3*resumePageSize:
+					b.Publish(ctx, record())
+				case arg.AfterID == int64(len(recorded)):
+					// replay caught up, the rest is live
+					b.Publish(ctx, live)
+					b.Close()
+				}
+				page := make([]postgres.PaymentEvent, 0)
+				for _, e := range recorded {
+					if e.ID > arg.AfterID && len(page) < int(arg.RowLimit) {
+						page = append(page, e)
+					}
+				}
+				return page, nil
+			},
+		},
+	}
+
+	req := httptest.NewRequest("GET", "/payment/{id}/events/stream", http.NoBody)
+	req.Header.Set("Last-Event-ID", "0")
+	c := chi.NewRouteContext()
+	c.URLParams.Add("id", "1")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, c))
+	req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}))
+
+	rec := httptest.NewRecorder()
+	api.streamPaymentEvents(rec, req)
+
+	var expected strings.Builder
+	for _, e := range recorded {
+		expected.WriteString(eventText(t, event.PaymentEvent(e)))
+	}
+	expected.WriteString(eventText(t, live))
+	assert.Equal(t, 3*resumePageSize+3, len(recorded))
+	assert.Equal(t, expected.String(), rec.Body.String())
+}
+
+func TestStreamHeartbeat(t *testing.T) {
+	api := API{
+		paymentStore: &postgres.QuerierMock{
+			GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+				return tPayments[0], nil
+			},
+		},
+		streams: Streams{Broadcaster: event.NewBroadcaster(10), Heartbeat: time.Millisecond},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "/payment/{id}/events/stream", http.NoBody)
+	c := chi.NewRouteContext()
+	c.URLParams.Add("id", "1")
+	req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, c))
+	req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}))
+
+	rec := httptest.NewRecorder()
+	api.streamPaymentEvents(rec, req)
+
+	assert.True(t, strings.HasPrefix(rec.Body.String(), ": heartbeat\n\n"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestStreamWriteTimeout(t *testing.T) {
+	api := API{
+		paymentStore: &postgres.QuerierMock{
+			GetPaymentByIDFunc: func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+				return tPayments[0], nil
+			},
+		},
+		streams: Streams{Broadcaster: event.NewBroadcaster(10), Heartbeat: 5 * time.Millisecond, WriteTimeout: 50 * time.Millisecond},
+	}
+
+	t.Run("HTTP/1 stream outlives write timeout", func(t *testing.T) {
+		r := chi.NewRouter()
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1})))
+			})
+		})
+		r.Get("/payment/{id}/events/stream", api.streamPaymentEvents)
+		srv := httptest.NewUnstartedServer(r)
+		srv.Config.WriteTimeout = api.streams.WriteTimeout
+		srv.Config.ConnContext = ConnContext
+		srv.Start()
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 4*api.streams.WriteTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/payment/1/events/stream", http.NoBody)
+		require.NoError(t, err)
+		resp, err := srv.Client().Do(req)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+
+		body, err := io.ReadAll(resp.Body)
+		// stream is ended by client, not by broken connection
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Greater(t, strings.Count(string(body), ": heartbeat\n\n"), 10)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("HTTP/2 stream ends before write timeout", func(t *testing.T) {
+		api := api
+		api.streams.WriteTimeout = waitMargin + 20*time.Millisecond
+
+		req := httptest.NewRequest("GET", "/payment/{id}/events/stream", http.NoBody)
+		req.ProtoMajor, req.ProtoMinor = 2, 0
+		c := chi.NewRouteContext()
+		c.URLParams.Add("id", "1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, c))
+		req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}))
+
+		done := make(chan struct{})
+		rec := httptest.NewRecorder()
+		go func() {
+			defer close(done)
+			api.streamPaymentEvents(rec, req)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("stream isn't ended before write timeout")
+		}
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestStatusChangeIsPublished(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	success := recordedEvent(1, changedPayment(tPayments[0], postgres.ValidStatusSuccess, 2))
+	reqB, err := json.Marshal(tUpdate)
+	require.NoError(t, err)
+
+	cases := []struct {
+		description string
+		expectSQL   func(mock sqlmock.Sqlmock)
+		events      []event.Event
+	}{
+		{
+			description: "committed",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			events: []event.Event{success},
+		},
+		{
+			description: "commit failed",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit().WillReturnError(fmt.Errorf("can't commit transaction"))
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			mockedStore := &postgres.QuerierMock{
+				GetPaymentStatusByIDFunc: func(ctx context.Context, arg postgres.GetPaymentStatusByIDParams) (postgres.ValidStatus, error) {
+					return postgres.ValidStatusNew, nil
+				},
+				UpdatePaymentStatusFunc: func(ctx context.Context, arg postgres.UpdatePaymentStatusParams) (int64, error) {
+					return 1, nil
+				},
+				CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+					return []postgres.PaymentEvent{eventRecord(success)}, nil
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			}
+			b := event.NewBroadcaster(10)
+			sub := b.Subscribe(func(e event.Event) bool { return true })
+			api := API{processor: processor.New(mockedStore, db, b, nil)}
+
+			req := httptest.NewRequest("PUT", "/payment/{id}", bytes.NewBuffer(reqB))
+			req.Header.Set("Content-Type", "application/json")
+			c := chi.NewRouteContext()
+			c.URLParams.Add("id", "1")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, c))
+
+			tc.expectSQL(mock)
+
+			rec := httptest.NewRecorder()
+			api.updateStatus(rec, req)
+			b.Close()
+
+			var events []event.Event
+			for e := range sub.Events() {
+				events = append(events, e)
+			}
+			assert.Equal(t, tc.events, events)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
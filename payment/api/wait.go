@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/semka95/payment-service/payment/event"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// maxWait is the longest wait of payment change
+const maxWait = time.Minute
+
+// waitMargin is time left to write response before server write timeout
+const waitMargin = time.Second
+
+// waitLimit returns the longest wait of payment change, response must be written before server write timeout
+func (s Streams) waitLimit() time.Duration {
+	limit := maxWait
+	if s.WriteTimeout > 0 && s.WriteTimeout-waitMargin < limit {
+		limit = s.WriteTimeout - waitMargin
+	}
+	if limit < 0 {
+		return 0
+	}
+
+	return limit
+}
+
+// queryWait reads wait duration from wait parameter, longer wait is cut to limit
+func queryWait(query url.Values, limit time.Duration) (time.Duration, error) {
+	v := query.Get("wait")
+	if v == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(v)
+	if err != nil || wait < 0 {
+		return 0, fmt.Errorf("wait must be non-negative duration, e.g. 30s, got %q", v)
+	}
+	if wait > limit {
+		wait = limit
+	}
+
+	return wait, nil
+}
+
+// waitForChange waits until payment is changed, wait elapses or client leaves, changed payment is read again,
+// subscription must be made before payment is read, so change made in between isn't missed
+func (a *API) waitForChange(ctx context.Context, sub *event.Subscription, payment paymentModel.Payment, wait time.Duration) (paymentModel.Payment, error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return payment, nil
+		case <-timer.C:
+			return payment, nil
+		case e, ok := <-sub.Events():
+			if ok && e.Version <= payment.Version {
+				continue
+			}
+			// subscription is closed on shutdown or when it falls behind, payment may be changed then
+			return a.paymentStore.GetPaymentByID(ctx, paymentModel.GetPaymentByIDParams{ID: payment.ID, MerchantID: payment.MerchantID})
+		}
+	}
+}
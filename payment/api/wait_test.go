@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/semka95/payment-service/payment/event"
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+func TestQueryWait(t *testing.T) {
+	cases := []struct {
+		description string
+		query       string
+		limit       time.Duration
+		wait        time.Duration
+		err         string
+	}{
+		{description: "no wait", query: "fields=id", limit: maxWait},
+		{description: "wait", query: "wait=30s", limit: maxWait, wait: 30 * time.Second},
+		{description: "wait over limit", query: "wait=5m", limit: maxWait, wait: maxWait},
+		{description: "negative wait", query: "wait=-1s", limit: maxWait, err: `wait must be non-negative duration, e.g. 30s, got "-1s"`},
+		{description: "wait without unit", query: "wait=30", limit: maxWait, err: `wait must be non-negative duration, e.g. 30s, got "30"`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			query, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+			wait, err := queryWait(query, tc.limit)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wait, wait)
+		})
+	}
+}
+
+func TestWaitLimit(t *testing.T) {
+	cases := []struct {
+		description  string
+		writeTimeout time.Duration
+		limit        time.Duration
+	}{
+		{description: "no write timeout", limit: maxWait},
+		{description: "long write timeout", writeTimeout: 5 * time.Minute, limit: maxWait},
+		{description: "short write timeout", writeTimeout: 10 * time.Second, limit: 9 * time.Second},
+		{description: "write timeout shorter than margin", writeTimeout: time.Millisecond, limit: 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.limit, Streams{WriteTimeout: tc.writeTimeout}.waitLimit())
+		})
+	}
+}
+
+func TestGetPaymentWait(t *testing.T) {
+	owner := principal{Method: methodAPIKey, Subject: "1", MerchantID: 1}
+	success := changedPayment(tPayments[0], postgres.ValidStatusSuccess, 2)
+	failure := changedPayment(tPayments[0], postgres.ValidStatusFailure, 2)
+
+	cases := []struct {
+		description  string
+		query        string
+		writeTimeout time.Duration
+		principal    principal
+		canceled     bool
+		// payments are returned by consecutive reads, published events are sent after the first read
+		payments  []postgres.Payment
+		published []event.Event
+		closed    bool
+		reads     int
+		status    postgres.ValidStatus
+		code      int
+	}{
+		{
+			description: "status changed",
+			query:       "?wait=30s",
+			principal:   owner,
+			payments:    []postgres.Payment{tPayments[0], success},
+			published:   []event.Event{recordedEvent(1, success)},
+			reads:       2,
+			status:      postgres.ValidStatusSuccess,
+			code:        http.StatusOK,
+		},
+		{
+			description: "wait elapsed",
+			query:       "?wait=10ms",
+			principal:   owner,
+			payments:    []postgres.Payment{tPayments[0]},
+			// event of version that is already read doesn't end wait
+			published: []event.Event{recordedEvent(1, tPayments[0])},
+			reads:     1,
+			status:    postgres.ValidStatusNew,
+			code:      http.StatusOK,
+		},
+		{
+			description:  "wait is cut by write timeout",
+			query:        "?wait=30s",
+			writeTimeout: waitMargin + 10*time.Millisecond,
+			principal:    owner,
+			payments:     []postgres.Payment{tPayments[0]},
+			reads:        1,
+			status:       postgres.ValidStatusNew,
+			code:         http.StatusOK,
+		},
+		{
+			description: "client left",
+			query:       "?wait=30s",
+			principal:   owner,
+			canceled:    true,
+			payments:    []postgres.Payment{tPayments[0]},
+			reads:       1,
+			status:      postgres.ValidStatusNew,
+			code:        http.StatusOK,
+		},
+		{
+			description: "shutdown",
+			query:       "?wait=30s",
+			principal:   owner,
+			payments:    []postgres.Payment{tPayments[0], failure},
+			closed:      true,
+			reads:       2,
+			status:      postgres.ValidStatusFailure,
+			code:        http.StatusOK,
+		},
+		{
+			description: "final status",
+			query:       "?wait=30s",
+			principal:   owner,
+			payments:    []postgres.Payment{success},
+			reads:       1,
+			status:      postgres.ValidStatusSuccess,
+			code:        http.StatusOK,
+		},
+		{
+			description: "payment of other user",
+			query:       "?wait=30s",
+			principal:   principal{Method: methodBearer, Subject: "7", MerchantID: 1, UserID: 7},
+			payments:    []postgres.Payment{tPayments[0]},
+			reads:       1,
+			code:        http.StatusNotFound,
+		},
+		{
+			description: "invalid wait",
+			query:       "?wait=soon",
+			principal:   owner,
+			code:        http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			b := event.NewBroadcaster(10)
+			api := API{streams: Streams{Broadcaster: b, WriteTimeout: tc.writeTimeout}}
+			mockedStore := &postgres.QuerierMock{}
+			mockedStore.GetPaymentByIDFunc = func(ctx context.Context, arg postgres.GetPaymentByIDParams) (postgres.Payment, error) {
+				assert.Equal(t, postgres.GetPaymentByIDParams{ID: 1, MerchantID: 1}, arg)
+				read := len(mockedStore.GetPaymentByIDCalls())
+				if read == 1 {
+					for _, e := range tc.published {
+						b.Publish(ctx, e)
+					}
+					if tc.closed {
+						b.Close()
+					}
+				}
+				return tc.payments[read-1], nil
+			}
+			api.paymentStore = mockedStore
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.canceled {
+				cancel()
+			}
+			req := httptest.NewRequest("GET", "/payment/{id}"+tc.query, http.NoBody)
+			c := chi.NewRouteContext()
+			c.URLParams.Add("id", "1")
+			req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, c))
+			req = req.WithContext(context.WithValue(req.Context(), principalCtxKey, tc.principal))
+
+			start := time.Now()
+			rec := httptest.NewRecorder()
+			api.getPayment(rec, req)
+
+			assert.Less(t, time.Since(start), 5*time.Second)
+			assert.Equal(t, tc.reads, len(mockedStore.GetPaymentByIDCalls()))
+			assert.Equal(t, tc.code, rec.Code)
+			if tc.code != http.StatusOK {
+				return
+			}
+			result := postgres.Payment{}
+			err := json.NewDecoder(rec.Body).Decode(&result)
+			require.NoError(t, err)
+			assert.Equal(t, tc.status, result.PaymentStatus)
+			assert.Equal(t, versionETag(result.Version, false), rec.Header().Get("ETag"))
+		})
+	}
+}
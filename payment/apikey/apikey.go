@@ -0,0 +1,167 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Scope grants access to group of api routes
+type Scope string
+
+// Available scopes
+const (
+	ScopePaymentsCreate       Scope = "payments:create"
+	ScopePaymentsRead         Scope = "payments:read"
+	ScopePaymentsCancel       Scope = "payments:cancel"
+	ScopePaymentsUpdateStatus Scope = "payments:update_status"
+)
+
+// Scopes lists all available scopes
+var Scopes = []Scope{ScopePaymentsCreate, ScopePaymentsRead, ScopePaymentsCancel, ScopePaymentsUpdateStatus}
+
+// Errors returned by manager
+var (
+	ErrNotFound     = errors.New("api key not found")
+	ErrNoName       = errors.New("no name provided")
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// keyPrefix marks service api keys, prefixLength is length of key part stored in plain text to identify key
+const (
+	keyPrefix    = "psk_"
+	prefixLength = len(keyPrefix) + 8
+)
+
+// Manager issues, rotates, revokes and checks api keys, only key hashes are stored
+type Manager struct {
+	store paymentModel.Querier
+}
+
+// NewManager creates api key manager
+func NewManager(store paymentModel.Querier) *Manager {
+	return &Manager{store: store}
+}
+
+// Issue creates new api key of merchant with scopes, plain key is returned only once
+func (m *Manager) Issue(ctx context.Context, merchantID int64, name string, scopes []string) (paymentModel.ApiKey, string, error) {
+	if name == "" {
+		return paymentModel.ApiKey{}, "", ErrNoName
+	}
+	if err := ValidateScopes(scopes); err != nil {
+		return paymentModel.ApiKey{}, "", err
+	}
+
+	key, err := generate()
+	if err != nil {
+		return paymentModel.ApiKey{}, "", err
+	}
+	k, err := m.store.CreateAPIKey(ctx, paymentModel.CreateAPIKeyParams{
+		Name:       name,
+		Prefix:     key[:prefixLength],
+		KeyHash:    Hash(key),
+		Scopes:     scopes,
+		MerchantID: merchantID,
+	})
+	if err != nil {
+		return paymentModel.ApiKey{}, "", err
+	}
+
+	return k, key, nil
+}
+
+// Rotate replaces api key of merchant with new one keeping its name and scopes, old key stops working immediately
+func (m *Manager) Rotate(ctx context.Context, merchantID, id int64) (paymentModel.ApiKey, string, error) {
+	key, err := generate()
+	if err != nil {
+		return paymentModel.ApiKey{}, "", err
+	}
+	k, err := m.store.RotateAPIKey(ctx, paymentModel.RotateAPIKeyParams{
+		ID:         id,
+		Prefix:     key[:prefixLength],
+		KeyHash:    Hash(key),
+		MerchantID: merchantID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return paymentModel.ApiKey{}, "", ErrNotFound
+	}
+	if err != nil {
+		return paymentModel.ApiKey{}, "", err
+	}
+
+	return k, key, nil
+}
+
+// Revoke disables api key of merchant
+func (m *Manager) Revoke(ctx context.Context, merchantID, id int64) error {
+	rows, err := m.store.RevokeAPIKey(ctx, paymentModel.RevokeAPIKeyParams{ID: id, MerchantID: merchantID})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// List returns all api keys of merchant including revoked ones
+func (m *Manager) List(ctx context.Context, merchantID int64) ([]paymentModel.ApiKey, error) {
+	return m.store.ListAPIKeys(ctx, merchantID)
+}
+
+// Authenticate finds active api key by plain key
+func (m *Manager) Authenticate(ctx context.Context, key string) (paymentModel.ApiKey, error) {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return paymentModel.ApiKey{}, ErrNotFound
+	}
+	k, err := m.store.GetAPIKeyByHash(ctx, Hash(key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return paymentModel.ApiKey{}, ErrNotFound
+	}
+
+	return k, err
+}
+
+// ValidateScopes checks that scopes are not empty and known
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: no scopes provided", ErrInvalidScope)
+	}
+	for _, s := range scopes {
+		known := false
+		for _, scope := range Scopes {
+			if Scope(s) == scope {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("%w: %s", ErrInvalidScope, s)
+		}
+	}
+
+	return nil
+}
+
+// Hash returns hex encoded sha256 of key, keys are random so salt is not needed
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func generate() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("can't generate api key: %w", err)
+	}
+
+	return keyPrefix + hex.EncodeToString(b), nil
+}
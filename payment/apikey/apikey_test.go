@@ -0,0 +1,96 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+func TestIssue(t *testing.T) {
+	mockedStore := &postgres.QuerierMock{
+		CreateAPIKeyFunc: func(ctx context.Context, arg postgres.CreateAPIKeyParams) (postgres.ApiKey, error) {
+			return postgres.ApiKey{ID: 1, Name: arg.Name, Prefix: arg.Prefix, KeyHash: arg.KeyHash, Scopes: arg.Scopes, MerchantID: arg.MerchantID}, nil
+		},
+	}
+	m := NewManager(mockedStore)
+
+	k, key, err := m.Issue(context.Background(), 1, "shop", []string{"payments:create", "payments:read"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, keyPrefix))
+	assert.True(t, strings.HasPrefix(key, k.Prefix))
+
+	calls := mockedStore.CreateAPIKeyCalls()
+	require.Equal(t, 1, len(calls))
+	assert.Equal(t, Hash(key), calls[0].Arg.KeyHash)
+	assert.NotContains(t, calls[0].Arg.KeyHash, key)
+	assert.Equal(t, []string{"payments:create", "payments:read"}, calls[0].Arg.Scopes)
+	assert.Equal(t, int64(1), k.MerchantID)
+
+	_, _, err = m.Issue(context.Background(), 1, "", []string{"payments:read"})
+	assert.ErrorIs(t, err, ErrNoName)
+	_, _, err = m.Issue(context.Background(), 1, "shop", []string{"payments:delete"})
+	assert.ErrorIs(t, err, ErrInvalidScope)
+	_, _, err = m.Issue(context.Background(), 1, "shop", nil)
+	assert.ErrorIs(t, err, ErrInvalidScope)
+	assert.Equal(t, 1, len(mockedStore.CreateAPIKeyCalls()))
+}
+
+func TestAuthenticate(t *testing.T) {
+	key := keyPrefix + "secret"
+	mockedStore := &postgres.QuerierMock{
+		GetAPIKeyByHashFunc: func(ctx context.Context, keyHash string) (postgres.ApiKey, error) {
+			if keyHash == Hash(key) {
+				return postgres.ApiKey{ID: 1, Scopes: []string{"payments:read"}}, nil
+			}
+			return postgres.ApiKey{}, sql.ErrNoRows
+		},
+	}
+	m := NewManager(mockedStore)
+
+	k, err := m.Authenticate(context.Background(), key)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"payments:read"}, k.Scopes)
+
+	_, err = m.Authenticate(context.Background(), keyPrefix+"other")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = m.Authenticate(context.Background(), "secret")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 2, len(mockedStore.GetAPIKeyByHashCalls()))
+}
+
+func TestRotateRevoke(t *testing.T) {
+	mockedStore := &postgres.QuerierMock{
+		RotateAPIKeyFunc: func(ctx context.Context, arg postgres.RotateAPIKeyParams) (postgres.ApiKey, error) {
+			if arg.ID != 1 || arg.MerchantID != 1 {
+				return postgres.ApiKey{}, sql.ErrNoRows
+			}
+			return postgres.ApiKey{ID: 1, Prefix: arg.Prefix, KeyHash: arg.KeyHash}, nil
+		},
+		RevokeAPIKeyFunc: func(ctx context.Context, arg postgres.RevokeAPIKeyParams) (int64, error) {
+			if arg.ID != 1 || arg.MerchantID != 1 {
+				return 0, nil
+			}
+			return 1, nil
+		},
+	}
+	m := NewManager(mockedStore)
+
+	k, key, err := m.Rotate(context.Background(), 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, Hash(key), k.KeyHash)
+	_, _, err = m.Rotate(context.Background(), 1, 2)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, _, err = m.Rotate(context.Background(), 2, 1)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, m.Revoke(context.Background(), 1, 1))
+	assert.ErrorIs(t, m.Revoke(context.Background(), 1, 2), ErrNotFound)
+	assert.ErrorIs(t, m.Revoke(context.Background(), 2, 1), ErrNotFound)
+}
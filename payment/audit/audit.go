@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"context"
+	"strconv"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Actor is principal that makes change, Method and Path are request that made it,
+// background workers and CLI have no request
+type Actor struct {
+	Name   string
+	Method string
+	Path   string
+}
+
+// Actors of changes made by service itself
+var (
+	Simulator = Actor{Name: "worker:simulator"}
+	Sweeper   = Actor{Name: "worker:expiry"}
+	Reconcile = Actor{Name: "cli:reconcile"}
+
+	MerchantCommand = Actor{Name: "cli:merchant"}
+	APIKeyCommand   = Actor{Name: "cli:apikey"}
+	UserCommand     = Actor{Name: "cli:user"}
+)
+
+// Actions recorded in audit log
+const (
+	ActionCreatePayment     = "payment.create"
+	ActionCancelPayment     = "payment.cancel"
+	ActionUpdateStatus      = "payment.update_status"
+	ActionExpirePayment     = "payment.expire"
+	ActionApprovePayment    = "payment.approve"
+	ActionDeclinePayment    = "payment.decline"
+	ActionOpenDispute       = "dispute.open"
+	ActionAddEvidence       = "dispute.add_evidence"
+	ActionResolveDispute    = "dispute.resolve"
+	ActionRunReconciliation = "reconciliation.run"
+	ActionIssueAPIKey       = "api_key.issue"
+	ActionRotateAPIKey      = "api_key.rotate"
+	ActionRevokeAPIKey      = "api_key.revoke"
+	ActionAddMerchant       = "merchant.add"
+	ActionAddUser           = "user.add"
+	ActionSetUserPassword   = "user.set_password"
+	ActionSetUserRole       = "user.set_role"
+	ActionDeleteUser        = "user.delete"
+)
+
+// Record writes entry of action made by actor on target of merchant, store must be bound to transaction
+// of the change, so change isn't committed without its entry
+func Record(ctx context.Context, store paymentModel.Querier, actor Actor, merchantID int64, action, target string) error {
+	return store.CreateAuditLog(ctx, paymentModel.CreateAuditLogParams{
+		Actor:      actor.Name,
+		Action:     action,
+		Target:     target,
+		Method:     actor.Method,
+		Path:       actor.Path,
+		MerchantID: merchantID,
+	})
+}
+
+// Payment returns target of payment change
+func Payment(id int64) string {
+	return "payment:" + strconv.FormatInt(id, 10)
+}
+
+// APIKey returns target of api key change
+func APIKey(id int64) string {
+	return "api_key:" + strconv.FormatInt(id, 10)
+}
+
+// Merchant returns target of merchant change
+func Merchant(id int64) string {
+	return "merchant:" + strconv.FormatInt(id, 10)
+}
+
+// User returns target of user change
+func User(id int64) string {
+	return "user:" + strconv.FormatInt(id, 10)
+}
+
+// Reconciliation returns target of reconciliation run
+func Reconciliation(id int64) string {
+	return "reconciliation:" + strconv.FormatInt(id, 10)
+}
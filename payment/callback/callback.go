@@ -0,0 +1,130 @@
+package callback
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Headers of signed callback
+const (
+	HeaderTimestamp = "X-Signature-Timestamp"
+	HeaderNonce     = "X-Signature-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+// maxNonceLength is nonce column size
+const maxNonceLength = 64
+
+// Errors returned when callback can't be trusted
+var (
+	ErrInvalidSignature = errors.New("invalid callback signature")
+	ErrStale            = errors.New("stale callback timestamp")
+	ErrReplay           = errors.New("callback nonce is already used")
+)
+
+// Sign returns hex encoded HMAC-SHA256 of request method and path on separate lines followed by timestamp,
+// nonce and body joined by dots, so signature of one request isn't valid for other payment or route
+func Sign(secret, method, path, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + path + "\n" + timestamp + "." + nonce + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verifier checks signed callbacks of payment system, callback may be signed by any of
+// active secrets, so secret can be rotated without downtime, each secret is bound to merchant
+type Verifier struct {
+	paymentStore paymentModel.Querier
+	logger       *zap.Logger
+	secrets      map[string]int64
+	tolerance    time.Duration
+	now          func() time.Time
+}
+
+// NewVerifier creates callback verifier, secrets map secret to merchant whose payments callbacks signed by it
+// may change, callbacks with timestamp further than tolerance from now are rejected
+func NewVerifier(paymentStore paymentModel.Querier, logger *zap.Logger, secrets map[string]int64, tolerance time.Duration) *Verifier {
+	return &Verifier{
+		paymentStore: paymentStore,
+		logger:       logger,
+		secrets:      secrets,
+		tolerance:    tolerance,
+		now:          time.Now,
+	}
+}
+
+// Verify checks callback timestamp and signature of request method, path and body and records nonce,
+// so the same callback can't be replayed, merchant bound to secret the callback is signed by is returned
+func (v *Verifier) Verify(ctx context.Context, method, path, timestamp, nonce, signature string, body []byte) (int64, error) {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid timestamp", ErrStale)
+	}
+	diff := v.now().Sub(time.Unix(ts, 0))
+	if diff > v.tolerance || diff < -v.tolerance {
+		return 0, ErrStale
+	}
+	if nonce == "" || len(nonce) > maxNonceLength {
+		return 0, fmt.Errorf("%w: invalid nonce", ErrInvalidSignature)
+	}
+
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return 0, ErrInvalidSignature
+	}
+	var merchantID int64
+	for secret, id := range v.secrets {
+		expected, _ := hex.DecodeString(Sign(secret, method, path, timestamp, nonce, body))
+		if hmac.Equal(sig, expected) {
+			merchantID = id
+			break
+		}
+	}
+	if merchantID == 0 {
+		return 0, ErrInvalidSignature
+	}
+
+	// nonce is recorded only for valid signature, so nobody else can burn provider nonces
+	rows, err := v.paymentStore.CreateCallbackNonce(ctx, nonce)
+	if err != nil {
+		return 0, fmt.Errorf("can't save callback nonce: %w", err)
+	}
+	if rows == 0 {
+		return 0, ErrReplay
+	}
+
+	return merchantID, nil
+}
+
+// Run deletes nonces which can't be replayed anymore until context is canceled,
+// nonce must be kept while its timestamp is within tolerance
+func (v *Verifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(v.tolerance)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := v.paymentStore.DeleteCallbackNonces(ctx, v.now().Add(-2*v.tolerance).UTC())
+			if err != nil {
+				v.logger.Error("can't delete callback nonces", zap.Error(err))
+				continue
+			}
+			if count > 0 {
+				v.logger.Debug("callback nonces deleted", zap.Int64("deleted", count))
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package callback
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"payment_status":"success"}`)
+
+	newStore := func(rows int64, err error) *postgres.QuerierMock {
+		return &postgres.QuerierMock{
+			CreateCallbackNonceFunc: func(ctx context.Context, nonce string) (int64, error) {
+				return rows, err
+			},
+		}
+	}
+
+	cases := []struct {
+		description string
+		mockedStore *postgres.QuerierMock
+		timestamp   string
+		nonce       string
+		signature   string
+		merchantID  int64
+		err         error
+		nonceSaved  bool
+	}{
+		{
+			description: "signed by current secret",
+			mockedStore: newStore(1, nil),
+			timestamp:   ts,
+			nonce:       "n1",
+			signature:   Sign("new", "PUT", "/payment/1", ts, "n1", body),
+			merchantID:  1,
+			nonceSaved:  true,
+		},
+		{
+			description: "signed by previous secret",
+			mockedStore: newStore(1, nil),
+			timestamp:   ts,
+			nonce:       "n1",
+			signature:   Sign("old", "PUT", "/payment/1", ts, "n1", body),
+			merchantID:  1,
+			nonceSaved:  true,
+		},
+		{
+			description: "signed by secret of other merchant",
+			mockedStore: newStore(1, nil),
+			timestamp:   ts,
+			nonce:       "n1",
+			signature:   Sign("partner", "PUT", "/payment/1", ts, "n1", body),
+			merchantID:  2,
+			nonceSaved:  true,
+		},
+		{
+			description: "unknown secret",
+			mockedStore: newStore(1, nil),
+			timestamp:   ts,
+			nonce:       "n1",
+			signature:   Sign("other", "PUT", "/payment/1", ts, "n1", body),
+			err:         ErrInvalidSignature,
+		},
+		{
+			description: "signature of other nonce",
+			mockedStore: newStore(1, nil),
+			timestamp:   ts,
+			nonce:       "n2",
+			signature:   Sign("new", "PUT", "/payment/1", ts, "n1", body),
+			err:         ErrInvalidSignature,
+		},
+		{
+			description: "signature of other payment",
+			mockedStore: newStore(1, nil),
+			timestamp:   ts,
+			nonce:       "n1",
+			signature:   Sign("new", "PUT", "/payment/2", ts, "n1", body),
+			err:         ErrInvalidSignature,
+		},
+		{
+			description: "signature of other method",
+			mockedStore: newStore(1, nil),
+			timestamp:   ts,
+			nonce:       "n1",
+			signature:   Sign("new", "POST", "/payment/1", ts, "n1", body),
+			err:         ErrInvalidSignature,
+		},
+		{
+			description: "no nonce",
+			mockedStore: newStore(1, nil),
+			timestamp:   ts,
+			signature:   Sign("new", "PUT", "/payment/1", ts, "", body),
+			err:         ErrInvalidSignature,
+		},
+		{
+			description: "stale timestamp",
+			mockedStore: newStore(1, nil),
+			timestamp:   strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10),
+			nonce:       "n1",
+			signature:   Sign("new", "PUT", "/payment/1", strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10), "n1", body),
+			err:         ErrStale,
+		},
+		{
+			description: "future timestamp",
+			mockedStore: newStore(1, nil),
+			timestamp:   strconv.FormatInt(now.Add(10*time.Minute).Unix(), 10),
+			nonce:       "n1",
+			signature:   Sign("new", "PUT", "/payment/1", strconv.FormatInt(now.Add(10*time.Minute).Unix(), 10), "n1", body),
+			err:         ErrStale,
+		},
+		{
+			description: "replay",
+			mockedStore: newStore(0, nil),
+			timestamp:   ts,
+			nonce:       "n1",
+			signature:   Sign("new", "PUT", "/payment/1", ts, "n1", body),
+			err:         ErrReplay,
+			nonceSaved:  true,
+		},
+		{
+			description: "repository server error",
+			mockedStore: newStore(0, fmt.Errorf("server error")),
+			timestamp:   ts,
+			nonce:       "n1",
+			signature:   Sign("new", "PUT", "/payment/1", ts, "n1", body),
+			err:         fmt.Errorf("can't save callback nonce: server error"),
+			nonceSaved:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			v := NewVerifier(tc.mockedStore, zap.NewNop(), map[string]int64{"new": 1, "old": 1, "partner": 2}, 5*time.Minute)
+			v.now = func() time.Time { return now }
+
+			merchantID, err := v.Verify(context.Background(), "PUT", "/payment/1", tc.timestamp, tc.nonce, tc.signature, body)
+			assert.Equal(t, tc.merchantID, merchantID)
+			switch {
+			case tc.err == nil:
+				assert.NoError(t, err)
+			case tc.err == ErrInvalidSignature || tc.err == ErrStale || tc.err == ErrReplay:
+				assert.ErrorIs(t, err, tc.err)
+			default:
+				assert.EqualError(t, err, tc.err.Error())
+			}
+
+			calls := tc.mockedStore.CreateCallbackNonceCalls()
+			if tc.nonceSaved {
+				assert.Equal(t, 1, len(calls))
+				assert.Equal(t, tc.nonce, calls[0].Nonce)
+			} else {
+				assert.Equal(t, 0, len(calls))
+			}
+		})
+	}
+}
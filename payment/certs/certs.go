@@ -0,0 +1,193 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Options sets server certificate and client certificate verification, client certificates are
+// verified only if ClientCAFile is set, they are optional unless RequireClientCert is set
+type Options struct {
+	CertFile          string
+	KeyFile           string
+	ClientCAFile      string
+	MinVersion        string
+	RequireClientCert bool
+}
+
+// Reloader serves tls configuration and reloads certificates on SIGHUP or files change,
+// failed reload keeps previous certificates
+type Reloader struct {
+	opts       Options
+	minVersion uint16
+	logger     *zap.Logger
+
+	mu       sync.RWMutex
+	config   *tls.Config
+	modTimes map[string]time.Time
+}
+
+// NewReloader creates reloader and loads certificates
+func NewReloader(opts Options, logger *zap.Logger) (*Reloader, error) {
+	minVersion, err := parseVersion(opts.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	if opts.RequireClientCert && opts.ClientCAFile == "" {
+		return nil, errors.New("client ca file is required to verify client certificates")
+	}
+
+	r := &Reloader{
+		opts:       opts,
+		minVersion: minVersion,
+		logger:     logger,
+	}
+	if err = r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// TLSConfig returns server tls configuration, every handshake uses current certificates. GetCertificate
+// lets http.Server know certificate is served by config. HTTP/2 is advertised by config itself, since
+// http.Server doesn't add protocols to configuration it is given on every go version, protocols of
+// config are copied to configuration of each handshake
+func (r *Reloader) TLSConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion: r.minVersion,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return &r.config.Certificates[0], nil
+		},
+	}
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		current := r.config.Clone()
+		r.mu.RUnlock()
+		current.NextProtos = config.NextProtos
+		return current, nil
+	}
+
+	return config
+}
+
+// Reload loads certificates from files
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("can't load certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   r.minVersion,
+		Certificates: []tls.Certificate{cert},
+	}
+	if r.opts.ClientCAFile != "" {
+		pem, readErr := os.ReadFile(r.opts.ClientCAFile)
+		if readErr != nil {
+			return fmt.Errorf("can't read client ca: %w", readErr)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("no certificates found in client ca file")
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if r.opts.RequireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	r.mu.Lock()
+	r.config = config
+	r.modTimes = r.files()
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Run reloads certificates on SIGHUP or when files are changed, files are checked every interval
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.reload("sighup")
+		case <-ticker.C:
+			if r.changed() {
+				r.reload("files changed")
+			}
+		}
+	}
+}
+
+func (r *Reloader) reload(reason string) {
+	if err := r.Reload(); err != nil {
+		// broken files are not reloaded again until they are changed
+		r.mu.Lock()
+		r.modTimes = r.files()
+		r.mu.Unlock()
+		r.logger.Error("can't reload certificates, previous certificates are kept", zap.Error(err), zap.String("reason", reason))
+		return
+	}
+	r.logger.Info("certificates reloaded", zap.String("reason", reason))
+}
+
+// changed reports whether any file modification time differs from last reload
+func (r *Reloader) changed() bool {
+	current := r.files()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for name, modTime := range current {
+		if !r.modTimes[name].Equal(modTime) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Reloader) files() map[string]time.Time {
+	modTimes := make(map[string]time.Time, 3)
+	for _, name := range []string{r.opts.CertFile, r.opts.KeyFile, r.opts.ClientCAFile} {
+		if name == "" {
+			continue
+		}
+		if info, err := os.Stat(name); err == nil {
+			modTimes[name] = info.ModTime()
+		}
+	}
+
+	return modTimes
+}
+
+func parseVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported tls version %q, use 1.2 or 1.3", v)
+	}
+}
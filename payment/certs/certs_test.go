@@ -0,0 +1,227 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newCert(t *testing.T, name string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeFiles(t *testing.T, dir string, server, ca *testCert) Options {
+	t.Helper()
+	opts := Options{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	require.NoError(t, os.WriteFile(opts.CertFile, server.certPEM, 0o600))
+	require.NoError(t, os.WriteFile(opts.KeyFile, server.keyPEM, 0o600))
+	require.NoError(t, os.WriteFile(opts.ClientCAFile, ca.certPEM, 0o600))
+	return opts
+}
+
+func serverCert(t *testing.T, r *Reloader) *x509.Certificate {
+	t.Helper()
+	config, err := r.TLSConfig().GetConfigForClient(nil)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+	return cert
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCert(t, "ca", nil, x509.ExtKeyUsageAny)
+	first := newCert(t, "first", ca, x509.ExtKeyUsageServerAuth)
+	opts := writeFiles(t, dir, first, ca)
+
+	r, err := NewReloader(opts, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, "first", serverCert(t, r).Subject.CommonName)
+	config, err := r.TLSConfig().GetConfigForClient(nil)
+	require.NoError(t, err)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, config.ClientAuth)
+	assert.False(t, r.changed())
+
+	second := newCert(t, "second", ca, x509.ExtKeyUsageServerAuth)
+	writeFiles(t, dir, second, ca)
+	// file systems with coarse modification time may not notice quick rewrite
+	now := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(opts.CertFile, now, now))
+	assert.True(t, r.changed())
+	r.reload("files changed")
+	assert.Equal(t, "second", serverCert(t, r).Subject.CommonName)
+
+	require.NoError(t, os.WriteFile(opts.KeyFile, []byte("broken"), 0o600))
+	r.reload("sighup")
+	assert.Equal(t, "second", serverCert(t, r).Subject.CommonName)
+	assert.False(t, r.changed())
+}
+
+func TestNewReloaderErrors(t *testing.T) {
+	_, err := NewReloader(Options{MinVersion: "1.1"}, zap.NewNop())
+	assert.EqualError(t, err, `unsupported tls version "1.1", use 1.2 or 1.3`)
+
+	_, err = NewReloader(Options{RequireClientCert: true}, zap.NewNop())
+	assert.EqualError(t, err, "client ca file is required to verify client certificates")
+
+	_, err = NewReloader(Options{CertFile: "missing.crt", KeyFile: "missing.key"}, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestClientCertHandshake(t *testing.T) {
+	ca := newCert(t, "ca", nil, x509.ExtKeyUsageAny)
+	server := newCert(t, "localhost", ca, x509.ExtKeyUsageServerAuth)
+	client := newCert(t, "provider", ca, x509.ExtKeyUsageClientAuth)
+	opts := writeFiles(t, t.TempDir(), server, ca)
+	opts.MinVersion = "1.3"
+
+	r, err := NewReloader(opts, zap.NewNop())
+	require.NoError(t, err)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", r.TLSConfig())
+	require.NoError(t, err)
+	defer ln.Close()
+
+	peers := make(chan []*x509.Certificate, 1)
+	go func() {
+		conn, acceptErr := ln.Accept()
+		if acceptErr != nil {
+			peers <- nil
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*tls.Conn)
+		if tlsConn.Handshake() != nil {
+			peers <- nil
+			return
+		}
+		peers <- tlsConn.ConnectionState().PeerCertificates
+	}()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	clientCert, err := tls.X509KeyPair(client.certPEM, client.keyPEM)
+	require.NoError(t, err)
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+		RootCAs:      pool,
+		ServerName:   "localhost",
+		Certificates: []tls.Certificate{clientCert},
+		MinVersion:   tls.VersionTLS13,
+	})
+	require.NoError(t, err)
+	require.NoError(t, conn.Handshake())
+	defer conn.Close()
+
+	got := <-peers
+	require.Equal(t, 1, len(got))
+	assert.Equal(t, "provider", got[0].Subject.CommonName)
+}
+
+func TestServeTLS(t *testing.T) {
+	ca := newCert(t, "ca", nil, x509.ExtKeyUsageAny)
+	server := newCert(t, "localhost", ca, x509.ExtKeyUsageServerAuth)
+	client := newCert(t, "provider", ca, x509.ExtKeyUsageClientAuth)
+	opts := writeFiles(t, t.TempDir(), server, ca)
+
+	r, err := NewReloader(opts, zap.NewNop())
+	require.NoError(t, err)
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = w.Write([]byte(req.TLS.PeerCertificates[0].Subject.CommonName))
+		}),
+		TLSConfig: r.TLSConfig(),
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	served := make(chan error, 1)
+	go func() {
+		// certificate files aren't passed, like in server of service
+		served <- srv.ServeTLS(ln, "", "")
+	}()
+	defer func() {
+		_ = srv.Close()
+		assert.ErrorIs(t, <-served, http.ErrServerClosed)
+	}()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	clientCert, err := tls.X509KeyPair(client.certPEM, client.keyPEM)
+	require.NoError(t, err)
+	httpClient := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{
+			RootCAs:      pool,
+			Certificates: []tls.Certificate{clientCert},
+		},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := httpClient.Get("https://localhost:" + strconv.Itoa(ln.Addr().(*net.TCPAddr).Port))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "provider", string(body))
+	assert.Equal(t, 2, resp.ProtoMajor)
+}
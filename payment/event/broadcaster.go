@@ -0,0 +1,97 @@
+package event
+
+import (
+	"context"
+	"expvar"
+	"sync"
+)
+
+var droppedTotal = expvar.NewInt("payment_event_subscriptions_dropped_total")
+
+// Broadcaster passes published events to subscriptions without blocking publisher, subscription
+// that doesn't keep up with events is closed, subscriber may resume it from the last event it got
+type Broadcaster struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	buffer int
+	closed bool
+}
+
+// Subscription receives events that match its filter until it is closed
+type Subscription struct {
+	b      *Broadcaster
+	c      chan Event
+	filter func(Event) bool
+}
+
+// NewBroadcaster creates broadcaster, buffer is number of events subscription may fall behind by
+func NewBroadcaster(buffer int) *Broadcaster {
+	return &Broadcaster{
+		subs:   make(map[*Subscription]struct{}),
+		buffer: buffer,
+	}
+}
+
+// Subscribe creates subscription to events that match filter, subscription to closed broadcaster
+// is closed at once
+func (b *Broadcaster) Subscribe(filter func(Event) bool) *Subscription {
+	s := &Subscription{b: b, c: make(chan Event, b.buffer), filter: filter}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.c)
+		return s
+	}
+	b.subs[s] = struct{}{}
+
+	return s
+}
+
+// Publish passes event to matching subscriptions, subscription with full buffer is closed
+func (b *Broadcaster) Publish(ctx context.Context, e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		if !s.filter(e) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			droppedTotal.Add(1)
+			b.remove(s)
+		}
+	}
+}
+
+// Close closes all subscriptions, it is called on shutdown to end event streams
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		b.remove(s)
+	}
+}
+
+// remove closes subscription, mutex must be held
+func (b *Broadcaster) remove(s *Subscription) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.c)
+}
+
+// Events returns channel of events, it is closed when subscription is closed
+func (s *Subscription) Events() <-chan Event {
+	return s.c
+}
+
+// Close unsubscribes from events
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.remove(s)
+}
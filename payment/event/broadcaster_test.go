@@ -0,0 +1,176 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// drain returns events received by subscription until it is closed
+func drain(s *Subscription) []Event {
+	var events []Event
+	for e := range s.Events() {
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestBroadcaster(t *testing.T) {
+	first := Event{Type: TypeStatusChanged, PaymentID: 1, Version: 2}
+	second := Event{Type: TypeExpired, PaymentID: 2, Version: 2}
+	third := Event{Type: TypeStatusChanged, PaymentID: 1, Version: 3}
+
+	cases := []struct {
+		description string
+		buffer      int
+		filter      func(Event) bool
+		unsubscribe bool
+		events      []Event
+	}{
+		{
+			description: "all events",
+			buffer:      3,
+			filter:      func(e Event) bool { return true },
+			events:      []Event{first, second, third},
+		},
+		{
+			description: "filtered events",
+			buffer:      3,
+			filter:      func(e Event) bool { return e.PaymentID == 1 },
+			events:      []Event{first, third},
+		},
+		{
+			description: "slow subscription is closed",
+			buffer:      1,
+			filter:      func(e Event) bool { return true },
+			events:      []Event{first},
+		},
+		{
+			description: "closed subscription",
+			buffer:      3,
+			filter:      func(e Event) bool { return true },
+			unsubscribe: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			b := NewBroadcaster(tc.buffer)
+			s := b.Subscribe(tc.filter)
+			if tc.unsubscribe {
+				s.Close()
+			}
+			for _, e := range []Event{first, second, third} {
+				b.Publish(context.Background(), e)
+			}
+			b.Close()
+
+			assert.Equal(t, tc.events, drain(s))
+		})
+	}
+}
+
+func TestBroadcasterClose(t *testing.T) {
+	b := NewBroadcaster(1)
+	b.Close()
+	s := b.Subscribe(func(e Event) bool { return true })
+	b.Publish(context.Background(), Event{PaymentID: 1})
+	s.Close()
+
+	assert.Empty(t, drain(s))
+}
+
+func TestRelayNotify(t *testing.T) {
+	r, err := NewRelay("", zap.NewNop())
+	require.NoError(t, err)
+	mockedStore := &paymentModel.QuerierMock{
+		NotifyPaymentEventsFunc: func(ctx context.Context, arg paymentModel.NotifyPaymentEventsParams) error {
+			return nil
+		},
+	}
+	recorded := []paymentModel.PaymentEvent{
+		{ID: 1, EventType: string(TypeStatusChanged), PaymentID: 1, UserID: 2, MerchantID: 3, PaymentStatus: "success", Version: 2},
+		{ID: 2, EventType: string(TypeExpired), PaymentID: 4, UserID: 2, MerchantID: 3, PaymentStatus: "expired", Version: 1},
+	}
+
+	require.NoError(t, r.Notify(context.Background(), mockedStore, recorded))
+	calls := mockedStore.NotifyPaymentEventsCalls()
+	require.Equal(t, 1, len(calls))
+	assert.Equal(t, Channel, calls[0].Arg.Channel)
+	require.Equal(t, 2, len(calls[0].Arg.Payloads))
+	for i, payload := range calls[0].Arg.Payloads {
+		var msg notification
+		require.NoError(t, json.Unmarshal([]byte(payload), &msg))
+		assert.Equal(t, r.origin, msg.Origin)
+		assert.Equal(t, PaymentEvent(recorded[i]), msg.Event)
+	}
+
+	// nothing is sent without events
+	require.NoError(t, r.Notify(context.Background(), mockedStore, nil))
+	assert.Equal(t, 1, len(mockedStore.NotifyPaymentEventsCalls()))
+}
+
+// failingListener fails first attempts to listen
+type failingListener struct {
+	failures int
+	calls    int
+}
+
+func (l *failingListener) Listen(channel string) error {
+	l.calls++
+	if l.calls <= l.failures {
+		return fmt.Errorf("server error")
+	}
+	return nil
+}
+
+func TestRelayListen(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := []struct {
+		description string
+		ctx         context.Context
+		listener    *failingListener
+		expected    bool
+		calls       int
+	}{
+		{
+			description: "listens at once",
+			ctx:         context.Background(),
+			listener:    &failingListener{},
+			expected:    true,
+			calls:       1,
+		},
+		{
+			description: "retries failed attempts",
+			ctx:         context.Background(),
+			listener:    &failingListener{failures: 2},
+			expected:    true,
+			calls:       3,
+		},
+		{
+			description: "context canceled",
+			ctx:         canceled,
+			listener:    &failingListener{failures: 1},
+			expected:    false,
+			calls:       1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			r := &Relay{logger: zap.NewNop(), retryDelay: time.Millisecond}
+			assert.Equal(t, tc.expected, r.listen(tc.ctx, tc.listener))
+			assert.Equal(t, tc.calls, tc.listener.calls)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Type is payment event type
+type Type string
+
+// Event types
+const (
+	TypeStatusChanged Type = "payment.status_changed"
+	TypeExpired       Type = "payment.expired"
+)
+
+// Event describes change that happened to payment, ID is sequence number of change in event log,
+// Version is payment version after change
+type Event struct {
+	ID         int64                    `json:"id"`
+	Type       Type                     `json:"type"`
+	PaymentID  int64                    `json:"payment_id"`
+	UserID     int64                    `json:"user_id"`
+	MerchantID int64                    `json:"merchant_id"`
+	Status     paymentModel.ValidStatus `json:"status"`
+	Version    int64                    `json:"version"`
+	CreatedAt  time.Time                `json:"created_at"`
+}
+
+// PaymentEvent returns event of payment status change recorded in event log
+func PaymentEvent(e paymentModel.PaymentEvent) Event {
+	return Event{
+		ID:         e.ID,
+		Type:       Type(e.EventType),
+		PaymentID:  e.PaymentID,
+		UserID:     e.UserID,
+		MerchantID: e.MerchantID,
+		Status:     e.PaymentStatus,
+		Version:    e.Version,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+// Publisher publishes payment events
+type Publisher interface {
+	Publish(ctx context.Context, e Event)
+}
+
+// Notifier notifies other service instances about events in transaction of store that records them
+type Notifier interface {
+	Notify(ctx context.Context, store paymentModel.Querier, events []paymentModel.PaymentEvent) error
+}
+
+// Handler handles published event
+type Handler func(ctx context.Context, e Event)
+
+// Bus is in-process event publisher, it passes every event to all subscribed handlers
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+// NewBus creates event bus
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe adds event handler
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish passes event to subscribed handlers
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, h := range b.handlers {
+		h(ctx, e)
+	}
+}
@@ -0,0 +1,151 @@
+package event
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Channel is postgres notification channel of payment events
+const Channel = "payment_events"
+
+// notification is event sent to other service instances, Origin is relay that sent it
+type notification struct {
+	Origin string `json:"origin"`
+	Event  Event  `json:"event"`
+}
+
+// Relay shares events between service instances with postgres NOTIFY and LISTEN, it notifies
+// other instances about events recorded here and passes their events to local publisher
+type Relay struct {
+	dsn        string
+	origin     string
+	logger     *zap.Logger
+	retryDelay time.Duration
+}
+
+// maxRetryDelay is the longest pause between attempts to listen to channel
+const maxRetryDelay = time.Minute
+
+// channelListener starts listening to notification channel
+type channelListener interface {
+	Listen(channel string) error
+}
+
+// NewRelay creates relay, dsn is used by dedicated listener connection
+func NewRelay(dsn string, logger *zap.Logger) (*Relay, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	return &Relay{
+		dsn:        dsn,
+		origin:     hex.EncodeToString(b),
+		logger:     logger,
+		retryDelay: time.Second,
+	}, nil
+}
+
+// Notify notifies other instances about events in transaction of store that records them, notifications
+// are delivered when transaction is committed and dropped if it is rolled back
+func (r *Relay) Notify(ctx context.Context, store paymentModel.Querier, events []paymentModel.PaymentEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	payloads := make([]string, 0, len(events))
+	for _, e := range events {
+		payload, err := json.Marshal(notification{Origin: r.origin, Event: PaymentEvent(e)})
+		if err != nil {
+			return err
+		}
+		payloads = append(payloads, string(payload))
+	}
+
+	return store.NotifyPaymentEvents(ctx, paymentModel.NotifyPaymentEventsParams{Channel: Channel, Payloads: payloads})
+}
+
+// Run passes events of other instances to publisher until context is canceled, listener reconnects
+// after connection loss and failed attempt to listen is retried, events sent while it is disconnected are lost
+func (r *Relay) Run(ctx context.Context, publisher Publisher) {
+	listener := pq.NewListener(r.dsn, time.Second, time.Minute, func(_ pq.ListenerEventType, err error) {
+		if err != nil {
+			r.logger.Error("payment events listener failed", zap.Error(err))
+		}
+	})
+	// listener is closed on cancel too, it unblocks Listen waiting for connection
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		_ = listener.Close()
+	}()
+	if !r.listen(ctx, listener) {
+		return
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			// nil notification is sent after reconnect
+			if n == nil {
+				continue
+			}
+			var msg notification
+			if err := json.Unmarshal([]byte(n.Extra), &msg); err != nil {
+				r.logger.Error("can't decode payment event", zap.Error(err))
+				continue
+			}
+			if msg.Origin == r.origin {
+				continue
+			}
+			publisher.Publish(ctx, msg.Event)
+		case <-ticker.C:
+			// ping finds broken connection that gets no notifications
+			go listener.Ping()
+		}
+	}
+}
+
+// listen starts listening to channel, failed attempts are retried with growing delay,
+// it reports false if context is canceled before listening starts
+func (r *Relay) listen(ctx context.Context, listener channelListener) bool {
+	delay := r.retryDelay
+	for {
+		err := listener.Listen(Channel)
+		if err == nil || errors.Is(err, pq.ErrChannelAlreadyOpen) {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		r.logger.Error("can't listen to payment events", zap.Error(err), zap.Duration("retry in", delay))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
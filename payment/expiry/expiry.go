@@ -0,0 +1,146 @@
+package expiry
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/semka95/payment-service/payment/audit"
+	"github.com/semka95/payment-service/payment/event"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+var (
+	expiredTotal     = expvar.NewInt("payments_expired_total")
+	sweepErrorsTotal = expvar.NewInt("payments_expiry_errors_total")
+)
+
+// Policy sets how long payment may stay in new status, zero TTL means payment never expires
+type Policy struct {
+	TTL         time.Duration
+	CurrencyTTL map[paymentModel.ValidCurrency]time.Duration
+}
+
+// ExpiresAt returns expiration time of payment created at now, nil if payment never expires
+func (p Policy) ExpiresAt(currency paymentModel.ValidCurrency, now time.Time) *time.Time {
+	ttl, ok := p.CurrencyTTL[currency]
+	if !ok {
+		ttl = p.TTL
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	expiresAt := now.Add(ttl).UTC()
+	return &expiresAt
+}
+
+// Sweeper moves stale new payments to expired status
+type Sweeper struct {
+	paymentStore paymentModel.Querier
+	db           *sql.DB
+	publisher    event.Publisher
+	notifier     event.Notifier
+	logger       *zap.Logger
+	interval     time.Duration
+	batchSize    int32
+}
+
+// NewSweeper creates expired payments sweeper, nil notifier doesn't notify other instances about expired payments
+func NewSweeper(paymentStore paymentModel.Querier, db *sql.DB, publisher event.Publisher, notifier event.Notifier, logger *zap.Logger, interval time.Duration, batchSize int32) *Sweeper {
+	return &Sweeper{
+		paymentStore: paymentStore,
+		db:           db,
+		publisher:    publisher,
+		notifier:     notifier,
+		logger:       logger,
+		interval:     interval,
+		batchSize:    batchSize,
+	}
+}
+
+// Run sweeps expired payments until context is canceled
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.sweep(ctx)
+			if err != nil {
+				sweepErrorsTotal.Add(1)
+				s.logger.Error("can't expire payments", zap.Error(err), zap.Int("expired", count))
+				continue
+			}
+			if count > 0 {
+				s.logger.Info("payments expired", zap.Int("expired", count))
+			}
+		}
+	}
+}
+
+// sweep expires payments batch by batch, it returns number of expired payments
+func (s *Sweeper) sweep(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		events, err := s.expire(ctx)
+		if err != nil {
+			return total, err
+		}
+
+		for _, e := range events {
+			s.publisher.Publish(ctx, event.PaymentEvent(e))
+		}
+		total += len(events)
+		expiredTotal.Add(int64(len(events)))
+
+		if len(events) == 0 || len(events) < int(s.batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// expire expires one batch of payments and records audit log and event of each in the same transaction,
+// other instances are notified in it too, events of expired payments are returned
+func (s *Sweeper) expire(ctx context.Context) ([]paymentModel.PaymentEvent, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	store := paymentModel.WithTx(s.paymentStore, tx)
+
+	payments, err := store.ExpirePayments(ctx, s.batchSize)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(payments))
+	for _, p := range payments {
+		if err = audit.Record(ctx, store, audit.Sweeper, p.MerchantID, audit.ActionExpirePayment, audit.Payment(p.ID)); err != nil {
+			return nil, err
+		}
+		ids = append(ids, p.ID)
+	}
+	var events []paymentModel.PaymentEvent
+	if len(ids) > 0 {
+		if events, err = store.CreatePaymentEvents(ctx, ids); err != nil {
+			return nil, err
+		}
+		if s.notifier != nil {
+			if err = s.notifier.Notify(ctx, store, events); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
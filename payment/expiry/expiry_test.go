@@ -0,0 +1,160 @@
+package expiry
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/semka95/payment-service/payment/audit"
+	"github.com/semka95/payment-service/payment/event"
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+func TestPolicyExpiresAt(t *testing.T) {
+	now := time.Now().UTC()
+	policy := Policy{
+		TTL:         time.Hour,
+		CurrencyTTL: map[postgres.ValidCurrency]time.Duration{postgres.ValidCurrencyRub: time.Minute, postgres.ValidCurrencyEur: 0},
+	}
+
+	expiresAt := policy.ExpiresAt(postgres.ValidCurrencyUsd, now)
+	require.NotNil(t, expiresAt)
+	assert.Equal(t, now.Add(time.Hour), *expiresAt)
+
+	expiresAt = policy.ExpiresAt(postgres.ValidCurrencyRub, now)
+	require.NotNil(t, expiresAt)
+	assert.Equal(t, now.Add(time.Minute), *expiresAt)
+
+	assert.Nil(t, policy.ExpiresAt(postgres.ValidCurrencyEur, now))
+	assert.Nil(t, Policy{}.ExpiresAt(postgres.ValidCurrencyUsd, now))
+}
+
+// notifierFunc notifies about events by calling itself
+type notifierFunc func(ctx context.Context, store postgres.Querier, events []postgres.PaymentEvent) error
+
+func (f notifierFunc) Notify(ctx context.Context, store postgres.Querier, events []postgres.PaymentEvent) error {
+	return f(ctx, store, events)
+}
+
+func TestSweep(t *testing.T) {
+	cases := []struct {
+		description string
+		batches     [][]postgres.Payment
+		err         error
+		expected    int
+		expectedErr string
+	}{
+		{
+			description: "several batches",
+			batches: [][]postgres.Payment{
+				{{ID: 1, PaymentStatus: postgres.ValidStatusExpired, MerchantID: 1}, {ID: 2, PaymentStatus: postgres.ValidStatusExpired, MerchantID: 2}},
+				{{ID: 3, PaymentStatus: postgres.ValidStatusExpired, MerchantID: 1}},
+			},
+			expected: 3,
+		},
+		{
+			description: "nothing to expire",
+			batches:     [][]postgres.Payment{{}},
+			expected:    0,
+		},
+		{
+			description: "repository error",
+			batches: [][]postgres.Payment{
+				{{ID: 1, PaymentStatus: postgres.ValidStatusExpired}, {ID: 2, PaymentStatus: postgres.ValidStatusExpired}},
+			},
+			err:         fmt.Errorf("server error"),
+			expected:    2,
+			expectedErr: "server error",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			call := 0
+			mockedStore := &postgres.QuerierMock{
+				ExpirePaymentsFunc: func(ctx context.Context, limit int32) ([]postgres.Payment, error) {
+					defer func() { call++ }()
+					if call < len(tc.batches) {
+						return tc.batches[call], nil
+					}
+					return nil, tc.err
+				},
+				CreateAuditLogFunc: func(ctx context.Context, arg postgres.CreateAuditLogParams) error {
+					return nil
+				},
+			}
+			seq := int64(0)
+			mockedStore.CreatePaymentEventsFunc = func(ctx context.Context, ids []int64) ([]postgres.PaymentEvent, error) {
+				payments := tc.batches[call-1]
+				require.Len(t, ids, len(payments))
+				records := make([]postgres.PaymentEvent, 0, len(ids))
+				for i, p := range payments {
+					assert.Equal(t, p.ID, ids[i])
+					seq++
+					records = append(records, postgres.PaymentEvent{
+						ID:            seq,
+						EventType:     string(event.TypeExpired),
+						PaymentID:     p.ID,
+						MerchantID:    p.MerchantID,
+						PaymentStatus: p.PaymentStatus,
+					})
+				}
+				return records, nil
+			}
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+			for i := 0; i < len(tc.batches); i++ {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			}
+			if tc.err != nil {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			}
+
+			var events []event.Event
+			bus := event.NewBus()
+			bus.Subscribe(func(ctx context.Context, e event.Event) {
+				events = append(events, e)
+			})
+
+			var notified []postgres.PaymentEvent
+			notifier := notifierFunc(func(ctx context.Context, store postgres.Querier, events []postgres.PaymentEvent) error {
+				notified = append(notified, events...)
+				return nil
+			})
+
+			s := NewSweeper(mockedStore, db, bus, notifier, zap.NewNop(), time.Second, 2)
+			count, err := s.sweep(context.Background())
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, count)
+			assert.Len(t, events, tc.expected)
+			assert.Len(t, notified, tc.expected)
+			for i, e := range events {
+				assert.Equal(t, int64(i+1), e.ID)
+				assert.Equal(t, event.TypeExpired, e.Type)
+				assert.Equal(t, postgres.ValidStatusExpired, e.Status)
+			}
+			for _, c := range mockedStore.ExpirePaymentsCalls() {
+				assert.Equal(t, int32(2), c.Limit)
+			}
+			logs := mockedStore.CreateAuditLogCalls()
+			assert.Len(t, logs, tc.expected)
+			for i, e := range events {
+				assert.Equal(t, postgres.CreateAuditLogParams{Actor: audit.Sweeper.Name, Action: audit.ActionExpirePayment, Target: audit.Payment(e.PaymentID), MerchantID: e.MerchantID}, logs[i].Arg)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/semka95/payment-service/payment/audit"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// NewPayment is payment to create, payment with idempotency key is created once per key owner
+type NewPayment struct {
+	paymentModel.CreatePaymentParams
+	IdempotencyKey string
+}
+
+// RequestHash returns hash of payment details sent by client, payment replayed by idempotency key
+// must have the same details, status and risk screening are set by service, so they aren't hashed
+func (np NewPayment) RequestHash() (string, error) {
+	details, err := json.Marshal(struct {
+		UserID            int64                      `json:"user_id"`
+		Email             string                     `json:"email"`
+		Amount            string                     `json:"amount"`
+		Currency          paymentModel.ValidCurrency `json:"currency"`
+		Description       string                     `json:"description"`
+		MerchantReference string                     `json:"merchant_reference"`
+		Metadata          json.RawMessage            `json:"metadata"`
+	}{np.UserID, np.Email, np.Amount.String(), np.Currency, np.Description, np.MerchantReference, np.Metadata})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(details)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// newPaymentRow is row of multi-row insert, payment id is reserved before insert,
+// so inserted payments are matched to batch items by id
+type newPaymentRow struct {
+	ID int64 `json:"id"`
+	paymentModel.CreatePaymentParams
+}
+
+// CreatedPayment is payment of batch, Replayed payment was created earlier with the same idempotency key,
+// Err is reason payment of partial batch isn't created
+type CreatedPayment struct {
+	Payment  paymentModel.Payment
+	Replayed bool
+	Err      error
+}
+
+// IdempotentPayment is payment created earlier with idempotency key, RequestHash is hash of its details
+type IdempotentPayment struct {
+	Payment     paymentModel.Payment
+	RequestHash string
+}
+
+// IdempotentPayments returns payments of merchant created earlier with idempotency keys of owner by key, it runs
+// in transaction of caller, so the same payments are replayed by CreatePaymentsTx and caller can skip new payment
+// checks of them
+func (p *Processor) IdempotentPayments(ctx context.Context, tx *sql.Tx, merchantID int64, owner string, keys []string) (map[string]IdempotentPayment, error) {
+	store := paymentModel.WithTx(p.paymentStore, tx)
+
+	known := make(map[string]IdempotentPayment)
+	if len(keys) == 0 {
+		return known, nil
+	}
+	idempotent, err := store.ListIdempotencyKeys(ctx, paymentModel.ListIdempotencyKeysParams{MerchantID: merchantID, Owner: owner, Keys: keys})
+	if err != nil {
+		return nil, &Error{Kind: ErrInternal, Details: "can't find idempotency keys", Err: err}
+	}
+	if len(idempotent) == 0 {
+		return known, nil
+	}
+	ids := make([]int64, 0, len(idempotent))
+	for _, k := range idempotent {
+		ids = append(ids, k.PaymentID)
+	}
+	existing, err := store.ListPaymentsByIDs(ctx, paymentModel.ListPaymentsByIDsParams{Ids: ids, MerchantID: merchantID})
+	if err != nil {
+		return nil, &Error{Kind: ErrInternal, Details: "can't find payment", Err: err}
+	}
+	byID := make(map[int64]paymentModel.Payment, len(existing))
+	for _, e := range existing {
+		byID[e.ID] = e
+	}
+	for _, k := range idempotent {
+		if e, ok := byID[k.PaymentID]; ok {
+			known[k.IdempotencyKey] = IdempotentPayment{Payment: e, RequestHash: k.RequestHash}
+		}
+	}
+
+	return known, nil
+}
+
+// CreatePaymentsTx creates payments of merchant with single multi-row insert in transaction of caller, payments with
+// known idempotency key returned by IdempotentPayments aren't created again, earlier payments are returned instead,
+// created payments are recorded in audit log. Key reused with other payment details and merchant reference of earlier
+// payment are conflict of whole batch, partial batch reports them per payment and creates the rest
+func (p *Processor) CreatePaymentsTx(ctx context.Context, tx *sql.Tx, actor audit.Actor, merchantID int64, owner string, payments []NewPayment, known map[string]IdempotentPayment, partial bool) ([]CreatedPayment, error) {
+	store := paymentModel.WithTx(p.paymentStore, tx)
+
+	hashes := make([]string, len(payments))
+	for i, np := range payments {
+		if np.IdempotencyKey == "" {
+			continue
+		}
+		var err error
+		if hashes[i], err = np.RequestHash(); err != nil {
+			return nil, &Error{Kind: ErrInternal, Details: "can't hash payment details", Err: err}
+		}
+	}
+
+	used := make(map[string]bool)
+	if partial {
+		references := make([]string, 0, len(payments))
+		for _, np := range payments {
+			if _, ok := known[np.IdempotencyKey]; (!ok || np.IdempotencyKey == "") && np.MerchantReference != "" {
+				references = append(references, np.MerchantReference)
+			}
+		}
+		if len(references) > 0 {
+			usedReferences, err := store.ListUsedMerchantReferences(ctx, paymentModel.ListUsedMerchantReferencesParams{MerchantID: merchantID, MerchantReferences: references})
+			if err != nil {
+				return nil, &Error{Kind: ErrInternal, Details: "can't find merchant references", Err: err}
+			}
+			for _, ref := range usedReferences {
+				used[ref] = true
+			}
+		}
+	}
+
+	created := make([]CreatedPayment, len(payments))
+	params := make([]paymentModel.CreatePaymentParams, 0, len(payments))
+	pending := make([]int, 0, len(payments))
+	for i, np := range payments {
+		if e, ok := known[np.IdempotencyKey]; ok && np.IdempotencyKey != "" {
+			if e.RequestHash != hashes[i] {
+				err := fmt.Errorf("idempotency key %q is used with other payment details", np.IdempotencyKey)
+				if !partial {
+					return nil, &Error{Kind: ErrConflict, Details: "can't create payment records", Err: err}
+				}
+				created[i] = CreatedPayment{Err: err}
+				continue
+			}
+			created[i] = CreatedPayment{Payment: e.Payment, Replayed: true}
+			continue
+		}
+		if used[np.MerchantReference] {
+			created[i] = CreatedPayment{Err: errors.New("merchant reference is already used")}
+			continue
+		}
+		np.MerchantID = merchantID
+		params = append(params, np.CreatePaymentParams)
+		pending = append(pending, i)
+	}
+
+	if len(params) > 0 {
+		ids, err := store.ReservePaymentIDs(ctx, int32(len(params)))
+		if err != nil {
+			return nil, &Error{Kind: ErrInternal, Details: "can't create payment records", Err: err}
+		}
+		if len(ids) != len(params) {
+			return nil, &Error{Kind: ErrInternal, Details: "can't create payment records", Err: fmt.Errorf("%d of %d payment ids reserved", len(ids), len(params))}
+		}
+		newRows := make([]newPaymentRow, 0, len(params))
+		for n, param := range params {
+			newRows = append(newRows, newPaymentRow{ID: ids[n], CreatePaymentParams: param})
+		}
+		rows, err := json.Marshal(newRows)
+		if err != nil {
+			return nil, &Error{Kind: ErrInternal, Details: "can't create payment records", Err: err}
+		}
+		inserted, err := store.CreatePayments(ctx, rows)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == paymentModel.UniqueViolation {
+			return nil, &Error{Kind: ErrConflict, Details: "can't create payment records", Err: errors.New("merchant reference is already used")}
+		}
+		if err != nil {
+			return nil, &Error{Kind: ErrInternal, Details: "can't create payment records", Err: err}
+		}
+		if len(inserted) != len(params) {
+			return nil, &Error{Kind: ErrInternal, Details: "can't create payment records", Err: fmt.Errorf("%d of %d payments created", len(inserted), len(params))}
+		}
+
+		byID := make(map[int64]paymentModel.Payment, len(inserted))
+		for _, ins := range inserted {
+			byID[ins.ID] = ins
+		}
+
+		newKeys := paymentModel.CreateIdempotencyKeysParams{MerchantID: merchantID, Owner: owner}
+		for n, i := range pending {
+			payment, ok := byID[ids[n]]
+			if !ok {
+				return nil, &Error{Kind: ErrInternal, Details: "can't create payment records", Err: fmt.Errorf("payment %d isn't created", ids[n])}
+			}
+			created[i] = CreatedPayment{Payment: payment}
+			if err = audit.Record(ctx, store, actor, merchantID, audit.ActionCreatePayment, audit.Payment(payment.ID)); err != nil {
+				return nil, &Error{Kind: ErrInternal, Details: "can't record audit log", Err: err}
+			}
+			if key := payments[i].IdempotencyKey; key != "" {
+				newKeys.Keys = append(newKeys.Keys, key)
+				newKeys.RequestHashes = append(newKeys.RequestHashes, hashes[i])
+				newKeys.PaymentIds = append(newKeys.PaymentIds, payment.ID)
+			}
+		}
+		if len(newKeys.Keys) > 0 {
+			err = store.CreateIdempotencyKeys(ctx, newKeys)
+			if errors.As(err, &pqErr) && pqErr.Code == paymentModel.UniqueViolation {
+				return nil, &Error{Kind: ErrConflict, Details: "can't create payment records", Err: errors.New("idempotency key is used by concurrent request")}
+			}
+			if err != nil {
+				return nil, &Error{Kind: ErrInternal, Details: "can't save idempotency keys", Err: err}
+			}
+		}
+	}
+
+	return created, nil
+}
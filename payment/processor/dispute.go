@@ -0,0 +1,162 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/semka95/payment-service/payment/audit"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// OpenDispute opens dispute on successful payment of merchant that isn't reversed yet
+func (p *Processor) OpenDispute(ctx context.Context, actor audit.Actor, merchantID, paymentID int64, reason string, evidence json.RawMessage) (paymentModel.Dispute, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't start transaction", Err: err}
+	}
+	defer tx.Rollback()
+	store := paymentModel.WithTx(p.paymentStore, tx)
+
+	status, err := store.GetPaymentStatusByID(ctx, paymentModel.GetPaymentStatusByIDParams{ID: paymentID, MerchantID: merchantID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return paymentModel.Dispute{}, &Error{Kind: ErrNotFound, Details: "payment not found", Err: err}
+	}
+	if err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't open dispute", Err: err}
+	}
+	if status != paymentModel.ValidStatusSuccess {
+		return paymentModel.Dispute{}, &Error{Kind: ErrTransition, Details: "can't open dispute", Err: fmt.Errorf("can't open dispute for payment with %s status", status)}
+	}
+	// payment is reversed once, after dispute is lost it can't be disputed again
+	reversed, err := store.IsPaymentReversed(ctx, paymentID)
+	if err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't open dispute", Err: err}
+	}
+	if reversed {
+		return paymentModel.Dispute{}, &Error{Kind: ErrConflict, Details: "can't open dispute", Err: errors.New("payment is already reversed")}
+	}
+
+	dispute, err := store.CreateDispute(ctx, paymentModel.CreateDisputeParams{
+		Reason:     reason,
+		Evidence:   evidence,
+		PaymentID:  paymentID,
+		MerchantID: merchantID,
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == paymentModel.UniqueViolation {
+		return paymentModel.Dispute{}, &Error{Kind: ErrConflict, Details: "can't open dispute", Err: errors.New("payment already has open dispute")}
+	}
+	if err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't open dispute", Err: err}
+	}
+	if err = audit.Record(ctx, store, actor, merchantID, audit.ActionOpenDispute, audit.Payment(paymentID)); err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't record audit log", Err: err}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't commit dispute", Err: err}
+	}
+
+	return dispute, nil
+}
+
+// AddDisputeEvidence merges evidence metadata into open dispute evidence
+func (p *Processor) AddDisputeEvidence(ctx context.Context, actor audit.Actor, merchantID, paymentID, disputeID int64, evidence json.RawMessage) (paymentModel.Dispute, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't start transaction", Err: err}
+	}
+	defer tx.Rollback()
+	store := paymentModel.WithTx(p.paymentStore, tx)
+
+	dispute, err := store.AddDisputeEvidence(ctx, paymentModel.AddDisputeEvidenceParams{
+		ID:         disputeID,
+		PaymentID:  paymentID,
+		MerchantID: merchantID,
+		Evidence:   evidence,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return paymentModel.Dispute{}, disputeStateError(ctx, store, merchantID, paymentID, disputeID, "can't add evidence")
+	}
+	if err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't add evidence", Err: err}
+	}
+	if err = audit.Record(ctx, store, actor, merchantID, audit.ActionAddEvidence, audit.Payment(paymentID)); err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't record audit log", Err: err}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't commit dispute", Err: err}
+	}
+
+	return dispute, nil
+}
+
+// ResolveDispute resolves open dispute as won or lost, lost dispute records payment reversal
+func (p *Processor) ResolveDispute(ctx context.Context, actor audit.Actor, merchantID, paymentID, disputeID int64, status paymentModel.DisputeStatus) (paymentModel.Dispute, error) {
+	if status != paymentModel.DisputeStatusWon && status != paymentModel.DisputeStatusLost {
+		return paymentModel.Dispute{}, &Error{Kind: ErrTransition, Details: "can't resolve dispute", Err: fmt.Errorf("dispute can't be resolved with %q status", status)}
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't start transaction", Err: err}
+	}
+	defer tx.Rollback()
+	store := paymentModel.WithTx(p.paymentStore, tx)
+
+	dispute, err := store.ResolveDispute(ctx, paymentModel.ResolveDisputeParams{
+		ID:            disputeID,
+		PaymentID:     paymentID,
+		MerchantID:    merchantID,
+		DisputeStatus: status,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return paymentModel.Dispute{}, disputeStateError(ctx, store, merchantID, paymentID, disputeID, "can't resolve dispute")
+	}
+	if err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't resolve dispute", Err: err}
+	}
+
+	if status == paymentModel.DisputeStatusLost {
+		_, err = store.CreateReversal(ctx, paymentModel.CreateReversalParams{ID: disputeID, MerchantID: merchantID})
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == paymentModel.UniqueViolation {
+			return paymentModel.Dispute{}, &Error{Kind: ErrConflict, Details: "can't record reversal", Err: errors.New("payment is already reversed")}
+		}
+		if err != nil {
+			return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't record reversal", Err: err}
+		}
+	}
+	if err = audit.Record(ctx, store, actor, merchantID, audit.ActionResolveDispute, audit.Payment(paymentID)); err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't record audit log", Err: err}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return paymentModel.Dispute{}, &Error{Kind: ErrInternal, Details: "can't commit dispute", Err: err}
+	}
+
+	return dispute, nil
+}
+
+// disputeStateError explains why open dispute wasn't found
+func disputeStateError(ctx context.Context, store paymentModel.Querier, merchantID, paymentID, disputeID int64, details string) error {
+	status, err := store.GetDisputeStatus(ctx, paymentModel.GetDisputeStatusParams{
+		ID:         disputeID,
+		PaymentID:  paymentID,
+		MerchantID: merchantID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Details: "dispute not found", Err: err}
+	}
+	if err != nil {
+		return &Error{Kind: ErrInternal, Details: details, Err: err}
+	}
+
+	return &Error{Kind: ErrTransition, Details: details, Err: fmt.Errorf("dispute is already resolved, it has %s status", status)}
+}
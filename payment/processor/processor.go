@@ -0,0 +1,275 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/semka95/payment-service/payment/audit"
+	"github.com/semka95/payment-service/payment/event"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Error kinds
+var (
+	ErrNotFound     = errors.New("payment not found")
+	ErrTransition   = errors.New("invalid status transition")
+	ErrConflict     = errors.New("conflict")
+	ErrPrecondition = errors.New("precondition failed")
+	ErrInternal     = errors.New("internal error")
+)
+
+// Error is returned when payment can't be processed
+type Error struct {
+	Kind    error
+	Details string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the kind of error
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+// Processor changes payment statuses, it is shared by rest api and background workers
+type Processor struct {
+	paymentStore paymentModel.Querier
+	db           *sql.DB
+	publisher    event.Publisher
+	notifier     event.Notifier
+}
+
+// New creates payment processor, status changes are published to publisher after commit, nil publisher
+// disables events, other instances are notified by notifier in transaction of change, nil notifier doesn't notify them
+func New(paymentStore paymentModel.Querier, db *sql.DB, publisher event.Publisher, notifier event.Notifier) *Processor {
+	return &Processor{
+		paymentStore: paymentStore,
+		db:           db,
+		publisher:    publisher,
+		notifier:     notifier,
+	}
+}
+
+// UpdateStatus moves payment of merchant to the new status if it is not final yet, change is recorded
+// in audit log as made by actor
+func (p *Processor) UpdateStatus(ctx context.Context, actor audit.Actor, merchantID, id int64, newStatus paymentModel.ValidStatus) error {
+	return p.UpdateStatusIfMatch(ctx, actor, merchantID, id, 0, newStatus)
+}
+
+// UpdateStatusIfMatch is UpdateStatus of payment with given version, zero version matches any version
+func (p *Processor) UpdateStatusIfMatch(ctx context.Context, actor audit.Actor, merchantID, id, version int64, newStatus paymentModel.ValidStatus) error {
+	if !providerStatus(newStatus) {
+		return &Error{Kind: ErrTransition, Details: "can't update payment status", Err: fmt.Errorf("can't update to %q status", newStatus)}
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return &Error{Kind: ErrInternal, Details: "can't start transaction", Err: err}
+	}
+	defer tx.Rollback()
+	store := paymentModel.WithTx(p.paymentStore, tx)
+
+	status, err := store.GetPaymentStatusByID(ctx, paymentModel.GetPaymentStatusByIDParams{ID: id, MerchantID: merchantID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Details: "payment not found", Err: err}
+	}
+	if err != nil {
+		return &Error{Kind: ErrInternal, Details: "can't update payment", Err: err}
+	}
+
+	rows, err := store.UpdatePaymentStatus(ctx, paymentModel.UpdatePaymentStatusParams{
+		ID:            id,
+		PaymentStatus: newStatus,
+		MerchantID:    merchantID,
+		Version:       version,
+	})
+	if err != nil {
+		return &Error{Kind: ErrInternal, Details: "can't update payment", Err: err}
+	}
+	if rows == 0 && version != 0 {
+		current, err := store.GetPaymentByID(ctx, paymentModel.GetPaymentByIDParams{ID: id, MerchantID: merchantID})
+		if err != nil {
+			return &Error{Kind: ErrInternal, Details: "can't update payment", Err: err}
+		}
+		if current.Version != version {
+			return &Error{Kind: ErrPrecondition, Details: "payment is changed", Err: fmt.Errorf("payment has %d version, not %d", current.Version, version)}
+		}
+	}
+	if rows == 0 {
+		return &Error{Kind: ErrTransition, Details: "can't update payment status", Err: fmt.Errorf("can't update from %s status to %s status", status, newStatus)}
+	}
+	if err = audit.Record(ctx, store, actor, merchantID, audit.ActionUpdateStatus, audit.Payment(id)); err != nil {
+		return &Error{Kind: ErrInternal, Details: "can't record audit log", Err: err}
+	}
+	changed, err := p.recordEvents(ctx, store, id)
+	if err != nil {
+		return &Error{Kind: ErrInternal, Details: "can't update payment", Err: err}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return &Error{Kind: ErrInternal, Details: "can't commit payment", Err: err}
+	}
+	p.Publish(ctx, changed)
+
+	return nil
+}
+
+// providerStatus reports whether payment system may move payment to status, new, expired and review
+// statuses are set by service itself
+func providerStatus(status paymentModel.ValidStatus) bool {
+	switch status {
+	case paymentModel.ValidStatusSuccess, paymentModel.ValidStatusFailure, paymentModel.ValidStatusError:
+		return true
+	}
+
+	return false
+}
+
+// recordEvents records status change events of payments changed in transaction in event log,
+// so streams can resume from them, and notifies other instances in the same transaction,
+// events are returned to be published after commit
+func (p *Processor) recordEvents(ctx context.Context, store paymentModel.Querier, ids ...int64) ([]paymentModel.PaymentEvent, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	events, err := store.CreatePaymentEvents(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if p.notifier != nil {
+		if err = p.notifier.Notify(ctx, store, events); err != nil {
+			return nil, err
+		}
+	}
+
+	return events, nil
+}
+
+// Publish publishes status change events of committed payments, nothing is published if events are disabled
+func (p *Processor) Publish(ctx context.Context, events []paymentModel.PaymentEvent) {
+	if p.publisher == nil {
+		return
+	}
+	for _, e := range events {
+		p.publisher.Publish(ctx, event.PaymentEvent(e))
+	}
+}
+
+// Outcomes of status update in batch
+const (
+	OutcomeUpdated           = "updated"
+	OutcomeNotFound          = "not_found"
+	OutcomeInvalidTransition = "invalid_transition"
+)
+
+// StatusUpdate is new status of payment
+type StatusUpdate struct {
+	ID     int64                    `json:"id"`
+	Status paymentModel.ValidStatus `json:"status"`
+}
+
+// StatusOutcome is result of status update, Error explains why payment isn't updated
+type StatusOutcome struct {
+	ID      int64                    `json:"id"`
+	Status  paymentModel.ValidStatus `json:"status"`
+	Outcome string                   `json:"outcome"`
+	Error   string                   `json:"error,omitempty"`
+}
+
+// UpdateStatuses applies status updates in request order in one transaction, payments are locked
+// until it's committed, update of missing payment or final status doesn't fail others, payments of other
+// merchants are not found, every updated payment is recorded in audit log
+func (p *Processor) UpdateStatuses(ctx context.Context, actor audit.Actor, merchantID int64, updates []StatusUpdate) ([]StatusOutcome, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, &Error{Kind: ErrInternal, Details: "can't start transaction", Err: err}
+	}
+	defer tx.Rollback()
+
+	outcomes, changed, err := p.UpdateStatusesTx(ctx, tx, actor, merchantID, updates)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, &Error{Kind: ErrInternal, Details: "can't commit payments", Err: err}
+	}
+	p.Publish(ctx, changed)
+
+	return outcomes, nil
+}
+
+// UpdateStatusesTx applies status updates like UpdateStatuses in transaction of caller, so they are committed
+// together with caller's changes, returned events must be published after commit
+func (p *Processor) UpdateStatusesTx(ctx context.Context, tx *sql.Tx, actor audit.Actor, merchantID int64, updates []StatusUpdate) ([]StatusOutcome, []paymentModel.PaymentEvent, error) {
+	store := paymentModel.WithTx(p.paymentStore, tx)
+
+	ids := make([]int64, 0, len(updates))
+	for _, u := range updates {
+		ids = append(ids, u.ID)
+	}
+	rows, err := store.ListPaymentStatuses(ctx, paymentModel.ListPaymentStatusesParams{Ids: ids, MerchantID: merchantID})
+	if err != nil {
+		return nil, nil, &Error{Kind: ErrInternal, Details: "can't update payments", Err: err}
+	}
+	statuses := make(map[int64]paymentModel.ValidStatus, len(rows))
+	for _, row := range rows {
+		statuses[row.ID] = row.PaymentStatus
+	}
+
+	outcomes := make([]StatusOutcome, 0, len(updates))
+	updatedIDs := make([]int64, 0, len(updates))
+	for _, u := range updates {
+		outcome := StatusOutcome{ID: u.ID, Status: u.Status}
+		status, ok := statuses[u.ID]
+		if ok && !providerStatus(u.Status) {
+			outcome.Outcome = OutcomeInvalidTransition
+			outcome.Error = fmt.Sprintf("can't update to %q status", u.Status)
+			outcomes = append(outcomes, outcome)
+			continue
+		}
+		if !ok {
+			outcome.Outcome = OutcomeNotFound
+			outcome.Error = "payment not found"
+			outcomes = append(outcomes, outcome)
+			continue
+		}
+
+		updated, err := store.UpdatePaymentStatus(ctx, paymentModel.UpdatePaymentStatusParams{
+			ID:            u.ID,
+			PaymentStatus: u.Status,
+			MerchantID:    merchantID,
+		})
+		if err != nil {
+			return nil, nil, &Error{Kind: ErrInternal, Details: "can't update payments", Err: err}
+		}
+		if updated == 0 {
+			outcome.Outcome = OutcomeInvalidTransition
+			outcome.Error = fmt.Sprintf("can't update from %s status to %s status", status, u.Status)
+		} else {
+			if err = audit.Record(ctx, store, actor, merchantID, audit.ActionUpdateStatus, audit.Payment(u.ID)); err != nil {
+				return nil, nil, &Error{Kind: ErrInternal, Details: "can't record audit log", Err: err}
+			}
+			outcome.Outcome = OutcomeUpdated
+			statuses[u.ID] = u.Status
+			updatedIDs = append(updatedIDs, u.ID)
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	changed, err := p.recordEvents(ctx, store, updatedIDs...)
+	if err != nil {
+		return nil, nil, &Error{Kind: ErrInternal, Details: "can't update payments", Err: err}
+	}
+
+	return outcomes, changed, nil
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/semka95/payment-service/payment/audit"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// ReviewPayment approves payment of merchant in review status back to new or declines it to failure,
+// reviewer note is saved with decision, approved payment gets its lifetime again from the time of approval,
+// decision is recorded in audit log as made by actor
+func (p *Processor) ReviewPayment(ctx context.Context, actor audit.Actor, merchantID, id int64, decision paymentModel.ReviewDecision, reviewer, note string) (paymentModel.PaymentReview, error) {
+	newStatus, action := paymentModel.ValidStatusNew, audit.ActionApprovePayment
+	if decision == paymentModel.ReviewDecisionDeclined {
+		newStatus, action = paymentModel.ValidStatusFailure, audit.ActionDeclinePayment
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return paymentModel.PaymentReview{}, &Error{Kind: ErrInternal, Details: "can't start transaction", Err: err}
+	}
+	defer tx.Rollback()
+	store := paymentModel.WithTx(p.paymentStore, tx)
+
+	rows, err := store.ReviewPayment(ctx, paymentModel.ReviewPaymentParams{
+		ID:            id,
+		PaymentStatus: newStatus,
+		MerchantID:    merchantID,
+	})
+	if err != nil {
+		return paymentModel.PaymentReview{}, &Error{Kind: ErrInternal, Details: "can't review payment", Err: err}
+	}
+	if rows == 0 {
+		status, statusErr := store.GetPaymentStatusByID(ctx, paymentModel.GetPaymentStatusByIDParams{ID: id, MerchantID: merchantID})
+		if errors.Is(statusErr, sql.ErrNoRows) {
+			return paymentModel.PaymentReview{}, &Error{Kind: ErrNotFound, Details: "payment not found", Err: statusErr}
+		}
+		if statusErr != nil {
+			return paymentModel.PaymentReview{}, &Error{Kind: ErrInternal, Details: "can't review payment", Err: statusErr}
+		}
+		return paymentModel.PaymentReview{}, &Error{Kind: ErrTransition, Details: "can't review payment", Err: fmt.Errorf("payment is not in review, it has %s status", status)}
+	}
+
+	review, err := store.CreatePaymentReview(ctx, paymentModel.CreatePaymentReviewParams{
+		PaymentID:      id,
+		Reviewer:       reviewer,
+		ReviewDecision: decision,
+		Note:           note,
+	})
+	if err != nil {
+		return paymentModel.PaymentReview{}, &Error{Kind: ErrInternal, Details: "can't save review", Err: err}
+	}
+	if err = audit.Record(ctx, store, actor, merchantID, action, audit.Payment(id)); err != nil {
+		return paymentModel.PaymentReview{}, &Error{Kind: ErrInternal, Details: "can't record audit log", Err: err}
+	}
+	changed, err := p.recordEvents(ctx, store, id)
+	if err != nil {
+		return paymentModel.PaymentReview{}, &Error{Kind: ErrInternal, Details: "can't review payment", Err: err}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return paymentModel.PaymentReview{}, &Error{Kind: ErrInternal, Details: "can't commit review", Err: err}
+	}
+	p.Publish(ctx, changed)
+
+	return review, nil
+}
@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is token bucket limit, bucket holds up to Burst tokens and gains Rate tokens per second,
+// zero Rate disables limit
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute creates limit of requests per minute, burst lower than 1 is set to 1
+func PerMinute(requests, burst int) Limit {
+	if burst < 1 {
+		burst = 1
+	}
+	return Limit{Rate: float64(requests) / 60, Burst: burst}
+}
+
+// Enabled reports whether limit restricts requests
+func (l Limit) Enabled() bool {
+	return l.Rate > 0
+}
+
+// Result is state of bucket after request took a token, Reset is time until bucket is full,
+// RetryAfter is time until next token is available if request isn't allowed
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets, it may be replaced by external store shared between service instances
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	Refund(ctx context.Context, key string, limit Limit) error
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// MemoryStore keeps token buckets in memory of single service instance
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewMemoryStore creates in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Take takes token from bucket of key, new bucket is full
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+
+	res := Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+	b.full = now.Add(res.Reset)
+
+	return res, nil
+}
+
+// Refund gives back token taken from bucket of key, removed bucket is full, so it's left as is
+func (s *MemoryStore) Refund(_ context.Context, key string, limit Limit) error {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		return nil
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+1+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+	b.full = now.Add(seconds((float64(limit.Burst) - b.tokens) / limit.Rate))
+
+	return nil
+}
+
+// Run removes full buckets every interval
+func (s *MemoryStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.evict()
+		}
+	}
+}
+
+// evict removes buckets which are refilled, new bucket is full too, so removing them doesn't change limits
+func (s *MemoryStore) evict() {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTake(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	limit := PerMinute(60, 2)
+
+	res, err := s.Take(context.Background(), "a", limit)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}, res)
+
+	res, err = s.Take(context.Background(), "a", limit)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}, res)
+
+	res, err = s.Take(context.Background(), "a", limit)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Allowed: false, Limit: 2, Remaining: 0, Reset: 2 * time.Second, RetryAfter: time.Second}, res)
+
+	// other key has its own bucket
+	res, err = s.Take(context.Background(), "b", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	res, err = s.Take(context.Background(), "a", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	now = now.Add(500 * time.Millisecond)
+	res, err = s.Take(context.Background(), "a", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestRefund(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	limit := PerMinute(60, 2)
+
+	// bucket without tokens taken is left as is
+	require.NoError(t, s.Refund(context.Background(), "a", limit))
+	assert.Empty(t, s.buckets)
+
+	_, err := s.Take(context.Background(), "a", limit)
+	require.NoError(t, err)
+	_, err = s.Take(context.Background(), "a", limit)
+	require.NoError(t, err)
+	require.NoError(t, s.Refund(context.Background(), "a", limit))
+
+	res, err := s.Take(context.Background(), "a", limit)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}, res)
+
+	// refund doesn't overfill bucket
+	require.NoError(t, s.Refund(context.Background(), "a", limit))
+	require.NoError(t, s.Refund(context.Background(), "a", limit))
+	require.NoError(t, s.Refund(context.Background(), "a", limit))
+	assert.Equal(t, float64(2), s.buckets["a"].tokens)
+}
+
+func TestEvict(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	limit := PerMinute(60, 5)
+
+	_, err := s.Take(context.Background(), "a", limit)
+	require.NoError(t, err)
+	now = now.Add(500 * time.Millisecond)
+	_, err = s.Take(context.Background(), "b", limit)
+	require.NoError(t, err)
+
+	now = now.Add(700 * time.Millisecond)
+	s.evict()
+	assert.Equal(t, 1, len(s.buckets))
+	assert.Contains(t, s.buckets, "b")
+}
+
+func TestPerMinute(t *testing.T) {
+	assert.Equal(t, Limit{Rate: 2, Burst: 1}, PerMinute(120, 0))
+	assert.False(t, PerMinute(0, 10).Enabled())
+}
@@ -0,0 +1,348 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/semka95/payment-service/payment/audit"
+	"github.com/semka95/payment-service/payment/processor"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Reconciliation errors
+var (
+	ErrInvalidSettlement = errors.New("invalid settlement file")
+	ErrInvalidPeriod     = errors.New("invalid reconciliation period")
+)
+
+// settlementColumns are required columns of settlement file, other columns are ignored
+var settlementColumns = []string{"id", "amount", "currency", "status"}
+
+// Line is payment settled by provider
+type Line struct {
+	Number    int
+	PaymentID int64
+	Amount    decimal.Decimal
+	Currency  paymentModel.ValidCurrency
+	Status    paymentModel.ValidStatus
+}
+
+// ParseSettlement reads csv settlement file, first row is header with id, amount, currency and status columns
+func ParseSettlement(r io.Reader) ([]Line, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidSettlement)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSettlement, err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range settlementColumns {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("%w: %s column is missing", ErrInvalidSettlement, name)
+		}
+	}
+
+	lines := make([]Line, 0)
+	seen := make(map[int64]int)
+	for number := 2; ; number++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSettlement, err)
+		}
+		value := func(name string) string {
+			if i := index[name]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		line := Line{Number: number}
+		line.PaymentID, err = strconv.ParseInt(value("id"), 10, 64)
+		if err != nil || line.PaymentID < 1 {
+			return nil, fmt.Errorf("%w: line %d: id must be positive integer, got %q", ErrInvalidSettlement, number, value("id"))
+		}
+		if prev, ok := seen[line.PaymentID]; ok {
+			return nil, fmt.Errorf("%w: line %d: payment %d is already settled on line %d", ErrInvalidSettlement, number, line.PaymentID, prev)
+		}
+		seen[line.PaymentID] = number
+		line.Amount, err = decimal.NewFromString(value("amount"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: amount must be decimal, got %q", ErrInvalidSettlement, number, value("amount"))
+		}
+		line.Currency = paymentModel.ValidCurrency(strings.ToLower(value("currency")))
+		switch line.Currency {
+		case paymentModel.ValidCurrencyUsd, paymentModel.ValidCurrencyEur, paymentModel.ValidCurrencyRub:
+		default:
+			return nil, fmt.Errorf("%w: line %d: unknown currency %q", ErrInvalidSettlement, number, value("currency"))
+		}
+		line.Status = paymentModel.ValidStatus(strings.ToLower(value("status")))
+		switch line.Status {
+		case paymentModel.ValidStatusNew, paymentModel.ValidStatusSuccess, paymentModel.ValidStatusFailure,
+			paymentModel.ValidStatusError, paymentModel.ValidStatusExpired:
+		default:
+			return nil, fmt.Errorf("%w: line %d: unknown status %q", ErrInvalidSettlement, number, value("status"))
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+// Options of reconciliation run, payments of merchant created in [From, To) range are expected in settlement,
+// Apply moves payments to provider statuses, Actor is recorded with run and in audit log
+type Options struct {
+	MerchantID int64
+	Source     string
+	Actor      audit.Actor
+	From       time.Time
+	To         time.Time
+	Apply      bool
+}
+
+// Result is stored reconciliation run with its discrepancies
+type Result struct {
+	Run           paymentModel.ReconciliationRun           `json:"run"`
+	Discrepancies []paymentModel.ReconciliationDiscrepancy `json:"discrepancies"`
+}
+
+// Reconciler matches settlement lines against payments
+type Reconciler struct {
+	paymentStore paymentModel.Querier
+	db           *sql.DB
+	processor    *processor.Processor
+}
+
+// New creates reconciler, provider statuses are applied by processor
+func New(paymentStore paymentModel.Querier, db *sql.DB, proc *processor.Processor) *Reconciler {
+	return &Reconciler{
+		paymentStore: paymentStore,
+		db:           db,
+		processor:    proc,
+	}
+}
+
+// Run reports payments with amount, currency or status different from settlement, settled payments
+// that are missing and payments of period missing in settlement. Statuses are applied through usual
+// transitions in the same transaction run is stored in, payment in final status or with amount or currency
+// discrepancy isn't changed and gets apply error
+func (rc *Reconciler) Run(ctx context.Context, opts Options, lines []Line) (Result, error) {
+	if !opts.From.Before(opts.To) {
+		return Result{}, fmt.Errorf("%w: from must be before to", ErrInvalidPeriod)
+	}
+
+	ids := make([]int64, 0, len(lines))
+	for _, l := range lines {
+		ids = append(ids, l.PaymentID)
+	}
+	payments, err := rc.paymentStore.ListPaymentsByIDs(ctx, paymentModel.ListPaymentsByIDsParams{Ids: ids, MerchantID: opts.MerchantID})
+	if err != nil {
+		return Result{}, &processor.Error{Kind: processor.ErrInternal, Details: "can't find payments", Err: err}
+	}
+	byID := make(map[int64]paymentModel.Payment, len(payments))
+	for _, p := range payments {
+		byID[p.ID] = p
+	}
+
+	discrepancies := make([]paymentModel.ReconciliationDiscrepancy, 0)
+	matched := 0
+	for _, l := range lines {
+		p, ok := byID[l.PaymentID]
+		if !ok {
+			discrepancies = append(discrepancies, paymentModel.ReconciliationDiscrepancy{
+				PaymentID:     l.PaymentID,
+				Kind:          paymentModel.DiscrepancyKindMissingPayment,
+				ProviderValue: describe(l.Amount, l.Currency, l.Status),
+			})
+			continue
+		}
+		found := len(discrepancies)
+		if !p.Amount.Equal(l.Amount) {
+			discrepancies = append(discrepancies, paymentModel.ReconciliationDiscrepancy{
+				PaymentID:     p.ID,
+				Kind:          paymentModel.DiscrepancyKindAmount,
+				ProviderValue: l.Amount.String(),
+				PaymentValue:  p.Amount.String(),
+			})
+		}
+		if p.Currency != l.Currency {
+			discrepancies = append(discrepancies, paymentModel.ReconciliationDiscrepancy{
+				PaymentID:     p.ID,
+				Kind:          paymentModel.DiscrepancyKindCurrency,
+				ProviderValue: string(l.Currency),
+				PaymentValue:  string(p.Currency),
+			})
+		}
+		if p.PaymentStatus != l.Status {
+			discrepancies = append(discrepancies, paymentModel.ReconciliationDiscrepancy{
+				PaymentID:     p.ID,
+				Kind:          paymentModel.DiscrepancyKindStatus,
+				ProviderValue: string(l.Status),
+				PaymentValue:  string(p.PaymentStatus),
+			})
+		}
+		if found == len(discrepancies) {
+			matched++
+		}
+	}
+
+	unsettled, err := rc.paymentStore.ListUnsettledPayments(ctx, paymentModel.ListUnsettledPaymentsParams{
+		MerchantID:  opts.MerchantID,
+		CreatedFrom: opts.From.UTC(),
+		CreatedTo:   opts.To.UTC(),
+		Ids:         ids,
+	})
+	if err != nil {
+		return Result{}, &processor.Error{Kind: processor.ErrInternal, Details: "can't find payments", Err: err}
+	}
+	for _, p := range unsettled {
+		discrepancies = append(discrepancies, paymentModel.ReconciliationDiscrepancy{
+			PaymentID:    p.ID,
+			Kind:         paymentModel.DiscrepancyKindMissingSettlement,
+			PaymentValue: describe(p.Amount, p.Currency, p.PaymentStatus),
+		})
+	}
+
+	tx, err := rc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, &processor.Error{Kind: processor.ErrInternal, Details: "can't start transaction", Err: err}
+	}
+	defer tx.Rollback()
+
+	applied := 0
+	var changed []paymentModel.PaymentEvent
+	if opts.Apply {
+		applied, changed, err = rc.apply(ctx, tx, opts.Actor, opts.MerchantID, discrepancies)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	result, err := rc.store(ctx, paymentModel.WithTx(rc.paymentStore, tx), opts.Actor, paymentModel.CreateReconciliationRunParams{
+		Source:           opts.Source,
+		Actor:            opts.Actor.Name,
+		PeriodFrom:       opts.From.UTC(),
+		PeriodTo:         opts.To.UTC(),
+		AutoApply:        opts.Apply,
+		LineCount:        int32(len(lines)),
+		MatchedCount:     int32(matched),
+		DiscrepancyCount: int32(len(discrepancies)),
+		AppliedCount:     int32(applied),
+		MerchantID:       opts.MerchantID,
+	}, discrepancies)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Result{}, &processor.Error{Kind: processor.ErrInternal, Details: "can't commit reconciliation", Err: err}
+	}
+	rc.processor.Publish(ctx, changed)
+
+	return result, nil
+}
+
+// apply moves payments with status discrepancies to provider statuses and marks discrepancies applied,
+// payment that settlement reports with other amount or currency isn't the same payment, so its status isn't applied
+func (rc *Reconciler) apply(ctx context.Context, tx *sql.Tx, actor audit.Actor, merchantID int64, discrepancies []paymentModel.ReconciliationDiscrepancy) (int, []paymentModel.PaymentEvent, error) {
+	mismatched := make(map[int64]bool)
+	for _, d := range discrepancies {
+		if d.Kind == paymentModel.DiscrepancyKindAmount || d.Kind == paymentModel.DiscrepancyKindCurrency {
+			mismatched[d.PaymentID] = true
+		}
+	}
+	updates := make([]processor.StatusUpdate, 0)
+	for _, d := range discrepancies {
+		if d.Kind == paymentModel.DiscrepancyKindStatus && !mismatched[d.PaymentID] {
+			updates = append(updates, processor.StatusUpdate{ID: d.PaymentID, Status: paymentModel.ValidStatus(d.ProviderValue)})
+		}
+	}
+
+	var outcomes []processor.StatusOutcome
+	var changed []paymentModel.PaymentEvent
+	if len(updates) > 0 {
+		var err error
+		outcomes, changed, err = rc.processor.UpdateStatusesTx(ctx, tx, actor, merchantID, updates)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+	byID := make(map[int64]processor.StatusOutcome, len(outcomes))
+	for _, o := range outcomes {
+		byID[o.ID] = o
+	}
+
+	applied := 0
+	for i, d := range discrepancies {
+		if d.Kind != paymentModel.DiscrepancyKindStatus {
+			continue
+		}
+		if mismatched[d.PaymentID] {
+			discrepancies[i].ApplyError = "payment has amount or currency discrepancy"
+			continue
+		}
+		o := byID[d.PaymentID]
+		if o.Outcome == processor.OutcomeUpdated {
+			discrepancies[i].Applied = true
+			applied++
+			continue
+		}
+		discrepancies[i].ApplyError = o.Error
+	}
+
+	return applied, changed, nil
+}
+
+// store saves run with its discrepancies and its audit log entry, store must be bound to transaction of run
+func (rc *Reconciler) store(ctx context.Context, store paymentModel.Querier, actor audit.Actor, run paymentModel.CreateReconciliationRunParams, discrepancies []paymentModel.ReconciliationDiscrepancy) (Result, error) {
+	var err error
+	result := Result{Discrepancies: make([]paymentModel.ReconciliationDiscrepancy, 0)}
+	result.Run, err = store.CreateReconciliationRun(ctx, run)
+	if err != nil {
+		return Result{}, &processor.Error{Kind: processor.ErrInternal, Details: "can't store reconciliation", Err: err}
+	}
+
+	if len(discrepancies) > 0 {
+		arg := paymentModel.CreateReconciliationDiscrepanciesParams{RunID: result.Run.ID}
+		for _, d := range discrepancies {
+			arg.PaymentIds = append(arg.PaymentIds, d.PaymentID)
+			arg.Kinds = append(arg.Kinds, string(d.Kind))
+			arg.ProviderValues = append(arg.ProviderValues, d.ProviderValue)
+			arg.PaymentValues = append(arg.PaymentValues, d.PaymentValue)
+			arg.Applied = append(arg.Applied, d.Applied)
+			arg.ApplyErrors = append(arg.ApplyErrors, d.ApplyError)
+		}
+		result.Discrepancies, err = store.CreateReconciliationDiscrepancies(ctx, arg)
+		if err != nil {
+			return Result{}, &processor.Error{Kind: processor.ErrInternal, Details: "can't store reconciliation", Err: err}
+		}
+	}
+	if err = audit.Record(ctx, store, actor, result.Run.MerchantID, audit.ActionRunReconciliation, audit.Reconciliation(result.Run.ID)); err != nil {
+		return Result{}, &processor.Error{Kind: processor.ErrInternal, Details: "can't record audit log", Err: err}
+	}
+
+	return result, nil
+}
+
+// describe formats payment or settlement line for missing discrepancy
+func describe(amount decimal.Decimal, currency paymentModel.ValidCurrency, status paymentModel.ValidStatus) string {
+	return fmt.Sprintf("%s %s %s", amount.String(), currency, status)
+}
@@ -0,0 +1,293 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/semka95/payment-service/payment/audit"
+	"github.com/semka95/payment-service/payment/processor"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+func TestParseSettlement(t *testing.T) {
+	cases := []struct {
+		description string
+		file        string
+		expected    []Line
+		expectedErr string
+	}{
+		{
+			description: "success",
+			file:        "Status,ID,Amount,Currency,Fee\nsuccess,1,123.40,USD,1\n failure ,2,0.1,eur,0\n",
+			expected: []Line{
+				{Number: 2, PaymentID: 1, Amount: decimal.RequireFromString("123.40"), Currency: paymentModel.ValidCurrencyUsd, Status: paymentModel.ValidStatusSuccess},
+				{Number: 3, PaymentID: 2, Amount: decimal.RequireFromString("0.1"), Currency: paymentModel.ValidCurrencyEur, Status: paymentModel.ValidStatusFailure},
+			},
+		},
+		{
+			description: "header only",
+			file:        "id,amount,currency,status\n",
+			expected:    []Line{},
+		},
+		{
+			description: "empty file",
+			expectedErr: "invalid settlement file: file is empty",
+		},
+		{
+			description: "missing column",
+			file:        "id,amount,status\n1,10,success\n",
+			expectedErr: "invalid settlement file: currency column is missing",
+		},
+		{
+			description: "bad id",
+			file:        "id,amount,currency,status\n0,10,usd,success\n",
+			expectedErr: `invalid settlement file: line 2: id must be positive integer, got "0"`,
+		},
+		{
+			description: "bad amount",
+			file:        "id,amount,currency,status\n1,ten,usd,success\n",
+			expectedErr: `invalid settlement file: line 2: amount must be decimal, got "ten"`,
+		},
+		{
+			description: "unknown currency",
+			file:        "id,amount,currency,status\n1,10,gbp,success\n",
+			expectedErr: `invalid settlement file: line 2: unknown currency "gbp"`,
+		},
+		{
+			description: "unknown status",
+			file:        "id,amount,currency,status\n1,10,usd,review\n",
+			expectedErr: `invalid settlement file: line 2: unknown status "review"`,
+		},
+		{
+			description: "duplicate payment",
+			file:        "id,amount,currency,status\n1,10,usd,success\n1,10,usd,success\n",
+			expectedErr: "invalid settlement file: line 3: payment 1 is already settled on line 2",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			lines, err := ParseSettlement(strings.NewReader(tc.file))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				assert.ErrorIs(t, err, ErrInvalidSettlement)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, lines)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	from := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	payments := []paymentModel.Payment{
+		{ID: 1, Amount: decimal.RequireFromString("10.00"), Currency: paymentModel.ValidCurrencyUsd, PaymentStatus: paymentModel.ValidStatusSuccess},
+		{ID: 2, Amount: decimal.RequireFromString("20.00"), Currency: paymentModel.ValidCurrencyUsd, PaymentStatus: paymentModel.ValidStatusNew},
+		{ID: 3, Amount: decimal.RequireFromString("30.00"), Currency: paymentModel.ValidCurrencyEur, PaymentStatus: paymentModel.ValidStatusFailure},
+	}
+	lines := []Line{
+		{Number: 2, PaymentID: 1, Amount: decimal.RequireFromString("10"), Currency: paymentModel.ValidCurrencyUsd, Status: paymentModel.ValidStatusSuccess},
+		{Number: 3, PaymentID: 2, Amount: decimal.RequireFromString("20"), Currency: paymentModel.ValidCurrencyUsd, Status: paymentModel.ValidStatusSuccess},
+		{Number: 4, PaymentID: 3, Amount: decimal.RequireFromString("31"), Currency: paymentModel.ValidCurrencyUsd, Status: paymentModel.ValidStatusSuccess},
+		{Number: 5, PaymentID: 7, Amount: decimal.RequireFromString("5"), Currency: paymentModel.ValidCurrencyRub, Status: paymentModel.ValidStatusSuccess},
+	}
+	newStore := func() *paymentModel.QuerierMock {
+		return &paymentModel.QuerierMock{
+			ListPaymentsByIDsFunc: func(ctx context.Context, arg paymentModel.ListPaymentsByIDsParams) ([]paymentModel.Payment, error) {
+				if arg.MerchantID != 1 {
+					return nil, nil
+				}
+				return payments, nil
+			},
+			ListUnsettledPaymentsFunc: func(ctx context.Context, arg paymentModel.ListUnsettledPaymentsParams) ([]paymentModel.Payment, error) {
+				if arg.MerchantID != 1 {
+					return nil, nil
+				}
+				return []paymentModel.Payment{{ID: 4, Amount: decimal.RequireFromString("1.50"), Currency: paymentModel.ValidCurrencyRub, PaymentStatus: paymentModel.ValidStatusNew}}, nil
+			},
+			ListPaymentStatusesFunc: func(ctx context.Context, arg paymentModel.ListPaymentStatusesParams) ([]paymentModel.ListPaymentStatusesRow, error) {
+				return []paymentModel.ListPaymentStatusesRow{{ID: 2, PaymentStatus: paymentModel.ValidStatusNew}, {ID: 3, PaymentStatus: paymentModel.ValidStatusFailure}}, nil
+			},
+			UpdatePaymentStatusFunc: func(ctx context.Context, arg paymentModel.UpdatePaymentStatusParams) (int64, error) {
+				if arg.ID == 3 {
+					return 0, nil
+				}
+				return 1, nil
+			},
+			CreateReconciliationRunFunc: func(ctx context.Context, arg paymentModel.CreateReconciliationRunParams) (paymentModel.ReconciliationRun, error) {
+				return paymentModel.ReconciliationRun{ID: 1, MerchantID: arg.MerchantID, Source: arg.Source, AutoApply: arg.AutoApply, LineCount: arg.LineCount, MatchedCount: arg.MatchedCount, DiscrepancyCount: arg.DiscrepancyCount, AppliedCount: arg.AppliedCount}, nil
+			},
+			CreateReconciliationDiscrepanciesFunc: func(ctx context.Context, arg paymentModel.CreateReconciliationDiscrepanciesParams) ([]paymentModel.ReconciliationDiscrepancy, error) {
+				ds := make([]paymentModel.ReconciliationDiscrepancy, 0, len(arg.PaymentIds))
+				for i := range arg.PaymentIds {
+					ds = append(ds, paymentModel.ReconciliationDiscrepancy{
+						RunID:         arg.RunID,
+						PaymentID:     arg.PaymentIds[i],
+						Kind:          paymentModel.DiscrepancyKind(arg.Kinds[i]),
+						ProviderValue: arg.ProviderValues[i],
+						PaymentValue:  arg.PaymentValues[i],
+						Applied:       arg.Applied[i],
+						ApplyError:    arg.ApplyErrors[i],
+					})
+				}
+				return ds, nil
+			},
+			CreateAuditLogFunc: func(ctx context.Context, arg paymentModel.CreateAuditLogParams) error {
+				return nil
+			},
+			CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]paymentModel.PaymentEvent, error) {
+				return nil, nil
+			},
+		}
+	}
+	discrepancies := func(applied bool) []paymentModel.ReconciliationDiscrepancy {
+		ds := []paymentModel.ReconciliationDiscrepancy{
+			{RunID: 1, PaymentID: 2, Kind: paymentModel.DiscrepancyKindStatus, ProviderValue: "success", PaymentValue: "new"},
+			{RunID: 1, PaymentID: 3, Kind: paymentModel.DiscrepancyKindAmount, ProviderValue: "31", PaymentValue: "30"},
+			{RunID: 1, PaymentID: 3, Kind: paymentModel.DiscrepancyKindCurrency, ProviderValue: "usd", PaymentValue: "eur"},
+			{RunID: 1, PaymentID: 3, Kind: paymentModel.DiscrepancyKindStatus, ProviderValue: "success", PaymentValue: "failure"},
+			{RunID: 1, PaymentID: 7, Kind: paymentModel.DiscrepancyKindMissingPayment, ProviderValue: "5 rub success"},
+			{RunID: 1, PaymentID: 4, Kind: paymentModel.DiscrepancyKindMissingSettlement, PaymentValue: "1.5 rub new"},
+		}
+		if applied {
+			ds[0].Applied = true
+			ds[3].ApplyError = "payment has amount or currency discrepancy"
+		}
+		return ds
+	}
+
+	cases := []struct {
+		description     string
+		opts            Options
+		runErr          error
+		expectSQL       func(mock sqlmock.Sqlmock)
+		checkMockCalls  func(tr *paymentModel.QuerierMock)
+		expectedRun     paymentModel.ReconciliationRun
+		expectedResults []paymentModel.ReconciliationDiscrepancy
+		expectedErr     string
+	}{
+		{
+			description: "report only",
+			opts:        Options{MerchantID: 1, Source: "settlement.csv", From: from, To: to},
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *paymentModel.QuerierMock) {
+				assert.Equal(t, paymentModel.ListPaymentsByIDsParams{Ids: []int64{1, 2, 3, 7}, MerchantID: 1}, tr.ListPaymentsByIDsCalls()[0].Arg)
+				assert.Equal(t, paymentModel.ListUnsettledPaymentsParams{MerchantID: 1, CreatedFrom: from, CreatedTo: to, Ids: []int64{1, 2, 3, 7}}, tr.ListUnsettledPaymentsCalls()[0].Arg)
+				assert.Equal(t, 0, len(tr.UpdatePaymentStatusCalls()))
+			},
+			expectedRun:     paymentModel.ReconciliationRun{ID: 1, MerchantID: 1, Source: "settlement.csv", LineCount: 4, MatchedCount: 1, DiscrepancyCount: 6},
+			expectedResults: discrepancies(false),
+		},
+		{
+			description: "payments of other merchant",
+			opts:        Options{MerchantID: 2, Source: "settlement.csv", From: from, To: to, Apply: true},
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *paymentModel.QuerierMock) {
+				assert.Equal(t, 0, len(tr.ListPaymentStatusesCalls()))
+				assert.Equal(t, 0, len(tr.UpdatePaymentStatusCalls()))
+			},
+			expectedRun: paymentModel.ReconciliationRun{ID: 1, MerchantID: 2, Source: "settlement.csv", AutoApply: true, LineCount: 4, DiscrepancyCount: 4},
+			expectedResults: []paymentModel.ReconciliationDiscrepancy{
+				{RunID: 1, PaymentID: 1, Kind: paymentModel.DiscrepancyKindMissingPayment, ProviderValue: "10 usd success"},
+				{RunID: 1, PaymentID: 2, Kind: paymentModel.DiscrepancyKindMissingPayment, ProviderValue: "20 usd success"},
+				{RunID: 1, PaymentID: 3, Kind: paymentModel.DiscrepancyKindMissingPayment, ProviderValue: "31 usd success"},
+				{RunID: 1, PaymentID: 7, Kind: paymentModel.DiscrepancyKindMissingPayment, ProviderValue: "5 rub success"},
+			},
+		},
+		{
+			description: "apply statuses",
+			opts:        Options{MerchantID: 1, Source: "settlement.csv", From: from, To: to, Apply: true, Actor: audit.Reconcile},
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			checkMockCalls: func(tr *paymentModel.QuerierMock) {
+				// payment 3 has amount and currency discrepancies, so its status isn't applied
+				assert.Equal(t, paymentModel.ListPaymentStatusesParams{Ids: []int64{2}, MerchantID: 1}, tr.ListPaymentStatusesCalls()[0].Arg)
+				calls := tr.UpdatePaymentStatusCalls()
+				require.Equal(t, 1, len(calls))
+				assert.Equal(t, paymentModel.UpdatePaymentStatusParams{ID: 2, PaymentStatus: paymentModel.ValidStatusSuccess, MerchantID: 1}, calls[0].Arg)
+
+				logs := tr.CreateAuditLogCalls()
+				require.Equal(t, 2, len(logs))
+				assert.Equal(t, paymentModel.CreateAuditLogParams{Actor: "cli:reconcile", Action: "payment.update_status", Target: "payment:2", MerchantID: 1}, logs[0].Arg)
+				assert.Equal(t, paymentModel.CreateAuditLogParams{Actor: "cli:reconcile", Action: "reconciliation.run", Target: "reconciliation:1", MerchantID: 1}, logs[1].Arg)
+			},
+			expectedRun:     paymentModel.ReconciliationRun{ID: 1, MerchantID: 1, Source: "settlement.csv", AutoApply: true, LineCount: 4, MatchedCount: 1, DiscrepancyCount: 6, AppliedCount: 1},
+			expectedResults: discrepancies(true),
+		},
+		{
+			description: "applied statuses are rolled back with run",
+			opts:        Options{MerchantID: 1, Source: "settlement.csv", From: from, To: to, Apply: true},
+			runErr:      fmt.Errorf("server error"),
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			checkMockCalls: func(tr *paymentModel.QuerierMock) {
+				assert.Equal(t, 1, len(tr.UpdatePaymentStatusCalls()))
+				assert.Equal(t, 1, len(tr.CreateReconciliationRunCalls()))
+			},
+			expectedErr: "server error",
+		},
+		{
+			description:    "invalid period",
+			opts:           Options{From: to, To: from},
+			expectSQL:      func(mock sqlmock.Sqlmock) {},
+			checkMockCalls: func(tr *paymentModel.QuerierMock) {},
+			expectedErr:    "invalid reconciliation period: from must be before to",
+		},
+		{
+			description: "database error",
+			opts:        Options{From: from, To: to},
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(fmt.Errorf("server error"))
+			},
+			checkMockCalls: func(tr *paymentModel.QuerierMock) {},
+			expectedErr:    "server error",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+
+			store := newStore()
+			if tc.runErr != nil {
+				store.CreateReconciliationRunFunc = func(ctx context.Context, arg paymentModel.CreateReconciliationRunParams) (paymentModel.ReconciliationRun, error) {
+					return paymentModel.ReconciliationRun{}, tc.runErr
+				}
+			}
+			tc.expectSQL(mock)
+
+			result, err := New(store, db, processor.New(store, db, nil, nil)).Run(context.Background(), tc.opts, lines)
+			assert.NoError(t, mock.ExpectationsWereMet())
+			tc.checkMockCalls(store)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedRun, result.Run)
+			assert.Equal(t, tc.expectedResults, result.Discrepancies)
+		})
+	}
+}
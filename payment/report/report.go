@@ -0,0 +1,180 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Time buckets, payments are grouped by creation time truncated to bucket in UTC
+const (
+	BucketHour  = "hour"
+	BucketDay   = "day"
+	BucketMonth = "month"
+)
+
+// Report sources
+const (
+	SourcePayments = "payments"
+	SourceSummary  = "summary"
+)
+
+// ErrInvalidQuery is returned when report query is invalid
+var ErrInvalidQuery = errors.New("invalid report query")
+
+// Query selects payments of merchant created in [From, To) range, zero UserID selects payments of all users
+type Query struct {
+	MerchantID int64
+	From       time.Time
+	To         time.Time
+	Bucket     string
+	UserID     int64
+}
+
+// Row is number and exact sum of payments with currency and status created in bucket
+type Row struct {
+	Bucket   time.Time                  `json:"bucket"`
+	Currency paymentModel.ValidCurrency `json:"currency"`
+	Status   paymentModel.ValidStatus   `json:"status"`
+	Count    int64                      `json:"count"`
+	Amount   decimal.Decimal            `json:"amount"`
+}
+
+// Reporter aggregates payments, daily summary is used for day and month buckets
+// of whole days if it is enabled, otherwise payments are aggregated directly
+type Reporter struct {
+	paymentStore paymentModel.Querier
+	summary      bool
+}
+
+// NewReporter creates payments reporter
+func NewReporter(paymentStore paymentModel.Querier, summary bool) *Reporter {
+	return &Reporter{
+		paymentStore: paymentStore,
+		summary:      summary,
+	}
+}
+
+// Report returns rows ordered by bucket, currency and status and source they are aggregated from
+func (r *Reporter) Report(ctx context.Context, q Query) ([]Row, string, error) {
+	switch q.Bucket {
+	case BucketHour, BucketDay, BucketMonth:
+	default:
+		return nil, "", fmt.Errorf("%w: unknown bucket %q", ErrInvalidQuery, q.Bucket)
+	}
+	q.From, q.To = q.From.UTC(), q.To.UTC()
+	if !q.From.Before(q.To) {
+		return nil, "", fmt.Errorf("%w: from must be before to", ErrInvalidQuery)
+	}
+
+	rows := make([]Row, 0)
+	if r.summary && q.Bucket != BucketHour && isDay(q.From) && isDay(q.To) {
+		summaries, err := r.paymentStore.ReportDailySummaries(ctx, paymentModel.ReportDailySummariesParams{
+			Bucket:     q.Bucket,
+			MerchantID: q.MerchantID,
+			DayFrom:    q.From,
+			DayTo:      q.To,
+			UserID:     q.UserID,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		for _, s := range summaries {
+			rows = append(rows, Row{Bucket: s.Bucket, Currency: s.Currency, Status: s.PaymentStatus, Count: s.PaymentCount, Amount: s.Amount})
+		}
+		return rows, SourceSummary, nil
+	}
+
+	payments, err := r.paymentStore.ReportPayments(ctx, paymentModel.ReportPaymentsParams{
+		Bucket:      q.Bucket,
+		MerchantID:  q.MerchantID,
+		CreatedFrom: q.From,
+		CreatedTo:   q.To,
+		UserID:      q.UserID,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	for _, p := range payments {
+		rows = append(rows, Row{Bucket: p.Bucket, Currency: p.Currency, Status: p.PaymentStatus, Count: p.PaymentCount, Amount: p.Amount})
+	}
+
+	return rows, SourcePayments, nil
+}
+
+func isDay(t time.Time) bool {
+	return t.Equal(t.Truncate(24 * time.Hour))
+}
+
+// Refresher rebuilds daily summary, whole summary is rebuilt on start, then last days are
+// rebuilt every interval, since statuses of recent payments still change
+type Refresher struct {
+	paymentStore paymentModel.Querier
+	db           *sql.DB
+	logger       *zap.Logger
+	interval     time.Duration
+	days         int
+	now          func() time.Time
+}
+
+// NewRefresher creates daily summary refresher
+func NewRefresher(paymentStore paymentModel.Querier, db *sql.DB, logger *zap.Logger, interval time.Duration, days int) *Refresher {
+	return &Refresher{
+		paymentStore: paymentStore,
+		db:           db,
+		logger:       logger,
+		interval:     interval,
+		days:         days,
+		now:          time.Now,
+	}
+}
+
+// Run refreshes daily summary until context is canceled
+func (r *Refresher) Run(ctx context.Context) {
+	if err := r.refresh(ctx, time.Time{}); err != nil {
+		r.logger.Error("can't rebuild daily summary", zap.Error(err))
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			today := r.now().UTC().Truncate(24 * time.Hour)
+			if err := r.refresh(ctx, today.AddDate(0, 0, 1-r.days)); err != nil {
+				r.logger.Error("can't refresh daily summary", zap.Error(err))
+			}
+		}
+	}
+}
+
+// refresh rebuilds summary of days from day of from till today in one transaction
+func (r *Refresher) refresh(ctx context.Context, from time.Time) error {
+	to := r.now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	store := paymentModel.WithTx(r.paymentStore, tx)
+
+	if err := store.DeleteDailySummaries(ctx, paymentModel.DeleteDailySummariesParams{DayFrom: from, DayTo: to}); err != nil {
+		return err
+	}
+	if err := store.CreateDailySummaries(ctx, paymentModel.CreateDailySummariesParams{DayFrom: from, DayTo: to}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
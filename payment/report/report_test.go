@@ -0,0 +1,151 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+func TestReport(t *testing.T) {
+	day := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	row := Row{Bucket: day, Currency: paymentModel.ValidCurrencyUsd, Status: paymentModel.ValidStatusSuccess, Count: 2, Amount: decimal.RequireFromString("0.30")}
+
+	cases := []struct {
+		description    string
+		summary        bool
+		query          Query
+		err            error
+		expectedSource string
+		expectedErr    string
+	}{
+		{
+			description:    "payments",
+			query:          Query{From: day, To: day.AddDate(0, 0, 1), Bucket: BucketDay, UserID: 2},
+			expectedSource: SourcePayments,
+		},
+		{
+			description:    "summary for whole days",
+			summary:        true,
+			query:          Query{From: day, To: day.AddDate(0, 1, 0), Bucket: BucketMonth},
+			expectedSource: SourceSummary,
+		},
+		{
+			description:    "payments for hour bucket",
+			summary:        true,
+			query:          Query{From: day, To: day.AddDate(0, 0, 1), Bucket: BucketHour},
+			expectedSource: SourcePayments,
+		},
+		{
+			description:    "payments for part of day",
+			summary:        true,
+			query:          Query{From: day.Add(time.Hour), To: day.AddDate(0, 0, 1), Bucket: BucketDay},
+			expectedSource: SourcePayments,
+		},
+		{
+			description: "unknown bucket",
+			query:       Query{From: day, To: day.AddDate(0, 0, 1), Bucket: "week"},
+			expectedErr: `invalid report query: unknown bucket "week"`,
+		},
+		{
+			description: "empty range",
+			query:       Query{From: day, To: day, Bucket: BucketDay},
+			expectedErr: "invalid report query: from must be before to",
+		},
+		{
+			description: "repository error",
+			query:       Query{From: day, To: day.AddDate(0, 0, 1), Bucket: BucketDay},
+			err:         fmt.Errorf("server error"),
+			expectedErr: "server error",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			mockedStore := &paymentModel.QuerierMock{
+				ReportPaymentsFunc: func(ctx context.Context, arg paymentModel.ReportPaymentsParams) ([]paymentModel.ReportPaymentsRow, error) {
+					assert.Equal(t, paymentModel.ReportPaymentsParams{Bucket: tc.query.Bucket, CreatedFrom: tc.query.From, CreatedTo: tc.query.To, UserID: tc.query.UserID}, arg)
+					return []paymentModel.ReportPaymentsRow{{Bucket: row.Bucket, Currency: row.Currency, PaymentStatus: row.Status, PaymentCount: row.Count, Amount: row.Amount}}, tc.err
+				},
+				ReportDailySummariesFunc: func(ctx context.Context, arg paymentModel.ReportDailySummariesParams) ([]paymentModel.ReportDailySummariesRow, error) {
+					assert.Equal(t, paymentModel.ReportDailySummariesParams{Bucket: tc.query.Bucket, DayFrom: tc.query.From, DayTo: tc.query.To, UserID: tc.query.UserID}, arg)
+					return []paymentModel.ReportDailySummariesRow{{Bucket: row.Bucket, Currency: row.Currency, PaymentStatus: row.Status, PaymentCount: row.Count, Amount: row.Amount}}, tc.err
+				},
+			}
+
+			rows, source, err := NewReporter(mockedStore, tc.summary).Report(context.Background(), tc.query)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedSource, source)
+			assert.Equal(t, []Row{row}, rows)
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	now := time.Date(2022, 6, 10, 15, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		description string
+		from        time.Time
+		expectSQL   func(mock sqlmock.Sqlmock)
+		expectedErr string
+	}{
+		{
+			description: "last days",
+			from:        time.Date(2022, 6, 9, 0, 0, 0, 0, time.UTC),
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM payment_daily_summaries").
+					WithArgs(time.Date(2022, 6, 9, 0, 0, 0, 0, time.UTC), time.Date(2022, 6, 11, 0, 0, 0, 0, time.UTC)).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectExec("INSERT INTO payment_daily_summaries").
+					WithArgs(time.Date(2022, 6, 9, 0, 0, 0, 0, time.UTC), time.Date(2022, 6, 11, 0, 0, 0, 0, time.UTC)).
+					WillReturnResult(sqlmock.NewResult(0, 4))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			description: "database error",
+			expectSQL: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM payment_daily_summaries").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("INSERT INTO payment_daily_summaries").WillReturnError(fmt.Errorf("server error"))
+				mock.ExpectRollback()
+			},
+			expectedErr: "server error",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+
+			refresher := NewRefresher(paymentModel.New(db), db, zap.NewNop(), time.Minute, 2)
+			refresher.now = func() time.Time { return now }
+
+			tc.expectSQL(mock)
+
+			err = refresher.refresh(context.Background(), tc.from)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,4 @@
+package repository
+
+// UniqueViolation is postgres error code for unique constraint violation
+const UniqueViolation = "23505"
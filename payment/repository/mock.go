@@ -5,7 +5,9 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
+	"time"
 )
 
 // Ensure, that QuerierMock does implement Querier.
@@ -18,24 +20,177 @@ var _ Querier = &QuerierMock{}
 //
 // 		// make and configure a mocked Querier
 // 		mockedQuerier := &QuerierMock{
+// 			AddDisputeEvidenceFunc: func(ctx context.Context, arg AddDisputeEvidenceParams) (Dispute, error) {
+// 				panic("mock out the AddDisputeEvidence method")
+// 			},
+// 			ClaimDueSettlementsFunc: func(ctx context.Context, arg ClaimDueSettlementsParams) ([]Payment, error) {
+// 				panic("mock out the ClaimDueSettlements method")
+// 			},
+// 			CountRecentPaymentsFunc: func(ctx context.Context, arg CountRecentPaymentsParams) (int64, error) {
+// 				panic("mock out the CountRecentPayments method")
+// 			},
+// 			CreateAPIKeyFunc: func(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error) {
+// 				panic("mock out the CreateAPIKey method")
+// 			},
+// 			CreateAuditLogFunc: func(ctx context.Context, arg CreateAuditLogParams) error {
+// 				panic("mock out the CreateAuditLog method")
+// 			},
+// 			CreateCallbackNonceFunc: func(ctx context.Context, nonce string) (int64, error) {
+// 				panic("mock out the CreateCallbackNonce method")
+// 			},
+// 			CreateDailySummariesFunc: func(ctx context.Context, arg CreateDailySummariesParams) error {
+// 				panic("mock out the CreateDailySummaries method")
+// 			},
+// 			CreateDisputeFunc: func(ctx context.Context, arg CreateDisputeParams) (Dispute, error) {
+// 				panic("mock out the CreateDispute method")
+// 			},
+// 			CreateIdempotencyKeysFunc: func(ctx context.Context, arg CreateIdempotencyKeysParams) error {
+// 				panic("mock out the CreateIdempotencyKeys method")
+// 			},
+// 			CreateMerchantFunc: func(ctx context.Context, name string) (Merchant, error) {
+// 				panic("mock out the CreateMerchant method")
+// 			},
 // 			CreatePaymentFunc: func(ctx context.Context, arg CreatePaymentParams) (Payment, error) {
 // 				panic("mock out the CreatePayment method")
 // 			},
-// 			DiscardPaymentFunc: func(ctx context.Context, id int64) (int64, error) {
+// 			CreatePaymentEventsFunc: func(ctx context.Context, ids []int64) ([]PaymentEvent, error) {
+// 				panic("mock out the CreatePaymentEvents method")
+// 			},
+// 			CreatePaymentReviewFunc: func(ctx context.Context, arg CreatePaymentReviewParams) (PaymentReview, error) {
+// 				panic("mock out the CreatePaymentReview method")
+// 			},
+// 			CreatePaymentsFunc: func(ctx context.Context, payments json.RawMessage) ([]Payment, error) {
+// 				panic("mock out the CreatePayments method")
+// 			},
+// 			CreateReconciliationDiscrepanciesFunc: func(ctx context.Context, arg CreateReconciliationDiscrepanciesParams) ([]ReconciliationDiscrepancy, error) {
+// 				panic("mock out the CreateReconciliationDiscrepancies method")
+// 			},
+// 			CreateReconciliationRunFunc: func(ctx context.Context, arg CreateReconciliationRunParams) (ReconciliationRun, error) {
+// 				panic("mock out the CreateReconciliationRun method")
+// 			},
+// 			CreateReversalFunc: func(ctx context.Context, arg CreateReversalParams) (Reversal, error) {
+// 				panic("mock out the CreateReversal method")
+// 			},
+// 			CreateUserFunc: func(ctx context.Context, arg CreateUserParams) (User, error) {
+// 				panic("mock out the CreateUser method")
+// 			},
+// 			DeleteCallbackNoncesFunc: func(ctx context.Context, createdAt time.Time) (int64, error) {
+// 				panic("mock out the DeleteCallbackNonces method")
+// 			},
+// 			DeleteDailySummariesFunc: func(ctx context.Context, arg DeleteDailySummariesParams) error {
+// 				panic("mock out the DeleteDailySummaries method")
+// 			},
+// 			DeleteUserFunc: func(ctx context.Context, name string) (int64, error) {
+// 				panic("mock out the DeleteUser method")
+// 			},
+// 			DiscardPaymentFunc: func(ctx context.Context, arg DiscardPaymentParams) (int64, error) {
 // 				panic("mock out the DiscardPayment method")
 // 			},
-// 			GetPaymentStatusByIDFunc: func(ctx context.Context, id int64) (ValidStatus, error) {
+// 			ExpirePaymentsFunc: func(ctx context.Context, limit int32) ([]Payment, error) {
+// 				panic("mock out the ExpirePayments method")
+// 			},
+// 			GetAPIKeyByHashFunc: func(ctx context.Context, keyHash string) (ApiKey, error) {
+// 				panic("mock out the GetAPIKeyByHash method")
+// 			},
+// 			GetDisputeStatusFunc: func(ctx context.Context, arg GetDisputeStatusParams) (DisputeStatus, error) {
+// 				panic("mock out the GetDisputeStatus method")
+// 			},
+// 			GetPaymentByIDFunc: func(ctx context.Context, arg GetPaymentByIDParams) (Payment, error) {
+// 				panic("mock out the GetPaymentByID method")
+// 			},
+// 			GetPaymentByMerchantReferenceFunc: func(ctx context.Context, arg GetPaymentByMerchantReferenceParams) (Payment, error) {
+// 				panic("mock out the GetPaymentByMerchantReference method")
+// 			},
+// 			GetPaymentStatusByIDFunc: func(ctx context.Context, arg GetPaymentStatusByIDParams) (ValidStatus, error) {
 // 				panic("mock out the GetPaymentStatusByID method")
 // 			},
-// 			ListUserPaymentsByEmailFunc: func(ctx context.Context, arg ListUserPaymentsByEmailParams) ([]Payment, error) {
-// 				panic("mock out the ListUserPaymentsByEmail method")
+// 			GetReconciliationRunFunc: func(ctx context.Context, arg GetReconciliationRunParams) (ReconciliationRun, error) {
+// 				panic("mock out the GetReconciliationRun method")
+// 			},
+// 			GetUserByNameFunc: func(ctx context.Context, name string) (User, error) {
+// 				panic("mock out the GetUserByName method")
+// 			},
+// 			IsPaymentReversedFunc: func(ctx context.Context, paymentID int64) (bool, error) {
+// 				panic("mock out the IsPaymentReversed method")
+// 			},
+// 			ListAPIKeysFunc: func(ctx context.Context, merchantID int64) ([]ApiKey, error) {
+// 				panic("mock out the ListAPIKeys method")
+// 			},
+// 			ListIdempotencyKeysFunc: func(ctx context.Context, arg ListIdempotencyKeysParams) ([]IdempotencyKey, error) {
+// 				panic("mock out the ListIdempotencyKeys method")
+// 			},
+// 			ListMerchantsFunc: func(ctx context.Context) ([]Merchant, error) {
+// 				panic("mock out the ListMerchants method")
+// 			},
+// 			ListPaymentDisputesFunc: func(ctx context.Context, arg ListPaymentDisputesParams) ([]Dispute, error) {
+// 				panic("mock out the ListPaymentDisputes method")
+// 			},
+// 			ListPaymentEventsFunc: func(ctx context.Context, arg ListPaymentEventsParams) ([]PaymentEvent, error) {
+// 				panic("mock out the ListPaymentEvents method")
 // 			},
-// 			ListUserPaymentsByIDFunc: func(ctx context.Context, arg ListUserPaymentsByIDParams) ([]Payment, error) {
-// 				panic("mock out the ListUserPaymentsByID method")
+// 			ListPaymentStatusesFunc: func(ctx context.Context, arg ListPaymentStatusesParams) ([]ListPaymentStatusesRow, error) {
+// 				panic("mock out the ListPaymentStatuses method")
+// 			},
+// 			ListPaymentsByIDsFunc: func(ctx context.Context, arg ListPaymentsByIDsParams) ([]Payment, error) {
+// 				panic("mock out the ListPaymentsByIDs method")
+// 			},
+// 			ListReconciliationDiscrepanciesFunc: func(ctx context.Context, arg ListReconciliationDiscrepanciesParams) ([]ReconciliationDiscrepancy, error) {
+// 				panic("mock out the ListReconciliationDiscrepancies method")
+// 			},
+// 			ListReconciliationRunsFunc: func(ctx context.Context, arg ListReconciliationRunsParams) ([]ReconciliationRun, error) {
+// 				panic("mock out the ListReconciliationRuns method")
+// 			},
+// 			ListReviewPaymentsFunc: func(ctx context.Context, arg ListReviewPaymentsParams) ([]Payment, error) {
+// 				panic("mock out the ListReviewPayments method")
+// 			},
+// 			ListUnscheduledPaymentsFunc: func(ctx context.Context, limit int32) ([]ListUnscheduledPaymentsRow, error) {
+// 				panic("mock out the ListUnscheduledPayments method")
+// 			},
+// 			ListUnsettledPaymentsFunc: func(ctx context.Context, arg ListUnsettledPaymentsParams) ([]Payment, error) {
+// 				panic("mock out the ListUnsettledPayments method")
+// 			},
+// 			ListUsedMerchantReferencesFunc: func(ctx context.Context, arg ListUsedMerchantReferencesParams) ([]string, error) {
+// 				panic("mock out the ListUsedMerchantReferences method")
+// 			},
+// 			ListUsersFunc: func(ctx context.Context) ([]User, error) {
+// 				panic("mock out the ListUsers method")
+// 			},
+// 			NotifyPaymentEventsFunc: func(ctx context.Context, arg NotifyPaymentEventsParams) error {
+// 				panic("mock out the NotifyPaymentEvents method")
+// 			},
+// 			ReportDailySummariesFunc: func(ctx context.Context, arg ReportDailySummariesParams) ([]ReportDailySummariesRow, error) {
+// 				panic("mock out the ReportDailySummaries method")
+// 			},
+// 			ReportPaymentsFunc: func(ctx context.Context, arg ReportPaymentsParams) ([]ReportPaymentsRow, error) {
+// 				panic("mock out the ReportPayments method")
+// 			},
+// 			ReservePaymentIDsFunc: func(ctx context.Context, count int32) ([]int64, error) {
+// 				panic("mock out the ReservePaymentIDs method")
+// 			},
+// 			ResolveDisputeFunc: func(ctx context.Context, arg ResolveDisputeParams) (Dispute, error) {
+// 				panic("mock out the ResolveDispute method")
+// 			},
+// 			ReviewPaymentFunc: func(ctx context.Context, arg ReviewPaymentParams) (int64, error) {
+// 				panic("mock out the ReviewPayment method")
+// 			},
+// 			RevokeAPIKeyFunc: func(ctx context.Context, arg RevokeAPIKeyParams) (int64, error) {
+// 				panic("mock out the RevokeAPIKey method")
+// 			},
+// 			RotateAPIKeyFunc: func(ctx context.Context, arg RotateAPIKeyParams) (ApiKey, error) {
+// 				panic("mock out the RotateAPIKey method")
+// 			},
+// 			ScheduleSettlementsFunc: func(ctx context.Context, arg ScheduleSettlementsParams) error {
+// 				panic("mock out the ScheduleSettlements method")
 // 			},
 // 			UpdatePaymentStatusFunc: func(ctx context.Context, arg UpdatePaymentStatusParams) (int64, error) {
 // 				panic("mock out the UpdatePaymentStatus method")
 // 			},
+// 			UpdateUserPasswordFunc: func(ctx context.Context, arg UpdateUserPasswordParams) (int64, error) {
+// 				panic("mock out the UpdateUserPassword method")
+// 			},
+// 			UpdateUserRoleFunc: func(ctx context.Context, arg UpdateUserRoleParams) (int64, error) {
+// 				panic("mock out the UpdateUserRole method")
+// 			},
 // 		}
 //
 // 		// use mockedQuerier in code that requires Querier
@@ -43,249 +198,2513 @@ var _ Querier = &QuerierMock{}
 //
 // 	}
 type QuerierMock struct {
+	// AddDisputeEvidenceFunc mocks the AddDisputeEvidence method.
+	AddDisputeEvidenceFunc func(ctx context.Context, arg AddDisputeEvidenceParams) (Dispute, error)
+
+	// ClaimDueSettlementsFunc mocks the ClaimDueSettlements method.
+	ClaimDueSettlementsFunc func(ctx context.Context, arg ClaimDueSettlementsParams) ([]Payment, error)
+
+	// CountRecentPaymentsFunc mocks the CountRecentPayments method.
+	CountRecentPaymentsFunc func(ctx context.Context, arg CountRecentPaymentsParams) (int64, error)
+
+	// CreateAPIKeyFunc mocks the CreateAPIKey method.
+	CreateAPIKeyFunc func(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
+
+	// CreateAuditLogFunc mocks the CreateAuditLog method.
+	CreateAuditLogFunc func(ctx context.Context, arg CreateAuditLogParams) error
+
+	// CreateCallbackNonceFunc mocks the CreateCallbackNonce method.
+	CreateCallbackNonceFunc func(ctx context.Context, nonce string) (int64, error)
+
+	// CreateDailySummariesFunc mocks the CreateDailySummaries method.
+	CreateDailySummariesFunc func(ctx context.Context, arg CreateDailySummariesParams) error
+
+	// CreateDisputeFunc mocks the CreateDispute method.
+	CreateDisputeFunc func(ctx context.Context, arg CreateDisputeParams) (Dispute, error)
+
+	// CreateIdempotencyKeysFunc mocks the CreateIdempotencyKeys method.
+	CreateIdempotencyKeysFunc func(ctx context.Context, arg CreateIdempotencyKeysParams) error
+
+	// CreateMerchantFunc mocks the CreateMerchant method.
+	CreateMerchantFunc func(ctx context.Context, name string) (Merchant, error)
+
 	// CreatePaymentFunc mocks the CreatePayment method.
 	CreatePaymentFunc func(ctx context.Context, arg CreatePaymentParams) (Payment, error)
 
-	// DiscardPaymentFunc mocks the DiscardPayment method.
-	DiscardPaymentFunc func(ctx context.Context, id int64) (int64, error)
+	// CreatePaymentEventsFunc mocks the CreatePaymentEvents method.
+	CreatePaymentEventsFunc func(ctx context.Context, ids []int64) ([]PaymentEvent, error)
+
+	// CreatePaymentReviewFunc mocks the CreatePaymentReview method.
+	CreatePaymentReviewFunc func(ctx context.Context, arg CreatePaymentReviewParams) (PaymentReview, error)
+
+	// CreatePaymentsFunc mocks the CreatePayments method.
+	CreatePaymentsFunc func(ctx context.Context, payments json.RawMessage) ([]Payment, error)
+
+	// CreateReconciliationDiscrepanciesFunc mocks the CreateReconciliationDiscrepancies method.
+	CreateReconciliationDiscrepanciesFunc func(ctx context.Context, arg CreateReconciliationDiscrepanciesParams) ([]ReconciliationDiscrepancy, error)
+
+	// CreateReconciliationRunFunc mocks the CreateReconciliationRun method.
+	CreateReconciliationRunFunc func(ctx context.Context, arg CreateReconciliationRunParams) (ReconciliationRun, error)
+
+	// CreateReversalFunc mocks the CreateReversal method.
+	CreateReversalFunc func(ctx context.Context, arg CreateReversalParams) (Reversal, error)
+
+	// CreateUserFunc mocks the CreateUser method.
+	CreateUserFunc func(ctx context.Context, arg CreateUserParams) (User, error)
+
+	// DeleteCallbackNoncesFunc mocks the DeleteCallbackNonces method.
+	DeleteCallbackNoncesFunc func(ctx context.Context, createdAt time.Time) (int64, error)
+
+	// DeleteDailySummariesFunc mocks the DeleteDailySummaries method.
+	DeleteDailySummariesFunc func(ctx context.Context, arg DeleteDailySummariesParams) error
+
+	// DeleteUserFunc mocks the DeleteUser method.
+	DeleteUserFunc func(ctx context.Context, name string) (int64, error)
+
+	// DiscardPaymentFunc mocks the DiscardPayment method.
+	DiscardPaymentFunc func(ctx context.Context, arg DiscardPaymentParams) (int64, error)
+
+	// ExpirePaymentsFunc mocks the ExpirePayments method.
+	ExpirePaymentsFunc func(ctx context.Context, limit int32) ([]Payment, error)
+
+	// GetAPIKeyByHashFunc mocks the GetAPIKeyByHash method.
+	GetAPIKeyByHashFunc func(ctx context.Context, keyHash string) (ApiKey, error)
+
+	// GetDisputeStatusFunc mocks the GetDisputeStatus method.
+	GetDisputeStatusFunc func(ctx context.Context, arg GetDisputeStatusParams) (DisputeStatus, error)
+
+	// GetPaymentByIDFunc mocks the GetPaymentByID method.
+	GetPaymentByIDFunc func(ctx context.Context, arg GetPaymentByIDParams) (Payment, error)
+
+	// GetPaymentByMerchantReferenceFunc mocks the GetPaymentByMerchantReference method.
+	GetPaymentByMerchantReferenceFunc func(ctx context.Context, arg GetPaymentByMerchantReferenceParams) (Payment, error)
+
+	// GetPaymentStatusByIDFunc mocks the GetPaymentStatusByID method.
+	GetPaymentStatusByIDFunc func(ctx context.Context, arg GetPaymentStatusByIDParams) (ValidStatus, error)
+
+	// GetReconciliationRunFunc mocks the GetReconciliationRun method.
+	GetReconciliationRunFunc func(ctx context.Context, arg GetReconciliationRunParams) (ReconciliationRun, error)
+
+	// GetUserByNameFunc mocks the GetUserByName method.
+	GetUserByNameFunc func(ctx context.Context, name string) (User, error)
+
+	// IsPaymentReversedFunc mocks the IsPaymentReversed method.
+	IsPaymentReversedFunc func(ctx context.Context, paymentID int64) (bool, error)
+
+	// ListAPIKeysFunc mocks the ListAPIKeys method.
+	ListAPIKeysFunc func(ctx context.Context, merchantID int64) ([]ApiKey, error)
+
+	// ListIdempotencyKeysFunc mocks the ListIdempotencyKeys method.
+	ListIdempotencyKeysFunc func(ctx context.Context, arg ListIdempotencyKeysParams) ([]IdempotencyKey, error)
+
+	// ListMerchantsFunc mocks the ListMerchants method.
+	ListMerchantsFunc func(ctx context.Context) ([]Merchant, error)
+
+	// ListPaymentDisputesFunc mocks the ListPaymentDisputes method.
+	ListPaymentDisputesFunc func(ctx context.Context, arg ListPaymentDisputesParams) ([]Dispute, error)
+
+	// ListPaymentEventsFunc mocks the ListPaymentEvents method.
+	ListPaymentEventsFunc func(ctx context.Context, arg ListPaymentEventsParams) ([]PaymentEvent, error)
+
+	// ListPaymentStatusesFunc mocks the ListPaymentStatuses method.
+	ListPaymentStatusesFunc func(ctx context.Context, arg ListPaymentStatusesParams) ([]ListPaymentStatusesRow, error)
+
+	// ListPaymentsByIDsFunc mocks the ListPaymentsByIDs method.
+	ListPaymentsByIDsFunc func(ctx context.Context, arg ListPaymentsByIDsParams) ([]Payment, error)
+
+	// ListReconciliationDiscrepanciesFunc mocks the ListReconciliationDiscrepancies method.
+	ListReconciliationDiscrepanciesFunc func(ctx context.Context, arg ListReconciliationDiscrepanciesParams) ([]ReconciliationDiscrepancy, error)
+
+	// ListReconciliationRunsFunc mocks the ListReconciliationRuns method.
+	ListReconciliationRunsFunc func(ctx context.Context, arg ListReconciliationRunsParams) ([]ReconciliationRun, error)
+
+	// ListReviewPaymentsFunc mocks the ListReviewPayments method.
+	ListReviewPaymentsFunc func(ctx context.Context, arg ListReviewPaymentsParams) ([]Payment, error)
+
+	// ListUnscheduledPaymentsFunc mocks the ListUnscheduledPayments method.
+	ListUnscheduledPaymentsFunc func(ctx context.Context, limit int32) ([]ListUnscheduledPaymentsRow, error)
+
+	// ListUnsettledPaymentsFunc mocks the ListUnsettledPayments method.
+	ListUnsettledPaymentsFunc func(ctx context.Context, arg ListUnsettledPaymentsParams) ([]Payment, error)
+
+	// ListUsedMerchantReferencesFunc mocks the ListUsedMerchantReferences method.
+	ListUsedMerchantReferencesFunc func(ctx context.Context, arg ListUsedMerchantReferencesParams) ([]string, error)
+
+	// ListUsersFunc mocks the ListUsers method.
+	ListUsersFunc func(ctx context.Context) ([]User, error)
+
+	// NotifyPaymentEventsFunc mocks the NotifyPaymentEvents method.
+	NotifyPaymentEventsFunc func(ctx context.Context, arg NotifyPaymentEventsParams) error
+
+	// ReportDailySummariesFunc mocks the ReportDailySummaries method.
+	ReportDailySummariesFunc func(ctx context.Context, arg ReportDailySummariesParams) ([]ReportDailySummariesRow, error)
+
+	// ReportPaymentsFunc mocks the ReportPayments method.
+	ReportPaymentsFunc func(ctx context.Context, arg ReportPaymentsParams) ([]ReportPaymentsRow, error)
+
+	// ReservePaymentIDsFunc mocks the ReservePaymentIDs method.
+	ReservePaymentIDsFunc func(ctx context.Context, count int32) ([]int64, error)
+
+	// ResolveDisputeFunc mocks the ResolveDispute method.
+	ResolveDisputeFunc func(ctx context.Context, arg ResolveDisputeParams) (Dispute, error)
+
+	// ReviewPaymentFunc mocks the ReviewPayment method.
+	ReviewPaymentFunc func(ctx context.Context, arg ReviewPaymentParams) (int64, error)
+
+	// RevokeAPIKeyFunc mocks the RevokeAPIKey method.
+	RevokeAPIKeyFunc func(ctx context.Context, arg RevokeAPIKeyParams) (int64, error)
+
+	// RotateAPIKeyFunc mocks the RotateAPIKey method.
+	RotateAPIKeyFunc func(ctx context.Context, arg RotateAPIKeyParams) (ApiKey, error)
+
+	// ScheduleSettlementsFunc mocks the ScheduleSettlements method.
+	ScheduleSettlementsFunc func(ctx context.Context, arg ScheduleSettlementsParams) error
+
+	// UpdatePaymentStatusFunc mocks the UpdatePaymentStatus method.
+	UpdatePaymentStatusFunc func(ctx context.Context, arg UpdatePaymentStatusParams) (int64, error)
+
+	// UpdateUserPasswordFunc mocks the UpdateUserPassword method.
+	UpdateUserPasswordFunc func(ctx context.Context, arg UpdateUserPasswordParams) (int64, error)
+
+	// UpdateUserRoleFunc mocks the UpdateUserRole method.
+	UpdateUserRoleFunc func(ctx context.Context, arg UpdateUserRoleParams) (int64, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// AddDisputeEvidence holds details about calls to the AddDisputeEvidence method.
+		AddDisputeEvidence []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg AddDisputeEvidenceParams
+		}
+		// ClaimDueSettlements holds details about calls to the ClaimDueSettlements method.
+		ClaimDueSettlements []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ClaimDueSettlementsParams
+		}
+		// CountRecentPayments holds details about calls to the CountRecentPayments method.
+		CountRecentPayments []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg CountRecentPaymentsParams
+		}
+		// CreateAPIKey holds details about calls to the CreateAPIKey method.
+		CreateAPIKey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg CreateAPIKeyParams
+		}
+		// CreateAuditLog holds details about calls to the CreateAuditLog method.
+		CreateAuditLog []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg CreateAuditLogParams
+		}
+		// CreateCallbackNonce holds details about calls to the CreateCallbackNonce method.
+		CreateCallbackNonce []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Nonce is the nonce argument value.
+			Nonce string
+		}
+		// CreateDailySummaries holds details about calls to the CreateDailySummaries method.
+		CreateDailySummaries []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg CreateDailySummariesParams
+		}
+		// CreateDispute holds details about calls to the CreateDispute method.
+		CreateDispute []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg CreateDisputeParams
+		}
+		// CreateIdempotencyKeys holds details about calls to the CreateIdempotencyKeys method.
+		CreateIdempotencyKeys []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg CreateIdempotencyKeysParams
+		}
+		// CreateMerchant holds details about calls to the CreateMerchant method.
+		CreateMerchant []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Name is the name argument value.
+			Name string
+		}
+		// CreatePayment holds details about calls to the CreatePayment method.
+		CreatePayment []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg CreatePaymentParams
+		}
+		// CreatePaymentEvents holds details about calls to the CreatePaymentEvents method.
+		CreatePaymentEvents []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Ids is the ids argument value.
+			Ids []int64
+		}
+		// CreatePaymentReview holds details about calls to the CreatePaymentReview method.
+		CreatePaymentReview []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg CreatePaymentReviewParams
+		}
+		// CreatePayments holds details about calls to the CreatePayments method.
+		CreatePayments []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Payments is the payments argument value.
+			Payments json.RawMessage
+		}
+		// CreateReconciliationDiscrepancies holds details about calls to the CreateReconciliationDiscrepancies method.
+		CreateReconciliationDiscrepancies []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg CreateReconciliationDiscrepanciesParams
+		}
+		// CreateReconciliationRun holds details about calls to the CreateReconciliationRun method.
+		CreateReconciliationRun []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg CreateReconciliationRunParams
+		}
+		// CreateReversal holds details about calls to the CreateReversal method.
+		CreateReversal []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg CreateReversalParams
+		}
+		// CreateUser holds details about calls to the CreateUser method.
+		CreateUser []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg CreateUserParams
+		}
+		// DeleteCallbackNonces holds details about calls to the DeleteCallbackNonces method.
+		DeleteCallbackNonces []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// CreatedAt is the createdAt argument value.
+			CreatedAt time.Time
+		}
+		// DeleteDailySummaries holds details about calls to the DeleteDailySummaries method.
+		DeleteDailySummaries []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg DeleteDailySummariesParams
+		}
+		// DeleteUser holds details about calls to the DeleteUser method.
+		DeleteUser []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Name is the name argument value.
+			Name string
+		}
+		// DiscardPayment holds details about calls to the DiscardPayment method.
+		DiscardPayment []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg DiscardPaymentParams
+		}
+		// ExpirePayments holds details about calls to the ExpirePayments method.
+		ExpirePayments []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Limit is the limit argument value.
+			Limit int32
+		}
+		// GetAPIKeyByHash holds details about calls to the GetAPIKeyByHash method.
+		GetAPIKeyByHash []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// KeyHash is the keyHash argument value.
+			KeyHash string
+		}
+		// GetDisputeStatus holds details about calls to the GetDisputeStatus method.
+		GetDisputeStatus []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg GetDisputeStatusParams
+		}
+		// GetPaymentByID holds details about calls to the GetPaymentByID method.
+		GetPaymentByID []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg GetPaymentByIDParams
+		}
+		// GetPaymentByMerchantReference holds details about calls to the GetPaymentByMerchantReference method.
+		GetPaymentByMerchantReference []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg GetPaymentByMerchantReferenceParams
+		}
+		// GetPaymentStatusByID holds details about calls to the GetPaymentStatusByID method.
+		GetPaymentStatusByID []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg GetPaymentStatusByIDParams
+		}
+		// GetReconciliationRun holds details about calls to the GetReconciliationRun method.
+		GetReconciliationRun []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg GetReconciliationRunParams
+		}
+		// GetUserByName holds details about calls to the GetUserByName method.
+		GetUserByName []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Name is the name argument value.
+			Name string
+		}
+		// IsPaymentReversed holds details about calls to the IsPaymentReversed method.
+		IsPaymentReversed []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// PaymentID is the paymentID argument value.
+			PaymentID int64
+		}
+		// ListAPIKeys holds details about calls to the ListAPIKeys method.
+		ListAPIKeys []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// MerchantID is the merchantID argument value.
+			MerchantID int64
+		}
+		// ListIdempotencyKeys holds details about calls to the ListIdempotencyKeys method.
+		ListIdempotencyKeys []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ListIdempotencyKeysParams
+		}
+		// ListMerchants holds details about calls to the ListMerchants method.
+		ListMerchants []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// ListPaymentDisputes holds details about calls to the ListPaymentDisputes method.
+		ListPaymentDisputes []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ListPaymentDisputesParams
+		}
+		// ListPaymentEvents holds details about calls to the ListPaymentEvents method.
+		ListPaymentEvents []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ListPaymentEventsParams
+		}
+		// ListPaymentStatuses holds details about calls to the ListPaymentStatuses method.
+		ListPaymentStatuses []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ListPaymentStatusesParams
+		}
+		// ListPaymentsByIDs holds details about calls to the ListPaymentsByIDs method.
+		ListPaymentsByIDs []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ListPaymentsByIDsParams
+		}
+		// ListReconciliationDiscrepancies holds details about calls to the ListReconciliationDiscrepancies method.
+		ListReconciliationDiscrepancies []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ListReconciliationDiscrepanciesParams
+		}
+		// ListReconciliationRuns holds details about calls to the ListReconciliationRuns method.
+		ListReconciliationRuns []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ListReconciliationRunsParams
+		}
+		// ListReviewPayments holds details about calls to the ListReviewPayments method.
+		ListReviewPayments []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ListReviewPaymentsParams
+		}
+		// ListUnscheduledPayments holds details about calls to the ListUnscheduledPayments method.
+		ListUnscheduledPayments []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Limit is the limit argument value.
+			Limit int32
+		}
+		// ListUnsettledPayments holds details about calls to the ListUnsettledPayments method.
+		ListUnsettledPayments []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ListUnsettledPaymentsParams
+		}
+		// ListUsedMerchantReferences holds details about calls to the ListUsedMerchantReferences method.
+		ListUsedMerchantReferences []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ListUsedMerchantReferencesParams
+		}
+		// ListUsers holds details about calls to the ListUsers method.
+		ListUsers []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// NotifyPaymentEvents holds details about calls to the NotifyPaymentEvents method.
+		NotifyPaymentEvents []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg NotifyPaymentEventsParams
+		}
+		// ReportDailySummaries holds details about calls to the ReportDailySummaries method.
+		ReportDailySummaries []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ReportDailySummariesParams
+		}
+		// ReportPayments holds details about calls to the ReportPayments method.
+		ReportPayments []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ReportPaymentsParams
+		}
+		// ReservePaymentIDs holds details about calls to the ReservePaymentIDs method.
+		ReservePaymentIDs []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Count is the count argument value.
+			Count int32
+		}
+		// ResolveDispute holds details about calls to the ResolveDispute method.
+		ResolveDispute []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ResolveDisputeParams
+		}
+		// ReviewPayment holds details about calls to the ReviewPayment method.
+		ReviewPayment []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ReviewPaymentParams
+		}
+		// RevokeAPIKey holds details about calls to the RevokeAPIKey method.
+		RevokeAPIKey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg RevokeAPIKeyParams
+		}
+		// RotateAPIKey holds details about calls to the RotateAPIKey method.
+		RotateAPIKey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg RotateAPIKeyParams
+		}
+		// ScheduleSettlements holds details about calls to the ScheduleSettlements method.
+		ScheduleSettlements []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg ScheduleSettlementsParams
+		}
+		// UpdatePaymentStatus holds details about calls to the UpdatePaymentStatus method.
+		UpdatePaymentStatus []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg UpdatePaymentStatusParams
+		}
+		// UpdateUserPassword holds details about calls to the UpdateUserPassword method.
+		UpdateUserPassword []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg UpdateUserPasswordParams
+		}
+		// UpdateUserRole holds details about calls to the UpdateUserRole method.
+		UpdateUserRole []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg UpdateUserRoleParams
+		}
+	}
+	lockAddDisputeEvidence                sync.RWMutex
+	lockClaimDueSettlements               sync.RWMutex
+	lockCountRecentPayments               sync.RWMutex
+	lockCreateAPIKey                      sync.RWMutex
+	lockCreateAuditLog                    sync.RWMutex
+	lockCreateCallbackNonce               sync.RWMutex
+	lockCreateDailySummaries              sync.RWMutex
+	lockCreateDispute                     sync.RWMutex
+	lockCreateIdempotencyKeys             sync.RWMutex
+	lockCreateMerchant                    sync.RWMutex
+	lockCreatePayment                     sync.RWMutex
+	lockCreatePaymentEvents               sync.RWMutex
+	lockCreatePaymentReview               sync.RWMutex
+	lockCreatePayments                    sync.RWMutex
+	lockCreateReconciliationDiscrepancies sync.RWMutex
+	lockCreateReconciliationRun           sync.RWMutex
+	lockCreateReversal                    sync.RWMutex
+	lockCreateUser                        sync.RWMutex
+	lockDeleteCallbackNonces              sync.RWMutex
+	lockDeleteDailySummaries              sync.RWMutex
+	lockDeleteUser                        sync.RWMutex
+	lockDiscardPayment                    sync.RWMutex
+	lockExpirePayments                    sync.RWMutex
+	lockGetAPIKeyByHash                   sync.RWMutex
+	lockGetDisputeStatus                  sync.RWMutex
+	lockGetPaymentByID                    sync.RWMutex
+	lockGetPaymentByMerchantReference     sync.RWMutex
+	lockGetPaymentStatusByID              sync.RWMutex
+	lockGetReconciliationRun              sync.RWMutex
+	lockGetUserByName                     sync.RWMutex
+	lockIsPaymentReversed                 sync.RWMutex
+	lockListAPIKeys                       sync.RWMutex
+	lockListIdempotencyKeys               sync.RWMutex
+	lockListMerchants                     sync.RWMutex
+	lockListPaymentDisputes               sync.RWMutex
+	lockListPaymentEvents                 sync.RWMutex
+	lockListPaymentStatuses               sync.RWMutex
+	lockListPaymentsByIDs                 sync.RWMutex
+	lockListReconciliationDiscrepancies   sync.RWMutex
+	lockListReconciliationRuns            sync.RWMutex
+	lockListReviewPayments                sync.RWMutex
+	lockListUnscheduledPayments           sync.RWMutex
+	lockListUnsettledPayments             sync.RWMutex
+	lockListUsedMerchantReferences        sync.RWMutex
+	lockListUsers                         sync.RWMutex
+	lockNotifyPaymentEvents               sync.RWMutex
+	lockReportDailySummaries              sync.RWMutex
+	lockReportPayments                    sync.RWMutex
+	lockReservePaymentIDs                 sync.RWMutex
+	lockResolveDispute                    sync.RWMutex
+	lockReviewPayment                     sync.RWMutex
+	lockRevokeAPIKey                      sync.RWMutex
+	lockRotateAPIKey                      sync.RWMutex
+	lockScheduleSettlements               sync.RWMutex
+	lockUpdatePaymentStatus               sync.RWMutex
+	lockUpdateUserPassword                sync.RWMutex
+	lockUpdateUserRole                    sync.RWMutex
+}
+
+// AddDisputeEvidence calls AddDisputeEvidenceFunc.
+func (mock *QuerierMock) AddDisputeEvidence(ctx context.Context, arg AddDisputeEvidenceParams) (Dispute, error) {
+	if mock.AddDisputeEvidenceFunc == nil {
+		panic("QuerierMock.AddDisputeEvidenceFunc: method is nil but Querier.AddDisputeEvidence was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg AddDisputeEvidenceParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockAddDisputeEvidence.Lock()
+	mock.calls.AddDisputeEvidence = append(mock.calls.AddDisputeEvidence, callInfo)
+	mock.lockAddDisputeEvidence.Unlock()
+	return mock.AddDisputeEvidenceFunc(ctx, arg)
+}
+
+// AddDisputeEvidenceCalls gets all the calls that were made to AddDisputeEvidence.
+// Check the length with:
+//     len(mockedQuerier.AddDisputeEvidenceCalls())
+func (mock *QuerierMock) AddDisputeEvidenceCalls() []struct {
+	Ctx context.Context
+	Arg AddDisputeEvidenceParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg AddDisputeEvidenceParams
+	}
+	mock.lockAddDisputeEvidence.RLock()
+	calls = mock.calls.AddDisputeEvidence
+	mock.lockAddDisputeEvidence.RUnlock()
+	return calls
+}
+
+// ClaimDueSettlements calls ClaimDueSettlementsFunc.
+func (mock *QuerierMock) ClaimDueSettlements(ctx context.Context, arg ClaimDueSettlementsParams) ([]Payment, error) {
+	if mock.ClaimDueSettlementsFunc == nil {
+		panic("QuerierMock.ClaimDueSettlementsFunc: method is nil but Querier.ClaimDueSettlements was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg ClaimDueSettlementsParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockClaimDueSettlements.Lock()
+	mock.calls.ClaimDueSettlements = append(mock.calls.ClaimDueSettlements, callInfo)
+	mock.lockClaimDueSettlements.Unlock()
+	return mock.ClaimDueSettlementsFunc(ctx, arg)
+}
+
+// ClaimDueSettlementsCalls gets all the calls that were made to ClaimDueSettlements.
+// Check the length with:
+//     len(mockedQuerier.ClaimDueSettlementsCalls())
+func (mock *QuerierMock) ClaimDueSettlementsCalls() []struct {
+	Ctx context.Context
+	Arg ClaimDueSettlementsParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg ClaimDueSettlementsParams
+	}
+	mock.lockClaimDueSettlements.RLock()
+	calls = mock.calls.ClaimDueSettlements
+	mock.lockClaimDueSettlements.RUnlock()
+	return calls
+}
+
+// CountRecentPayments calls CountRecentPaymentsFunc.
+func (mock *QuerierMock) CountRecentPayments(ctx context.Context, arg CountRecentPaymentsParams) (int64, error) {
+	if mock.CountRecentPaymentsFunc == nil {
+		panic("QuerierMock.CountRecentPaymentsFunc: method is nil but Querier.CountRecentPayments was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg CountRecentPaymentsParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCountRecentPayments.Lock()
+	mock.calls.CountRecentPayments = append(mock.calls.CountRecentPayments, callInfo)
+	mock.lockCountRecentPayments.Unlock()
+	return mock.CountRecentPaymentsFunc(ctx, arg)
+}
+
+// CountRecentPaymentsCalls gets all the calls that were made to CountRecentPayments.
+// Check the length with:
+//     len(mockedQuerier.CountRecentPaymentsCalls())
+func (mock *QuerierMock) CountRecentPaymentsCalls() []struct {
+	Ctx context.Context
+	Arg CountRecentPaymentsParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg CountRecentPaymentsParams
+	}
+	mock.lockCountRecentPayments.RLock()
+	calls = mock.calls.CountRecentPayments
+	mock.lockCountRecentPayments.RUnlock()
+	return calls
+}
+
+// CreateAPIKey calls CreateAPIKeyFunc.
+func (mock *QuerierMock) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error) {
+	if mock.CreateAPIKeyFunc == nil {
+		panic("QuerierMock.CreateAPIKeyFunc: method is nil but Querier.CreateAPIKey was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg CreateAPIKeyParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCreateAPIKey.Lock()
+	mock.calls.CreateAPIKey = append(mock.calls.CreateAPIKey, callInfo)
+	mock.lockCreateAPIKey.Unlock()
+	return mock.CreateAPIKeyFunc(ctx, arg)
+}
+
+// CreateAPIKeyCalls gets all the calls that were made to CreateAPIKey.
+// Check the length with:
+//     len(mockedQuerier.CreateAPIKeyCalls())
+func (mock *QuerierMock) CreateAPIKeyCalls() []struct {
+	Ctx context.Context
+	Arg CreateAPIKeyParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg CreateAPIKeyParams
+	}
+	mock.lockCreateAPIKey.RLock()
+	calls = mock.calls.CreateAPIKey
+	mock.lockCreateAPIKey.RUnlock()
+	return calls
+}
+
+// CreateAuditLog calls CreateAuditLogFunc.
+func (mock *QuerierMock) CreateAuditLog(ctx context.Context, arg CreateAuditLogParams) error {
+	if mock.CreateAuditLogFunc == nil {
+		panic("QuerierMock.CreateAuditLogFunc: method is nil but Querier.CreateAuditLog was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg CreateAuditLogParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCreateAuditLog.Lock()
+	mock.calls.CreateAuditLog = append(mock.calls.CreateAuditLog, callInfo)
+	mock.lockCreateAuditLog.Unlock()
+	return mock.CreateAuditLogFunc(ctx, arg)
+}
+
+// CreateAuditLogCalls gets all the calls that were made to CreateAuditLog.
+// Check the length with:
+//     len(mockedQuerier.CreateAuditLogCalls())
+func (mock *QuerierMock) CreateAuditLogCalls() []struct {
+	Ctx context.Context
+	Arg CreateAuditLogParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg CreateAuditLogParams
+	}
+	mock.lockCreateAuditLog.RLock()
+	calls = mock.calls.CreateAuditLog
+	mock.lockCreateAuditLog.RUnlock()
+	return calls
+}
+
+// CreateCallbackNonce calls CreateCallbackNonceFunc.
+func (mock *QuerierMock) CreateCallbackNonce(ctx context.Context, nonce string) (int64, error) {
+	if mock.CreateCallbackNonceFunc == nil {
+		panic("QuerierMock.CreateCallbackNonceFunc: method is nil but Querier.CreateCallbackNonce was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Nonce string
+	}{
+		Ctx:   ctx,
+		Nonce: nonce,
+	}
+	mock.lockCreateCallbackNonce.Lock()
+	mock.calls.CreateCallbackNonce = append(mock.calls.CreateCallbackNonce, callInfo)
+	mock.lockCreateCallbackNonce.Unlock()
+	return mock.CreateCallbackNonceFunc(ctx, nonce)
+}
+
+// CreateCallbackNonceCalls gets all the calls that were made to CreateCallbackNonce.
+// Check the length with:
+//     len(mockedQuerier.CreateCallbackNonceCalls())
+func (mock *QuerierMock) CreateCallbackNonceCalls() []struct {
+	Ctx   context.Context
+	Nonce string
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Nonce string
+	}
+	mock.lockCreateCallbackNonce.RLock()
+	calls = mock.calls.CreateCallbackNonce
+	mock.lockCreateCallbackNonce.RUnlock()
+	return calls
+}
+
+// CreateDailySummaries calls CreateDailySummariesFunc.
+func (mock *QuerierMock) CreateDailySummaries(ctx context.Context, arg CreateDailySummariesParams) error {
+	if mock.CreateDailySummariesFunc == nil {
+		panic("QuerierMock.CreateDailySummariesFunc: method is nil but Querier.CreateDailySummaries was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg CreateDailySummariesParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCreateDailySummaries.Lock()
+	mock.calls.CreateDailySummaries = append(mock.calls.CreateDailySummaries, callInfo)
+	mock.lockCreateDailySummaries.Unlock()
+	return mock.CreateDailySummariesFunc(ctx, arg)
+}
+
+// CreateDailySummariesCalls gets all the calls that were made to CreateDailySummaries.
+// Check the length with:
+//     len(mockedQuerier.CreateDailySummariesCalls())
+func (mock *QuerierMock) CreateDailySummariesCalls() []struct {
+	Ctx context.Context
+	Arg CreateDailySummariesParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg CreateDailySummariesParams
+	}
+	mock.lockCreateDailySummaries.RLock()
+	calls = mock.calls.CreateDailySummaries
+	mock.lockCreateDailySummaries.RUnlock()
+	return calls
+}
+
+// CreateDispute calls CreateDisputeFunc.
+func (mock *QuerierMock) CreateDispute(ctx context.Context, arg CreateDisputeParams) (Dispute, error) {
+	if mock.CreateDisputeFunc == nil {
+		panic("QuerierMock.CreateDisputeFunc: method is nil but Querier.CreateDispute was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg CreateDisputeParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCreateDispute.Lock()
+	mock.calls.CreateDispute = append(mock.calls.CreateDispute, callInfo)
+	mock.lockCreateDispute.Unlock()
+	return mock.CreateDisputeFunc(ctx, arg)
+}
+
+// CreateDisputeCalls gets all the calls that were made to CreateDispute.
+// Check the length with:
+//     len(mockedQuerier.CreateDisputeCalls())
+func (mock *QuerierMock) CreateDisputeCalls() []struct {
+	Ctx context.Context
+	Arg CreateDisputeParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg CreateDisputeParams
+	}
+	mock.lockCreateDispute.RLock()
+	calls = mock.calls.CreateDispute
+	mock.lockCreateDispute.RUnlock()
+	return calls
+}
+
+// CreateIdempotencyKeys calls CreateIdempotencyKeysFunc.
+func (mock *QuerierMock) CreateIdempotencyKeys(ctx context.Context, arg CreateIdempotencyKeysParams) error {
+	if mock.CreateIdempotencyKeysFunc == nil {
+		panic("QuerierMock.CreateIdempotencyKeysFunc: method is nil but Querier.CreateIdempotencyKeys was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg CreateIdempotencyKeysParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCreateIdempotencyKeys.Lock()
+	mock.calls.CreateIdempotencyKeys = append(mock.calls.CreateIdempotencyKeys, callInfo)
+	mock.lockCreateIdempotencyKeys.Unlock()
+	return mock.CreateIdempotencyKeysFunc(ctx, arg)
+}
+
+// CreateIdempotencyKeysCalls gets all the calls that were made to CreateIdempotencyKeys.
+// Check the length with:
+//     len(mockedQuerier.CreateIdempotencyKeysCalls())
+func (mock *QuerierMock) CreateIdempotencyKeysCalls() []struct {
+	Ctx context.Context
+	Arg CreateIdempotencyKeysParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg CreateIdempotencyKeysParams
+	}
+	mock.lockCreateIdempotencyKeys.RLock()
+	calls = mock.calls.CreateIdempotencyKeys
+	mock.lockCreateIdempotencyKeys.RUnlock()
+	return calls
+}
+
+// CreateMerchant calls CreateMerchantFunc.
+func (mock *QuerierMock) CreateMerchant(ctx context.Context, name string) (Merchant, error) {
+	if mock.CreateMerchantFunc == nil {
+		panic("QuerierMock.CreateMerchantFunc: method is nil but Querier.CreateMerchant was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Name string
+	}{
+		Ctx:  ctx,
+		Name: name,
+	}
+	mock.lockCreateMerchant.Lock()
+	mock.calls.CreateMerchant = append(mock.calls.CreateMerchant, callInfo)
+	mock.lockCreateMerchant.Unlock()
+	return mock.CreateMerchantFunc(ctx, name)
+}
+
+// CreateMerchantCalls gets all the calls that were made to CreateMerchant.
+// Check the length with:
+//     len(mockedQuerier.CreateMerchantCalls())
+func (mock *QuerierMock) CreateMerchantCalls() []struct {
+	Ctx  context.Context
+	Name string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Name string
+	}
+	mock.lockCreateMerchant.RLock()
+	calls = mock.calls.CreateMerchant
+	mock.lockCreateMerchant.RUnlock()
+	return calls
+}
+
+// CreatePayment calls CreatePaymentFunc.
+func (mock *QuerierMock) CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error) {
+	if mock.CreatePaymentFunc == nil {
+		panic("QuerierMock.CreatePaymentFunc: method is nil but Querier.CreatePayment was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg CreatePaymentParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCreatePayment.Lock()
+	mock.calls.CreatePayment = append(mock.calls.CreatePayment, callInfo)
+	mock.lockCreatePayment.Unlock()
+	return mock.CreatePaymentFunc(ctx, arg)
+}
+
+// CreatePaymentCalls gets all the calls that were made to CreatePayment.
+// Check the length with:
+//     len(mockedQuerier.CreatePaymentCalls())
+func (mock *QuerierMock) CreatePaymentCalls() []struct {
+	Ctx context.Context
+	Arg CreatePaymentParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg CreatePaymentParams
+	}
+	mock.lockCreatePayment.RLock()
+	calls = mock.calls.CreatePayment
+	mock.lockCreatePayment.RUnlock()
+	return calls
+}
+
+// CreatePaymentEvents calls CreatePaymentEventsFunc.
+func (mock *QuerierMock) CreatePaymentEvents(ctx context.Context, ids []int64) ([]PaymentEvent, error) {
+	if mock.CreatePaymentEventsFunc == nil {
+		panic("QuerierMock.CreatePaymentEventsFunc: method is nil but Querier.CreatePaymentEvents was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Ids []int64
+	}{
+		Ctx: ctx,
+		Ids: ids,
+	}
+	mock.lockCreatePaymentEvents.Lock()
+	mock.calls.CreatePaymentEvents = append(mock.calls.CreatePaymentEvents, callInfo)
+	mock.lockCreatePaymentEvents.Unlock()
+	return mock.CreatePaymentEventsFunc(ctx, ids)
+}
+
+// CreatePaymentEventsCalls gets all the calls that were made to CreatePaymentEvents.
+// Check the length with:
+//     len(mockedQuerier.CreatePaymentEventsCalls())
+func (mock *QuerierMock) CreatePaymentEventsCalls() []struct {
+	Ctx context.Context
+	Ids []int64
+} {
+	var calls []struct {
+		Ctx context.Context
+		Ids []int64
+	}
+	mock.lockCreatePaymentEvents.RLock()
+	calls = mock.calls.CreatePaymentEvents
+	mock.lockCreatePaymentEvents.RUnlock()
+	return calls
+}
+
+// CreatePaymentReview calls CreatePaymentReviewFunc.
+func (mock *QuerierMock) CreatePaymentReview(ctx context.Context, arg CreatePaymentReviewParams) (PaymentReview, error) {
+	if mock.CreatePaymentReviewFunc == nil {
+		panic("QuerierMock.CreatePaymentReviewFunc: method is nil but Querier.CreatePaymentReview was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg CreatePaymentReviewParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCreatePaymentReview.Lock()
+	mock.calls.CreatePaymentReview = append(mock.calls.CreatePaymentReview, callInfo)
+	mock.lockCreatePaymentReview.Unlock()
+	return mock.CreatePaymentReviewFunc(ctx, arg)
+}
+
+// CreatePaymentReviewCalls gets all the calls that were made to CreatePaymentReview.
+// Check the length with:
+//     len(mockedQuerier.CreatePaymentReviewCalls())
+func (mock *QuerierMock) CreatePaymentReviewCalls() []struct {
+	Ctx context.Context
+	Arg CreatePaymentReviewParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg CreatePaymentReviewParams
+	}
+	mock.lockCreatePaymentReview.RLock()
+	calls = mock.calls.CreatePaymentReview
+	mock.lockCreatePaymentReview.RUnlock()
+	return calls
+}
+
+// CreatePayments calls CreatePaymentsFunc.
+func (mock *QuerierMock) CreatePayments(ctx context.Context, payments json.RawMessage) ([]Payment, error) {
+	if mock.CreatePaymentsFunc == nil {
+		panic("QuerierMock.CreatePaymentsFunc: method is nil but Querier.CreatePayments was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Payments json.RawMessage
+	}{
+		Ctx:      ctx,
+		Payments: payments,
+	}
+	mock.lockCreatePayments.Lock()
+	mock.calls.CreatePayments = append(mock.calls.CreatePayments, callInfo)
+	mock.lockCreatePayments.Unlock()
+	return mock.CreatePaymentsFunc(ctx, payments)
+}
+
+// CreatePaymentsCalls gets all the calls that were made to CreatePayments.
+// Check the length with:
+//     len(mockedQuerier.CreatePaymentsCalls())
+func (mock *QuerierMock) CreatePaymentsCalls() []struct {
+	Ctx      context.Context
+	Payments json.RawMessage
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Payments json.RawMessage
+	}
+	mock.lockCreatePayments.RLock()
+	calls = mock.calls.CreatePayments
+	mock.lockCreatePayments.RUnlock()
+	return calls
+}
+
+// CreateReconciliationDiscrepancies calls CreateReconciliationDiscrepanciesFunc.
+func (mock *QuerierMock) CreateReconciliationDiscrepancies(ctx context.Context, arg CreateReconciliationDiscrepanciesParams) ([]ReconciliationDiscrepancy, error) {
+	if mock.CreateReconciliationDiscrepanciesFunc == nil {
+		panic("QuerierMock.CreateReconciliationDiscrepanciesFunc: method is nil but Querier.CreateReconciliationDiscrepancies was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg CreateReconciliationDiscrepanciesParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCreateReconciliationDiscrepancies.Lock()
+	mock.calls.CreateReconciliationDiscrepancies = append(mock.calls.CreateReconciliationDiscrepancies, callInfo)
+	mock.lockCreateReconciliationDiscrepancies.Unlock()
+	return mock.CreateReconciliationDiscrepanciesFunc(ctx, arg)
+}
+
+// CreateReconciliationDiscrepanciesCalls gets all the calls that were made to CreateReconciliationDiscrepancies.
+// Check the length with:
+//     len(mockedQuerier.CreateReconciliationDiscrepanciesCalls())
+func (mock *QuerierMock) CreateReconciliationDiscrepanciesCalls() []struct {
+	Ctx context.Context
+	Arg CreateReconciliationDiscrepanciesParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg CreateReconciliationDiscrepanciesParams
+	}
+	mock.lockCreateReconciliationDiscrepancies.RLock()
+	calls = mock.calls.CreateReconciliationDiscrepancies
+	mock.lockCreateReconciliationDiscrepancies.RUnlock()
+	return calls
+}
+
+// CreateReconciliationRun calls CreateReconciliationRunFunc.
+func (mock *QuerierMock) CreateReconciliationRun(ctx context.Context, arg CreateReconciliationRunParams) (ReconciliationRun, error) {
+	if mock.CreateReconciliationRunFunc == nil {
+		panic("QuerierMock.CreateReconciliationRunFunc: method is nil but Querier.CreateReconciliationRun was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg CreateReconciliationRunParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCreateReconciliationRun.Lock()
+	mock.calls.CreateReconciliationRun = append(mock.calls.CreateReconciliationRun, callInfo)
+	mock.lockCreateReconciliationRun.Unlock()
+	return mock.CreateReconciliationRunFunc(ctx, arg)
+}
+
+// CreateReconciliationRunCalls gets all the calls that were made to CreateReconciliationRun.
+// Check the length with:
+//     len(mockedQuerier.CreateReconciliationRunCalls())
+func (mock *QuerierMock) CreateReconciliationRunCalls() []struct {
+	Ctx context.Context
+	Arg CreateReconciliationRunParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg CreateReconciliationRunParams
+	}
+	mock.lockCreateReconciliationRun.RLock()
+	calls = mock.calls.CreateReconciliationRun
+	mock.lockCreateReconciliationRun.RUnlock()
+	return calls
+}
+
+// CreateReversal calls CreateReversalFunc.
+func (mock *QuerierMock) CreateReversal(ctx context.Context, arg CreateReversalParams) (Reversal, error) {
+	if mock.CreateReversalFunc == nil {
+		panic("QuerierMock.CreateReversalFunc: method is nil but Querier.CreateReversal was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg CreateReversalParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCreateReversal.Lock()
+	mock.calls.CreateReversal = append(mock.calls.CreateReversal, callInfo)
+	mock.lockCreateReversal.Unlock()
+	return mock.CreateReversalFunc(ctx, arg)
+}
+
+// CreateReversalCalls gets all the calls that were made to CreateReversal.
+// Check the length with:
+//     len(mockedQuerier.CreateReversalCalls())
+func (mock *QuerierMock) CreateReversalCalls() []struct {
+	Ctx context.Context
+	Arg CreateReversalParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg CreateReversalParams
+	}
+	mock.lockCreateReversal.RLock()
+	calls = mock.calls.CreateReversal
+	mock.lockCreateReversal.RUnlock()
+	return calls
+}
+
+// CreateUser calls CreateUserFunc.
+func (mock *QuerierMock) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	if mock.CreateUserFunc == nil {
+		panic("QuerierMock.CreateUserFunc: method is nil but Querier.CreateUser was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg CreateUserParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCreateUser.Lock()
+	mock.calls.CreateUser = append(mock.calls.CreateUser, callInfo)
+	mock.lockCreateUser.Unlock()
+	return mock.CreateUserFunc(ctx, arg)
+}
+
+// CreateUserCalls gets all the calls that were made to CreateUser.
+// Check the length with:
+//     len(mockedQuerier.CreateUserCalls())
+func (mock *QuerierMock) CreateUserCalls() []struct {
+	Ctx context.Context
+	Arg CreateUserParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg CreateUserParams
+	}
+	mock.lockCreateUser.RLock()
+	calls = mock.calls.CreateUser
+	mock.lockCreateUser.RUnlock()
+	return calls
+}
+
+// DeleteCallbackNonces calls DeleteCallbackNoncesFunc.
+func (mock *QuerierMock) DeleteCallbackNonces(ctx context.Context, createdAt time.Time) (int64, error) {
+	if mock.DeleteCallbackNoncesFunc == nil {
+		panic("QuerierMock.DeleteCallbackNoncesFunc: method is nil but Querier.DeleteCallbackNonces was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		CreatedAt time.Time
+	}{
+		Ctx:       ctx,
+		CreatedAt: createdAt,
+	}
+	mock.lockDeleteCallbackNonces.Lock()
+	mock.calls.DeleteCallbackNonces = append(mock.calls.DeleteCallbackNonces, callInfo)
+	mock.lockDeleteCallbackNonces.Unlock()
+	return mock.DeleteCallbackNoncesFunc(ctx, createdAt)
+}
+
+// DeleteCallbackNoncesCalls gets all the calls that were made to DeleteCallbackNonces.
+// Check the length with:
+//     len(mockedQuerier.DeleteCallbackNoncesCalls())
+func (mock *QuerierMock) DeleteCallbackNoncesCalls() []struct {
+	Ctx       context.Context
+	CreatedAt time.Time
+} {
+	var calls []struct {
+		Ctx       context.Context
+		CreatedAt time.Time
+	}
+	mock.lockDeleteCallbackNonces.RLock()
+	calls = mock.calls.DeleteCallbackNonces
+	mock.lockDeleteCallbackNonces.RUnlock()
+	return calls
+}
+
+// DeleteDailySummaries calls DeleteDailySummariesFunc.
+func (mock *QuerierMock) DeleteDailySummaries(ctx context.Context, arg DeleteDailySummariesParams) error {
+	if mock.DeleteDailySummariesFunc == nil {
+		panic("QuerierMock.DeleteDailySummariesFunc: method is nil but Querier.DeleteDailySummaries was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg DeleteDailySummariesParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockDeleteDailySummaries.Lock()
+	mock.calls.DeleteDailySummaries = append(mock.calls.DeleteDailySummaries, callInfo)
+	mock.lockDeleteDailySummaries.Unlock()
+	return mock.DeleteDailySummariesFunc(ctx, arg)
+}
+
+// DeleteDailySummariesCalls gets all the calls that were made to DeleteDailySummaries.
+// Check the length with:
+//     len(mockedQuerier.DeleteDailySummariesCalls())
+func (mock *QuerierMock) DeleteDailySummariesCalls() []struct {
+	Ctx context.Context
+	Arg DeleteDailySummariesParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg DeleteDailySummariesParams
+	}
+	mock.lockDeleteDailySummaries.RLock()
+	calls = mock.calls.DeleteDailySummaries
+	mock.lockDeleteDailySummaries.RUnlock()
+	return calls
+}
+
+// DeleteUser calls DeleteUserFunc.
+func (mock *QuerierMock) DeleteUser(ctx context.Context, name string) (int64, error) {
+	if mock.DeleteUserFunc == nil {
+		panic("QuerierMock.DeleteUserFunc: method is nil but Querier.DeleteUser was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Name string
+	}{
+		Ctx:  ctx,
+		Name: name,
+	}
+	mock.lockDeleteUser.Lock()
+	mock.calls.DeleteUser = append(mock.calls.DeleteUser, callInfo)
+	mock.lockDeleteUser.Unlock()
+	return mock.DeleteUserFunc(ctx, name)
+}
+
+// DeleteUserCalls gets all the calls that were made to DeleteUser.
+// Check the length with:
+//     len(mockedQuerier.DeleteUserCalls())
+func (mock *QuerierMock) DeleteUserCalls() []struct {
+	Ctx  context.Context
+	Name string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Name string
+	}
+	mock.lockDeleteUser.RLock()
+	calls = mock.calls.DeleteUser
+	mock.lockDeleteUser.RUnlock()
+	return calls
+}
+
+// DiscardPayment calls DiscardPaymentFunc.
+func (mock *QuerierMock) DiscardPayment(ctx context.Context, arg DiscardPaymentParams) (int64, error) {
+	if mock.DiscardPaymentFunc == nil {
+		panic("QuerierMock.DiscardPaymentFunc: method is nil but Querier.DiscardPayment was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg DiscardPaymentParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockDiscardPayment.Lock()
+	mock.calls.DiscardPayment = append(mock.calls.DiscardPayment, callInfo)
+	mock.lockDiscardPayment.Unlock()
+	return mock.DiscardPaymentFunc(ctx, arg)
+}
+
+// DiscardPaymentCalls gets all the calls that were made to DiscardPayment.
+// Check the length with:
+//     len(mockedQuerier.DiscardPaymentCalls())
+func (mock *QuerierMock) DiscardPaymentCalls() []struct {
+	Ctx context.Context
+	Arg DiscardPaymentParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg DiscardPaymentParams
+	}
+	mock.lockDiscardPayment.RLock()
+	calls = mock.calls.DiscardPayment
+	mock.lockDiscardPayment.RUnlock()
+	return calls
+}
+
+// ExpirePayments calls ExpirePaymentsFunc.
+func (mock *QuerierMock) ExpirePayments(ctx context.Context, limit int32) ([]Payment, error) {
+	if mock.ExpirePaymentsFunc == nil {
+		panic("QuerierMock.ExpirePaymentsFunc: method is nil but Querier.ExpirePayments was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Limit int32
+	}{
+		Ctx:   ctx,
+		Limit: limit,
+	}
+	mock.lockExpirePayments.Lock()
+	mock.calls.ExpirePayments = append(mock.calls.ExpirePayments, callInfo)
+	mock.lockExpirePayments.Unlock()
+	return mock.ExpirePaymentsFunc(ctx, limit)
+}
+
+// ExpirePaymentsCalls gets all the calls that were made to ExpirePayments.
+// Check the length with:
+//     len(mockedQuerier.ExpirePaymentsCalls())
+func (mock *QuerierMock) ExpirePaymentsCalls() []struct {
+	Ctx   context.Context
+	Limit int32
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Limit int32
+	}
+	mock.lockExpirePayments.RLock()
+	calls = mock.calls.ExpirePayments
+	mock.lockExpirePayments.RUnlock()
+	return calls
+}
+
+// GetAPIKeyByHash calls GetAPIKeyByHashFunc.
+func (mock *QuerierMock) GetAPIKeyByHash(ctx context.Context, keyHash string) (ApiKey, error) {
+	if mock.GetAPIKeyByHashFunc == nil {
+		panic("QuerierMock.GetAPIKeyByHashFunc: method is nil but Querier.GetAPIKeyByHash was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		KeyHash string
+	}{
+		Ctx:     ctx,
+		KeyHash: keyHash,
+	}
+	mock.lockGetAPIKeyByHash.Lock()
+	mock.calls.GetAPIKeyByHash = append(mock.calls.GetAPIKeyByHash, callInfo)
+	mock.lockGetAPIKeyByHash.Unlock()
+	return mock.GetAPIKeyByHashFunc(ctx, keyHash)
+}
+
+// GetAPIKeyByHashCalls gets all the calls that were made to GetAPIKeyByHash.
+// Check the length with:
+//     len(mockedQuerier.GetAPIKeyByHashCalls())
+func (mock *QuerierMock) GetAPIKeyByHashCalls() []struct {
+	Ctx     context.Context
+	KeyHash string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		KeyHash string
+	}
+	mock.lockGetAPIKeyByHash.RLock()
+	calls = mock.calls.GetAPIKeyByHash
+	mock.lockGetAPIKeyByHash.RUnlock()
+	return calls
+}
+
+// GetDisputeStatus calls GetDisputeStatusFunc.
+func (mock *QuerierMock) GetDisputeStatus(ctx context.Context, arg GetDisputeStatusParams) (DisputeStatus, error) {
+	if mock.GetDisputeStatusFunc == nil {
+		panic("QuerierMock.GetDisputeStatusFunc: method is nil but Querier.GetDisputeStatus was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg GetDisputeStatusParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockGetDisputeStatus.Lock()
+	mock.calls.GetDisputeStatus = append(mock.calls.GetDisputeStatus, callInfo)
+	mock.lockGetDisputeStatus.Unlock()
+	return mock.GetDisputeStatusFunc(ctx, arg)
+}
+
+// GetDisputeStatusCalls gets all the calls that were made to GetDisputeStatus.
+// Check the length with:
+//     len(mockedQuerier.GetDisputeStatusCalls())
+func (mock *QuerierMock) GetDisputeStatusCalls() []struct {
+	Ctx context.Context
+	Arg GetDisputeStatusParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg GetDisputeStatusParams
+	}
+	mock.lockGetDisputeStatus.RLock()
+	calls = mock.calls.GetDisputeStatus
+	mock.lockGetDisputeStatus.RUnlock()
+	return calls
+}
+
+// GetPaymentByID calls GetPaymentByIDFunc.
+func (mock *QuerierMock) GetPaymentByID(ctx context.Context, arg GetPaymentByIDParams) (Payment, error) {
+	if mock.GetPaymentByIDFunc == nil {
+		panic("QuerierMock.GetPaymentByIDFunc: method is nil but Querier.GetPaymentByID was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg GetPaymentByIDParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockGetPaymentByID.Lock()
+	mock.calls.GetPaymentByID = append(mock.calls.GetPaymentByID, callInfo)
+	mock.lockGetPaymentByID.Unlock()
+	return mock.GetPaymentByIDFunc(ctx, arg)
+}
+
+// GetPaymentByIDCalls gets all the calls that were made to GetPaymentByID.
+// Check the length with:
+//     len(mockedQuerier.GetPaymentByIDCalls())
+func (mock *QuerierMock) GetPaymentByIDCalls() []struct {
+	Ctx context.Context
+	Arg GetPaymentByIDParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg GetPaymentByIDParams
+	}
+	mock.lockGetPaymentByID.RLock()
+	calls = mock.calls.GetPaymentByID
+	mock.lockGetPaymentByID.RUnlock()
+	return calls
+}
+
+// GetPaymentByMerchantReference calls GetPaymentByMerchantReferenceFunc.
+func (mock *QuerierMock) GetPaymentByMerchantReference(ctx context.Context, arg GetPaymentByMerchantReferenceParams) (Payment, error) {
+	if mock.GetPaymentByMerchantReferenceFunc == nil {
+		panic("QuerierMock.GetPaymentByMerchantReferenceFunc: method is nil but Querier.GetPaymentByMerchantReference was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg GetPaymentByMerchantReferenceParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockGetPaymentByMerchantReference.Lock()
+	mock.calls.GetPaymentByMerchantReference = append(mock.calls.GetPaymentByMerchantReference, callInfo)
+	mock.lockGetPaymentByMerchantReference.Unlock()
+	return mock.GetPaymentByMerchantReferenceFunc(ctx, arg)
+}
+
+// GetPaymentByMerchantReferenceCalls gets all the calls that were made to GetPaymentByMerchantReference.
+// Check the length with:
+//     len(mockedQuerier.GetPaymentByMerchantReferenceCalls())
+func (mock *QuerierMock) GetPaymentByMerchantReferenceCalls() []struct {
+	Ctx context.Context
+	Arg GetPaymentByMerchantReferenceParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg GetPaymentByMerchantReferenceParams
+	}
+	mock.lockGetPaymentByMerchantReference.RLock()
+	calls = mock.calls.GetPaymentByMerchantReference
+	mock.lockGetPaymentByMerchantReference.RUnlock()
+	return calls
+}
+
+// GetPaymentStatusByID calls GetPaymentStatusByIDFunc.
+func (mock *QuerierMock) GetPaymentStatusByID(ctx context.Context, arg GetPaymentStatusByIDParams) (ValidStatus, error) {
+	if mock.GetPaymentStatusByIDFunc == nil {
+		panic("QuerierMock.GetPaymentStatusByIDFunc: method is nil but Querier.GetPaymentStatusByID was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg GetPaymentStatusByIDParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockGetPaymentStatusByID.Lock()
+	mock.calls.GetPaymentStatusByID = append(mock.calls.GetPaymentStatusByID, callInfo)
+	mock.lockGetPaymentStatusByID.Unlock()
+	return mock.GetPaymentStatusByIDFunc(ctx, arg)
+}
+
+// GetPaymentStatusByIDCalls gets all the calls that were made to GetPaymentStatusByID.
+// Check the length with:
+//     len(mockedQuerier.GetPaymentStatusByIDCalls())
+func (mock *QuerierMock) GetPaymentStatusByIDCalls() []struct {
+	Ctx context.Context
+	Arg GetPaymentStatusByIDParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg GetPaymentStatusByIDParams
+	}
+	mock.lockGetPaymentStatusByID.RLock()
+	calls = mock.calls.GetPaymentStatusByID
+	mock.lockGetPaymentStatusByID.RUnlock()
+	return calls
+}
+
+// GetReconciliationRun calls GetReconciliationRunFunc.
+func (mock *QuerierMock) GetReconciliationRun(ctx context.Context, arg GetReconciliationRunParams) (ReconciliationRun, error) {
+	if mock.GetReconciliationRunFunc == nil {
+		panic("QuerierMock.GetReconciliationRunFunc: method is nil but Querier.GetReconciliationRun was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg GetReconciliationRunParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockGetReconciliationRun.Lock()
+	mock.calls.GetReconciliationRun = append(mock.calls.GetReconciliationRun, callInfo)
+	mock.lockGetReconciliationRun.Unlock()
+	return mock.GetReconciliationRunFunc(ctx, arg)
+}
+
+// GetReconciliationRunCalls gets all the calls that were made to GetReconciliationRun.
+// Check the length with:
+//     len(mockedQuerier.GetReconciliationRunCalls())
+func (mock *QuerierMock) GetReconciliationRunCalls() []struct {
+	Ctx context.Context
+	Arg GetReconciliationRunParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg GetReconciliationRunParams
+	}
+	mock.lockGetReconciliationRun.RLock()
+	calls = mock.calls.GetReconciliationRun
+	mock.lockGetReconciliationRun.RUnlock()
+	return calls
+}
+
+// GetUserByName calls GetUserByNameFunc.
+func (mock *QuerierMock) GetUserByName(ctx context.Context, name string) (User, error) {
+	if mock.GetUserByNameFunc == nil {
+		panic("QuerierMock.GetUserByNameFunc: method is nil but Querier.GetUserByName was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Name string
+	}{
+		Ctx:  ctx,
+		Name: name,
+	}
+	mock.lockGetUserByName.Lock()
+	mock.calls.GetUserByName = append(mock.calls.GetUserByName, callInfo)
+	mock.lockGetUserByName.Unlock()
+	return mock.GetUserByNameFunc(ctx, name)
+}
+
+// GetUserByNameCalls gets all the calls that were made to GetUserByName.
+// Check the length with:
+//     len(mockedQuerier.GetUserByNameCalls())
+func (mock *QuerierMock) GetUserByNameCalls() []struct {
+	Ctx  context.Context
+	Name string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Name string
+	}
+	mock.lockGetUserByName.RLock()
+	calls = mock.calls.GetUserByName
+	mock.lockGetUserByName.RUnlock()
+	return calls
+}
+
+// IsPaymentReversed calls IsPaymentReversedFunc.
+func (mock *QuerierMock) IsPaymentReversed(ctx context.Context, paymentID int64) (bool, error) {
+	if mock.IsPaymentReversedFunc == nil {
+		panic("QuerierMock.IsPaymentReversedFunc: method is nil but Querier.IsPaymentReversed was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		PaymentID int64
+	}{
+		Ctx:       ctx,
+		PaymentID: paymentID,
+	}
+	mock.lockIsPaymentReversed.Lock()
+	mock.calls.IsPaymentReversed = append(mock.calls.IsPaymentReversed, callInfo)
+	mock.lockIsPaymentReversed.Unlock()
+	return mock.IsPaymentReversedFunc(ctx, paymentID)
+}
+
+// IsPaymentReversedCalls gets all the calls that were made to IsPaymentReversed.
+// Check the length with:
+//     len(mockedQuerier.IsPaymentReversedCalls())
+func (mock *QuerierMock) IsPaymentReversedCalls() []struct {
+	Ctx       context.Context
+	PaymentID int64
+} {
+	var calls []struct {
+		Ctx       context.Context
+		PaymentID int64
+	}
+	mock.lockIsPaymentReversed.RLock()
+	calls = mock.calls.IsPaymentReversed
+	mock.lockIsPaymentReversed.RUnlock()
+	return calls
+}
+
+// ListAPIKeys calls ListAPIKeysFunc.
+func (mock *QuerierMock) ListAPIKeys(ctx context.Context, merchantID int64) ([]ApiKey, error) {
+	if mock.ListAPIKeysFunc == nil {
+		panic("QuerierMock.ListAPIKeysFunc: method is nil but Querier.ListAPIKeys was just called")
+	}
+	callInfo := struct {
+		Ctx        context.Context
+		MerchantID int64
+	}{
+		Ctx:        ctx,
+		MerchantID: merchantID,
+	}
+	mock.lockListAPIKeys.Lock()
+	mock.calls.ListAPIKeys = append(mock.calls.ListAPIKeys, callInfo)
+	mock.lockListAPIKeys.Unlock()
+	return mock.ListAPIKeysFunc(ctx, merchantID)
+}
+
+// ListAPIKeysCalls gets all the calls that were made to ListAPIKeys.
+// Check the length with:
+//     len(mockedQuerier.ListAPIKeysCalls())
+func (mock *QuerierMock) ListAPIKeysCalls() []struct {
+	Ctx        context.Context
+	MerchantID int64
+} {
+	var calls []struct {
+		Ctx        context.Context
+		MerchantID int64
+	}
+	mock.lockListAPIKeys.RLock()
+	calls = mock.calls.ListAPIKeys
+	mock.lockListAPIKeys.RUnlock()
+	return calls
+}
+
+// ListIdempotencyKeys calls ListIdempotencyKeysFunc.
+func (mock *QuerierMock) ListIdempotencyKeys(ctx context.Context, arg ListIdempotencyKeysParams) ([]IdempotencyKey, error) {
+	if mock.ListIdempotencyKeysFunc == nil {
+		panic("QuerierMock.ListIdempotencyKeysFunc: method is nil but Querier.ListIdempotencyKeys was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg ListIdempotencyKeysParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockListIdempotencyKeys.Lock()
+	mock.calls.ListIdempotencyKeys = append(mock.calls.ListIdempotencyKeys, callInfo)
+	mock.lockListIdempotencyKeys.Unlock()
+	return mock.ListIdempotencyKeysFunc(ctx, arg)
+}
+
+// ListIdempotencyKeysCalls gets all the calls that were made to ListIdempotencyKeys.
+// Check the length with:
+//     len(mockedQuerier.ListIdempotencyKeysCalls())
+func (mock *QuerierMock) ListIdempotencyKeysCalls() []struct {
+	Ctx context.Context
+	Arg ListIdempotencyKeysParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg ListIdempotencyKeysParams
+	}
+	mock.lockListIdempotencyKeys.RLock()
+	calls = mock.calls.ListIdempotencyKeys
+	mock.lockListIdempotencyKeys.RUnlock()
+	return calls
+}
+
+// ListMerchants calls ListMerchantsFunc.
+func (mock *QuerierMock) ListMerchants(ctx context.Context) ([]Merchant, error) {
+	if mock.ListMerchantsFunc == nil {
+		panic("QuerierMock.ListMerchantsFunc: method is nil but Querier.ListMerchants was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockListMerchants.Lock()
+	mock.calls.ListMerchants = append(mock.calls.ListMerchants, callInfo)
+	mock.lockListMerchants.Unlock()
+	return mock.ListMerchantsFunc(ctx)
+}
+
+// ListMerchantsCalls gets all the calls that were made to ListMerchants.
+// Check the length with:
+//     len(mockedQuerier.ListMerchantsCalls())
+func (mock *QuerierMock) ListMerchantsCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockListMerchants.RLock()
+	calls = mock.calls.ListMerchants
+	mock.lockListMerchants.RUnlock()
+	return calls
+}
+
+// ListPaymentDisputes calls ListPaymentDisputesFunc.
+func (mock *QuerierMock) ListPaymentDisputes(ctx context.Context, arg ListPaymentDisputesParams) ([]Dispute, error) {
+	if mock.ListPaymentDisputesFunc == nil {
+		panic("QuerierMock.ListPaymentDisputesFunc: method is nil but Querier.ListPaymentDisputes was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg ListPaymentDisputesParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockListPaymentDisputes.Lock()
+	mock.calls.ListPaymentDisputes = append(mock.calls.ListPaymentDisputes, callInfo)
+	mock.lockListPaymentDisputes.Unlock()
+	return mock.ListPaymentDisputesFunc(ctx, arg)
+}
+
+// ListPaymentDisputesCalls gets all the calls that were made to ListPaymentDisputes.
+// Check the length with:
+//     len(mockedQuerier.ListPaymentDisputesCalls())
+func (mock *QuerierMock) ListPaymentDisputesCalls() []struct {
+	Ctx context.Context
+	Arg ListPaymentDisputesParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg ListPaymentDisputesParams
+	}
+	mock.lockListPaymentDisputes.RLock()
+	calls = mock.calls.ListPaymentDisputes
+	mock.lockListPaymentDisputes.RUnlock()
+	return calls
+}
+
+// ListPaymentEvents calls ListPaymentEventsFunc.
+func (mock *QuerierMock) ListPaymentEvents(ctx context.Context, arg ListPaymentEventsParams) ([]PaymentEvent, error) {
+	if mock.ListPaymentEventsFunc == nil {
+		panic("QuerierMock.ListPaymentEventsFunc: method is nil but Querier.ListPaymentEvents was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg ListPaymentEventsParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockListPaymentEvents.Lock()
+	mock.calls.ListPaymentEvents = append(mock.calls.ListPaymentEvents, callInfo)
+	mock.lockListPaymentEvents.Unlock()
+	return mock.ListPaymentEventsFunc(ctx, arg)
+}
+
+// ListPaymentEventsCalls gets all the calls that were made to ListPaymentEvents.
+// Check the length with:
+//     len(mockedQuerier.ListPaymentEventsCalls())
+func (mock *QuerierMock) ListPaymentEventsCalls() []struct {
+	Ctx context.Context
+	Arg ListPaymentEventsParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg ListPaymentEventsParams
+	}
+	mock.lockListPaymentEvents.RLock()
+	calls = mock.calls.ListPaymentEvents
+	mock.lockListPaymentEvents.RUnlock()
+	return calls
+}
+
+// ListPaymentStatuses calls ListPaymentStatusesFunc.
+func (mock *QuerierMock) ListPaymentStatuses(ctx context.Context, arg ListPaymentStatusesParams) ([]ListPaymentStatusesRow, error) {
+	if mock.ListPaymentStatusesFunc == nil {
+		panic("QuerierMock.ListPaymentStatusesFunc: method is nil but Querier.ListPaymentStatuses was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg ListPaymentStatusesParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockListPaymentStatuses.Lock()
+	mock.calls.ListPaymentStatuses = append(mock.calls.ListPaymentStatuses, callInfo)
+	mock.lockListPaymentStatuses.Unlock()
+	return mock.ListPaymentStatusesFunc(ctx, arg)
+}
+
+// ListPaymentStatusesCalls gets all the calls that were made to ListPaymentStatuses.
+// Check the length with:
+//     len(mockedQuerier.ListPaymentStatusesCalls())
+func (mock *QuerierMock) ListPaymentStatusesCalls() []struct {
+	Ctx context.Context
+	Arg ListPaymentStatusesParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg ListPaymentStatusesParams
+	}
+	mock.lockListPaymentStatuses.RLock()
+	calls = mock.calls.ListPaymentStatuses
+	mock.lockListPaymentStatuses.RUnlock()
+	return calls
+}
+
+// ListPaymentsByIDs calls ListPaymentsByIDsFunc.
+func (mock *QuerierMock) ListPaymentsByIDs(ctx context.Context, arg ListPaymentsByIDsParams) ([]Payment, error) {
+	if mock.ListPaymentsByIDsFunc == nil {
+		panic("QuerierMock.ListPaymentsByIDsFunc: method is nil but Querier.ListPaymentsByIDs was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg ListPaymentsByIDsParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockListPaymentsByIDs.Lock()
+	mock.calls.ListPaymentsByIDs = append(mock.calls.ListPaymentsByIDs, callInfo)
+	mock.lockListPaymentsByIDs.Unlock()
+	return mock.ListPaymentsByIDsFunc(ctx, arg)
+}
+
+// ListPaymentsByIDsCalls gets all the calls that were made to ListPaymentsByIDs.
+// Check the length with:
+//     len(mockedQuerier.ListPaymentsByIDsCalls())
+func (mock *QuerierMock) ListPaymentsByIDsCalls() []struct {
+	Ctx context.Context
+	Arg ListPaymentsByIDsParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg ListPaymentsByIDsParams
+	}
+	mock.lockListPaymentsByIDs.RLock()
+	calls = mock.calls.ListPaymentsByIDs
+	mock.lockListPaymentsByIDs.RUnlock()
+	return calls
+}
+
+// ListReconciliationDiscrepancies calls ListReconciliationDiscrepanciesFunc.
+func (mock *QuerierMock) ListReconciliationDiscrepancies(ctx context.Context, arg ListReconciliationDiscrepanciesParams) ([]ReconciliationDiscrepancy, error) {
+	if mock.ListReconciliationDiscrepanciesFunc == nil {
+		panic("QuerierMock.ListReconciliationDiscrepanciesFunc: method is nil but Querier.ListReconciliationDiscrepancies was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg ListReconciliationDiscrepanciesParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockListReconciliationDiscrepancies.Lock()
+	mock.calls.ListReconciliationDiscrepancies = append(mock.calls.ListReconciliationDiscrepancies, callInfo)
+	mock.lockListReconciliationDiscrepancies.Unlock()
+	return mock.ListReconciliationDiscrepanciesFunc(ctx, arg)
+}
+
+// ListReconciliationDiscrepanciesCalls gets all the calls that were made to ListReconciliationDiscrepancies.
+// Check the length with:
+//     len(mockedQuerier.ListReconciliationDiscrepanciesCalls())
+func (mock *QuerierMock) ListReconciliationDiscrepanciesCalls() []struct {
+	Ctx context.Context
+	Arg ListReconciliationDiscrepanciesParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg ListReconciliationDiscrepanciesParams
+	}
+	mock.lockListReconciliationDiscrepancies.RLock()
+	calls = mock.calls.ListReconciliationDiscrepancies
+	mock.lockListReconciliationDiscrepancies.RUnlock()
+	return calls
+}
+
+// ListReconciliationRuns calls ListReconciliationRunsFunc.
+func (mock *QuerierMock) ListReconciliationRuns(ctx context.Context, arg ListReconciliationRunsParams) ([]ReconciliationRun, error) {
+	if mock.ListReconciliationRunsFunc == nil {
+		panic("QuerierMock.ListReconciliationRunsFunc: method is nil but Querier.ListReconciliationRuns was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg ListReconciliationRunsParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockListReconciliationRuns.Lock()
+	mock.calls.ListReconciliationRuns = append(mock.calls.ListReconciliationRuns, callInfo)
+	mock.lockListReconciliationRuns.Unlock()
+	return mock.ListReconciliationRunsFunc(ctx, arg)
+}
+
+// ListReconciliationRunsCalls gets all the calls that were made to ListReconciliationRuns.
+// Check the length with:
+//     len(mockedQuerier.ListReconciliationRunsCalls())
+func (mock *QuerierMock) ListReconciliationRunsCalls() []struct {
+	Ctx context.Context
+	Arg ListReconciliationRunsParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg ListReconciliationRunsParams
+	}
+	mock.lockListReconciliationRuns.RLock()
+	calls = mock.calls.ListReconciliationRuns
+	mock.lockListReconciliationRuns.RUnlock()
+	return calls
+}
+
+// ListReviewPayments calls ListReviewPaymentsFunc.
+func (mock *QuerierMock) ListReviewPayments(ctx context.Context, arg ListReviewPaymentsParams) ([]Payment, error) {
+	if mock.ListReviewPaymentsFunc == nil {
+		panic("QuerierMock.ListReviewPaymentsFunc: method is nil but Querier.ListReviewPayments was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg ListReviewPaymentsParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockListReviewPayments.Lock()
+	mock.calls.ListReviewPayments = append(mock.calls.ListReviewPayments, callInfo)
+	mock.lockListReviewPayments.Unlock()
+	return mock.ListReviewPaymentsFunc(ctx, arg)
+}
+
+// ListReviewPaymentsCalls gets all the calls that were made to ListReviewPayments.
+// Check the length with:
+//     len(mockedQuerier.ListReviewPaymentsCalls())
+func (mock *QuerierMock) ListReviewPaymentsCalls() []struct {
+	Ctx context.Context
+	Arg ListReviewPaymentsParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg ListReviewPaymentsParams
+	}
+	mock.lockListReviewPayments.RLock()
+	calls = mock.calls.ListReviewPayments
+	mock.lockListReviewPayments.RUnlock()
+	return calls
+}
 
-	// GetPaymentStatusByIDFunc mocks the GetPaymentStatusByID method.
-	GetPaymentStatusByIDFunc func(ctx context.Context, id int64) (ValidStatus, error)
+// ListUnscheduledPayments calls ListUnscheduledPaymentsFunc.
+func (mock *QuerierMock) ListUnscheduledPayments(ctx context.Context, limit int32) ([]ListUnscheduledPaymentsRow, error) {
+	if mock.ListUnscheduledPaymentsFunc == nil {
+		panic("QuerierMock.ListUnscheduledPaymentsFunc: method is nil but Querier.ListUnscheduledPayments was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Limit int32
+	}{
+		Ctx:   ctx,
+		Limit: limit,
+	}
+	mock.lockListUnscheduledPayments.Lock()
+	mock.calls.ListUnscheduledPayments = append(mock.calls.ListUnscheduledPayments, callInfo)
+	mock.lockListUnscheduledPayments.Unlock()
+	return mock.ListUnscheduledPaymentsFunc(ctx, limit)
+}
 
-	// ListUserPaymentsByEmailFunc mocks the ListUserPaymentsByEmail method.
-	ListUserPaymentsByEmailFunc func(ctx context.Context, arg ListUserPaymentsByEmailParams) ([]Payment, error)
+// ListUnscheduledPaymentsCalls gets all the calls that were made to ListUnscheduledPayments.
+// Check the length with:
+//     len(mockedQuerier.ListUnscheduledPaymentsCalls())
+func (mock *QuerierMock) ListUnscheduledPaymentsCalls() []struct {
+	Ctx   context.Context
+	Limit int32
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Limit int32
+	}
+	mock.lockListUnscheduledPayments.RLock()
+	calls = mock.calls.ListUnscheduledPayments
+	mock.lockListUnscheduledPayments.RUnlock()
+	return calls
+}
 
-	// ListUserPaymentsByIDFunc mocks the ListUserPaymentsByID method.
-	ListUserPaymentsByIDFunc func(ctx context.Context, arg ListUserPaymentsByIDParams) ([]Payment, error)
+// ListUnsettledPayments calls ListUnsettledPaymentsFunc.
+func (mock *QuerierMock) ListUnsettledPayments(ctx context.Context, arg ListUnsettledPaymentsParams) ([]Payment, error) {
+	if mock.ListUnsettledPaymentsFunc == nil {
+		panic("QuerierMock.ListUnsettledPaymentsFunc: method is nil but Querier.ListUnsettledPayments was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg ListUnsettledPaymentsParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockListUnsettledPayments.Lock()
+	mock.calls.ListUnsettledPayments = append(mock.calls.ListUnsettledPayments, callInfo)
+	mock.lockListUnsettledPayments.Unlock()
+	return mock.ListUnsettledPaymentsFunc(ctx, arg)
+}
 
-	// UpdatePaymentStatusFunc mocks the UpdatePaymentStatus method.
-	UpdatePaymentStatusFunc func(ctx context.Context, arg UpdatePaymentStatusParams) (int64, error)
+// ListUnsettledPaymentsCalls gets all the calls that were made to ListUnsettledPayments.
+// Check the length with:
+//     len(mockedQuerier.ListUnsettledPaymentsCalls())
+func (mock *QuerierMock) ListUnsettledPaymentsCalls() []struct {
+	Ctx context.Context
+	Arg ListUnsettledPaymentsParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg ListUnsettledPaymentsParams
+	}
+	mock.lockListUnsettledPayments.RLock()
+	calls = mock.calls.ListUnsettledPayments
+	mock.lockListUnsettledPayments.RUnlock()
+	return calls
+}
 
-	// calls tracks calls to the methods.
-	calls struct {
-		// CreatePayment holds details about calls to the CreatePayment method.
-		CreatePayment []struct {
-			// Ctx is the ctx argument value.
-			Ctx context.Context
-			// Arg is the arg argument value.
-			Arg CreatePaymentParams
-		}
-		// DiscardPayment holds details about calls to the DiscardPayment method.
-		DiscardPayment []struct {
-			// Ctx is the ctx argument value.
-			Ctx context.Context
-			// ID is the id argument value.
-			ID int64
-		}
-		// GetPaymentStatusByID holds details about calls to the GetPaymentStatusByID method.
-		GetPaymentStatusByID []struct {
-			// Ctx is the ctx argument value.
-			Ctx context.Context
-			// ID is the id argument value.
-			ID int64
-		}
-		// ListUserPaymentsByEmail holds details about calls to the ListUserPaymentsByEmail method.
-		ListUserPaymentsByEmail []struct {
-			// Ctx is the ctx argument value.
-			Ctx context.Context
-			// Arg is the arg argument value.
-			Arg ListUserPaymentsByEmailParams
-		}
-		// ListUserPaymentsByID holds details about calls to the ListUserPaymentsByID method.
-		ListUserPaymentsByID []struct {
-			// Ctx is the ctx argument value.
-			Ctx context.Context
-			// Arg is the arg argument value.
-			Arg ListUserPaymentsByIDParams
-		}
-		// UpdatePaymentStatus holds details about calls to the UpdatePaymentStatus method.
-		UpdatePaymentStatus []struct {
-			// Ctx is the ctx argument value.
-			Ctx context.Context
-			// Arg is the arg argument value.
-			Arg UpdatePaymentStatusParams
-		}
+// ListUsedMerchantReferences calls ListUsedMerchantReferencesFunc.
+func (mock *QuerierMock) ListUsedMerchantReferences(ctx context.Context, arg ListUsedMerchantReferencesParams) ([]string, error) {
+	if mock.ListUsedMerchantReferencesFunc == nil {
+		panic("QuerierMock.ListUsedMerchantReferencesFunc: method is nil but Querier.ListUsedMerchantReferences was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg ListUsedMerchantReferencesParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
 	}
-	lockCreatePayment           sync.RWMutex
-	lockDiscardPayment          sync.RWMutex
-	lockGetPaymentStatusByID    sync.RWMutex
-	lockListUserPaymentsByEmail sync.RWMutex
-	lockListUserPaymentsByID    sync.RWMutex
-	lockUpdatePaymentStatus     sync.RWMutex
+	mock.lockListUsedMerchantReferences.Lock()
+	mock.calls.ListUsedMerchantReferences = append(mock.calls.ListUsedMerchantReferences, callInfo)
+	mock.lockListUsedMerchantReferences.Unlock()
+	return mock.ListUsedMerchantReferencesFunc(ctx, arg)
 }
 
-// CreatePayment calls CreatePaymentFunc.
-func (mock *QuerierMock) CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error) {
-	if mock.CreatePaymentFunc == nil {
-		panic("QuerierMock.CreatePaymentFunc: method is nil but Querier.CreatePayment was just called")
+// ListUsedMerchantReferencesCalls gets all the calls that were made to ListUsedMerchantReferences.
+// Check the length with:
+//     len(mockedQuerier.ListUsedMerchantReferencesCalls())
+func (mock *QuerierMock) ListUsedMerchantReferencesCalls() []struct {
+	Ctx context.Context
+	Arg ListUsedMerchantReferencesParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg ListUsedMerchantReferencesParams
+	}
+	mock.lockListUsedMerchantReferences.RLock()
+	calls = mock.calls.ListUsedMerchantReferences
+	mock.lockListUsedMerchantReferences.RUnlock()
+	return calls
+}
+
+// ListUsers calls ListUsersFunc.
+func (mock *QuerierMock) ListUsers(ctx context.Context) ([]User, error) {
+	if mock.ListUsersFunc == nil {
+		panic("QuerierMock.ListUsersFunc: method is nil but Querier.ListUsers was just called")
 	}
 	callInfo := struct {
 		Ctx context.Context
-		Arg CreatePaymentParams
+	}{
+		Ctx: ctx,
+	}
+	mock.lockListUsers.Lock()
+	mock.calls.ListUsers = append(mock.calls.ListUsers, callInfo)
+	mock.lockListUsers.Unlock()
+	return mock.ListUsersFunc(ctx)
+}
+
+// ListUsersCalls gets all the calls that were made to ListUsers.
+// Check the length with:
+//     len(mockedQuerier.ListUsersCalls())
+func (mock *QuerierMock) ListUsersCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockListUsers.RLock()
+	calls = mock.calls.ListUsers
+	mock.lockListUsers.RUnlock()
+	return calls
+}
+
+// NotifyPaymentEvents calls NotifyPaymentEventsFunc.
+func (mock *QuerierMock) NotifyPaymentEvents(ctx context.Context, arg NotifyPaymentEventsParams) error {
+	if mock.NotifyPaymentEventsFunc == nil {
+		panic("QuerierMock.NotifyPaymentEventsFunc: method is nil but Querier.NotifyPaymentEvents was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg NotifyPaymentEventsParams
 	}{
 		Ctx: ctx,
 		Arg: arg,
 	}
-	mock.lockCreatePayment.Lock()
-	mock.calls.CreatePayment = append(mock.calls.CreatePayment, callInfo)
-	mock.lockCreatePayment.Unlock()
-	return mock.CreatePaymentFunc(ctx, arg)
+	mock.lockNotifyPaymentEvents.Lock()
+	mock.calls.NotifyPaymentEvents = append(mock.calls.NotifyPaymentEvents, callInfo)
+	mock.lockNotifyPaymentEvents.Unlock()
+	return mock.NotifyPaymentEventsFunc(ctx, arg)
 }
 
-// CreatePaymentCalls gets all the calls that were made to CreatePayment.
+// NotifyPaymentEventsCalls gets all the calls that were made to NotifyPaymentEvents.
 // Check the length with:
-//     len(mockedQuerier.CreatePaymentCalls())
-func (mock *QuerierMock) CreatePaymentCalls() []struct {
+//     len(mockedQuerier.NotifyPaymentEventsCalls())
+func (mock *QuerierMock) NotifyPaymentEventsCalls() []struct {
 	Ctx context.Context
-	Arg CreatePaymentParams
+	Arg NotifyPaymentEventsParams
 } {
 	var calls []struct {
 		Ctx context.Context
-		Arg CreatePaymentParams
+		Arg NotifyPaymentEventsParams
 	}
-	mock.lockCreatePayment.RLock()
-	calls = mock.calls.CreatePayment
-	mock.lockCreatePayment.RUnlock()
+	mock.lockNotifyPaymentEvents.RLock()
+	calls = mock.calls.NotifyPaymentEvents
+	mock.lockNotifyPaymentEvents.RUnlock()
 	return calls
 }
 
-// DiscardPayment calls DiscardPaymentFunc.
-func (mock *QuerierMock) DiscardPayment(ctx context.Context, id int64) (int64, error) {
-	if mock.DiscardPaymentFunc == nil {
-		panic("QuerierMock.DiscardPaymentFunc: method is nil but Querier.DiscardPayment was just called")
+// ReportDailySummaries calls ReportDailySummariesFunc.
+func (mock *QuerierMock) ReportDailySummaries(ctx context.Context, arg ReportDailySummariesParams) ([]ReportDailySummariesRow, error) {
+	if mock.ReportDailySummariesFunc == nil {
+		panic("QuerierMock.ReportDailySummariesFunc: method is nil but Querier.ReportDailySummaries was just called")
 	}
 	callInfo := struct {
 		Ctx context.Context
-		ID  int64
+		Arg ReportDailySummariesParams
 	}{
 		Ctx: ctx,
-		ID:  id,
+		Arg: arg,
 	}
-	mock.lockDiscardPayment.Lock()
-	mock.calls.DiscardPayment = append(mock.calls.DiscardPayment, callInfo)
-	mock.lockDiscardPayment.Unlock()
-	return mock.DiscardPaymentFunc(ctx, id)
+	mock.lockReportDailySummaries.Lock()
+	mock.calls.ReportDailySummaries = append(mock.calls.ReportDailySummaries, callInfo)
+	mock.lockReportDailySummaries.Unlock()
+	return mock.ReportDailySummariesFunc(ctx, arg)
 }
 
-// DiscardPaymentCalls gets all the calls that were made to DiscardPayment.
+// ReportDailySummariesCalls gets all the calls that were made to ReportDailySummaries.
 // Check the length with:
-//     len(mockedQuerier.DiscardPaymentCalls())
-func (mock *QuerierMock) DiscardPaymentCalls() []struct {
+//     len(mockedQuerier.ReportDailySummariesCalls())
+func (mock *QuerierMock) ReportDailySummariesCalls() []struct {
 	Ctx context.Context
-	ID  int64
+	Arg ReportDailySummariesParams
 } {
 	var calls []struct {
 		Ctx context.Context
-		ID  int64
+		Arg ReportDailySummariesParams
 	}
-	mock.lockDiscardPayment.RLock()
-	calls = mock.calls.DiscardPayment
-	mock.lockDiscardPayment.RUnlock()
+	mock.lockReportDailySummaries.RLock()
+	calls = mock.calls.ReportDailySummaries
+	mock.lockReportDailySummaries.RUnlock()
 	return calls
 }
 
-// GetPaymentStatusByID calls GetPaymentStatusByIDFunc.
-func (mock *QuerierMock) GetPaymentStatusByID(ctx context.Context, id int64) (ValidStatus, error) {
-	if mock.GetPaymentStatusByIDFunc == nil {
-		panic("QuerierMock.GetPaymentStatusByIDFunc: method is nil but Querier.GetPaymentStatusByID was just called")
+// ReportPayments calls ReportPaymentsFunc.
+func (mock *QuerierMock) ReportPayments(ctx context.Context, arg ReportPaymentsParams) ([]ReportPaymentsRow, error) {
+	if mock.ReportPaymentsFunc == nil {
+		panic("QuerierMock.ReportPaymentsFunc: method is nil but Querier.ReportPayments was just called")
 	}
 	callInfo := struct {
 		Ctx context.Context
-		ID  int64
+		Arg ReportPaymentsParams
 	}{
 		Ctx: ctx,
-		ID:  id,
+		Arg: arg,
 	}
-	mock.lockGetPaymentStatusByID.Lock()
-	mock.calls.GetPaymentStatusByID = append(mock.calls.GetPaymentStatusByID, callInfo)
-	mock.lockGetPaymentStatusByID.Unlock()
-	return mock.GetPaymentStatusByIDFunc(ctx, id)
+	mock.lockReportPayments.Lock()
+	mock.calls.ReportPayments = append(mock.calls.ReportPayments, callInfo)
+	mock.lockReportPayments.Unlock()
+	return mock.ReportPaymentsFunc(ctx, arg)
 }
 
-// GetPaymentStatusByIDCalls gets all the calls that were made to GetPaymentStatusByID.
+// ReportPaymentsCalls gets all the calls that were made to ReportPayments.
 // Check the length with:
-//     len(mockedQuerier.GetPaymentStatusByIDCalls())
-func (mock *QuerierMock) GetPaymentStatusByIDCalls() []struct {
+//     len(mockedQuerier.ReportPaymentsCalls())
+func (mock *QuerierMock) ReportPaymentsCalls() []struct {
 	Ctx context.Context
-	ID  int64
+	Arg ReportPaymentsParams
 } {
 	var calls []struct {
 		Ctx context.Context
-		ID  int64
+		Arg ReportPaymentsParams
 	}
-	mock.lockGetPaymentStatusByID.RLock()
-	calls = mock.calls.GetPaymentStatusByID
-	mock.lockGetPaymentStatusByID.RUnlock()
+	mock.lockReportPayments.RLock()
+	calls = mock.calls.ReportPayments
+	mock.lockReportPayments.RUnlock()
+	return calls
+}
+
+// ReservePaymentIDs calls ReservePaymentIDsFunc.
+func (mock *QuerierMock) ReservePaymentIDs(ctx context.Context, count int32) ([]int64, error) {
+	if mock.ReservePaymentIDsFunc == nil {
+		panic("QuerierMock.ReservePaymentIDsFunc: method is nil but Querier.ReservePaymentIDs was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Count int32
+	}{
+		Ctx:   ctx,
+		Count: count,
+	}
+	mock.lockReservePaymentIDs.Lock()
+	mock.calls.ReservePaymentIDs = append(mock.calls.ReservePaymentIDs, callInfo)
+	mock.lockReservePaymentIDs.Unlock()
+	return mock.ReservePaymentIDsFunc(ctx, count)
+}
+
+// ReservePaymentIDsCalls gets all the calls that were made to ReservePaymentIDs.
+// Check the length with:
+//     len(mockedQuerier.ReservePaymentIDsCalls())
+func (mock *QuerierMock) ReservePaymentIDsCalls() []struct {
+	Ctx   context.Context
+	Count int32
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Count int32
+	}
+	mock.lockReservePaymentIDs.RLock()
+	calls = mock.calls.ReservePaymentIDs
+	mock.lockReservePaymentIDs.RUnlock()
+	return calls
+}
+
+// ResolveDispute calls ResolveDisputeFunc.
+func (mock *QuerierMock) ResolveDispute(ctx context.Context, arg ResolveDisputeParams) (Dispute, error) {
+	if mock.ResolveDisputeFunc == nil {
+		panic("QuerierMock.ResolveDisputeFunc: method is nil but Querier.ResolveDispute was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg ResolveDisputeParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockResolveDispute.Lock()
+	mock.calls.ResolveDispute = append(mock.calls.ResolveDispute, callInfo)
+	mock.lockResolveDispute.Unlock()
+	return mock.ResolveDisputeFunc(ctx, arg)
+}
+
+// ResolveDisputeCalls gets all the calls that were made to ResolveDispute.
+// Check the length with:
+//     len(mockedQuerier.ResolveDisputeCalls())
+func (mock *QuerierMock) ResolveDisputeCalls() []struct {
+	Ctx context.Context
+	Arg ResolveDisputeParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg ResolveDisputeParams
+	}
+	mock.lockResolveDispute.RLock()
+	calls = mock.calls.ResolveDispute
+	mock.lockResolveDispute.RUnlock()
+	return calls
+}
+
+// ReviewPayment calls ReviewPaymentFunc.
+func (mock *QuerierMock) ReviewPayment(ctx context.Context, arg ReviewPaymentParams) (int64, error) {
+	if mock.ReviewPaymentFunc == nil {
+		panic("QuerierMock.ReviewPaymentFunc: method is nil but Querier.ReviewPayment was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg ReviewPaymentParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockReviewPayment.Lock()
+	mock.calls.ReviewPayment = append(mock.calls.ReviewPayment, callInfo)
+	mock.lockReviewPayment.Unlock()
+	return mock.ReviewPaymentFunc(ctx, arg)
+}
+
+// ReviewPaymentCalls gets all the calls that were made to ReviewPayment.
+// Check the length with:
+//     len(mockedQuerier.ReviewPaymentCalls())
+func (mock *QuerierMock) ReviewPaymentCalls() []struct {
+	Ctx context.Context
+	Arg ReviewPaymentParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg ReviewPaymentParams
+	}
+	mock.lockReviewPayment.RLock()
+	calls = mock.calls.ReviewPayment
+	mock.lockReviewPayment.RUnlock()
+	return calls
+}
+
+// RevokeAPIKey calls RevokeAPIKeyFunc.
+func (mock *QuerierMock) RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (int64, error) {
+	if mock.RevokeAPIKeyFunc == nil {
+		panic("QuerierMock.RevokeAPIKeyFunc: method is nil but Querier.RevokeAPIKey was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg RevokeAPIKeyParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockRevokeAPIKey.Lock()
+	mock.calls.RevokeAPIKey = append(mock.calls.RevokeAPIKey, callInfo)
+	mock.lockRevokeAPIKey.Unlock()
+	return mock.RevokeAPIKeyFunc(ctx, arg)
+}
+
+// RevokeAPIKeyCalls gets all the calls that were made to RevokeAPIKey.
+// Check the length with:
+//     len(mockedQuerier.RevokeAPIKeyCalls())
+func (mock *QuerierMock) RevokeAPIKeyCalls() []struct {
+	Ctx context.Context
+	Arg RevokeAPIKeyParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg RevokeAPIKeyParams
+	}
+	mock.lockRevokeAPIKey.RLock()
+	calls = mock.calls.RevokeAPIKey
+	mock.lockRevokeAPIKey.RUnlock()
 	return calls
 }
 
-// ListUserPaymentsByEmail calls ListUserPaymentsByEmailFunc.
-func (mock *QuerierMock) ListUserPaymentsByEmail(ctx context.Context, arg ListUserPaymentsByEmailParams) ([]Payment, error) {
-	if mock.ListUserPaymentsByEmailFunc == nil {
-		panic("QuerierMock.ListUserPaymentsByEmailFunc: method is nil but Querier.ListUserPaymentsByEmail was just called")
+// RotateAPIKey calls RotateAPIKeyFunc.
+func (mock *QuerierMock) RotateAPIKey(ctx context.Context, arg RotateAPIKeyParams) (ApiKey, error) {
+	if mock.RotateAPIKeyFunc == nil {
+		panic("QuerierMock.RotateAPIKeyFunc: method is nil but Querier.RotateAPIKey was just called")
 	}
 	callInfo := struct {
 		Ctx context.Context
-		Arg ListUserPaymentsByEmailParams
+		Arg RotateAPIKeyParams
 	}{
 		Ctx: ctx,
 		Arg: arg,
 	}
-	mock.lockListUserPaymentsByEmail.Lock()
-	mock.calls.ListUserPaymentsByEmail = append(mock.calls.ListUserPaymentsByEmail, callInfo)
-	mock.lockListUserPaymentsByEmail.Unlock()
-	return mock.ListUserPaymentsByEmailFunc(ctx, arg)
+	mock.lockRotateAPIKey.Lock()
+	mock.calls.RotateAPIKey = append(mock.calls.RotateAPIKey, callInfo)
+	mock.lockRotateAPIKey.Unlock()
+	return mock.RotateAPIKeyFunc(ctx, arg)
 }
 
-// ListUserPaymentsByEmailCalls gets all the calls that were made to ListUserPaymentsByEmail.
+// RotateAPIKeyCalls gets all the calls that were made to RotateAPIKey.
 // Check the length with:
-//     len(mockedQuerier.ListUserPaymentsByEmailCalls())
-func (mock *QuerierMock) ListUserPaymentsByEmailCalls() []struct {
+//     len(mockedQuerier.RotateAPIKeyCalls())
+func (mock *QuerierMock) RotateAPIKeyCalls() []struct {
 	Ctx context.Context
-	Arg ListUserPaymentsByEmailParams
+	Arg RotateAPIKeyParams
 } {
 	var calls []struct {
 		Ctx context.Context
-		Arg ListUserPaymentsByEmailParams
+		Arg RotateAPIKeyParams
 	}
-	mock.lockListUserPaymentsByEmail.RLock()
-	calls = mock.calls.ListUserPaymentsByEmail
-	mock.lockListUserPaymentsByEmail.RUnlock()
+	mock.lockRotateAPIKey.RLock()
+	calls = mock.calls.RotateAPIKey
+	mock.lockRotateAPIKey.RUnlock()
 	return calls
 }
 
-// ListUserPaymentsByID calls ListUserPaymentsByIDFunc.
-func (mock *QuerierMock) ListUserPaymentsByID(ctx context.Context, arg ListUserPaymentsByIDParams) ([]Payment, error) {
-	if mock.ListUserPaymentsByIDFunc == nil {
-		panic("QuerierMock.ListUserPaymentsByIDFunc: method is nil but Querier.ListUserPaymentsByID was just called")
+// ScheduleSettlements calls ScheduleSettlementsFunc.
+func (mock *QuerierMock) ScheduleSettlements(ctx context.Context, arg ScheduleSettlementsParams) error {
+	if mock.ScheduleSettlementsFunc == nil {
+		panic("QuerierMock.ScheduleSettlementsFunc: method is nil but Querier.ScheduleSettlements was just called")
 	}
 	callInfo := struct {
 		Ctx context.Context
-		Arg ListUserPaymentsByIDParams
+		Arg ScheduleSettlementsParams
 	}{
 		Ctx: ctx,
 		Arg: arg,
 	}
-	mock.lockListUserPaymentsByID.Lock()
-	mock.calls.ListUserPaymentsByID = append(mock.calls.ListUserPaymentsByID, callInfo)
-	mock.lockListUserPaymentsByID.Unlock()
-	return mock.ListUserPaymentsByIDFunc(ctx, arg)
+	mock.lockScheduleSettlements.Lock()
+	mock.calls.ScheduleSettlements = append(mock.calls.ScheduleSettlements, callInfo)
+	mock.lockScheduleSettlements.Unlock()
+	return mock.ScheduleSettlementsFunc(ctx, arg)
 }
 
-// ListUserPaymentsByIDCalls gets all the calls that were made to ListUserPaymentsByID.
+// ScheduleSettlementsCalls gets all the calls that were made to ScheduleSettlements.
 // Check the length with:
-//     len(mockedQuerier.ListUserPaymentsByIDCalls())
-func (mock *QuerierMock) ListUserPaymentsByIDCalls() []struct {
+//     len(mockedQuerier.ScheduleSettlementsCalls())
+func (mock *QuerierMock) ScheduleSettlementsCalls() []struct {
 	Ctx context.Context
-	Arg ListUserPaymentsByIDParams
+	Arg ScheduleSettlementsParams
 } {
 	var calls []struct {
 		Ctx context.Context
-		Arg ListUserPaymentsByIDParams
+		Arg ScheduleSettlementsParams
 	}
-	mock.lockListUserPaymentsByID.RLock()
-	calls = mock.calls.ListUserPaymentsByID
-	mock.lockListUserPaymentsByID.RUnlock()
+	mock.lockScheduleSettlements.RLock()
+	calls = mock.calls.ScheduleSettlements
+	mock.lockScheduleSettlements.RUnlock()
 	return calls
 }
 
@@ -323,3 +2742,73 @@ func (mock *QuerierMock) UpdatePaymentStatusCalls() []struct {
 	mock.lockUpdatePaymentStatus.RUnlock()
 	return calls
 }
+
+// UpdateUserPassword calls UpdateUserPasswordFunc.
+func (mock *QuerierMock) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (int64, error) {
+	if mock.UpdateUserPasswordFunc == nil {
+		panic("QuerierMock.UpdateUserPasswordFunc: method is nil but Querier.UpdateUserPassword was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg UpdateUserPasswordParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockUpdateUserPassword.Lock()
+	mock.calls.UpdateUserPassword = append(mock.calls.UpdateUserPassword, callInfo)
+	mock.lockUpdateUserPassword.Unlock()
+	return mock.UpdateUserPasswordFunc(ctx, arg)
+}
+
+// UpdateUserPasswordCalls gets all the calls that were made to UpdateUserPassword.
+// Check the length with:
+//     len(mockedQuerier.UpdateUserPasswordCalls())
+func (mock *QuerierMock) UpdateUserPasswordCalls() []struct {
+	Ctx context.Context
+	Arg UpdateUserPasswordParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg UpdateUserPasswordParams
+	}
+	mock.lockUpdateUserPassword.RLock()
+	calls = mock.calls.UpdateUserPassword
+	mock.lockUpdateUserPassword.RUnlock()
+	return calls
+}
+
+// UpdateUserRole calls UpdateUserRoleFunc.
+func (mock *QuerierMock) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (int64, error) {
+	if mock.UpdateUserRoleFunc == nil {
+		panic("QuerierMock.UpdateUserRoleFunc: method is nil but Querier.UpdateUserRole was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg UpdateUserRoleParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockUpdateUserRole.Lock()
+	mock.calls.UpdateUserRole = append(mock.calls.UpdateUserRole, callInfo)
+	mock.lockUpdateUserRole.Unlock()
+	return mock.UpdateUserRoleFunc(ctx, arg)
+}
+
+// UpdateUserRoleCalls gets all the calls that were made to UpdateUserRole.
+// Check the length with:
+//     len(mockedQuerier.UpdateUserRoleCalls())
+func (mock *QuerierMock) UpdateUserRoleCalls() []struct {
+	Ctx context.Context
+	Arg UpdateUserRoleParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg UpdateUserRoleParams
+	}
+	mock.lockUpdateUserRole.RLock()
+	calls = mock.calls.UpdateUserRole
+	mock.lockUpdateUserRole.RUnlock()
+	return calls
+}
@@ -5,12 +5,115 @@
 package repository
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
+type DiscrepancyKind string
+
+const (
+	DiscrepancyKindAmount            DiscrepancyKind = "amount"
+	DiscrepancyKindCurrency          DiscrepancyKind = "currency"
+	DiscrepancyKindStatus            DiscrepancyKind = "status"
+	DiscrepancyKindMissingPayment    DiscrepancyKind = "missing_payment"
+	DiscrepancyKindMissingSettlement DiscrepancyKind = "missing_settlement"
+)
+
+func (e *DiscrepancyKind) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = DiscrepancyKind(s)
+	case string:
+		*e = DiscrepancyKind(s)
+	default:
+		return fmt.Errorf("unsupported scan type for DiscrepancyKind: %T", src)
+	}
+	return nil
+}
+
+type DisputeStatus string
+
+const (
+	DisputeStatusOpen DisputeStatus = "open"
+	DisputeStatusWon  DisputeStatus = "won"
+	DisputeStatusLost DisputeStatus = "lost"
+)
+
+func (e *DisputeStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = DisputeStatus(s)
+	case string:
+		*e = DisputeStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for DisputeStatus: %T", src)
+	}
+	return nil
+}
+
+type ReviewDecision string
+
+const (
+	ReviewDecisionApproved ReviewDecision = "approved"
+	ReviewDecisionDeclined ReviewDecision = "declined"
+)
+
+func (e *ReviewDecision) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = ReviewDecision(s)
+	case string:
+		*e = ReviewDecision(s)
+	default:
+		return fmt.Errorf("unsupported scan type for ReviewDecision: %T", src)
+	}
+	return nil
+}
+
+type RiskDecision string
+
+const (
+	RiskDecisionApprove RiskDecision = "approve"
+	RiskDecisionReview  RiskDecision = "review"
+	RiskDecisionReject  RiskDecision = "reject"
+)
+
+func (e *RiskDecision) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = RiskDecision(s)
+	case string:
+		*e = RiskDecision(s)
+	default:
+		return fmt.Errorf("unsupported scan type for RiskDecision: %T", src)
+	}
+	return nil
+}
+
+type UserRole string
+
+const (
+	UserRoleProvider UserRole = "provider"
+	UserRoleOperator UserRole = "operator"
+	UserRoleAdmin    UserRole = "admin"
+	UserRoleReadonly UserRole = "readonly"
+)
+
+func (e *UserRole) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = UserRole(s)
+	case string:
+		*e = UserRole(s)
+	default:
+		return fmt.Errorf("unsupported scan type for UserRole: %T", src)
+	}
+	return nil
+}
+
 type ValidCurrency string
 
 const (
@@ -38,6 +141,8 @@ const (
 	ValidStatusSuccess ValidStatus = "success"
 	ValidStatusFailure ValidStatus = "failure"
 	ValidStatusError   ValidStatus = "error"
+	ValidStatusExpired ValidStatus = "expired"
+	ValidStatusReview  ValidStatus = "review"
 )
 
 func (e *ValidStatus) Scan(src interface{}) error {
@@ -52,13 +157,151 @@ func (e *ValidStatus) Scan(src interface{}) error {
 	return nil
 }
 
-type Payment struct {
+type ApiKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"key_hash"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	MerchantID int64      `json:"merchant_id"`
+}
+
+type AuditLog struct {
+	ID         int64     `json:"id"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	Target     string    `json:"target"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	MerchantID int64     `json:"merchant_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type CallbackNonce struct {
+	Nonce     string    `json:"nonce"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Dispute struct {
 	ID            int64           `json:"id"`
+	PaymentID     int64           `json:"payment_id"`
+	DisputeStatus DisputeStatus   `json:"dispute_status"`
+	Reason        string          `json:"reason"`
+	Evidence      json.RawMessage `json:"evidence"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	ResolvedAt    *time.Time      `json:"resolved_at"`
+}
+
+type IdempotencyKey struct {
+	MerchantID     int64     `json:"merchant_id"`
+	Owner          string    `json:"owner"`
+	IdempotencyKey string    `json:"idempotency_key"`
+	RequestHash    string    `json:"request_hash"`
+	PaymentID      int64     `json:"payment_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type Merchant struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Payment struct {
+	ID                int64           `json:"id"`
+	UserID            int64           `json:"user_id"`
+	Email             string          `json:"email"`
+	Amount            decimal.Decimal `json:"amount"`
+	Currency          ValidCurrency   `json:"currency"`
+	PaymentStatus     ValidStatus     `json:"payment_status"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+	ExpiresAt         *time.Time      `json:"expires_at"`
+	RiskScore         int32           `json:"risk_score"`
+	RiskDecision      RiskDecision    `json:"risk_decision"`
+	RiskRules         []string        `json:"risk_rules"`
+	MerchantID        int64           `json:"merchant_id"`
+	Description       string          `json:"description"`
+	MerchantReference string          `json:"merchant_reference"`
+	Metadata          json.RawMessage `json:"metadata"`
+	Version           int64           `json:"version"`
+}
+
+type PaymentDailySummary struct {
+	Day           time.Time       `json:"day"`
+	MerchantID    int64           `json:"merchant_id"`
 	UserID        int64           `json:"user_id"`
-	Email         string          `json:"email"`
-	Amount        decimal.Decimal `json:"amount"`
 	Currency      ValidCurrency   `json:"currency"`
 	PaymentStatus ValidStatus     `json:"payment_status"`
-	CreatedAt     time.Time       `json:"created_at"`
-	UpdatedAt     time.Time       `json:"updated_at"`
+	PaymentCount  int64           `json:"payment_count"`
+	Amount        decimal.Decimal `json:"amount"`
+}
+
+type PaymentEvent struct {
+	ID            int64       `json:"id"`
+	EventType     string      `json:"event_type"`
+	PaymentID     int64       `json:"payment_id"`
+	UserID        int64       `json:"user_id"`
+	MerchantID    int64       `json:"merchant_id"`
+	PaymentStatus ValidStatus `json:"payment_status"`
+	Version       int64       `json:"version"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+type PaymentReview struct {
+	ID             int64          `json:"id"`
+	PaymentID      int64          `json:"payment_id"`
+	Reviewer       string         `json:"reviewer"`
+	ReviewDecision ReviewDecision `json:"review_decision"`
+	Note           string         `json:"note"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+type ReconciliationDiscrepancy struct {
+	ID            int64           `json:"id"`
+	RunID         int64           `json:"run_id"`
+	PaymentID     int64           `json:"payment_id"`
+	Kind          DiscrepancyKind `json:"kind"`
+	ProviderValue string          `json:"provider_value"`
+	PaymentValue  string          `json:"payment_value"`
+	Applied       bool            `json:"applied"`
+	ApplyError    string          `json:"apply_error"`
+}
+
+type ReconciliationRun struct {
+	ID               int64     `json:"id"`
+	Source           string    `json:"source"`
+	Actor            string    `json:"actor"`
+	PeriodFrom       time.Time `json:"period_from"`
+	PeriodTo         time.Time `json:"period_to"`
+	AutoApply        bool      `json:"auto_apply"`
+	LineCount        int32     `json:"line_count"`
+	MatchedCount     int32     `json:"matched_count"`
+	DiscrepancyCount int32     `json:"discrepancy_count"`
+	AppliedCount     int32     `json:"applied_count"`
+	CreatedAt        time.Time `json:"created_at"`
+	MerchantID       int64     `json:"merchant_id"`
+}
+
+type Reversal struct {
+	ID        int64           `json:"id"`
+	PaymentID int64           `json:"payment_id"`
+	DisputeID int64           `json:"dispute_id"`
+	Amount    decimal.Decimal `json:"amount"`
+	Currency  ValidCurrency   `json:"currency"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type User struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"password_hash"`
+	Role         UserRole  `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	MerchantID   int64     `json:"merchant_id"`
 }
@@ -6,15 +6,68 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 )
 
 type Querier interface {
+	AddDisputeEvidence(ctx context.Context, arg AddDisputeEvidenceParams) (Dispute, error)
+	ClaimDueSettlements(ctx context.Context, arg ClaimDueSettlementsParams) ([]Payment, error)
+	CountRecentPayments(ctx context.Context, arg CountRecentPaymentsParams) (int64, error)
+	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
+	CreateAuditLog(ctx context.Context, arg CreateAuditLogParams) error
+	CreateCallbackNonce(ctx context.Context, nonce string) (int64, error)
+	CreateDailySummaries(ctx context.Context, arg CreateDailySummariesParams) error
+	CreateDispute(ctx context.Context, arg CreateDisputeParams) (Dispute, error)
+	CreateIdempotencyKeys(ctx context.Context, arg CreateIdempotencyKeysParams) error
+	CreateMerchant(ctx context.Context, name string) (Merchant, error)
 	CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error)
-	DiscardPayment(ctx context.Context, id int64) (int64, error)
-	GetPaymentStatusByID(ctx context.Context, id int64) (ValidStatus, error)
-	ListUserPaymentsByEmail(ctx context.Context, arg ListUserPaymentsByEmailParams) ([]Payment, error)
-	ListUserPaymentsByID(ctx context.Context, arg ListUserPaymentsByIDParams) ([]Payment, error)
+	CreatePaymentEvents(ctx context.Context, ids []int64) ([]PaymentEvent, error)
+	CreatePaymentReview(ctx context.Context, arg CreatePaymentReviewParams) (PaymentReview, error)
+	CreatePayments(ctx context.Context, payments json.RawMessage) ([]Payment, error)
+	CreateReconciliationDiscrepancies(ctx context.Context, arg CreateReconciliationDiscrepanciesParams) ([]ReconciliationDiscrepancy, error)
+	CreateReconciliationRun(ctx context.Context, arg CreateReconciliationRunParams) (ReconciliationRun, error)
+	CreateReversal(ctx context.Context, arg CreateReversalParams) (Reversal, error)
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	DeleteCallbackNonces(ctx context.Context, createdAt time.Time) (int64, error)
+	DeleteDailySummaries(ctx context.Context, arg DeleteDailySummariesParams) error
+	DeleteUser(ctx context.Context, name string) (int64, error)
+	DiscardPayment(ctx context.Context, arg DiscardPaymentParams) (int64, error)
+	ExpirePayments(ctx context.Context, limit int32) ([]Payment, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (ApiKey, error)
+	GetDisputeStatus(ctx context.Context, arg GetDisputeStatusParams) (DisputeStatus, error)
+	GetPaymentByID(ctx context.Context, arg GetPaymentByIDParams) (Payment, error)
+	GetPaymentByMerchantReference(ctx context.Context, arg GetPaymentByMerchantReferenceParams) (Payment, error)
+	GetPaymentStatusByID(ctx context.Context, arg GetPaymentStatusByIDParams) (ValidStatus, error)
+	GetReconciliationRun(ctx context.Context, arg GetReconciliationRunParams) (ReconciliationRun, error)
+	GetUserByName(ctx context.Context, name string) (User, error)
+	IsPaymentReversed(ctx context.Context, paymentID int64) (bool, error)
+	ListAPIKeys(ctx context.Context, merchantID int64) ([]ApiKey, error)
+	ListIdempotencyKeys(ctx context.Context, arg ListIdempotencyKeysParams) ([]IdempotencyKey, error)
+	ListMerchants(ctx context.Context) ([]Merchant, error)
+	ListPaymentDisputes(ctx context.Context, arg ListPaymentDisputesParams) ([]Dispute, error)
+	ListPaymentEvents(ctx context.Context, arg ListPaymentEventsParams) ([]PaymentEvent, error)
+	ListPaymentStatuses(ctx context.Context, arg ListPaymentStatusesParams) ([]ListPaymentStatusesRow, error)
+	ListPaymentsByIDs(ctx context.Context, arg ListPaymentsByIDsParams) ([]Payment, error)
+	ListReconciliationDiscrepancies(ctx context.Context, arg ListReconciliationDiscrepanciesParams) ([]ReconciliationDiscrepancy, error)
+	ListReconciliationRuns(ctx context.Context, arg ListReconciliationRunsParams) ([]ReconciliationRun, error)
+	ListReviewPayments(ctx context.Context, arg ListReviewPaymentsParams) ([]Payment, error)
+	ListUnscheduledPayments(ctx context.Context, limit int32) ([]ListUnscheduledPaymentsRow, error)
+	ListUnsettledPayments(ctx context.Context, arg ListUnsettledPaymentsParams) ([]Payment, error)
+	ListUsedMerchantReferences(ctx context.Context, arg ListUsedMerchantReferencesParams) ([]string, error)
+	ListUsers(ctx context.Context) ([]User, error)
+	NotifyPaymentEvents(ctx context.Context, arg NotifyPaymentEventsParams) error
+	ReportDailySummaries(ctx context.Context, arg ReportDailySummariesParams) ([]ReportDailySummariesRow, error)
+	ReportPayments(ctx context.Context, arg ReportPaymentsParams) ([]ReportPaymentsRow, error)
+	ReservePaymentIDs(ctx context.Context, count int32) ([]int64, error)
+	ResolveDispute(ctx context.Context, arg ResolveDisputeParams) (Dispute, error)
+	ReviewPayment(ctx context.Context, arg ReviewPaymentParams) (int64, error)
+	RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (int64, error)
+	RotateAPIKey(ctx context.Context, arg RotateAPIKeyParams) (ApiKey, error)
+	ScheduleSettlements(ctx context.Context, arg ScheduleSettlementsParams) error
 	UpdatePaymentStatus(ctx context.Context, arg UpdatePaymentStatusParams) (int64, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (int64, error)
+	UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -1,31 +1,390 @@
 -- name: CreatePayment :one
 INSERT INTO payments(
-    user_id, email, amount, currency, payment_status
+    user_id, email, amount, currency, payment_status, expires_at, risk_score, risk_decision, risk_rules, merchant_id,
+    description, merchant_reference, metadata
 ) VALUES (
-    $1, $2, $3, $4, $5
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
 )
 RETURNING *;
 
+-- name: GetPaymentByMerchantReference :one
+SELECT * FROM payments
+WHERE merchant_id = $1 AND merchant_reference = $2 AND merchant_reference <> '';
+
 -- name: UpdatePaymentStatus :execrows
 UPDATE payments
-SET payment_status = $2,
-    updated_at = NOW()
-WHERE id = $1 AND payment_status NOT IN ('success', 'failure');
+SET payment_status = sqlc.arg(payment_status),
+    updated_at = NOW(),
+    version = version + 1
+WHERE id = sqlc.arg(id) AND payment_status NOT IN ('success', 'failure', 'expired', 'review')
+    AND merchant_id = sqlc.arg(merchant_id)
+    AND (sqlc.arg(version)::bigint = 0 OR version = sqlc.arg(version));
 
 -- name: GetPaymentStatusByID :one
 SELECT payment_status FROM payments
-WHERE id = $1;
-
--- name: ListUserPaymentsByID :many
-SELECT * FROM payments
-WHERE user_id = $1 AND id > $2
-LIMIT $3;
+WHERE id = sqlc.arg(id) AND merchant_id = sqlc.arg(merchant_id);
 
--- name: ListUserPaymentsByEmail :many
+-- name: GetPaymentByID :one
 SELECT * FROM payments
-WHERE email = $1 AND id > $2
-LIMIT $3;
+WHERE id = sqlc.arg(id) AND merchant_id = sqlc.arg(merchant_id);
 
 -- name: DiscardPayment :execrows
 DELETE FROM payments 
-WHERE id = $1 AND payment_status NOT IN ('success', 'failure') ;
\ No newline at end of file
+WHERE id = $1 AND payment_status NOT IN ('success', 'failure', 'expired') AND merchant_id = $2
+    AND ($3::bigint = 0 OR version = $3);
+
+-- name: ListUnscheduledPayments :many
+SELECT id, created_at FROM payments p
+WHERE payment_status = 'new'
+    AND NOT EXISTS (SELECT 1 FROM simulated_settlements s WHERE s.payment_id = p.id)
+ORDER BY id
+LIMIT $1;
+
+-- name: ScheduleSettlements :exec
+INSERT INTO simulated_settlements(payment_id, settle_at)
+SELECT unnest(sqlc.arg(payment_ids)::bigint[]), unnest(sqlc.arg(settle_ats)::timestamptz[])
+ON CONFLICT (payment_id) DO NOTHING;
+
+-- name: ClaimDueSettlements :many
+WITH due AS (
+    DELETE FROM simulated_settlements
+    WHERE payment_id IN (
+        SELECT payment_id FROM simulated_settlements
+        WHERE settle_at <= $1
+        ORDER BY settle_at
+        LIMIT $2
+    )
+    RETURNING payment_id
+)
+SELECT p.* FROM payments p
+JOIN due ON due.payment_id = p.id
+WHERE p.payment_status = 'new'
+ORDER BY p.id;
+
+-- name: ExpirePayments :many
+UPDATE payments
+SET payment_status = 'expired',
+    updated_at = NOW(),
+    version = version + 1
+WHERE id IN (
+    SELECT id FROM payments
+    WHERE payment_status = 'new' AND expires_at <= NOW()
+    ORDER BY expires_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CreatePaymentEvents :many
+INSERT INTO payment_events(event_type, payment_id, user_id, merchant_id, payment_status, version)
+SELECT CASE WHEN p.payment_status = 'expired' THEN 'payment.expired' ELSE 'payment.status_changed' END,
+    p.id, p.user_id, p.merchant_id, p.payment_status, p.version
+FROM unnest(sqlc.arg(ids)::bigint[]) WITH ORDINALITY AS changed(id, ord)
+JOIN payments p ON p.id = changed.id
+ORDER BY changed.ord
+RETURNING *;
+
+-- name: NotifyPaymentEvents :exec
+SELECT pg_notify(sqlc.arg(channel)::text, payload)
+FROM unnest(sqlc.arg(payloads)::text[]) AS payload;
+
+-- name: ListPaymentEvents :many
+SELECT * FROM payment_events
+WHERE id > sqlc.arg(after_id) AND merchant_id = sqlc.arg(merchant_id)
+    AND (sqlc.arg(user_id)::bigint = 0 OR user_id = sqlc.arg(user_id))
+    AND (sqlc.arg(payment_id)::bigint = 0 OR payment_id = sqlc.arg(payment_id))
+ORDER BY id
+LIMIT sqlc.arg(row_limit);
+
+-- name: CreateDispute :one
+INSERT INTO disputes(
+    payment_id, reason, evidence
+)
+SELECT id, sqlc.arg(reason), sqlc.arg(evidence)
+FROM payments
+WHERE id = sqlc.arg(payment_id) AND merchant_id = sqlc.arg(merchant_id)
+RETURNING *;
+
+-- name: IsPaymentReversed :one
+SELECT EXISTS (
+    SELECT 1 FROM disputes
+    WHERE payment_id = $1 AND dispute_status = 'lost'
+) OR EXISTS (
+    SELECT 1 FROM reversals
+    WHERE payment_id = $1
+) AS reversed;
+
+-- name: GetDisputeStatus :one
+SELECT dispute_status FROM disputes
+WHERE id = sqlc.arg(id) AND payment_id = sqlc.arg(payment_id) AND payment_id IN (
+    SELECT id FROM payments
+    WHERE merchant_id = sqlc.arg(merchant_id)
+);
+
+-- name: AddDisputeEvidence :one
+UPDATE disputes
+SET evidence = evidence || sqlc.arg(evidence),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id) AND payment_id = sqlc.arg(payment_id) AND dispute_status = 'open' AND payment_id IN (
+    SELECT id FROM payments
+    WHERE merchant_id = sqlc.arg(merchant_id)
+)
+RETURNING *;
+
+-- name: ResolveDispute :one
+UPDATE disputes
+SET dispute_status = sqlc.arg(dispute_status),
+    updated_at = NOW(),
+    resolved_at = NOW()
+WHERE id = sqlc.arg(id) AND payment_id = sqlc.arg(payment_id) AND dispute_status = 'open' AND payment_id IN (
+    SELECT id FROM payments
+    WHERE merchant_id = sqlc.arg(merchant_id)
+)
+RETURNING *;
+
+-- name: ListPaymentDisputes :many
+SELECT * FROM disputes
+WHERE payment_id = $1 AND payment_id IN (
+    SELECT id FROM payments
+    WHERE merchant_id = $2
+)
+ORDER BY id;
+
+-- name: CreateReversal :one
+INSERT INTO reversals(
+    payment_id, dispute_id, amount, currency
+)
+SELECT p.id, d.id, p.amount, p.currency
+FROM disputes d
+JOIN payments p ON p.id = d.payment_id
+WHERE d.id = $1 AND p.merchant_id = $2
+RETURNING *;
+
+-- name: CountRecentPayments :one
+SELECT COUNT(*) FROM payments
+WHERE (user_id = $1 OR email = $2) AND created_at > $3 AND merchant_id = $4;
+
+-- name: ListReviewPayments :many
+SELECT * FROM payments
+WHERE payment_status = 'review'
+    AND merchant_id = sqlc.arg(merchant_id)
+    AND (sqlc.arg(user_id)::bigint = 0 OR user_id = sqlc.arg(user_id))
+    AND (sqlc.arg(email)::varchar = '' OR email = sqlc.arg(email))
+    AND (sqlc.arg(currency)::varchar = '' OR currency::varchar = sqlc.arg(currency))
+    AND risk_score >= sqlc.arg(min_score)
+    AND id > sqlc.arg(id)
+ORDER BY id
+LIMIT sqlc.arg(row_limit);
+
+-- name: ReviewPayment :execrows
+UPDATE payments
+SET payment_status = $2,
+    expires_at = CASE WHEN $2 = 'new' THEN NOW() + (expires_at - created_at) END,
+    updated_at = NOW(),
+    version = version + 1
+WHERE id = $1 AND payment_status = 'review' AND merchant_id = $3;
+
+-- name: CreatePaymentReview :one
+INSERT INTO payment_reviews(
+    payment_id, reviewer, review_decision, note
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: CreateAPIKey :one
+INSERT INTO api_keys(
+    name, prefix, key_hash, scopes, merchant_id
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetAPIKeyByHash :one
+SELECT * FROM api_keys
+WHERE key_hash = $1 AND revoked_at IS NULL;
+
+-- name: ListAPIKeys :many
+SELECT * FROM api_keys
+WHERE merchant_id = $1
+ORDER BY id;
+
+-- name: RotateAPIKey :one
+UPDATE api_keys
+SET prefix = $2,
+    key_hash = $3,
+    updated_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL AND merchant_id = $4
+RETURNING *;
+
+-- name: RevokeAPIKey :execrows
+UPDATE api_keys
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL AND merchant_id = $2;
+
+-- name: CreateCallbackNonce :execrows
+INSERT INTO callback_nonces(nonce)
+VALUES ($1)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteCallbackNonces :execrows
+DELETE FROM callback_nonces
+WHERE created_at < $1;
+
+-- name: CreateUser :one
+INSERT INTO users(
+    name, password_hash, role, merchant_id
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetUserByName :one
+SELECT * FROM users
+WHERE name = $1;
+
+-- name: ListUsers :many
+SELECT * FROM users
+ORDER BY id;
+
+-- name: UpdateUserPassword :execrows
+UPDATE users
+SET password_hash = $2,
+    updated_at = NOW()
+WHERE name = $1;
+
+-- name: UpdateUserRole :execrows
+UPDATE users
+SET role = $2,
+    updated_at = NOW()
+WHERE name = $1;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE name = $1;
+
+-- name: CreateAuditLog :exec
+INSERT INTO audit_log(
+    actor, action, target, method, path, merchant_id
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+);
+
+-- name: ReservePaymentIDs :many
+SELECT nextval(pg_get_serial_sequence('payments', 'id'))::bigint AS id
+FROM generate_series(1, sqlc.arg(count)::int);
+
+-- name: CreatePayments :many
+INSERT INTO payments(
+    id, user_id, email, amount, currency, payment_status, expires_at, risk_score, risk_decision, risk_rules, merchant_id,
+    description, merchant_reference, metadata
+)
+SELECT p.id, p.user_id, p.email, p.amount, p.currency, p.payment_status, p.expires_at, p.risk_score, p.risk_decision, COALESCE(p.risk_rules, '{}'), p.merchant_id,
+    COALESCE(p.description, ''), COALESCE(p.merchant_reference, ''), COALESCE(p.metadata, '{}')
+FROM jsonb_array_elements(sqlc.arg(payments)::jsonb) WITH ORDINALITY AS item(payment, ord),
+    jsonb_to_record(item.payment) AS p(
+        id BIGINT, user_id BIGINT, email VARCHAR, amount NUMERIC, currency valid_currency, payment_status valid_status,
+        expires_at TIMESTAMPTZ, risk_score INTEGER, risk_decision risk_decision, risk_rules TEXT[], merchant_id BIGINT,
+        description VARCHAR, merchant_reference VARCHAR, metadata JSONB
+    )
+ORDER BY item.ord
+RETURNING *;
+
+-- name: ListIdempotencyKeys :many
+SELECT * FROM idempotency_keys
+WHERE merchant_id = sqlc.arg(merchant_id) AND owner = sqlc.arg(owner) AND idempotency_key = ANY(sqlc.arg(keys)::varchar[]);
+
+-- name: ListUsedMerchantReferences :many
+SELECT merchant_reference FROM payments
+WHERE merchant_id = sqlc.arg(merchant_id) AND merchant_reference = ANY(sqlc.arg(merchant_references)::varchar[]);
+
+-- name: CreateIdempotencyKeys :exec
+INSERT INTO idempotency_keys(merchant_id, owner, idempotency_key, request_hash, payment_id)
+SELECT sqlc.arg(merchant_id), sqlc.arg(owner), unnest(sqlc.arg(keys)::varchar[]), unnest(sqlc.arg(request_hashes)::varchar[]), unnest(sqlc.arg(payment_ids)::bigint[]);
+
+-- name: ListPaymentsByIDs :many
+SELECT * FROM payments
+WHERE id = ANY(sqlc.arg(ids)::bigint[]) AND merchant_id = sqlc.arg(merchant_id)
+ORDER BY id;
+
+-- name: ListPaymentStatuses :many
+SELECT id, payment_status FROM payments
+WHERE id = ANY(sqlc.arg(ids)::bigint[]) AND merchant_id = sqlc.arg(merchant_id)
+ORDER BY id
+FOR UPDATE;
+
+-- name: ReportPayments :many
+SELECT date_trunc(sqlc.arg(bucket)::text, created_at AT TIME ZONE 'UTC')::timestamp AS bucket, currency, payment_status,
+    count(*)::bigint AS payment_count, sum(amount)::numeric AS amount
+FROM payments
+WHERE merchant_id = sqlc.arg(merchant_id) AND created_at >= sqlc.arg(created_from)::timestamptz AND created_at < sqlc.arg(created_to)::timestamptz
+    AND (sqlc.arg(user_id)::bigint = 0 OR user_id = sqlc.arg(user_id))
+GROUP BY 1, 2, 3
+ORDER BY 1, 2, 3;
+
+-- name: ReportDailySummaries :many
+SELECT date_trunc(sqlc.arg(bucket)::text, day::timestamp)::timestamp AS bucket, currency, payment_status,
+    sum(payment_count)::bigint AS payment_count, sum(amount)::numeric AS amount
+FROM payment_daily_summaries
+WHERE merchant_id = sqlc.arg(merchant_id) AND day >= sqlc.arg(day_from)::date AND day < sqlc.arg(day_to)::date
+    AND (sqlc.arg(user_id)::bigint = 0 OR user_id = sqlc.arg(user_id))
+GROUP BY 1, 2, 3
+ORDER BY 1, 2, 3;
+
+-- name: DeleteDailySummaries :exec
+DELETE FROM payment_daily_summaries
+WHERE day >= sqlc.arg(day_from)::date AND day < sqlc.arg(day_to)::date;
+
+-- name: CreateDailySummaries :exec
+INSERT INTO payment_daily_summaries(day, merchant_id, user_id, currency, payment_status, payment_count, amount)
+SELECT (created_at AT TIME ZONE 'UTC')::date, merchant_id, user_id, currency, payment_status, count(*), sum(amount)
+FROM payments
+WHERE created_at >= sqlc.arg(day_from)::timestamptz AND created_at < sqlc.arg(day_to)::timestamptz
+GROUP BY 1, 2, 3, 4, 5;
+
+-- name: ListUnsettledPayments :many
+SELECT * FROM payments
+WHERE merchant_id = sqlc.arg(merchant_id) AND created_at >= sqlc.arg(created_from) AND created_at < sqlc.arg(created_to)
+    AND NOT id = ANY(sqlc.arg(ids)::bigint[])
+ORDER BY id;
+
+-- name: CreateReconciliationRun :one
+INSERT INTO reconciliation_runs(source, actor, period_from, period_to, auto_apply, line_count, matched_count, discrepancy_count, applied_count, merchant_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING *;
+
+-- name: CreateReconciliationDiscrepancies :many
+INSERT INTO reconciliation_discrepancies(run_id, payment_id, kind, provider_value, payment_value, applied, apply_error)
+SELECT sqlc.arg(run_id), d.payment_id, d.kind, d.provider_value, d.payment_value, d.applied, d.apply_error
+FROM unnest(sqlc.arg(payment_ids)::bigint[], sqlc.arg(kinds)::discrepancy_kind[], sqlc.arg(provider_values)::text[],
+    sqlc.arg(payment_values)::text[], sqlc.arg(applied)::boolean[], sqlc.arg(apply_errors)::text[])
+    AS d(payment_id, kind, provider_value, payment_value, applied, apply_error)
+RETURNING *;
+
+-- name: GetReconciliationRun :one
+SELECT * FROM reconciliation_runs
+WHERE id = $1 AND merchant_id = $2;
+
+-- name: ListReconciliationRuns :many
+SELECT * FROM reconciliation_runs
+WHERE merchant_id = sqlc.arg(merchant_id) AND (sqlc.arg(before_id)::bigint = 0 OR id < sqlc.arg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(row_limit);
+
+-- name: ListReconciliationDiscrepancies :many
+SELECT * FROM reconciliation_discrepancies
+WHERE run_id = $1 AND run_id IN (
+    SELECT id FROM reconciliation_runs
+    WHERE merchant_id = $2
+)
+ORDER BY id;
+
+-- name: CreateMerchant :one
+INSERT INTO merchants(name)
+VALUES ($1)
+RETURNING *;
+
+-- name: ListMerchants :many
+SELECT * FROM merchants
+ORDER BY id;
@@ -7,25 +7,343 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 )
 
+const addDisputeEvidence = `-- name: AddDisputeEvidence :one
+UPDATE disputes
+SET evidence = evidence || $1,
+    updated_at = NOW()
+WHERE id = $2 AND payment_id = $3 AND dispute_status = 'open' AND payment_id IN (
+    SELECT id FROM payments
+    WHERE merchant_id = $4
+)
+RETURNING id, payment_id, dispute_status, reason, evidence, created_at, updated_at, resolved_at
+`
+
+type AddDisputeEvidenceParams struct {
+	Evidence   json.RawMessage `json:"evidence"`
+	ID         int64           `json:"id"`
+	PaymentID  int64           `json:"payment_id"`
+	MerchantID int64           `json:"merchant_id"`
+}
+
+func (q *Queries) AddDisputeEvidence(ctx context.Context, arg AddDisputeEvidenceParams) (Dispute, error) {
+	row := q.db.QueryRowContext(ctx, addDisputeEvidence,
+		arg.Evidence,
+		arg.ID,
+		arg.PaymentID,
+		arg.MerchantID,
+	)
+	var i Dispute
+	err := row.Scan(
+		&i.ID,
+		&i.PaymentID,
+		&i.DisputeStatus,
+		&i.Reason,
+		&i.Evidence,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const claimDueSettlements = `-- name: ClaimDueSettlements :many
+WITH due AS (
+    DELETE FROM simulated_settlements
+    WHERE payment_id IN (
+        SELECT payment_id FROM simulated_settlements
+        WHERE settle_at <= $1
+        ORDER BY settle_at
+        LIMIT $2
+    )
+    RETURNING payment_id
+)
+SELECT p.id, p.user_id, p.email, p.amount, p.currency, p.payment_status, p.created_at, p.updated_at, p.expires_at, p.risk_score, p.risk_decision, p.risk_rules, p.merchant_id, p.description, p.merchant_reference, p.metadata, p.version FROM payments p
+JOIN due ON due.payment_id = p.id
+WHERE p.payment_status = 'new'
+ORDER BY p.id
+`
+
+type ClaimDueSettlementsParams struct {
+	SettleAt time.Time `json:"settle_at"`
+	Limit    int32     `json:"limit"`
+}
+
+func (q *Queries) ClaimDueSettlements(ctx context.Context, arg ClaimDueSettlementsParams) ([]Payment, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueSettlements, arg.SettleAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Payment
+	for rows.Next() {
+		var i Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Email,
+			&i.Amount,
+			&i.Currency,
+			&i.PaymentStatus,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ExpiresAt,
+			&i.RiskScore,
+			&i.RiskDecision,
+			pq.Array(&i.RiskRules),
+			&i.MerchantID,
+			&i.Description,
+			&i.MerchantReference,
+			&i.Metadata,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countRecentPayments = `-- name: CountRecentPayments :one
+SELECT COUNT(*) FROM payments
+WHERE (user_id = $1 OR email = $2) AND created_at > $3 AND merchant_id = $4
+`
+
+type CountRecentPaymentsParams struct {
+	UserID     int64     `json:"user_id"`
+	Email      string    `json:"email"`
+	CreatedAt  time.Time `json:"created_at"`
+	MerchantID int64     `json:"merchant_id"`
+}
+
+func (q *Queries) CountRecentPayments(ctx context.Context, arg CountRecentPaymentsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countRecentPayments,
+		arg.UserID,
+		arg.Email,
+		arg.CreatedAt,
+		arg.MerchantID,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createAPIKey = `-- name: CreateAPIKey :one
+INSERT INTO api_keys(
+    name, prefix, key_hash, scopes, merchant_id
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING id, name, prefix, key_hash, scopes, created_at, updated_at, revoked_at, merchant_id
+`
+
+type CreateAPIKeyParams struct {
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	KeyHash    string   `json:"key_hash"`
+	Scopes     []string `json:"scopes"`
+	MerchantID int64    `json:"merchant_id"`
+}
+
+func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, createAPIKey,
+		arg.Name,
+		arg.Prefix,
+		arg.KeyHash,
+		pq.Array(arg.Scopes),
+		arg.MerchantID,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		pq.Array(&i.Scopes),
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RevokedAt,
+		&i.MerchantID,
+	)
+	return i, err
+}
+
+const createAuditLog = `-- name: CreateAuditLog :exec
+INSERT INTO audit_log(
+    actor, action, target, method, path, merchant_id
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+`
+
+type CreateAuditLogParams struct {
+	Actor      string `json:"actor"`
+	Action     string `json:"action"`
+	Target     string `json:"target"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	MerchantID int64  `json:"merchant_id"`
+}
+
+func (q *Queries) CreateAuditLog(ctx context.Context, arg CreateAuditLogParams) error {
+	_, err := q.db.ExecContext(ctx, createAuditLog,
+		arg.Actor,
+		arg.Action,
+		arg.Target,
+		arg.Method,
+		arg.Path,
+		arg.MerchantID,
+	)
+	return err
+}
+
+const createCallbackNonce = `-- name: CreateCallbackNonce :execrows
+INSERT INTO callback_nonces(nonce)
+VALUES ($1)
+ON CONFLICT DO NOTHING
+`
+
+func (q *Queries) CreateCallbackNonce(ctx context.Context, nonce string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createCallbackNonce, nonce)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createDailySummaries = `-- name: CreateDailySummaries :exec
+INSERT INTO payment_daily_summaries(day, merchant_id, user_id, currency, payment_status, payment_count, amount)
+SELECT (created_at AT TIME ZONE 'UTC')::date, merchant_id, user_id, currency, payment_status, count(*), sum(amount)
+FROM payments
+WHERE created_at >= $1::timestamptz AND created_at < $2::timestamptz
+GROUP BY 1, 2, 3, 4, 5
+`
+
+type CreateDailySummariesParams struct {
+	DayFrom time.Time `json:"day_from"`
+	DayTo   time.Time `json:"day_to"`
+}
+
+func (q *Queries) CreateDailySummaries(ctx context.Context, arg CreateDailySummariesParams) error {
+	_, err := q.db.ExecContext(ctx, createDailySummaries, arg.DayFrom, arg.DayTo)
+	return err
+}
+
+const createDispute = `-- name: CreateDispute :one
+INSERT INTO disputes(
+    payment_id, reason, evidence
+)
+SELECT id, $1, $2
+FROM payments
+WHERE id = $3 AND merchant_id = $4
+RETURNING id, payment_id, dispute_status, reason, evidence, created_at, updated_at, resolved_at
+`
+
+type CreateDisputeParams struct {
+	Reason     string          `json:"reason"`
+	Evidence   json.RawMessage `json:"evidence"`
+	PaymentID  int64           `json:"payment_id"`
+	MerchantID int64           `json:"merchant_id"`
+}
+
+func (q *Queries) CreateDispute(ctx context.Context, arg CreateDisputeParams) (Dispute, error) {
+	row := q.db.QueryRowContext(ctx, createDispute,
+		arg.Reason,
+		arg.Evidence,
+		arg.PaymentID,
+		arg.MerchantID,
+	)
+	var i Dispute
+	err := row.Scan(
+		&i.ID,
+		&i.PaymentID,
+		&i.DisputeStatus,
+		&i.Reason,
+		&i.Evidence,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const createIdempotencyKeys = `-- name: CreateIdempotencyKeys :exec
+INSERT INTO idempotency_keys(merchant_id, owner, idempotency_key, request_hash, payment_id)
+SELECT $1, $2, unnest($3::varchar[]), unnest($4::varchar[]), unnest($5::bigint[])
+`
+
+type CreateIdempotencyKeysParams struct {
+	MerchantID    int64    `json:"merchant_id"`
+	Owner         string   `json:"owner"`
+	Keys          []string `json:"keys"`
+	RequestHashes []string `json:"request_hashes"`
+	PaymentIds    []int64  `json:"payment_ids"`
+}
+
+func (q *Queries) CreateIdempotencyKeys(ctx context.Context, arg CreateIdempotencyKeysParams) error {
+	_, err := q.db.ExecContext(ctx, createIdempotencyKeys,
+		arg.MerchantID,
+		arg.Owner,
+		pq.Array(arg.Keys),
+		pq.Array(arg.RequestHashes),
+		pq.Array(arg.PaymentIds),
+	)
+	return err
+}
+
+const createMerchant = `-- name: CreateMerchant :one
+INSERT INTO merchants(name)
+VALUES ($1)
+RETURNING id, name, created_at
+`
+
+func (q *Queries) CreateMerchant(ctx context.Context, name string) (Merchant, error) {
+	row := q.db.QueryRowContext(ctx, createMerchant, name)
+	var i Merchant
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const createPayment = `-- name: CreatePayment :one
 INSERT INTO payments(
-    user_id, email, amount, currency, payment_status
+    user_id, email, amount, currency, payment_status, expires_at, risk_score, risk_decision, risk_rules, merchant_id,
+    description, merchant_reference, metadata
 ) VALUES (
-    $1, $2, $3, $4, $5
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
 )
-RETURNING id, user_id, email, amount, currency, payment_status, created_at, updated_at
+RETURNING id, user_id, email, amount, currency, payment_status, created_at, updated_at, expires_at, risk_score, risk_decision, risk_rules, merchant_id, description, merchant_reference, metadata, version
 `
 
 type CreatePaymentParams struct {
-	UserID        int64           `json:"user_id"`
-	Email         string          `json:"email"`
-	Amount        decimal.Decimal `json:"amount"`
-	Currency      ValidCurrency   `json:"currency"`
-	PaymentStatus ValidStatus     `json:"payment_status"`
+	UserID            int64           `json:"user_id"`
+	Email             string          `json:"email"`
+	Amount            decimal.Decimal `json:"amount"`
+	Currency          ValidCurrency   `json:"currency"`
+	PaymentStatus     ValidStatus     `json:"payment_status"`
+	ExpiresAt         *time.Time      `json:"expires_at"`
+	RiskScore         int32           `json:"risk_score"`
+	RiskDecision      RiskDecision    `json:"risk_decision"`
+	RiskRules         []string        `json:"risk_rules"`
+	MerchantID        int64           `json:"merchant_id"`
+	Description       string          `json:"description"`
+	MerchantReference string          `json:"merchant_reference"`
+	Metadata          json.RawMessage `json:"metadata"`
 }
 
 func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error) {
@@ -35,6 +353,14 @@ func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (P
 		arg.Amount,
 		arg.Currency,
 		arg.PaymentStatus,
+		arg.ExpiresAt,
+		arg.RiskScore,
+		arg.RiskDecision,
+		pq.Array(arg.RiskRules),
+		arg.MerchantID,
+		arg.Description,
+		arg.MerchantReference,
+		arg.Metadata,
 	)
 	var i Payment
 	err := row.Scan(
@@ -46,49 +372,115 @@ func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (P
 		&i.PaymentStatus,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ExpiresAt,
+		&i.RiskScore,
+		&i.RiskDecision,
+		pq.Array(&i.RiskRules),
+		&i.MerchantID,
+		&i.Description,
+		&i.MerchantReference,
+		&i.Metadata,
+		&i.Version,
 	)
 	return i, err
 }
 
-const discardPayment = `-- name: DiscardPayment :execrows
-DELETE FROM payments 
-WHERE id = $1 AND payment_status NOT IN ('success', 'failure')
+const createPaymentEvents = `-- name: CreatePaymentEvents :many
+INSERT INTO payment_events(event_type, payment_id, user_id, merchant_id, payment_status, version)
+SELECT CASE WHEN p.payment_status = 'expired' THEN 'payment.expired' ELSE 'payment.status_changed' END,
+    p.id, p.user_id, p.merchant_id, p.payment_status, p.version
+FROM unnest($1::bigint[]) WITH ORDINALITY AS changed(id, ord)
+JOIN payments p ON p.id = changed.id
+ORDER BY changed.ord
+RETURNING id, event_type, payment_id, user_id, merchant_id, payment_status, version, created_at
 `
 
-func (q *Queries) DiscardPayment(ctx context.Context, id int64) (int64, error) {
-	result, err := q.db.ExecContext(ctx, discardPayment, id)
+func (q *Queries) CreatePaymentEvents(ctx context.Context, ids []int64) ([]PaymentEvent, error) {
+	rows, err := q.db.QueryContext(ctx, createPaymentEvents, pq.Array(ids))
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return result.RowsAffected()
+	defer rows.Close()
+	var items []PaymentEvent
+	for rows.Next() {
+		var i PaymentEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventType,
+			&i.PaymentID,
+			&i.UserID,
+			&i.MerchantID,
+			&i.PaymentStatus,
+			&i.Version,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getPaymentStatusByID = `-- name: GetPaymentStatusByID :one
-SELECT payment_status FROM payments
-WHERE id = $1
+const createPaymentReview = `-- name: CreatePaymentReview :one
+INSERT INTO payment_reviews(
+    payment_id, reviewer, review_decision, note
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING id, payment_id, reviewer, review_decision, note, created_at
 `
 
-func (q *Queries) GetPaymentStatusByID(ctx context.Context, id int64) (ValidStatus, error) {
-	row := q.db.QueryRowContext(ctx, getPaymentStatusByID, id)
-	var payment_status ValidStatus
-	err := row.Scan(&payment_status)
-	return payment_status, err
+type CreatePaymentReviewParams struct {
+	PaymentID      int64          `json:"payment_id"`
+	Reviewer       string         `json:"reviewer"`
+	ReviewDecision ReviewDecision `json:"review_decision"`
+	Note           string         `json:"note"`
 }
 
-const listUserPaymentsByEmail = `-- name: ListUserPaymentsByEmail :many
-SELECT id, user_id, email, amount, currency, payment_status, created_at, updated_at FROM payments
-WHERE email = $1 AND id > $2
-LIMIT $3
-`
-
-type ListUserPaymentsByEmailParams struct {
-	Email string `json:"email"`
-	ID    int64  `json:"id"`
-	Limit int32  `json:"limit"`
+func (q *Queries) CreatePaymentReview(ctx context.Context, arg CreatePaymentReviewParams) (PaymentReview, error) {
+	row := q.db.QueryRowContext(ctx, createPaymentReview,
+		arg.PaymentID,
+		arg.Reviewer,
+		arg.ReviewDecision,
+		arg.Note,
+	)
+	var i PaymentReview
+	err := row.Scan(
+		&i.ID,
+		&i.PaymentID,
+		&i.Reviewer,
+		&i.ReviewDecision,
+		&i.Note,
+		&i.CreatedAt,
+	)
+	return i, err
 }
 
-func (q *Queries) ListUserPaymentsByEmail(ctx context.Context, arg ListUserPaymentsByEmailParams) ([]Payment, error) {
-	rows, err := q.db.QueryContext(ctx, listUserPaymentsByEmail, arg.Email, arg.ID, arg.Limit)
+const createPayments = `-- name: CreatePayments :many
+INSERT INTO payments(
+    id, user_id, email, amount, currency, payment_status, expires_at, risk_score, risk_decision, risk_rules, merchant_id,
+    description, merchant_reference, metadata
+)
+SELECT p.id, p.user_id, p.email, p.amount, p.currency, p.payment_status, p.expires_at, p.risk_score, p.risk_decision, COALESCE(p.risk_rules, '{}'), p.merchant_id,
+    COALESCE(p.description, ''), COALESCE(p.merchant_reference, ''), COALESCE(p.metadata, '{}')
+FROM jsonb_array_elements($1::jsonb) WITH ORDINALITY AS item(payment, ord),
+    jsonb_to_record(item.payment) AS p(
+        id BIGINT, user_id BIGINT, email VARCHAR, amount NUMERIC, currency valid_currency, payment_status valid_status,
+        expires_at TIMESTAMPTZ, risk_score INTEGER, risk_decision risk_decision, risk_rules TEXT[], merchant_id BIGINT,
+        description VARCHAR, merchant_reference VARCHAR, metadata JSONB
+    )
+ORDER BY item.ord
+RETURNING id, user_id, email, amount, currency, payment_status, created_at, updated_at, expires_at, risk_score, risk_decision, risk_rules, merchant_id, description, merchant_reference, metadata, version
+`
+
+func (q *Queries) CreatePayments(ctx context.Context, payments json.RawMessage) ([]Payment, error) {
+	rows, err := q.db.QueryContext(ctx, createPayments, payments)
 	if err != nil {
 		return nil, err
 	}
@@ -105,6 +497,15 @@ func (q *Queries) ListUserPaymentsByEmail(ctx context.Context, arg ListUserPayme
 			&i.PaymentStatus,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.ExpiresAt,
+			&i.RiskScore,
+			&i.RiskDecision,
+			pq.Array(&i.RiskRules),
+			&i.MerchantID,
+			&i.Description,
+			&i.MerchantReference,
+			&i.Metadata,
+			&i.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -119,36 +520,51 @@ func (q *Queries) ListUserPaymentsByEmail(ctx context.Context, arg ListUserPayme
 	return items, nil
 }
 
-const listUserPaymentsByID = `-- name: ListUserPaymentsByID :many
-SELECT id, user_id, email, amount, currency, payment_status, created_at, updated_at FROM payments
-WHERE user_id = $1 AND id > $2
-LIMIT $3
+const createReconciliationDiscrepancies = `-- name: CreateReconciliationDiscrepancies :many
+INSERT INTO reconciliation_discrepancies(run_id, payment_id, kind, provider_value, payment_value, applied, apply_error)
+SELECT $1, d.payment_id, d.kind, d.provider_value, d.payment_value, d.applied, d.apply_error
+FROM unnest($2::bigint[], $3::discrepancy_kind[], $4::text[],
+    $5::text[], $6::boolean[], $7::text[])
+    AS d(payment_id, kind, provider_value, payment_value, applied, apply_error)
+RETURNING id, run_id, payment_id, kind, provider_value, payment_value, applied, apply_error
 `
 
-type ListUserPaymentsByIDParams struct {
-	UserID int64 `json:"user_id"`
-	ID     int64 `json:"id"`
-	Limit  int32 `json:"limit"`
+type CreateReconciliationDiscrepanciesParams struct {
+	RunID          int64    `json:"run_id"`
+	PaymentIds     []int64  `json:"payment_ids"`
+	Kinds          []string `json:"kinds"`
+	ProviderValues []string `json:"provider_values"`
+	PaymentValues  []string `json:"payment_values"`
+	Applied        []bool   `json:"applied"`
+	ApplyErrors    []string `json:"apply_errors"`
 }
 
-func (q *Queries) ListUserPaymentsByID(ctx context.Context, arg ListUserPaymentsByIDParams) ([]Payment, error) {
-	rows, err := q.db.QueryContext(ctx, listUserPaymentsByID, arg.UserID, arg.ID, arg.Limit)
+func (q *Queries) CreateReconciliationDiscrepancies(ctx context.Context, arg CreateReconciliationDiscrepanciesParams) ([]ReconciliationDiscrepancy, error) {
+	rows, err := q.db.QueryContext(ctx, createReconciliationDiscrepancies,
+		arg.RunID,
+		pq.Array(arg.PaymentIds),
+		pq.Array(arg.Kinds),
+		pq.Array(arg.ProviderValues),
+		pq.Array(arg.PaymentValues),
+		pq.Array(arg.Applied),
+		pq.Array(arg.ApplyErrors),
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Payment
+	var items []ReconciliationDiscrepancy
 	for rows.Next() {
-		var i Payment
+		var i ReconciliationDiscrepancy
 		if err := rows.Scan(
 			&i.ID,
-			&i.UserID,
-			&i.Email,
-			&i.Amount,
-			&i.Currency,
-			&i.PaymentStatus,
-			&i.CreatedAt,
-			&i.UpdatedAt,
+			&i.RunID,
+			&i.PaymentID,
+			&i.Kind,
+			&i.ProviderValue,
+			&i.PaymentValue,
+			&i.Applied,
+			&i.ApplyError,
 		); err != nil {
 			return nil, err
 		}
@@ -163,20 +579,1442 @@ func (q *Queries) ListUserPaymentsByID(ctx context.Context, arg ListUserPayments
 	return items, nil
 }
 
-const updatePaymentStatus = `-- name: UpdatePaymentStatus :execrows
-UPDATE payments
-SET payment_status = $2,
-    updated_at = NOW()
-WHERE id = $1 AND payment_status NOT IN ('success', 'failure')
+const createReconciliationRun = `-- name: CreateReconciliationRun :one
+INSERT INTO reconciliation_runs(source, actor, period_from, period_to, auto_apply, line_count, matched_count, discrepancy_count, applied_count, merchant_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING id, source, actor, period_from, period_to, auto_apply, line_count, matched_count, discrepancy_count, applied_count, created_at, merchant_id
 `
 
-type UpdatePaymentStatusParams struct {
-	ID            int64       `json:"id"`
-	PaymentStatus ValidStatus `json:"payment_status"`
+type CreateReconciliationRunParams struct {
+	Source           string    `json:"source"`
+	Actor            string    `json:"actor"`
+	PeriodFrom       time.Time `json:"period_from"`
+	PeriodTo         time.Time `json:"period_to"`
+	AutoApply        bool      `json:"auto_apply"`
+	LineCount        int32     `json:"line_count"`
+	MatchedCount     int32     `json:"matched_count"`
+	DiscrepancyCount int32     `json:"discrepancy_count"`
+	AppliedCount     int32     `json:"applied_count"`
+	MerchantID       int64     `json:"merchant_id"`
 }
 
-func (q *Queries) UpdatePaymentStatus(ctx context.Context, arg UpdatePaymentStatusParams) (int64, error) {
-	result, err := q.db.ExecContext(ctx, updatePaymentStatus, arg.ID, arg.PaymentStatus)
+func (q *Queries) CreateReconciliationRun(ctx context.Context, arg CreateReconciliationRunParams) (ReconciliationRun, error) {
+	row := q.db.QueryRowContext(ctx, createReconciliationRun,
+		arg.Source,
+		arg.Actor,
+		arg.PeriodFrom,
+		arg.PeriodTo,
+		arg.AutoApply,
+		arg.LineCount,
+		arg.MatchedCount,
+		arg.DiscrepancyCount,
+		arg.AppliedCount,
+		arg.MerchantID,
+	)
+	var i ReconciliationRun
+	err := row.Scan(
+		&i.ID,
+		&i.Source,
+		&i.Actor,
+		&i.PeriodFrom,
+		&i.PeriodTo,
+		&i.AutoApply,
+		&i.LineCount,
+		&i.MatchedCount,
+		&i.DiscrepancyCount,
+		&i.AppliedCount,
+		&i.CreatedAt,
+		&i.MerchantID,
+	)
+	return i, err
+}
+
+const createReversal = `-- name: CreateReversal :one
+INSERT INTO reversals(
+    payment_id, dispute_id, amount, currency
+)
+SELECT p.id, d.id, p.amount, p.currency
+FROM disputes d
+JOIN payments p ON p.id = d.payment_id
+WHERE d.id = $1 AND p.merchant_id = $2
+RETURNING id, payment_id, dispute_id, amount, currency, created_at
+`
+
+type CreateReversalParams struct {
+	ID         int64 `json:"id"`
+	MerchantID int64 `json:"merchant_id"`
+}
+
+func (q *Queries) CreateReversal(ctx context.Context, arg CreateReversalParams) (Reversal, error) {
+	row := q.db.QueryRowContext(ctx, createReversal, arg.ID, arg.MerchantID)
+	var i Reversal
+	err := row.Scan(
+		&i.ID,
+		&i.PaymentID,
+		&i.DisputeID,
+		&i.Amount,
+		&i.Currency,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createUser = `-- name: CreateUser :one
+INSERT INTO users(
+    name, password_hash, role, merchant_id
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING id, name, password_hash, role, created_at, updated_at, merchant_id
+`
+
+type CreateUserParams struct {
+	Name         string   `json:"name"`
+	PasswordHash string   `json:"password_hash"`
+	Role         UserRole `json:"role"`
+	MerchantID   int64    `json:"merchant_id"`
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.Name,
+		arg.PasswordHash,
+		arg.Role,
+		arg.MerchantID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.PasswordHash,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.MerchantID,
+	)
+	return i, err
+}
+
+const deleteCallbackNonces = `-- name: DeleteCallbackNonces :execrows
+DELETE FROM callback_nonces
+WHERE created_at < $1
+`
+
+func (q *Queries) DeleteCallbackNonces(ctx context.Context, createdAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteCallbackNonces, createdAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteDailySummaries = `-- name: DeleteDailySummaries :exec
+DELETE FROM payment_daily_summaries
+WHERE day >= $1::date AND day < $2::date
+`
+
+type DeleteDailySummariesParams struct {
+	DayFrom time.Time `json:"day_from"`
+	DayTo   time.Time `json:"day_to"`
+}
+
+func (q *Queries) DeleteDailySummaries(ctx context.Context, arg DeleteDailySummariesParams) error {
+	_, err := q.db.ExecContext(ctx, deleteDailySummaries, arg.DayFrom, arg.DayTo)
+	return err
+}
+
+const deleteUser = `-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE name = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, name string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const discardPayment = `-- name: DiscardPayment :execrows
+DELETE FROM payments 
+WHERE id = $1 AND payment_status NOT IN ('success', 'failure', 'expired') AND merchant_id = $2
+    AND ($3::bigint = 0 OR version = $3)
+`
+
+type DiscardPaymentParams struct {
+	ID         int64 `json:"id"`
+	MerchantID int64 `json:"merchant_id"`
+	Version    int64 `json:"version"`
+}
+
+func (q *Queries) DiscardPayment(ctx context.Context, arg DiscardPaymentParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, discardPayment, arg.ID, arg.MerchantID, arg.Version)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const expirePayments = `-- name: ExpirePayments :many
+UPDATE payments
+SET payment_status = 'expired',
+    updated_at = NOW(),
+    version = version + 1
+WHERE id IN (
+    SELECT id FROM payments
+    WHERE payment_status = 'new' AND expires_at <= NOW()
+    ORDER BY expires_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, user_id, email, amount, currency, payment_status, created_at, updated_at, expires_at, risk_score, risk_decision, risk_rules, merchant_id, description, merchant_reference, metadata, version
+`
+
+func (q *Queries) ExpirePayments(ctx context.Context, limit int32) ([]Payment, error) {
+	rows, err := q.db.QueryContext(ctx, expirePayments, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Payment
+	for rows.Next() {
+		var i Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Email,
+			&i.Amount,
+			&i.Currency,
+			&i.PaymentStatus,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ExpiresAt,
+			&i.RiskScore,
+			&i.RiskDecision,
+			pq.Array(&i.RiskRules),
+			&i.MerchantID,
+			&i.Description,
+			&i.MerchantReference,
+			&i.Metadata,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAPIKeyByHash = `-- name: GetAPIKeyByHash :one
+SELECT id, name, prefix, key_hash, scopes, created_at, updated_at, revoked_at, merchant_id FROM api_keys
+WHERE key_hash = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) GetAPIKeyByHash(ctx context.Context, keyHash string) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, getAPIKeyByHash, keyHash)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		pq.Array(&i.Scopes),
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RevokedAt,
+		&i.MerchantID,
+	)
+	return i, err
+}
+
+const getDisputeStatus = `-- name: GetDisputeStatus :one
+SELECT dispute_status FROM disputes
+WHERE id = $1 AND payment_id = $2 AND payment_id IN (
+    SELECT id FROM payments
+    WHERE merchant_id = $3
+)
+`
+
+type GetDisputeStatusParams struct {
+	ID         int64 `json:"id"`
+	PaymentID  int64 `json:"payment_id"`
+	MerchantID int64 `json:"merchant_id"`
+}
+
+func (q *Queries) GetDisputeStatus(ctx context.Context, arg GetDisputeStatusParams) (DisputeStatus, error) {
+	row := q.db.QueryRowContext(ctx, getDisputeStatus, arg.ID, arg.PaymentID, arg.MerchantID)
+	var dispute_status DisputeStatus
+	err := row.Scan(&dispute_status)
+	return dispute_status, err
+}
+
+const getPaymentByID = `-- name: GetPaymentByID :one
+SELECT id, user_id, email, amount, currency, payment_status, created_at, updated_at, expires_at, risk_score, risk_decision, risk_rules, merchant_id, description, merchant_reference, metadata, version FROM payments
+WHERE id = $1 AND merchant_id = $2
+`
+
+type GetPaymentByIDParams struct {
+	ID         int64 `json:"id"`
+	MerchantID int64 `json:"merchant_id"`
+}
+
+func (q *Queries) GetPaymentByID(ctx context.Context, arg GetPaymentByIDParams) (Payment, error) {
+	row := q.db.QueryRowContext(ctx, getPaymentByID, arg.ID, arg.MerchantID)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Email,
+		&i.Amount,
+		&i.Currency,
+		&i.PaymentStatus,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ExpiresAt,
+		&i.RiskScore,
+		&i.RiskDecision,
+		pq.Array(&i.RiskRules),
+		&i.MerchantID,
+		&i.Description,
+		&i.MerchantReference,
+		&i.Metadata,
+		&i.Version,
+	)
+	return i, err
+}
+
+const getPaymentByMerchantReference = `-- name: GetPaymentByMerchantReference :one
+SELECT id, user_id, email, amount, currency, payment_status, created_at, updated_at, expires_at, risk_score, risk_decision, risk_rules, merchant_id, description, merchant_reference, metadata, version FROM payments
+WHERE merchant_id = $1 AND merchant_reference = $2 AND merchant_reference <> ''
+`
+
+type GetPaymentByMerchantReferenceParams struct {
+	MerchantID        int64  `json:"merchant_id"`
+	MerchantReference string `json:"merchant_reference"`
+}
+
+func (q *Queries) GetPaymentByMerchantReference(ctx context.Context, arg GetPaymentByMerchantReferenceParams) (Payment, error) {
+	row := q.db.QueryRowContext(ctx, getPaymentByMerchantReference, arg.MerchantID, arg.MerchantReference)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Email,
+		&i.Amount,
+		&i.Currency,
+		&i.PaymentStatus,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ExpiresAt,
+		&i.RiskScore,
+		&i.RiskDecision,
+		pq.Array(&i.RiskRules),
+		&i.MerchantID,
+		&i.Description,
+		&i.MerchantReference,
+		&i.Metadata,
+		&i.Version,
+	)
+	return i, err
+}
+
+const getPaymentStatusByID = `-- name: GetPaymentStatusByID :one
+SELECT payment_status FROM payments
+WHERE id = $1 AND merchant_id = $2
+`
+
+type GetPaymentStatusByIDParams struct {
+	ID         int64 `json:"id"`
+	MerchantID int64 `json:"merchant_id"`
+}
+
+func (q *Queries) GetPaymentStatusByID(ctx context.Context, arg GetPaymentStatusByIDParams) (ValidStatus, error) {
+	row := q.db.QueryRowContext(ctx, getPaymentStatusByID, arg.ID, arg.MerchantID)
+	var payment_status ValidStatus
+	err := row.Scan(&payment_status)
+	return payment_status, err
+}
+
+const getReconciliationRun = `-- name: GetReconciliationRun :one
+SELECT id, source, actor, period_from, period_to, auto_apply, line_count, matched_count, discrepancy_count, applied_count, created_at, merchant_id FROM reconciliation_runs
+WHERE id = $1 AND merchant_id = $2
+`
+
+type GetReconciliationRunParams struct {
+	ID         int64 `json:"id"`
+	MerchantID int64 `json:"merchant_id"`
+}
+
+func (q *Queries) GetReconciliationRun(ctx context.Context, arg GetReconciliationRunParams) (ReconciliationRun, error) {
+	row := q.db.QueryRowContext(ctx, getReconciliationRun, arg.ID, arg.MerchantID)
+	var i ReconciliationRun
+	err := row.Scan(
+		&i.ID,
+		&i.Source,
+		&i.Actor,
+		&i.PeriodFrom,
+		&i.PeriodTo,
+		&i.AutoApply,
+		&i.LineCount,
+		&i.MatchedCount,
+		&i.DiscrepancyCount,
+		&i.AppliedCount,
+		&i.CreatedAt,
+		&i.MerchantID,
+	)
+	return i, err
+}
+
+const getUserByName = `-- name: GetUserByName :one
+SELECT id, name, password_hash, role, created_at, updated_at, merchant_id FROM users
+WHERE name = $1
+`
+
+func (q *Queries) GetUserByName(ctx context.Context, name string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByName, name)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.PasswordHash,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.MerchantID,
+	)
+	return i, err
+}
+
+const isPaymentReversed = `-- name: IsPaymentReversed :one
+SELECT EXISTS (
+    SELECT 1 FROM disputes
+    WHERE payment_id = $1 AND dispute_status = 'lost'
+) OR EXISTS (
+    SELECT 1 FROM reversals
+    WHERE payment_id = $1
+) AS reversed
+`
+
+func (q *Queries) IsPaymentReversed(ctx context.Context, paymentID int64) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isPaymentReversed, paymentID)
+	var reversed bool
+	err := row.Scan(&reversed)
+	return reversed, err
+}
+
+const listAPIKeys = `-- name: ListAPIKeys :many
+SELECT id, name, prefix, key_hash, scopes, created_at, updated_at, revoked_at, merchant_id FROM api_keys
+WHERE merchant_id = $1
+ORDER BY id
+`
+
+func (q *Queries) ListAPIKeys(ctx context.Context, merchantID int64) ([]ApiKey, error) {
+	rows, err := q.db.QueryContext(ctx, listAPIKeys, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiKey
+	for rows.Next() {
+		var i ApiKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Prefix,
+			&i.KeyHash,
+			pq.Array(&i.Scopes),
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RevokedAt,
+			&i.MerchantID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listIdempotencyKeys = `-- name: ListIdempotencyKeys :many
+SELECT merchant_id, owner, idempotency_key, request_hash, payment_id, created_at FROM idempotency_keys
+WHERE merchant_id = $1 AND owner = $2 AND idempotency_key = ANY($3::varchar[])
+`
+
+type ListIdempotencyKeysParams struct {
+	MerchantID int64    `json:"merchant_id"`
+	Owner      string   `json:"owner"`
+	Keys       []string `json:"keys"`
+}
+
+func (q *Queries) ListIdempotencyKeys(ctx context.Context, arg ListIdempotencyKeysParams) ([]IdempotencyKey, error) {
+	rows, err := q.db.QueryContext(ctx, listIdempotencyKeys, arg.MerchantID, arg.Owner, pq.Array(arg.Keys))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []IdempotencyKey
+	for rows.Next() {
+		var i IdempotencyKey
+		if err := rows.Scan(
+			&i.MerchantID,
+			&i.Owner,
+			&i.IdempotencyKey,
+			&i.RequestHash,
+			&i.PaymentID,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMerchants = `-- name: ListMerchants :many
+SELECT id, name, created_at FROM merchants
+ORDER BY id
+`
+
+func (q *Queries) ListMerchants(ctx context.Context) ([]Merchant, error) {
+	rows, err := q.db.QueryContext(ctx, listMerchants)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Merchant
+	for rows.Next() {
+		var i Merchant
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPaymentDisputes = `-- name: ListPaymentDisputes :many
+SELECT id, payment_id, dispute_status, reason, evidence, created_at, updated_at, resolved_at FROM disputes
+WHERE payment_id = $1 AND payment_id IN (
+    SELECT id FROM payments
+    WHERE merchant_id = $2
+)
+ORDER BY id
+`
+
+type ListPaymentDisputesParams struct {
+	PaymentID  int64 `json:"payment_id"`
+	MerchantID int64 `json:"merchant_id"`
+}
+
+func (q *Queries) ListPaymentDisputes(ctx context.Context, arg ListPaymentDisputesParams) ([]Dispute, error) {
+	rows, err := q.db.QueryContext(ctx, listPaymentDisputes, arg.PaymentID, arg.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Dispute
+	for rows.Next() {
+		var i Dispute
+		if err := rows.Scan(
+			&i.ID,
+			&i.PaymentID,
+			&i.DisputeStatus,
+			&i.Reason,
+			&i.Evidence,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPaymentEvents = `-- name: ListPaymentEvents :many
+SELECT id, event_type, payment_id, user_id, merchant_id, payment_status, version, created_at FROM payment_events
+WHERE id > $1 AND merchant_id = $2
+    AND ($3::bigint = 0 OR user_id = $3)
+    AND ($4::bigint = 0 OR payment_id = $4)
+ORDER BY id
+LIMIT $5
+`
+
+type ListPaymentEventsParams struct {
+	AfterID    int64 `json:"after_id"`
+	MerchantID int64 `json:"merchant_id"`
+	UserID     int64 `json:"user_id"`
+	PaymentID  int64 `json:"payment_id"`
+	RowLimit   int32 `json:"row_limit"`
+}
+
+func (q *Queries) ListPaymentEvents(ctx context.Context, arg ListPaymentEventsParams) ([]PaymentEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listPaymentEvents,
+		arg.AfterID,
+		arg.MerchantID,
+		arg.UserID,
+		arg.PaymentID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PaymentEvent
+	for rows.Next() {
+		var i PaymentEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventType,
+			&i.PaymentID,
+			&i.UserID,
+			&i.MerchantID,
+			&i.PaymentStatus,
+			&i.Version,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPaymentStatuses = `-- name: ListPaymentStatuses :many
+SELECT id, payment_status FROM payments
+WHERE id = ANY($1::bigint[]) AND merchant_id = $2
+ORDER BY id
+FOR UPDATE
+`
+
+type ListPaymentStatusesParams struct {
+	Ids        []int64 `json:"ids"`
+	MerchantID int64   `json:"merchant_id"`
+}
+
+type ListPaymentStatusesRow struct {
+	ID            int64       `json:"id"`
+	PaymentStatus ValidStatus `json:"payment_status"`
+}
+
+func (q *Queries) ListPaymentStatuses(ctx context.Context, arg ListPaymentStatusesParams) ([]ListPaymentStatusesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPaymentStatuses, pq.Array(arg.Ids), arg.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPaymentStatusesRow
+	for rows.Next() {
+		var i ListPaymentStatusesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PaymentStatus,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPaymentsByIDs = `-- name: ListPaymentsByIDs :many
+SELECT id, user_id, email, amount, currency, payment_status, created_at, updated_at, expires_at, risk_score, risk_decision, risk_rules, merchant_id, description, merchant_reference, metadata, version FROM payments
+WHERE id = ANY($1::bigint[]) AND merchant_id = $2
+ORDER BY id
+`
+
+type ListPaymentsByIDsParams struct {
+	Ids        []int64 `json:"ids"`
+	MerchantID int64   `json:"merchant_id"`
+}
+
+func (q *Queries) ListPaymentsByIDs(ctx context.Context, arg ListPaymentsByIDsParams) ([]Payment, error) {
+	rows, err := q.db.QueryContext(ctx, listPaymentsByIDs, pq.Array(arg.Ids), arg.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Payment
+	for rows.Next() {
+		var i Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Email,
+			&i.Amount,
+			&i.Currency,
+			&i.PaymentStatus,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ExpiresAt,
+			&i.RiskScore,
+			&i.RiskDecision,
+			pq.Array(&i.RiskRules),
+			&i.MerchantID,
+			&i.Description,
+			&i.MerchantReference,
+			&i.Metadata,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReconciliationDiscrepancies = `-- name: ListReconciliationDiscrepancies :many
+SELECT id, run_id, payment_id, kind, provider_value, payment_value, applied, apply_error FROM reconciliation_discrepancies
+WHERE run_id = $1 AND run_id IN (
+    SELECT id FROM reconciliation_runs
+    WHERE merchant_id = $2
+)
+ORDER BY id
+`
+
+type ListReconciliationDiscrepanciesParams struct {
+	RunID      int64 `json:"run_id"`
+	MerchantID int64 `json:"merchant_id"`
+}
+
+func (q *Queries) ListReconciliationDiscrepancies(ctx context.Context, arg ListReconciliationDiscrepanciesParams) ([]ReconciliationDiscrepancy, error) {
+	rows, err := q.db.QueryContext(ctx, listReconciliationDiscrepancies, arg.RunID, arg.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReconciliationDiscrepancy
+	for rows.Next() {
+		var i ReconciliationDiscrepancy
+		if err := rows.Scan(
+			&i.ID,
+			&i.RunID,
+			&i.PaymentID,
+			&i.Kind,
+			&i.ProviderValue,
+			&i.PaymentValue,
+			&i.Applied,
+			&i.ApplyError,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReconciliationRuns = `-- name: ListReconciliationRuns :many
+SELECT id, source, actor, period_from, period_to, auto_apply, line_count, matched_count, discrepancy_count, applied_count, created_at, merchant_id FROM reconciliation_runs
+WHERE merchant_id = $1 AND ($2::bigint = 0 OR id < $2)
+ORDER BY id DESC
+LIMIT $3
+`
+
+type ListReconciliationRunsParams struct {
+	MerchantID int64 `json:"merchant_id"`
+	BeforeID   int64 `json:"before_id"`
+	RowLimit   int32 `json:"row_limit"`
+}
+
+func (q *Queries) ListReconciliationRuns(ctx context.Context, arg ListReconciliationRunsParams) ([]ReconciliationRun, error) {
+	rows, err := q.db.QueryContext(ctx, listReconciliationRuns, arg.MerchantID, arg.BeforeID, arg.RowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReconciliationRun
+	for rows.Next() {
+		var i ReconciliationRun
+		if err := rows.Scan(
+			&i.ID,
+			&i.Source,
+			&i.Actor,
+			&i.PeriodFrom,
+			&i.PeriodTo,
+			&i.AutoApply,
+			&i.LineCount,
+			&i.MatchedCount,
+			&i.DiscrepancyCount,
+			&i.AppliedCount,
+			&i.CreatedAt,
+			&i.MerchantID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReviewPayments = `-- name: ListReviewPayments :many
+SELECT id, user_id, email, amount, currency, payment_status, created_at, updated_at, expires_at, risk_score, risk_decision, risk_rules, merchant_id, description, merchant_reference, metadata, version FROM payments
+WHERE payment_status = 'review'
+    AND merchant_id = $1
+    AND ($2::bigint = 0 OR user_id = $2)
+    AND ($3::varchar = '' OR email = $3)
+    AND ($4::varchar = '' OR currency::varchar = $4)
+    AND risk_score >= $5
+    AND id > $6
+ORDER BY id
+LIMIT $7
+`
+
+type ListReviewPaymentsParams struct {
+	MerchantID int64  `json:"merchant_id"`
+	UserID     int64  `json:"user_id"`
+	Email      string `json:"email"`
+	Currency   string `json:"currency"`
+	MinScore   int32  `json:"min_score"`
+	ID         int64  `json:"id"`
+	RowLimit   int32  `json:"row_limit"`
+}
+
+func (q *Queries) ListReviewPayments(ctx context.Context, arg ListReviewPaymentsParams) ([]Payment, error) {
+	rows, err := q.db.QueryContext(ctx, listReviewPayments,
+		arg.MerchantID,
+		arg.UserID,
+		arg.Email,
+		arg.Currency,
+		arg.MinScore,
+		arg.ID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Payment
+	for rows.Next() {
+		var i Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Email,
+			&i.Amount,
+			&i.Currency,
+			&i.PaymentStatus,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ExpiresAt,
+			&i.RiskScore,
+			&i.RiskDecision,
+			pq.Array(&i.RiskRules),
+			&i.MerchantID,
+			&i.Description,
+			&i.MerchantReference,
+			&i.Metadata,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnscheduledPayments = `-- name: ListUnscheduledPayments :many
+SELECT id, created_at FROM payments p
+WHERE payment_status = 'new'
+    AND NOT EXISTS (SELECT 1 FROM simulated_settlements s WHERE s.payment_id = p.id)
+ORDER BY id
+LIMIT $1
+`
+
+type ListUnscheduledPaymentsRow struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (q *Queries) ListUnscheduledPayments(ctx context.Context, limit int32) ([]ListUnscheduledPaymentsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUnscheduledPayments, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUnscheduledPaymentsRow
+	for rows.Next() {
+		var i ListUnscheduledPaymentsRow
+		if err := rows.Scan(&i.ID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnsettledPayments = `-- name: ListUnsettledPayments :many
+SELECT id, user_id, email, amount, currency, payment_status, created_at, updated_at, expires_at, risk_score, risk_decision, risk_rules, merchant_id, description, merchant_reference, metadata, version FROM payments
+WHERE merchant_id = $1 AND created_at >= $2 AND created_at < $3
+    AND NOT id = ANY($4::bigint[])
+ORDER BY id
+`
+
+type ListUnsettledPaymentsParams struct {
+	MerchantID  int64     `json:"merchant_id"`
+	CreatedFrom time.Time `json:"created_from"`
+	CreatedTo   time.Time `json:"created_to"`
+	Ids         []int64   `json:"ids"`
+}
+
+func (q *Queries) ListUnsettledPayments(ctx context.Context, arg ListUnsettledPaymentsParams) ([]Payment, error) {
+	rows, err := q.db.QueryContext(ctx, listUnsettledPayments,
+		arg.MerchantID,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		pq.Array(arg.Ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Payment
+	for rows.Next() {
+		var i Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Email,
+			&i.Amount,
+			&i.Currency,
+			&i.PaymentStatus,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ExpiresAt,
+			&i.RiskScore,
+			&i.RiskDecision,
+			pq.Array(&i.RiskRules),
+			&i.MerchantID,
+			&i.Description,
+			&i.MerchantReference,
+			&i.Metadata,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsedMerchantReferences = `-- name: ListUsedMerchantReferences :many
+SELECT merchant_reference FROM payments
+WHERE merchant_id = $1 AND merchant_reference = ANY($2::varchar[])
+`
+
+type ListUsedMerchantReferencesParams struct {
+	MerchantID         int64    `json:"merchant_id"`
+	MerchantReferences []string `json:"merchant_references"`
+}
+
+func (q *Queries) ListUsedMerchantReferences(ctx context.Context, arg ListUsedMerchantReferencesParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listUsedMerchantReferences, arg.MerchantID, pq.Array(arg.MerchantReferences))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var merchant_reference string
+		if err := rows.Scan(&merchant_reference); err != nil {
+			return nil, err
+		}
+		items = append(items, merchant_reference)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, name, password_hash, role, created_at, updated_at, merchant_id FROM users
+ORDER BY id
+`
+
+func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.PasswordHash,
+			&i.Role,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.MerchantID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const notifyPaymentEvents = `-- name: NotifyPaymentEvents :exec
+SELECT pg_notify($1::text, payload)
+FROM unnest($2::text[]) AS payload
+`
+
+type NotifyPaymentEventsParams struct {
+	Channel  string   `json:"channel"`
+	Payloads []string `json:"payloads"`
+}
+
+func (q *Queries) NotifyPaymentEvents(ctx context.Context, arg NotifyPaymentEventsParams) error {
+	_, err := q.db.ExecContext(ctx, notifyPaymentEvents, arg.Channel, pq.Array(arg.Payloads))
+	return err
+}
+
+const reportDailySummaries = `-- name: ReportDailySummaries :many
+SELECT date_trunc($1::text, day::timestamp)::timestamp AS bucket, currency, payment_status,
+    sum(payment_count)::bigint AS payment_count, sum(amount)::numeric AS amount
+FROM payment_daily_summaries
+WHERE merchant_id = $2 AND day >= $3::date AND day < $4::date
+    AND ($5::bigint = 0 OR user_id = $5)
+GROUP BY 1, 2, 3
+ORDER BY 1, 2, 3
+`
+
+type ReportDailySummariesParams struct {
+	Bucket     string    `json:"bucket"`
+	MerchantID int64     `json:"merchant_id"`
+	DayFrom    time.Time `json:"day_from"`
+	DayTo      time.Time `json:"day_to"`
+	UserID     int64     `json:"user_id"`
+}
+
+type ReportDailySummariesRow struct {
+	Bucket        time.Time       `json:"bucket"`
+	Currency      ValidCurrency   `json:"currency"`
+	PaymentStatus ValidStatus     `json:"payment_status"`
+	PaymentCount  int64           `json:"payment_count"`
+	Amount        decimal.Decimal `json:"amount"`
+}
+
+func (q *Queries) ReportDailySummaries(ctx context.Context, arg ReportDailySummariesParams) ([]ReportDailySummariesRow, error) {
+	rows, err := q.db.QueryContext(ctx, reportDailySummaries,
+		arg.Bucket,
+		arg.MerchantID,
+		arg.DayFrom,
+		arg.DayTo,
+		arg.UserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReportDailySummariesRow
+	for rows.Next() {
+		var i ReportDailySummariesRow
+		if err := rows.Scan(
+			&i.Bucket,
+			&i.Currency,
+			&i.PaymentStatus,
+			&i.PaymentCount,
+			&i.Amount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const reportPayments = `-- name: ReportPayments :many
+SELECT date_trunc($1::text, created_at AT TIME ZONE 'UTC')::timestamp AS bucket, currency, payment_status,
+    count(*)::bigint AS payment_count, sum(amount)::numeric AS amount
+FROM payments
+WHERE merchant_id = $2 AND created_at >= $3::timestamptz AND created_at < $4::timestamptz
+    AND ($5::bigint = 0 OR user_id = $5)
+GROUP BY 1, 2, 3
+ORDER BY 1, 2, 3
+`
+
+type ReportPaymentsParams struct {
+	Bucket      string    `json:"bucket"`
+	MerchantID  int64     `json:"merchant_id"`
+	CreatedFrom time.Time `json:"created_from"`
+	CreatedTo   time.Time `json:"created_to"`
+	UserID      int64     `json:"user_id"`
+}
+
+type ReportPaymentsRow struct {
+	Bucket        time.Time       `json:"bucket"`
+	Currency      ValidCurrency   `json:"currency"`
+	PaymentStatus ValidStatus     `json:"payment_status"`
+	PaymentCount  int64           `json:"payment_count"`
+	Amount        decimal.Decimal `json:"amount"`
+}
+
+func (q *Queries) ReportPayments(ctx context.Context, arg ReportPaymentsParams) ([]ReportPaymentsRow, error) {
+	rows, err := q.db.QueryContext(ctx, reportPayments,
+		arg.Bucket,
+		arg.MerchantID,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.UserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReportPaymentsRow
+	for rows.Next() {
+		var i ReportPaymentsRow
+		if err := rows.Scan(
+			&i.Bucket,
+			&i.Currency,
+			&i.PaymentStatus,
+			&i.PaymentCount,
+			&i.Amount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const reservePaymentIDs = `-- name: ReservePaymentIDs :many
+SELECT nextval(pg_get_serial_sequence('payments', 'id'))::bigint AS id
+FROM generate_series(1, $1::int)
+`
+
+func (q *Queries) ReservePaymentIDs(ctx context.Context, count int32) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, reservePaymentIDs, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const resolveDispute = `-- name: ResolveDispute :one
+UPDATE disputes
+SET dispute_status = $1,
+    updated_at = NOW(),
+    resolved_at = NOW()
+WHERE id = $2 AND payment_id = $3 AND dispute_status = 'open' AND payment_id IN (
+    SELECT id FROM payments
+    WHERE merchant_id = $4
+)
+RETURNING id, payment_id, dispute_status, reason, evidence, created_at, updated_at, resolved_at
+`
+
+type ResolveDisputeParams struct {
+	DisputeStatus DisputeStatus `json:"dispute_status"`
+	ID            int64         `json:"id"`
+	PaymentID     int64         `json:"payment_id"`
+	MerchantID    int64         `json:"merchant_id"`
+}
+
+func (q *Queries) ResolveDispute(ctx context.Context, arg ResolveDisputeParams) (Dispute, error) {
+	row := q.db.QueryRowContext(ctx, resolveDispute,
+		arg.DisputeStatus,
+		arg.ID,
+		arg.PaymentID,
+		arg.MerchantID,
+	)
+	var i Dispute
+	err := row.Scan(
+		&i.ID,
+		&i.PaymentID,
+		&i.DisputeStatus,
+		&i.Reason,
+		&i.Evidence,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const reviewPayment = `-- name: ReviewPayment :execrows
+UPDATE payments
+SET payment_status = $2,
+    expires_at = CASE WHEN $2 = 'new' THEN NOW() + (expires_at - created_at) END,
+    updated_at = NOW(),
+    version = version + 1
+WHERE id = $1 AND payment_status = 'review' AND merchant_id = $3
+`
+
+type ReviewPaymentParams struct {
+	ID            int64       `json:"id"`
+	PaymentStatus ValidStatus `json:"payment_status"`
+	MerchantID    int64       `json:"merchant_id"`
+}
+
+func (q *Queries) ReviewPayment(ctx context.Context, arg ReviewPaymentParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, reviewPayment, arg.ID, arg.PaymentStatus, arg.MerchantID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeAPIKey = `-- name: RevokeAPIKey :execrows
+UPDATE api_keys
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL AND merchant_id = $2
+`
+
+type RevokeAPIKeyParams struct {
+	ID         int64 `json:"id"`
+	MerchantID int64 `json:"merchant_id"`
+}
+
+func (q *Queries) RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAPIKey, arg.ID, arg.MerchantID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rotateAPIKey = `-- name: RotateAPIKey :one
+UPDATE api_keys
+SET prefix = $2,
+    key_hash = $3,
+    updated_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL AND merchant_id = $4
+RETURNING id, name, prefix, key_hash, scopes, created_at, updated_at, revoked_at, merchant_id
+`
+
+type RotateAPIKeyParams struct {
+	ID         int64  `json:"id"`
+	Prefix     string `json:"prefix"`
+	KeyHash    string `json:"key_hash"`
+	MerchantID int64  `json:"merchant_id"`
+}
+
+func (q *Queries) RotateAPIKey(ctx context.Context, arg RotateAPIKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, rotateAPIKey,
+		arg.ID,
+		arg.Prefix,
+		arg.KeyHash,
+		arg.MerchantID,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		pq.Array(&i.Scopes),
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RevokedAt,
+		&i.MerchantID,
+	)
+	return i, err
+}
+
+const scheduleSettlements = `-- name: ScheduleSettlements :exec
+INSERT INTO simulated_settlements(payment_id, settle_at)
+SELECT unnest($1::bigint[]), unnest($2::timestamptz[])
+ON CONFLICT (payment_id) DO NOTHING
+`
+
+type ScheduleSettlementsParams struct {
+	PaymentIds []int64     `json:"payment_ids"`
+	SettleAts  []time.Time `json:"settle_ats"`
+}
+
+func (q *Queries) ScheduleSettlements(ctx context.Context, arg ScheduleSettlementsParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleSettlements, pq.Array(arg.PaymentIds), pq.Array(arg.SettleAts))
+	return err
+}
+
+const updatePaymentStatus = `-- name: UpdatePaymentStatus :execrows
+UPDATE payments
+SET payment_status = $1,
+    updated_at = NOW(),
+    version = version + 1
+WHERE id = $2 AND payment_status NOT IN ('success', 'failure', 'expired', 'review')
+    AND merchant_id = $3
+    AND ($4::bigint = 0 OR version = $4)
+`
+
+type UpdatePaymentStatusParams struct {
+	PaymentStatus ValidStatus `json:"payment_status"`
+	ID            int64       `json:"id"`
+	MerchantID    int64       `json:"merchant_id"`
+	Version       int64       `json:"version"`
+}
+
+func (q *Queries) UpdatePaymentStatus(ctx context.Context, arg UpdatePaymentStatusParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updatePaymentStatus,
+		arg.PaymentStatus,
+		arg.ID,
+		arg.MerchantID,
+		arg.Version,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :execrows
+UPDATE users
+SET password_hash = $2,
+    updated_at = NOW()
+WHERE name = $1
+`
+
+type UpdateUserPasswordParams struct {
+	Name         string `json:"name"`
+	PasswordHash string `json:"password_hash"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUserPassword, arg.Name, arg.PasswordHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUserRole = `-- name: UpdateUserRole :execrows
+UPDATE users
+SET role = $2,
+    updated_at = NOW()
+WHERE name = $1
+`
+
+type UpdateUserRoleParams struct {
+	Name string   `json:"name"`
+	Role UserRole `json:"role"`
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUserRole, arg.Name, arg.Role)
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,11 @@
+package repository
+
+import "database/sql"
+
+// WithTx returns querier bound to transaction, querier that isn't backed by database is returned as is
+func WithTx(q Querier, tx *sql.Tx) Querier {
+	if queries, ok := q.(*Queries); ok {
+		return queries.WithTx(tx)
+	}
+	return q
+}
@@ -0,0 +1,68 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Rule scores payment, zero score means rule didn't fire. Earlier are payments accepted before payment
+// in the same batch, they aren't stored yet, so rules counting payments count them on top of stored ones
+type Rule interface {
+	Name() string
+	Score(ctx context.Context, p paymentModel.CreatePaymentParams, earlier []paymentModel.CreatePaymentParams) (int32, error)
+}
+
+// Result is payment screening result
+type Result struct {
+	Score    int32
+	Decision paymentModel.RiskDecision
+	Rules    []string
+}
+
+// Engine runs risk rules and decides what to do with payment
+type Engine struct {
+	rules           []Rule
+	reviewThreshold int32
+	rejectThreshold int32
+}
+
+// NewEngine creates risk engine, payment with total score above review threshold goes to review,
+// above reject threshold is rejected
+func NewEngine(reviewThreshold, rejectThreshold int32, rules ...Rule) *Engine {
+	return &Engine{
+		rules:           rules,
+		reviewThreshold: reviewThreshold,
+		rejectThreshold: rejectThreshold,
+	}
+}
+
+// Screen runs all rules against payment, earlier are payments accepted before it in the same batch,
+// nil for single payment
+func (e *Engine) Screen(ctx context.Context, p paymentModel.CreatePaymentParams, earlier []paymentModel.CreatePaymentParams) (Result, error) {
+	result := Result{
+		Decision: paymentModel.RiskDecisionApprove,
+		Rules:    []string{},
+	}
+
+	for _, rule := range e.rules {
+		score, err := rule.Score(ctx, p, earlier)
+		if err != nil {
+			return Result{}, fmt.Errorf("%s rule: %w", rule.Name(), err)
+		}
+		if score > 0 {
+			result.Score += score
+			result.Rules = append(result.Rules, rule.Name())
+		}
+	}
+
+	switch {
+	case result.Score > e.rejectThreshold:
+		result.Decision = paymentModel.RiskDecisionReject
+	case result.Score > e.reviewThreshold:
+		result.Decision = paymentModel.RiskDecisionReview
+	}
+
+	return result, nil
+}
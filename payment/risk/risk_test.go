@@ -0,0 +1,124 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+var tPayment = postgres.CreatePaymentParams{
+	UserID:   1,
+	Email:    "test@example.com",
+	Amount:   decimal.NewFromFloat(123.42),
+	Currency: "usd",
+}
+
+func TestScreen(t *testing.T) {
+	countStore := func(count int64, err error) *postgres.QuerierMock {
+		return &postgres.QuerierMock{
+			CountRecentPaymentsFunc: func(ctx context.Context, arg postgres.CountRecentPaymentsParams) (int64, error) {
+				return count, err
+			},
+		}
+	}
+	amountRule := NewAmountRule(map[postgres.ValidCurrency]decimal.Decimal{postgres.ValidCurrencyUsd: decimal.NewFromInt(100)}, 60)
+
+	cases := []struct {
+		description string
+		payment     postgres.CreatePaymentParams
+		rules       []Rule
+		expected    Result
+		expectedErr string
+	}{
+		{
+			description: "no rules fired",
+			payment:     postgres.CreatePaymentParams{UserID: 1, Email: "test@example.com", Amount: decimal.NewFromInt(10), Currency: "usd"},
+			rules:       []Rule{NewVelocityRule(countStore(1, nil), time.Hour, 10, 60), amountRule, NewEmailDomainRule([]string{"spam.com"}, 110)},
+			expected:    Result{Decision: postgres.RiskDecisionApprove, Rules: []string{}},
+		},
+		{
+			description: "review",
+			payment:     tPayment,
+			rules:       []Rule{NewVelocityRule(countStore(1, nil), time.Hour, 10, 60), amountRule},
+			expected:    Result{Score: 60, Decision: postgres.RiskDecisionReview, Rules: []string{"amount"}},
+		},
+		{
+			description: "reject",
+			payment:     tPayment,
+			rules:       []Rule{NewVelocityRule(countStore(10, nil), time.Hour, 10, 60), amountRule},
+			expected:    Result{Score: 120, Decision: postgres.RiskDecisionReject, Rules: []string{"velocity", "amount"}},
+		},
+		{
+			description: "blocked domain",
+			payment:     postgres.CreatePaymentParams{Email: "user@Spam.com", Amount: decimal.NewFromInt(1), Currency: "eur"},
+			rules:       []Rule{amountRule, NewEmailDomainRule([]string{" spam.com"}, 110)},
+			expected:    Result{Score: 110, Decision: postgres.RiskDecisionReject, Rules: []string{"email_domain"}},
+		},
+		{
+			description: "rule error",
+			payment:     tPayment,
+			rules:       []Rule{NewVelocityRule(countStore(0, fmt.Errorf("server error")), time.Hour, 10, 60)},
+			expectedErr: "velocity rule: server error",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			e := NewEngine(50, 100, tc.rules...)
+			result, err := e.Screen(context.Background(), tc.payment, nil)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestVelocityRuleWindow(t *testing.T) {
+	now := time.Now().UTC()
+	mockedStore := &postgres.QuerierMock{
+		CountRecentPaymentsFunc: func(ctx context.Context, arg postgres.CountRecentPaymentsParams) (int64, error) {
+			return 0, nil
+		},
+	}
+	r := NewVelocityRule(mockedStore, time.Hour, 10, 60)
+	r.now = func() time.Time { return now }
+
+	_, err := r.Score(context.Background(), tPayment, nil)
+	require.NoError(t, err)
+
+	calls := mockedStore.CountRecentPaymentsCalls()
+	require.Equal(t, 1, len(calls))
+	assert.Equal(t, postgres.CountRecentPaymentsParams{UserID: 1, Email: "test@example.com", CreatedAt: now.Add(-time.Hour)}, calls[0].Arg)
+}
+
+func TestVelocityRuleBatch(t *testing.T) {
+	mockedStore := &postgres.QuerierMock{
+		CountRecentPaymentsFunc: func(ctx context.Context, arg postgres.CountRecentPaymentsParams) (int64, error) {
+			return 1, nil
+		},
+	}
+	r := NewVelocityRule(mockedStore, time.Hour, 3, 60)
+	sameUser := tPayment
+	sameUser.Email = "other@example.com"
+	sameEmail := tPayment
+	sameEmail.UserID = 2
+	otherUser := postgres.CreatePaymentParams{UserID: 3, Email: "user@example.com"}
+
+	score, err := r.Score(context.Background(), tPayment, []postgres.CreatePaymentParams{sameUser, otherUser})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), score)
+
+	score, err = r.Score(context.Background(), tPayment, []postgres.CreatePaymentParams{sameUser, otherUser, sameEmail})
+	require.NoError(t, err)
+	assert.Equal(t, int32(60), score)
+}
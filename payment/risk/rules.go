@@ -0,0 +1,126 @@
+package risk
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// VelocityRule fires when user made too many payments within sliding window,
+// payments are counted both by user id and email, earlier payments of the batch are counted too
+type VelocityRule struct {
+	paymentStore paymentModel.Querier
+	window       time.Duration
+	maxPayments  int64
+	score        int32
+	now          func() time.Time
+}
+
+// NewVelocityRule creates velocity rule
+func NewVelocityRule(paymentStore paymentModel.Querier, window time.Duration, maxPayments int64, score int32) *VelocityRule {
+	return &VelocityRule{
+		paymentStore: paymentStore,
+		window:       window,
+		maxPayments:  maxPayments,
+		score:        score,
+		now:          time.Now,
+	}
+}
+
+// Name returns rule name
+func (r *VelocityRule) Name() string {
+	return "velocity"
+}
+
+// Score scores payment
+func (r *VelocityRule) Score(ctx context.Context, p paymentModel.CreatePaymentParams, earlier []paymentModel.CreatePaymentParams) (int32, error) {
+	count, err := r.paymentStore.CountRecentPayments(ctx, paymentModel.CountRecentPaymentsParams{
+		UserID:     p.UserID,
+		Email:      p.Email,
+		CreatedAt:  r.now().Add(-r.window).UTC(),
+		MerchantID: p.MerchantID,
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range earlier {
+		if e.UserID == p.UserID || e.Email == p.Email {
+			count++
+		}
+	}
+	if count >= r.maxPayments {
+		return r.score, nil
+	}
+
+	return 0, nil
+}
+
+// AmountRule fires when payment amount exceeds currency threshold
+type AmountRule struct {
+	thresholds map[paymentModel.ValidCurrency]decimal.Decimal
+	score      int32
+}
+
+// NewAmountRule creates amount rule, currencies without threshold are not checked
+func NewAmountRule(thresholds map[paymentModel.ValidCurrency]decimal.Decimal, score int32) *AmountRule {
+	return &AmountRule{
+		thresholds: thresholds,
+		score:      score,
+	}
+}
+
+// Name returns rule name
+func (r *AmountRule) Name() string {
+	return "amount"
+}
+
+// Score scores payment
+func (r *AmountRule) Score(ctx context.Context, p paymentModel.CreatePaymentParams, earlier []paymentModel.CreatePaymentParams) (int32, error) {
+	threshold, ok := r.thresholds[p.Currency]
+	if ok && p.Amount.GreaterThan(threshold) {
+		return r.score, nil
+	}
+
+	return 0, nil
+}
+
+// EmailDomainRule fires when email domain is blocked
+type EmailDomainRule struct {
+	blocklist map[string]struct{}
+	score     int32
+}
+
+// NewEmailDomainRule creates email domain rule
+func NewEmailDomainRule(blocklist []string, score int32) *EmailDomainRule {
+	r := &EmailDomainRule{
+		blocklist: make(map[string]struct{}, len(blocklist)),
+		score:     score,
+	}
+	for _, domain := range blocklist {
+		r.blocklist[strings.ToLower(strings.TrimSpace(domain))] = struct{}{}
+	}
+
+	return r
+}
+
+// Name returns rule name
+func (r *EmailDomainRule) Name() string {
+	return "email_domain"
+}
+
+// Score scores payment
+func (r *EmailDomainRule) Score(ctx context.Context, p paymentModel.CreatePaymentParams, earlier []paymentModel.CreatePaymentParams) (int32, error) {
+	at := strings.LastIndex(p.Email, "@")
+	if at < 0 {
+		return 0, nil
+	}
+	if _, ok := r.blocklist[strings.ToLower(p.Email[at+1:])]; ok {
+		return r.score, nil
+	}
+
+	return 0, nil
+}
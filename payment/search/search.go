@@ -0,0 +1,394 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Sort fields, payments with equal sort value are ordered by id
+const (
+	SortID        = "id"
+	SortCreatedAt = "created_at"
+	SortAmount    = "amount"
+)
+
+// timeLayout is layout of timestamp key value, key keeps time in UTC without zone
+const timeLayout = "2006-01-02T15:04:05.999999"
+
+// Errors returned by Build
+var (
+	ErrInvalidKey = errors.New("invalid key")
+	ErrNoMerchant = errors.New("merchant id is required")
+)
+
+// sortColumns maps sort fields to columns, only these columns get into query text
+var sortColumns = map[string]string{
+	SortID:        "id",
+	SortCreatedAt: "created_at",
+	SortAmount:    "amount",
+}
+
+// ValidSort checks that payments can be sorted by field
+func ValidSort(field string) bool {
+	_, ok := sortColumns[field]
+	return ok
+}
+
+// Filter selects payments of merchant, MerchantID is required, other zero fields don't filter, ranges include
+// lower bound and exclude upper bound except amount range which includes both bounds, payment metadata must
+// contain all Metadata pairs
+type Filter struct {
+	MerchantID  int64
+	IDs         []int64
+	UserIDs     []int64
+	EmailPrefix string
+	UserID      int64
+	Email       string
+	Statuses    []paymentModel.ValidStatus
+	Currency    paymentModel.ValidCurrency
+	MinAmount   *decimal.Decimal
+	MaxAmount   *decimal.Decimal
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+	Metadata    map[string]string
+}
+
+// Key is payment position in sort order, Value is sort field value, it is empty for id sort
+type Key struct {
+	ID    int64
+	Value string
+}
+
+// KeyOf returns position of payment in sort order
+func KeyOf(p paymentModel.Payment, sort string) Key {
+	switch sort {
+	case SortCreatedAt:
+		return Key{ID: p.ID, Value: p.CreatedAt.UTC().Format(timeLayout)}
+	case SortAmount:
+		return Key{ID: p.ID, Value: p.Amount.String()}
+	}
+
+	return Key{ID: p.ID}
+}
+
+// LikePrefix makes LIKE pattern matching strings with prefix, pattern special characters in prefix are escaped
+func LikePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
+
+// Query is page of filtered payments, page starts after or ends before Key, Inclusive page includes payment at Key,
+// zero Limit doesn't limit page
+type Query struct {
+	Filter
+	Sort      string
+	Desc      bool
+	Key       *Key
+	Before    bool
+	Inclusive bool
+	Limit     int
+}
+
+// Build builds query text and its arguments, values are always passed as arguments and
+// column names are taken only from fixed fragments
+func Build(q Query) (string, []interface{}, error) {
+	column, ok := sortColumns[q.Sort]
+	if !ok {
+		return "", nil, fmt.Errorf("can't sort by %q", q.Sort)
+	}
+
+	b := builder{}
+	if err := b.filter(q.Filter); err != nil {
+		return "", nil, err
+	}
+
+	// page before key is read in reverse order and reversed by List
+	desc := q.Desc != q.Before
+	if q.Key != nil {
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		if q.Inclusive {
+			op += "="
+		}
+		switch q.Sort {
+		case SortID:
+			b.where("id "+op+" %s", q.Key.ID)
+		case SortCreatedAt:
+			t, err := time.Parse(timeLayout, q.Key.Value)
+			if err != nil {
+				return "", nil, ErrInvalidKey
+			}
+			b.where("(created_at, id) "+op+" (%s, %s)", t, q.Key.ID)
+		case SortAmount:
+			amount, err := decimal.NewFromString(q.Key.Value)
+			if err != nil {
+				return "", nil, ErrInvalidKey
+			}
+			b.where("(amount, id) "+op+" (%s, %s)", amount, q.Key.ID)
+		}
+	}
+
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+	order := "id " + dir
+	if column != "id" {
+		order = column + " " + dir + ", " + order
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT id, user_id, email, amount, currency, payment_status, created_at, updated_at, expires_at, risk_score, risk_decision, risk_rules, merchant_id, description, merchant_reference, metadata, version FROM payments")
+	if len(b.conds) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(b.conds, " AND "))
+	}
+	sb.WriteString(" ORDER BY ")
+	sb.WriteString(order)
+	if q.Limit > 0 {
+		sb.WriteString(" LIMIT ")
+		sb.WriteString(b.arg(q.Limit))
+	}
+
+	return sb.String(), b.args, nil
+}
+
+// List returns page of filtered payments in sort order
+func List(ctx context.Context, db paymentModel.DBTX, q Query) ([]paymentModel.Payment, error) {
+	query, args, err := Build(q)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var items []paymentModel.Payment
+	err = scanRows(rows, func(p paymentModel.Payment) error {
+		items = append(items, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Before {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	return items, nil
+}
+
+// BuildCountUpTo builds query counting filtered payments exactly, but only up to limit, so count stops
+// at limit instead of reading all matching rows, zero limit counts all of them
+func BuildCountUpTo(f Filter, limit int) (string, []interface{}, error) {
+	b := builder{}
+	if err := b.filter(f); err != nil {
+		return "", nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT count(*) FROM (SELECT 1 FROM payments WHERE ")
+	sb.WriteString(strings.Join(b.conds, " AND "))
+	if limit > 0 {
+		sb.WriteString(" LIMIT ")
+		sb.WriteString(b.arg(limit))
+	}
+	sb.WriteString(") AS matched")
+
+	return sb.String(), b.args, nil
+}
+
+// CountUpTo returns exact number of filtered payments if it's below limit and limit otherwise
+func CountUpTo(ctx context.Context, db paymentModel.DBTX, f Filter, limit int) (int64, error) {
+	query, args, err := BuildCountUpTo(f, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err = db.QueryRowContext(ctx, query, args...).Scan(&count)
+	return count, err
+}
+
+// exportCursor is name of cursor declared by Export, it is visible only in its transaction
+const exportCursor = "export_payments"
+
+// Export passes every filtered payment in sort order to fn, payments are read from database cursor
+// by batches of batchSize rows, so only one batch is kept in memory, flush is called after every batch,
+// export stops on first error of fn or flush
+func Export(ctx context.Context, db *sql.DB, q Query, batchSize int, fn func(paymentModel.Payment) error, flush func() error) error {
+	q.Key, q.Before, q.Limit = nil, false, 0
+	query, args, err := Build(q)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.ExecContext(ctx, "DECLARE "+exportCursor+" NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		return err
+	}
+
+	fetch := "FETCH FORWARD " + strconv.Itoa(batchSize) + " FROM " + exportCursor
+	for {
+		rows, err := tx.QueryContext(ctx, fetch)
+		if err != nil {
+			return err
+		}
+		n := 0
+		err = scanRows(rows, func(p paymentModel.Payment) error {
+			n++
+			return fn(p)
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		if err = flush(); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// scanRows scans payment rows and passes them to fn, rows are closed
+func scanRows(rows *sql.Rows, fn func(paymentModel.Payment) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		var i paymentModel.Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Email,
+			&i.Amount,
+			&i.Currency,
+			&i.PaymentStatus,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ExpiresAt,
+			&i.RiskScore,
+			&i.RiskDecision,
+			pq.Array(&i.RiskRules),
+			&i.MerchantID,
+			&i.Description,
+			&i.MerchantReference,
+			&i.Metadata,
+			&i.Version,
+		); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	return rows.Err()
+}
+
+// builder collects conditions with numbered placeholders
+type builder struct {
+	conds []string
+	args  []interface{}
+}
+
+func (b *builder) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// where adds condition, every %s in format is replaced by placeholder of next value
+func (b *builder) where(format string, values ...interface{}) {
+	placeholders := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		placeholders = append(placeholders, b.arg(v))
+	}
+	b.conds = append(b.conds, fmt.Sprintf(format, placeholders...))
+}
+
+// filter adds conditions of filter
+func (b *builder) filter(f Filter) error {
+	if f.MerchantID == 0 {
+		return ErrNoMerchant
+	}
+
+	b.where("merchant_id = %s", f.MerchantID)
+	if len(f.IDs) > 0 {
+		b.where("id = ANY(%s)", pq.Array(f.IDs))
+	}
+	if len(f.UserIDs) > 0 {
+		b.where("user_id = ANY(%s)", pq.Array(f.UserIDs))
+	}
+	if f.EmailPrefix != "" {
+		b.where("email LIKE %s", LikePrefix(f.EmailPrefix))
+	}
+	if f.UserID != 0 {
+		b.where("user_id = %s", f.UserID)
+	}
+	if f.Email != "" {
+		b.where("email = %s", f.Email)
+	}
+	if len(f.Statuses) > 0 {
+		statuses := make([]string, 0, len(f.Statuses))
+		for _, s := range f.Statuses {
+			statuses = append(statuses, string(s))
+		}
+		b.where("payment_status = ANY(%s::valid_status[])", pq.Array(statuses))
+	}
+	if f.Currency != "" {
+		b.where("currency = %s", f.Currency)
+	}
+	if f.MinAmount != nil {
+		b.where("amount >= %s", *f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		b.where("amount <= %s", *f.MaxAmount)
+	}
+	if f.CreatedFrom != nil {
+		b.where("created_at >= %s", f.CreatedFrom.UTC())
+	}
+	if f.CreatedTo != nil {
+		b.where("created_at < %s", f.CreatedTo.UTC())
+	}
+	if f.UpdatedFrom != nil {
+		b.where("updated_at >= %s", f.UpdatedFrom.UTC())
+	}
+	if f.UpdatedTo != nil {
+		b.where("updated_at < %s", f.UpdatedTo.UTC())
+	}
+	if len(f.Metadata) > 0 {
+		metadata, err := json.Marshal(f.Metadata)
+		if err != nil {
+			return err
+		}
+		b.where("metadata @> %s::jsonb", string(metadata))
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+const selectPayments = "SELECT id, user_id, email, amount, currency, payment_status, created_at, updated_at, expires_at, risk_score, risk_decision, risk_rules, merchant_id, description, merchant_reference, metadata, version FROM payments"
+
+func TestBuild(t *testing.T) {
+	from := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	minAmount := decimal.RequireFromString("10.5")
+	maxAmount := decimal.RequireFromString("100")
+
+	cases := []struct {
+		description string
+		query       Query
+		sql         string
+		args        []interface{}
+		err         string
+	}{
+		{
+			description: "first page by id",
+			query:       Query{Filter: Filter{MerchantID: 1, UserID: 2}, Sort: SortID, Limit: 11},
+			sql:         selectPayments + " WHERE merchant_id = $1 AND user_id = $2 ORDER BY id ASC LIMIT $3",
+			args:        []interface{}{int64(1), int64(2), 11},
+		},
+		{
+			description: "all filters",
+			query: Query{
+				Filter: Filter{
+					MerchantID:  1,
+					Email:       "test@example.com",
+					Statuses:    []paymentModel.ValidStatus{paymentModel.ValidStatusNew, paymentModel.ValidStatusSuccess},
+					Currency:    paymentModel.ValidCurrencyUsd,
+					MinAmount:   &minAmount,
+					MaxAmount:   &maxAmount,
+					CreatedFrom: &from,
+					CreatedTo:   &to,
+					UpdatedFrom: &from,
+					UpdatedTo:   &to,
+				},
+				Sort:  SortAmount,
+				Desc:  true,
+				Limit: 6,
+			},
+			sql: selectPayments + " WHERE merchant_id = $1 AND email = $2 AND payment_status = ANY($3::valid_status[]) AND currency = $4 AND amount >= $5 AND amount <= $6" +
+				" AND created_at >= $7 AND created_at < $8 AND updated_at >= $9 AND updated_at < $10 ORDER BY amount DESC, id DESC LIMIT $11",
+			args: []interface{}{int64(1), "test@example.com", pq.Array([]string{"new", "success"}), paymentModel.ValidCurrencyUsd, minAmount, maxAmount, from, to, from, to, 6},
+		},
+		{
+			description: "page after key sorted by created_at",
+			query:       Query{Filter: Filter{MerchantID: 1, UserID: 2}, Sort: SortCreatedAt, Key: &Key{ID: 5, Value: "2022-06-01T10:00:00.5"}, Limit: 3},
+			sql:         selectPayments + " WHERE merchant_id = $1 AND user_id = $2 AND (created_at, id) > ($3, $4) ORDER BY created_at ASC, id ASC LIMIT $5",
+			args:        []interface{}{int64(1), int64(2), time.Date(2022, 6, 1, 10, 0, 0, 500000000, time.UTC), int64(5), 3},
+		},
+		{
+			description: "page before key in descending order",
+			query:       Query{Filter: Filter{MerchantID: 1}, Sort: SortAmount, Desc: true, Key: &Key{ID: 5, Value: "12.30"}, Before: true, Inclusive: true, Limit: 3},
+			sql:         selectPayments + " WHERE merchant_id = $1 AND (amount, id) >= ($2, $3) ORDER BY amount ASC, id ASC LIMIT $4",
+			args:        []interface{}{int64(1), decimal.RequireFromString("12.30"), int64(5), 3},
+		},
+		{
+			description: "page before id",
+			query:       Query{Filter: Filter{MerchantID: 1}, Sort: SortID, Key: &Key{ID: 5}, Before: true, Limit: 3},
+			sql:         selectPayments + " WHERE merchant_id = $1 AND id < $2 ORDER BY id DESC LIMIT $3",
+			args:        []interface{}{int64(1), int64(5), 3},
+		},
+		{
+			description: "all users filters without limit",
+			query:       Query{Filter: Filter{MerchantID: 1, IDs: []int64{1, 2}, UserIDs: []int64{3}, EmailPrefix: "te_st"}, Sort: SortID},
+			sql:         selectPayments + " WHERE merchant_id = $1 AND id = ANY($2) AND user_id = ANY($3) AND email LIKE $4 ORDER BY id ASC",
+			args:        []interface{}{int64(1), pq.Array([]int64{1, 2}), pq.Array([]int64{3}), `te\_st%`},
+		},
+		{
+			description: "metadata",
+			query:       Query{Filter: Filter{MerchantID: 1, Metadata: map[string]string{"order": "A-1", "channel": "web"}}, Sort: SortID, Limit: 3},
+			sql:         selectPayments + " WHERE merchant_id = $1 AND metadata @> $2::jsonb ORDER BY id ASC LIMIT $3",
+			args:        []interface{}{int64(1), `{"channel":"web","order":"A-1"}`, 3},
+		},
+		{
+			description: "unknown sort",
+			query:       Query{Sort: "email; DROP TABLE payments", Limit: 3},
+			err:         `can't sort by "email; DROP TABLE payments"`,
+		},
+		{
+			description: "invalid key value",
+			query:       Query{Filter: Filter{MerchantID: 1}, Sort: SortAmount, Key: &Key{ID: 5, Value: "1 OR 1=1"}, Limit: 3},
+			err:         "invalid key",
+		},
+		{
+			description: "no merchant",
+			query:       Query{Filter: Filter{UserID: 2}, Sort: SortID, Limit: 3},
+			err:         "merchant id is required",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			sql, args, err := Build(tc.query)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.sql, sql)
+			assert.Equal(t, tc.args, args)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	created := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	columns := []string{"id", "user_id", "email", "amount", "currency", "payment_status", "created_at", "updated_at", "expires_at", "risk_score", "risk_decision", "risk_rules", "merchant_id", "description", "merchant_reference", "metadata", "version"}
+	mock.ExpectQuery(`ORDER BY id DESC LIMIT \$4`).
+		WithArgs(int64(1), int64(2), int64(5), 3).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(4, 2, "test@example.com", "10.00", "usd", "new", created, created, nil, 0, "approve", "{}", 1, "", "", []byte("{}"), 1).
+			AddRow(3, 2, "test@example.com", "20.00", "eur", "success", created, created, nil, 0, "approve", "{}", 1, "", "", []byte("{}"), 1))
+
+	ps, err := List(context.Background(), db, Query{Filter: Filter{MerchantID: 1, UserID: 2}, Sort: SortID, Key: &Key{ID: 5}, Before: true, Limit: 3})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(ps))
+	assert.Equal(t, int64(3), ps[0].ID)
+	assert.Equal(t, "20", ps[0].Amount.String())
+	assert.Equal(t, int64(4), ps[1].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountUpTo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`^SELECT count\(\*\) FROM \(SELECT 1 FROM payments WHERE merchant_id = \$1 AND id = ANY\(\$2\) AND email LIKE \$3 LIMIT \$4\) AS matched$`).
+		WithArgs(int64(1), pq.Array([]int64{1, 2}), `te\_st%`, 101).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	count, err := CountUpTo(context.Background(), db, Filter{MerchantID: 1, IDs: []int64{1, 2}, EmailPrefix: "te_st"}, 101)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = CountUpTo(context.Background(), db, Filter{IDs: []int64{1}}, 0)
+	assert.ErrorIs(t, err, ErrNoMerchant)
+}
+
+func TestKeyOf(t *testing.T) {
+	p := paymentModel.Payment{ID: 3, Amount: decimal.RequireFromString("12.30"), CreatedAt: time.Date(2022, 6, 1, 10, 0, 0, 123456000, time.UTC)}
+	assert.Equal(t, Key{ID: 3}, KeyOf(p, SortID))
+	assert.Equal(t, Key{ID: 3, Value: "12.3"}, KeyOf(p, SortAmount))
+	assert.Equal(t, Key{ID: 3, Value: "2022-06-01T10:00:00.123456"}, KeyOf(p, SortCreatedAt))
+}
+
+func TestLikePrefix(t *testing.T) {
+	assert.Equal(t, "test%", LikePrefix("test"))
+	assert.Equal(t, `100\%\_off\\%`, LikePrefix(`100%_off\`))
+	assert.Equal(t, "%", LikePrefix(""))
+}
+
+func TestExport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	defer func() { _ = db.Close() }()
+	require.NoError(t, err)
+
+	created := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	columns := []string{"id", "user_id", "email", "amount", "currency", "payment_status", "created_at", "updated_at", "expires_at", "risk_score", "risk_decision", "risk_rules", "merchant_id", "description", "merchant_reference", "metadata", "version"}
+	row := func(rows *sqlmock.Rows, id int64) *sqlmock.Rows {
+		return rows.AddRow(id, 2, "test@example.com", "10.10", "usd", "new", created, created, nil, 0, "approve", "{}", 1, "", "", []byte("{}"), 1)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DECLARE export_payments NO SCROLL CURSOR FOR SELECT .* FROM payments WHERE merchant_id = \$1 AND user_id = \$2 ORDER BY id ASC$`).
+		WithArgs(int64(1), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FETCH FORWARD 2 FROM export_payments").WillReturnRows(row(row(sqlmock.NewRows(columns), 1), 2))
+	mock.ExpectQuery("FETCH FORWARD 2 FROM export_payments").WillReturnRows(row(sqlmock.NewRows(columns), 3))
+	mock.ExpectQuery("FETCH FORWARD 2 FROM export_payments").WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectCommit()
+
+	ids := make([]int64, 0)
+	flushes := 0
+	q := Query{Filter: Filter{MerchantID: 1, UserID: 2}, Sort: SortID, Key: &Key{ID: 5}, Before: true, Limit: 10}
+	err = Export(context.Background(), db, q, 2, func(p paymentModel.Payment) error {
+		ids = append(ids, p.ID)
+		assert.Equal(t, "10.1", p.Amount.String())
+		return nil
+	}, func() error {
+		flushes++
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+	assert.Equal(t, 2, flushes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DECLARE export_payments").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FETCH FORWARD 2 FROM export_payments").WillReturnRows(row(sqlmock.NewRows(columns), 1))
+	mock.ExpectRollback()
+
+	err = Export(context.Background(), db, Query{Filter: Filter{MerchantID: 1}, Sort: SortID}, 2, func(p paymentModel.Payment) error {
+		return errors.New("client is gone")
+	}, func() error { return nil })
+	assert.EqualError(t, err, "client is gone")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,135 @@
+package simulator
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/semka95/payment-service/payment/audit"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Delay distributions
+const (
+	DistributionUniform     = "uniform"
+	DistributionExponential = "exponential"
+)
+
+// StatusUpdater changes status of merchant payment on behalf of actor
+type StatusUpdater interface {
+	UpdateStatus(ctx context.Context, actor audit.Actor, merchantID, id int64, newStatus paymentModel.ValidStatus) error
+}
+
+// Config stores payment system simulator configuration
+type Config struct {
+	Interval     time.Duration
+	MinDelay     time.Duration
+	MaxDelay     time.Duration
+	Distribution string
+	SuccessRatio float64
+	BatchSize    int32
+}
+
+// Simulator emulates payment system, it settles new payments after random delay
+type Simulator struct {
+	paymentStore paymentModel.Querier
+	updater      StatusUpdater
+	logger       *zap.Logger
+	config       Config
+	now          func() time.Time
+}
+
+// New creates payment system simulator
+func New(paymentStore paymentModel.Querier, updater StatusUpdater, logger *zap.Logger, config Config) *Simulator {
+	return &Simulator{
+		paymentStore: paymentStore,
+		updater:      updater,
+		logger:       logger,
+		config:       config,
+		now:          time.Now,
+	}
+}
+
+// Run settles payments until context is canceled
+func (s *Simulator) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.settle(ctx)
+		}
+	}
+}
+
+// settle schedules deadlines of new payments and resolves payments which deadlines have passed,
+// deadlines are stored in database, so every batch holds only due payments
+func (s *Simulator) settle(ctx context.Context) {
+	now := s.now()
+	unscheduled, err := s.paymentStore.ListUnscheduledPayments(ctx, s.config.BatchSize)
+	if err != nil {
+		s.logger.Error("can't list unscheduled payments", zap.Error(err))
+		return
+	}
+
+	if len(unscheduled) > 0 {
+		arg := paymentModel.ScheduleSettlementsParams{
+			PaymentIds: make([]int64, 0, len(unscheduled)),
+			SettleAts:  make([]time.Time, 0, len(unscheduled)),
+		}
+		for _, p := range unscheduled {
+			arg.PaymentIds = append(arg.PaymentIds, p.ID)
+			arg.SettleAts = append(arg.SettleAts, p.CreatedAt.Add(s.delay()).UTC())
+		}
+		if err := s.paymentStore.ScheduleSettlements(ctx, arg); err != nil {
+			s.logger.Error("can't schedule settlements", zap.Error(err))
+			return
+		}
+	}
+
+	// claimed payment that fails to settle stays new and unscheduled, so it is scheduled again on next tick
+	payments, err := s.paymentStore.ClaimDueSettlements(ctx, paymentModel.ClaimDueSettlementsParams{
+		SettleAt: now.UTC(),
+		Limit:    s.config.BatchSize,
+	})
+	if err != nil {
+		s.logger.Error("can't claim due settlements", zap.Error(err))
+		return
+	}
+
+	for _, p := range payments {
+		status := paymentModel.ValidStatusFailure
+		if rand.Float64() < s.config.SuccessRatio {
+			status = paymentModel.ValidStatusSuccess
+		}
+		if err := s.updater.UpdateStatus(ctx, audit.Simulator, p.MerchantID, p.ID, status); err != nil {
+			s.logger.Warn("can't settle payment", zap.Error(err), zap.Int64("payment id", p.ID))
+			continue
+		}
+		s.logger.Info("payment settled", zap.Int64("payment id", p.ID), zap.String("status", string(status)))
+	}
+}
+
+// delay returns random settlement delay according to configured distribution
+func (s *Simulator) delay() time.Duration {
+	spread := s.config.MaxDelay - s.config.MinDelay
+	if spread <= 0 {
+		return s.config.MinDelay
+	}
+
+	switch s.config.Distribution {
+	case DistributionExponential:
+		d := time.Duration(rand.ExpFloat64() * float64(spread) / 2)
+		if d > spread {
+			d = spread
+		}
+		return s.config.MinDelay + d
+	default:
+		return s.config.MinDelay + time.Duration(rand.Int63n(int64(spread)))
+	}
+}
@@ -0,0 +1,203 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/semka95/payment-service/payment/audit"
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+type updaterMock struct {
+	updated map[int64]postgres.ValidStatus
+	actors  []audit.Actor
+	err     error
+}
+
+func (u *updaterMock) UpdateStatus(ctx context.Context, actor audit.Actor, merchantID, id int64, newStatus postgres.ValidStatus) error {
+	if u.err != nil {
+		return u.err
+	}
+	u.updated[id] = newStatus
+	u.actors = append(u.actors, actor)
+	return nil
+}
+
+func TestSettle(t *testing.T) {
+	now := time.Now().UTC()
+	payments := []postgres.Payment{
+		{ID: 1, PaymentStatus: postgres.ValidStatusNew, CreatedAt: now.Add(-time.Minute)},
+		{ID: 2, PaymentStatus: postgres.ValidStatusNew, CreatedAt: now.Add(-15 * time.Second)},
+	}
+	listNone := func(ctx context.Context, limit int32) ([]postgres.ListUnscheduledPaymentsRow, error) {
+		return nil, nil
+	}
+
+	cases := []struct {
+		description string
+		config      Config
+		mockedStore *postgres.QuerierMock
+		updater     *updaterMock
+		expected    map[int64]postgres.ValidStatus
+		claimCalls  int
+	}{
+		{
+			description: "settle due payments",
+			config:      Config{MinDelay: 10 * time.Second, MaxDelay: 20 * time.Second, SuccessRatio: 1, BatchSize: 10},
+			mockedStore: &postgres.QuerierMock{
+				ListUnscheduledPaymentsFunc: listNone,
+				ClaimDueSettlementsFunc: func(ctx context.Context, arg postgres.ClaimDueSettlementsParams) ([]postgres.Payment, error) {
+					return payments[:1], nil
+				},
+			},
+			updater:    &updaterMock{updated: map[int64]postgres.ValidStatus{}},
+			expected:   map[int64]postgres.ValidStatus{1: postgres.ValidStatusSuccess},
+			claimCalls: 1,
+		},
+		{
+			description: "failure ratio",
+			config:      Config{MinDelay: 10 * time.Second, MaxDelay: 10 * time.Second, SuccessRatio: 0, BatchSize: 10},
+			mockedStore: &postgres.QuerierMock{
+				ListUnscheduledPaymentsFunc: listNone,
+				ClaimDueSettlementsFunc: func(ctx context.Context, arg postgres.ClaimDueSettlementsParams) ([]postgres.Payment, error) {
+					return payments, nil
+				},
+			},
+			updater:    &updaterMock{updated: map[int64]postgres.ValidStatus{}},
+			expected:   map[int64]postgres.ValidStatus{1: postgres.ValidStatusFailure, 2: postgres.ValidStatusFailure},
+			claimCalls: 1,
+		},
+		{
+			description: "list error",
+			config:      Config{MinDelay: 10 * time.Second, MaxDelay: 20 * time.Second, SuccessRatio: 1, BatchSize: 10},
+			mockedStore: &postgres.QuerierMock{
+				ListUnscheduledPaymentsFunc: func(ctx context.Context, limit int32) ([]postgres.ListUnscheduledPaymentsRow, error) {
+					return nil, fmt.Errorf("server error")
+				},
+			},
+			updater:  &updaterMock{updated: map[int64]postgres.ValidStatus{}},
+			expected: map[int64]postgres.ValidStatus{},
+		},
+		{
+			description: "schedule error",
+			config:      Config{MinDelay: 10 * time.Second, MaxDelay: 20 * time.Second, SuccessRatio: 1, BatchSize: 10},
+			mockedStore: &postgres.QuerierMock{
+				ListUnscheduledPaymentsFunc: func(ctx context.Context, limit int32) ([]postgres.ListUnscheduledPaymentsRow, error) {
+					return []postgres.ListUnscheduledPaymentsRow{{ID: 1, CreatedAt: now}}, nil
+				},
+				ScheduleSettlementsFunc: func(ctx context.Context, arg postgres.ScheduleSettlementsParams) error {
+					return fmt.Errorf("server error")
+				},
+			},
+			updater:  &updaterMock{updated: map[int64]postgres.ValidStatus{}},
+			expected: map[int64]postgres.ValidStatus{},
+		},
+		{
+			description: "claim error",
+			config:      Config{MinDelay: 10 * time.Second, MaxDelay: 20 * time.Second, SuccessRatio: 1, BatchSize: 10},
+			mockedStore: &postgres.QuerierMock{
+				ListUnscheduledPaymentsFunc: listNone,
+				ClaimDueSettlementsFunc: func(ctx context.Context, arg postgres.ClaimDueSettlementsParams) ([]postgres.Payment, error) {
+					return nil, fmt.Errorf("server error")
+				},
+			},
+			updater:    &updaterMock{updated: map[int64]postgres.ValidStatus{}},
+			expected:   map[int64]postgres.ValidStatus{},
+			claimCalls: 1,
+		},
+		{
+			description: "update error",
+			config:      Config{MinDelay: 10 * time.Second, MaxDelay: 20 * time.Second, SuccessRatio: 1, BatchSize: 10},
+			mockedStore: &postgres.QuerierMock{
+				ListUnscheduledPaymentsFunc: listNone,
+				ClaimDueSettlementsFunc: func(ctx context.Context, arg postgres.ClaimDueSettlementsParams) ([]postgres.Payment, error) {
+					return payments[:1], nil
+				},
+			},
+			updater:    &updaterMock{updated: map[int64]postgres.ValidStatus{}, err: fmt.Errorf("server error")},
+			expected:   map[int64]postgres.ValidStatus{},
+			claimCalls: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			s := New(tc.mockedStore, tc.updater, zap.NewNop(), tc.config)
+			s.now = func() time.Time { return now }
+
+			s.settle(context.Background())
+
+			listCalls := tc.mockedStore.ListUnscheduledPaymentsCalls()
+			assert.Equal(t, 1, len(listCalls))
+			assert.Equal(t, tc.config.BatchSize, listCalls[0].Limit)
+			calls := tc.mockedStore.ClaimDueSettlementsCalls()
+			assert.Equal(t, tc.claimCalls, len(calls))
+			if tc.claimCalls > 0 {
+				assert.Equal(t, now, calls[0].Arg.SettleAt)
+				assert.Equal(t, tc.config.BatchSize, calls[0].Arg.Limit)
+			}
+			assert.Equal(t, tc.expected, tc.updater.updated)
+		})
+	}
+}
+
+func TestSettleSchedulesDeadlines(t *testing.T) {
+	now := time.Now().UTC()
+	config := Config{MinDelay: time.Second, MaxDelay: time.Minute, SuccessRatio: 1, BatchSize: 10}
+	unscheduled := []postgres.ListUnscheduledPaymentsRow{{ID: 1, CreatedAt: now}, {ID: 2, CreatedAt: now.Add(-time.Hour)}}
+	deadlines := map[int64]time.Time{}
+	mockedStore := &postgres.QuerierMock{
+		ListUnscheduledPaymentsFunc: func(ctx context.Context, limit int32) ([]postgres.ListUnscheduledPaymentsRow, error) {
+			return unscheduled, nil
+		},
+		ScheduleSettlementsFunc: func(ctx context.Context, arg postgres.ScheduleSettlementsParams) error {
+			for i, id := range arg.PaymentIds {
+				deadlines[id] = arg.SettleAts[i]
+			}
+			unscheduled = nil
+			return nil
+		},
+		ClaimDueSettlementsFunc: func(ctx context.Context, arg postgres.ClaimDueSettlementsParams) ([]postgres.Payment, error) {
+			var due []postgres.Payment
+			for id, deadline := range deadlines {
+				if !deadline.After(arg.SettleAt) {
+					due = append(due, postgres.Payment{ID: id, PaymentStatus: postgres.ValidStatusNew})
+					delete(deadlines, id)
+				}
+			}
+			return due, nil
+		},
+	}
+	updater := &updaterMock{updated: map[int64]postgres.ValidStatus{}}
+	s := New(mockedStore, updater, zap.NewNop(), config)
+	s.now = func() time.Time { return now }
+
+	s.settle(context.Background())
+	assert.Equal(t, map[int64]postgres.ValidStatus{2: postgres.ValidStatusSuccess}, updater.updated)
+	deadline, ok := deadlines[1]
+	assert.True(t, ok)
+	assert.False(t, deadline.Before(now.Add(config.MinDelay)))
+	assert.False(t, deadline.After(now.Add(config.MaxDelay)))
+
+	s.now = func() time.Time { return deadline }
+	s.settle(context.Background())
+	assert.Equal(t, map[int64]postgres.ValidStatus{1: postgres.ValidStatusSuccess, 2: postgres.ValidStatusSuccess}, updater.updated)
+	assert.Empty(t, deadlines)
+	assert.Equal(t, 1, len(mockedStore.ScheduleSettlementsCalls()))
+}
+
+func TestDelay(t *testing.T) {
+	for _, distribution := range []string{DistributionUniform, DistributionExponential} {
+		s := New(nil, nil, zap.NewNop(), Config{MinDelay: time.Second, MaxDelay: 3 * time.Second, Distribution: distribution})
+		for i := 0; i < 100; i++ {
+			d := s.delay()
+			assert.GreaterOrEqual(t, d, time.Second, distribution)
+			assert.LessOrEqual(t, d, 3*time.Second, distribution)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrKeyNotFound is returned when key set doesn't have key with requested id
+var ErrKeyNotFound = errors.New("signing key not found")
+
+// minReload limits how often unknown key id or unavailable source triggers key set reload
+const minReload = 10 * time.Second
+
+// maxJWKSSize limits size of key set document
+const maxJWKSSize = 1 << 20
+
+// jwk is json web key, only RSA and EC P-256 signing keys are supported
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// KeySet loads json web key set from local file or url and caches it, set is reloaded
+// when cache is older than refresh interval or token is signed by unknown key. Only one load runs
+// at a time, concurrent requests wait for it, failed load isn't retried for minReload
+type KeySet struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+	now     func() time.Time
+
+	mu       sync.RWMutex
+	keys     map[string]interface{}
+	loadedAt time.Time
+	triedAt  time.Time
+	loadErr  error
+	// loading is closed when running load is done, it is nil when no load is running
+	loading chan struct{}
+}
+
+// NewKeySet creates key set, source is file path or http(s) url
+func NewKeySet(source string, refresh time.Duration) *KeySet {
+	return &KeySet{
+		source:  source,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		now:     time.Now,
+	}
+}
+
+// Key returns public key by key id, stale key is returned while source is unavailable
+func (ks *KeySet) Key(ctx context.Context, kid string) (interface{}, error) {
+	ks.mu.RLock()
+	key, ok := ks.keys[kid]
+	fresh := ks.keys != nil && ks.now().Sub(ks.loadedAt) < ks.refresh
+	ks.mu.RUnlock()
+	if ok && fresh {
+		return key, nil
+	}
+
+	ks.mu.Lock()
+	// other request could reload set while waiting for lock
+	key, ok = ks.keys[kid]
+	now := ks.now()
+	age := now.Sub(ks.loadedAt)
+	failed := ks.loadErr != nil && now.Sub(ks.triedAt) < minReload
+	switch {
+	case ok && (age < ks.refresh || failed):
+		ks.mu.Unlock()
+		return key, nil
+	case !ok && ks.keys != nil && age < minReload:
+		ks.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	case failed:
+		err := ks.loadErr
+		ks.mu.Unlock()
+		return nil, err
+	}
+	loading := ks.loading
+	if loading == nil {
+		loading = make(chan struct{})
+		ks.loading = loading
+		go ks.reload(loading)
+	}
+	ks.mu.Unlock()
+
+	select {
+	case <-loading:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	// stale keys are better than no keys while source is unavailable
+	if key, ok = ks.keys[kid]; ok {
+		return key, nil
+	}
+	if ks.loadErr != nil {
+		return nil, ks.loadErr
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+}
+
+// reload loads key set and closes done, load doesn't depend on request that started it,
+// so it isn't canceled with request and is limited by client timeout only
+func (ks *KeySet) reload(done chan struct{}) {
+	keys, err := ks.load(context.Background())
+
+	ks.mu.Lock()
+	ks.triedAt = ks.now()
+	ks.loadErr = err
+	if err == nil {
+		ks.keys = keys
+		ks.loadedAt = ks.triedAt
+	}
+	ks.loading = nil
+	ks.mu.Unlock()
+	close(done)
+}
+
+func (ks *KeySet) load(ctx context.Context) (map[string]interface{}, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(ks.source, "http://") || strings.HasPrefix(ks.source, "https://") {
+		data, err = ks.fetch(ctx)
+	} else {
+		data, err = os.ReadFile(ks.source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't load jwks: %w", err)
+	}
+
+	return parseJWKS(data)
+}
+
+func (ks *KeySet) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.source, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+// parseJWKS parses json web key set, keys not used for signing and unsupported keys are skipped
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("can't decode jwks: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			key, err := rsaKey(k)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s key: %w", k.Kid, err)
+			}
+			keys[k.Kid] = key
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			key, err := ecKey(k)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s key: %w", k.Kid, err)
+			}
+			keys[k.Kid] = key
+		}
+	}
+
+	return keys, nil
+}
+
+func rsaKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(e)
+	if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid exponent")
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+}
+
+func ecKey(k jwk) (*ecdsa.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		return nil, errors.New("point is not on curve")
+	}
+
+	return key, nil
+}
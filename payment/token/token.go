@@ -0,0 +1,72 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Claims are claims of gateway token, zero user id means token isn't bound to user
+type Claims struct {
+	jwt.RegisteredClaims
+	UserID     int64    `json:"user_id"`
+	MerchantID int64    `json:"merchant_id"`
+	Scopes     []string `json:"scopes"`
+}
+
+// Verifier checks bearer tokens signed by keys from key set
+type Verifier struct {
+	keys     *KeySet
+	issuer   string
+	audience string
+	parser   *jwt.Parser
+	now      func() time.Time
+}
+
+// NewVerifier creates token verifier, empty issuer or audience is not checked
+func NewVerifier(keys *KeySet, issuer, audience string) *Verifier {
+	return &Verifier{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		parser:   jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "ES256"}), jwt.WithoutClaimsValidation()),
+		now:      time.Now,
+	}
+}
+
+// Verify checks token signature and claims
+func (v *Verifier) Verify(ctx context.Context, raw string) (Claims, error) {
+	claims := Claims{}
+	_, err := v.parser.ParseWithClaims(raw, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return Claims{}, fmt.Errorf("invalid token: %w", err)
+	}
+
+	now := v.now()
+	if claims.ExpiresAt == nil {
+		return Claims{}, errors.New("no token expiration")
+	}
+	if !claims.VerifyExpiresAt(now, true) {
+		return Claims{}, errors.New("token is expired")
+	}
+	if !claims.VerifyNotBefore(now, false) {
+		return Claims{}, errors.New("token is not valid yet")
+	}
+	if v.issuer != "" && !claims.VerifyIssuer(v.issuer, true) {
+		return Claims{}, errors.New("invalid token issuer")
+	}
+	if v.audience != "" && !claims.VerifyAudience(v.audience, true) {
+		return Claims{}, errors.New("invalid token audience")
+	}
+	if claims.Subject == "" {
+		return Claims{}, errors.New("no token subject")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,208 @@
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rsaJWK(t *testing.T, kid string, key *rsa.PrivateKey) jwk {
+	t.Helper()
+	return jwk{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(t *testing.T, kid string, key *ecdsa.PrivateKey) jwk {
+	t.Helper()
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+	return jwk{Kty: "EC", Kid: kid, Crv: "P-256", X: base64.RawURLEncoding.EncodeToString(x), Y: base64.RawURLEncoding.EncodeToString(y)}
+}
+
+func writeJWKS(t *testing.T, path string, keys ...jwk) {
+	t.Helper()
+	data, err := json.Marshal(map[string][]jwk{"keys": keys})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims Claims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	tok.Header["kid"] = kid
+	raw, err := tok.SignedString(key)
+	require.NoError(t, err)
+	return raw
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, rsaJWK(t, "rsa", rsaKey), ecJWK(t, "ec", ecKey))
+	v := NewVerifier(NewKeySet(path, time.Minute), "gateway", "payments")
+
+	now := time.Now()
+	valid := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-2",
+			Issuer:    "gateway",
+			Audience:  jwt.ClaimStrings{"payments"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+		UserID: 2,
+		Scopes: []string{"payments:read"},
+	}
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+	otherIssuer := valid
+	otherIssuer.Issuer = "other"
+	noExpiry := valid
+	noExpiry.ExpiresAt = nil
+
+	cases := []struct {
+		description string
+		raw         string
+		err         string
+	}{
+		{description: "rs256", raw: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, valid)},
+		{description: "es256", raw: sign(t, jwt.SigningMethodES256, "ec", ecKey, valid)},
+		{description: "expired", raw: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, expired), err: "token is expired"},
+		{description: "no expiration", raw: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, noExpiry), err: "no token expiration"},
+		{description: "wrong issuer", raw: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, otherIssuer), err: "invalid token issuer"},
+		{description: "hs256", raw: sign(t, jwt.SigningMethodHS256, "rsa", []byte("secret"), valid), err: "invalid token: signing method HS256 is invalid"},
+		{description: "key mismatch", raw: sign(t, jwt.SigningMethodES256, "rsa", ecKey, valid), err: "invalid token: key is of invalid type"},
+		{description: "unknown key", raw: sign(t, jwt.SigningMethodRS256, "other", rsaKey, valid), err: "invalid token: signing key not found: other"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			claims, err := v.Verify(context.Background(), tc.raw)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "user-2", claims.Subject)
+			assert.Equal(t, int64(2), claims.UserID)
+			assert.Equal(t, []string{"payments:read"}, claims.Scopes)
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, rsaJWK(t, "old", oldKey))
+	ks := NewKeySet(path, time.Hour)
+	now := time.Now()
+	ks.now = func() time.Time { return now }
+
+	_, err = ks.Key(context.Background(), "old")
+	require.NoError(t, err)
+
+	// new key is not visible until unknown key reload interval passes
+	writeJWKS(t, path, rsaJWK(t, "new", newKey))
+	_, err = ks.Key(context.Background(), "new")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	now = now.Add(minReload)
+	key, err := ks.Key(context.Background(), "new")
+	require.NoError(t, err)
+	assert.Equal(t, &newKey.PublicKey, key)
+
+	// key removed from set is gone after reload
+	_, err = ks.Key(context.Background(), "old")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestKeySetURL(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_ = json.NewEncoder(w).Encode(map[string][]jwk{"keys": {ecJWK(t, "ec", key)}})
+	}))
+	defer srv.Close()
+
+	ks := NewKeySet(srv.URL, time.Hour)
+	for i := 0; i < 3; i++ {
+		got, err := ks.Key(context.Background(), "ec")
+		require.NoError(t, err)
+		assert.True(t, key.PublicKey.Equal(got))
+	}
+	assert.Equal(t, 1, requests)
+}
+
+func TestKeySetSourceDown(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string][]jwk{"keys": {ecJWK(t, "ec", key)}})
+	}))
+	defer srv.Close()
+
+	ks := NewKeySet(srv.URL, time.Minute)
+	now := time.Now()
+	ks.now = func() time.Time { return now }
+
+	// canceled request doesn't cancel load
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ks.Key(ctx, "ec")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = ks.Key(context.Background(), "ec")
+	require.NoError(t, err)
+
+	// stale key is used while source is down, failed load isn't retried until minReload passes
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		got, err := ks.Key(context.Background(), "ec")
+		require.NoError(t, err)
+		assert.True(t, key.PublicKey.Equal(got))
+	}
+	_, err = ks.Key(context.Background(), "other")
+	assert.EqualError(t, err, "can't load jwks: unexpected status 503")
+	assert.Equal(t, 2, requests)
+
+	now = now.Add(minReload)
+	_, err = ks.Key(context.Background(), "other")
+	assert.Error(t, err)
+	assert.Equal(t, 3, requests)
+}
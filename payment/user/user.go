@@ -0,0 +1,194 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/semka95/payment-service/payment/apikey"
+	paymentModel "github.com/semka95/payment-service/payment/repository"
+)
+
+// Errors returned by manager
+var (
+	ErrNotFound        = errors.New("user not found")
+	ErrExists          = errors.New("user already exists")
+	ErrInvalidRole     = errors.New("invalid role")
+	ErrInvalidPassword = errors.New("invalid password")
+	ErrNoName          = errors.New("no name provided")
+)
+
+// minPasswordLength is minimal password length, bcrypt ignores bytes after 72
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+// Scopes returns payment api scopes granted to role
+func Scopes(role paymentModel.UserRole) []string {
+	switch role {
+	case paymentModel.UserRoleProvider:
+		return []string{string(apikey.ScopePaymentsRead), string(apikey.ScopePaymentsUpdateStatus)}
+	case paymentModel.UserRoleOperator:
+		return []string{string(apikey.ScopePaymentsRead), string(apikey.ScopePaymentsCancel)}
+	case paymentModel.UserRoleAdmin:
+		scopes := make([]string, 0, len(apikey.Scopes))
+		for _, s := range apikey.Scopes {
+			scopes = append(scopes, string(s))
+		}
+		return scopes
+	case paymentModel.UserRoleReadonly:
+		return []string{string(apikey.ScopePaymentsRead)}
+	}
+
+	return nil
+}
+
+// ValidRole checks that role is known
+func ValidRole(role paymentModel.UserRole) error {
+	switch role {
+	case paymentModel.UserRoleProvider, paymentModel.UserRoleOperator, paymentModel.UserRoleAdmin, paymentModel.UserRoleReadonly:
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalidRole, role)
+}
+
+// Manager creates users and checks their passwords, only bcrypt hashes of passwords are stored
+type Manager struct {
+	store paymentModel.Querier
+	cost  int
+
+	dummyOnce sync.Once
+	dummyHash []byte
+}
+
+// NewManager creates user manager
+func NewManager(store paymentModel.Querier) *Manager {
+	return &Manager{store: store, cost: bcrypt.DefaultCost}
+}
+
+// Create creates user of merchant with role
+func (m *Manager) Create(ctx context.Context, merchantID int64, name, password string, role paymentModel.UserRole) (paymentModel.User, error) {
+	if name == "" {
+		return paymentModel.User{}, ErrNoName
+	}
+	if err := ValidRole(role); err != nil {
+		return paymentModel.User{}, err
+	}
+	hash, err := m.hash(password)
+	if err != nil {
+		return paymentModel.User{}, err
+	}
+
+	u, err := m.store.CreateUser(ctx, paymentModel.CreateUserParams{
+		Name:         name,
+		PasswordHash: hash,
+		Role:         role,
+		MerchantID:   merchantID,
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == paymentModel.UniqueViolation {
+		return paymentModel.User{}, ErrExists
+	}
+
+	return u, err
+}
+
+// SetPassword changes user password
+func (m *Manager) SetPassword(ctx context.Context, name, password string) error {
+	hash, err := m.hash(password)
+	if err != nil {
+		return err
+	}
+	rows, err := m.store.UpdateUserPassword(ctx, paymentModel.UpdateUserPasswordParams{Name: name, PasswordHash: hash})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetRole changes user role
+func (m *Manager) SetRole(ctx context.Context, name string, role paymentModel.UserRole) error {
+	if err := ValidRole(role); err != nil {
+		return err
+	}
+	rows, err := m.store.UpdateUserRole(ctx, paymentModel.UpdateUserRoleParams{Name: name, Role: role})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete deletes user
+func (m *Manager) Delete(ctx context.Context, name string) error {
+	rows, err := m.store.DeleteUser(ctx, name)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// List returns all users
+func (m *Manager) List(ctx context.Context) ([]paymentModel.User, error) {
+	return m.store.ListUsers(ctx)
+}
+
+// Authenticate checks user password, unknown user and wrong password both return ErrNotFound
+func (m *Manager) Authenticate(ctx context.Context, name, password string) (paymentModel.User, error) {
+	u, err := m.store.GetUserByName(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		// password is still compared, so unknown user can't be told from existing one by response time
+		_ = bcrypt.CompareHashAndPassword(m.dummy(), []byte(password))
+		return paymentModel.User{}, ErrNotFound
+	}
+	if err != nil {
+		return paymentModel.User{}, err
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		return paymentModel.User{}, ErrNotFound
+	}
+
+	return u, nil
+}
+
+// dummy returns hash of random password with cost of stored hashes, it is compared when user is unknown
+func (m *Manager) dummy() []byte {
+	m.dummyOnce.Do(func() {
+		password := make([]byte, maxPasswordLength)
+		_, _ = rand.Read(password)
+		m.dummyHash, _ = bcrypt.GenerateFromPassword(password, m.cost)
+	})
+
+	return m.dummyHash
+}
+
+func (m *Manager) hash(password string) (string, error) {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return "", fmt.Errorf("%w: password must be from %d to %d bytes long", ErrInvalidPassword, minPasswordLength, maxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), m.cost)
+	if err != nil {
+		return "", fmt.Errorf("can't hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
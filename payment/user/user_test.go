@@ -0,0 +1,80 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	postgres "github.com/semka95/payment-service/payment/repository"
+)
+
+func TestCreate(t *testing.T) {
+	mockedStore := &postgres.QuerierMock{
+		CreateUserFunc: func(ctx context.Context, arg postgres.CreateUserParams) (postgres.User, error) {
+			if arg.Name == "taken" {
+				return postgres.User{}, &pq.Error{Code: postgres.UniqueViolation}
+			}
+			return postgres.User{ID: 1, Name: arg.Name, PasswordHash: arg.PasswordHash, Role: arg.Role, MerchantID: arg.MerchantID}, nil
+		},
+	}
+	m := NewManager(mockedStore)
+	m.cost = bcrypt.MinCost
+
+	u, err := m.Create(context.Background(), 1, "alice", "password1", postgres.UserRoleOperator)
+	require.NoError(t, err)
+	assert.Equal(t, postgres.UserRoleOperator, u.Role)
+	assert.Equal(t, int64(1), u.MerchantID)
+	assert.NotEqual(t, "password1", u.PasswordHash)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte("password1")))
+
+	_, err = m.Create(context.Background(), 1, "taken", "password1", postgres.UserRoleOperator)
+	assert.ErrorIs(t, err, ErrExists)
+	_, err = m.Create(context.Background(), 1, "", "password1", postgres.UserRoleOperator)
+	assert.ErrorIs(t, err, ErrNoName)
+	_, err = m.Create(context.Background(), 1, "bob", "password1", postgres.UserRole("root"))
+	assert.ErrorIs(t, err, ErrInvalidRole)
+	_, err = m.Create(context.Background(), 1, "bob", "short", postgres.UserRoleOperator)
+	assert.ErrorIs(t, err, ErrInvalidPassword)
+	assert.Equal(t, 2, len(mockedStore.CreateUserCalls()))
+}
+
+func TestAuthenticate(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password1"), bcrypt.MinCost)
+	require.NoError(t, err)
+	mockedStore := &postgres.QuerierMock{
+		GetUserByNameFunc: func(ctx context.Context, name string) (postgres.User, error) {
+			if name != "alice" {
+				return postgres.User{}, sql.ErrNoRows
+			}
+			return postgres.User{ID: 1, Name: name, PasswordHash: string(hash), Role: postgres.UserRoleAdmin}, nil
+		},
+	}
+	m := NewManager(mockedStore)
+	m.cost = bcrypt.MinCost
+
+	u, err := m.Authenticate(context.Background(), "alice", "password1")
+	require.NoError(t, err)
+	assert.Equal(t, postgres.UserRoleAdmin, u.Role)
+
+	_, err = m.Authenticate(context.Background(), "alice", "password2")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = m.Authenticate(context.Background(), "bob", "password1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	// unknown user is checked against dummy hash of the same cost
+	cost, err := bcrypt.Cost(m.dummyHash)
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.MinCost, cost)
+}
+
+func TestScopes(t *testing.T) {
+	assert.Equal(t, []string{"payments:read"}, Scopes(postgres.UserRoleReadonly))
+	assert.Equal(t, []string{"payments:read", "payments:update_status"}, Scopes(postgres.UserRoleProvider))
+	assert.Equal(t, []string{"payments:read", "payments:cancel"}, Scopes(postgres.UserRoleOperator))
+	assert.Equal(t, 4, len(Scopes(postgres.UserRoleAdmin)))
+	assert.Empty(t, Scopes(postgres.UserRole("root")))
+}
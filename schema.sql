@@ -1,5 +1,16 @@
-CREATE TYPE valid_status AS ENUM ('new', 'success', 'failure', 'error');
+CREATE TYPE valid_status AS ENUM ('new', 'success', 'failure', 'error', 'expired', 'review');
 CREATE TYPE valid_currency AS ENUM ('usd', 'eur', 'rub');
+CREATE TYPE dispute_status AS ENUM ('open', 'won', 'lost');
+CREATE TYPE risk_decision AS ENUM ('approve', 'review', 'reject');
+CREATE TYPE review_decision AS ENUM ('approved', 'declined');
+CREATE TYPE user_role AS ENUM ('provider', 'operator', 'admin', 'readonly');
+CREATE TYPE discrepancy_kind AS ENUM ('amount', 'currency', 'status', 'missing_payment', 'missing_settlement');
+
+CREATE TABLE merchants (
+  id BIGSERIAL PRIMARY KEY,
+  name VARCHAR (255) NOT NULL UNIQUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
 
 CREATE TABLE payments (
   id BIGSERIAL PRIMARY KEY,
@@ -8,9 +19,187 @@ CREATE TABLE payments (
   amount NUMERIC(10, 2) NOT NULL CHECK (amount > 0),
   currency valid_currency NOT NULL,
   payment_status valid_status NOT NULL DEFAULT 'new',
-  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMPTZ,
+  risk_score INTEGER NOT NULL DEFAULT 0,
+  risk_decision risk_decision NOT NULL DEFAULT 'approve',
+  risk_rules TEXT[] NOT NULL DEFAULT '{}',
+  merchant_id BIGINT NOT NULL REFERENCES merchants (id),
+  description VARCHAR (1000) NOT NULL DEFAULT '',
+  merchant_reference VARCHAR (255) NOT NULL DEFAULT '',
+  metadata JSONB NOT NULL DEFAULT '{}',
+  version BIGINT NOT NULL DEFAULT 1
+);
+
+CREATE INDEX ON payments (merchant_id, id);
+CREATE UNIQUE INDEX ON payments (merchant_id, merchant_reference) WHERE merchant_reference <> '';
+CREATE INDEX ON payments USING GIN (metadata jsonb_path_ops);
+CREATE INDEX ON payments (email varchar_pattern_ops);
+-- every list and search query is limited to merchant of caller, so list indexes lead with merchant_id
+CREATE INDEX ON payments (merchant_id, email, id);
+CREATE INDEX ON payments (merchant_id, user_id, id);
+CREATE INDEX ON payments (merchant_id, user_id, created_at, id);
+CREATE INDEX ON payments (merchant_id, email, created_at, id);
+CREATE INDEX ON payments (merchant_id, user_id, amount, id);
+CREATE INDEX ON payments (merchant_id, email, amount, id);
+CREATE INDEX ON payments (merchant_id, user_id, payment_status, id);
+CREATE INDEX ON payments (merchant_id, email, payment_status, id);
+CREATE INDEX ON payments (expires_at) WHERE payment_status = 'new';
+CREATE INDEX ON payments (id) WHERE payment_status = 'review';
+CREATE INDEX ON payments (id) WHERE payment_status = 'new';
+
+CREATE TABLE payment_events (
+  id BIGSERIAL PRIMARY KEY,
+  event_type VARCHAR (32) NOT NULL,
+  payment_id BIGINT NOT NULL REFERENCES payments (id) ON DELETE CASCADE,
+  user_id BIGINT NOT NULL,
+  merchant_id BIGINT NOT NULL,
+  payment_status valid_status NOT NULL,
+  version BIGINT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX ON payment_events (merchant_id, id);
+CREATE INDEX ON payment_events (payment_id, id);
+
+CREATE TABLE disputes (
+  id BIGSERIAL PRIMARY KEY,
+  payment_id BIGINT NOT NULL REFERENCES payments (id),
+  dispute_status dispute_status NOT NULL DEFAULT 'open',
+  reason VARCHAR (255) NOT NULL,
+  evidence JSONB NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX ON disputes (payment_id);
+CREATE UNIQUE INDEX ON disputes (payment_id) WHERE dispute_status = 'open';
+
+CREATE TABLE reversals (
+  id BIGSERIAL PRIMARY KEY,
+  payment_id BIGINT NOT NULL REFERENCES payments (id),
+  dispute_id BIGINT NOT NULL REFERENCES disputes (id),
+  amount NUMERIC(10, 2) NOT NULL,
+  currency valid_currency NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX ON reversals (payment_id);
+
+CREATE TABLE payment_reviews (
+  id BIGSERIAL PRIMARY KEY,
+  payment_id BIGINT NOT NULL REFERENCES payments (id) ON DELETE CASCADE,
+  reviewer VARCHAR (255) NOT NULL,
+  review_decision review_decision NOT NULL,
+  note TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX ON payment_reviews (payment_id);
+
+CREATE TABLE api_keys (
+  id BIGSERIAL PRIMARY KEY,
+  name VARCHAR (255) NOT NULL,
+  prefix VARCHAR (16) NOT NULL,
+  key_hash VARCHAR (64) NOT NULL UNIQUE,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  revoked_at TIMESTAMPTZ,
+  merchant_id BIGINT NOT NULL REFERENCES merchants (id)
+);
+
+CREATE INDEX ON api_keys (merchant_id, id);
+
+CREATE TABLE callback_nonces (
+  nonce VARCHAR (64) PRIMARY KEY,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX ON callback_nonces (created_at);
+
+CREATE TABLE users (
+  id BIGSERIAL PRIMARY KEY,
+  name VARCHAR (255) NOT NULL UNIQUE,
+  password_hash VARCHAR (72) NOT NULL,
+  role user_role NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  merchant_id BIGINT NOT NULL REFERENCES merchants (id)
+);
+
+CREATE TABLE audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  actor VARCHAR (255) NOT NULL,
+  action VARCHAR (64) NOT NULL,
+  target VARCHAR (255) NOT NULL,
+  method VARCHAR (10) NOT NULL DEFAULT '',
+  path TEXT NOT NULL DEFAULT '',
+  merchant_id BIGINT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX ON audit_log (merchant_id, created_at);
+CREATE INDEX ON audit_log (actor, created_at);
+CREATE INDEX ON audit_log (target, created_at);
+
+CREATE TABLE idempotency_keys (
+  merchant_id BIGINT NOT NULL,
+  owner VARCHAR (255) NOT NULL,
+  idempotency_key VARCHAR (255) NOT NULL,
+  request_hash VARCHAR (64) NOT NULL,
+  payment_id BIGINT NOT NULL REFERENCES payments (id) ON DELETE CASCADE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (merchant_id, owner, idempotency_key)
+);
+
+-- settlement deadlines of simulated payment system, row is removed when simulator claims payment
+CREATE TABLE simulated_settlements (
+  payment_id BIGINT PRIMARY KEY REFERENCES payments (id) ON DELETE CASCADE,
+  settle_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX ON simulated_settlements (settle_at);
+
+CREATE TABLE payment_daily_summaries (
+  day DATE NOT NULL,
+  merchant_id BIGINT NOT NULL,
+  user_id BIGINT NOT NULL,
+  currency valid_currency NOT NULL,
+  payment_status valid_status NOT NULL,
+  payment_count BIGINT NOT NULL,
+  amount NUMERIC NOT NULL,
+  PRIMARY KEY (day, merchant_id, user_id, currency, payment_status)
+);
+
+CREATE TABLE reconciliation_runs (
+  id BIGSERIAL PRIMARY KEY,
+  source VARCHAR (255) NOT NULL,
+  actor VARCHAR (255) NOT NULL,
+  period_from TIMESTAMPTZ NOT NULL,
+  period_to TIMESTAMPTZ NOT NULL,
+  auto_apply BOOLEAN NOT NULL,
+  line_count INTEGER NOT NULL,
+  matched_count INTEGER NOT NULL,
+  discrepancy_count INTEGER NOT NULL,
+  applied_count INTEGER NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  merchant_id BIGINT NOT NULL REFERENCES merchants (id)
+);
+
+CREATE INDEX ON reconciliation_runs (merchant_id, id);
+
+CREATE TABLE reconciliation_discrepancies (
+  id BIGSERIAL PRIMARY KEY,
+  run_id BIGINT NOT NULL REFERENCES reconciliation_runs (id) ON DELETE CASCADE,
+  payment_id BIGINT NOT NULL,
+  kind discrepancy_kind NOT NULL,
+  provider_value TEXT NOT NULL,
+  payment_value TEXT NOT NULL,
+  applied BOOLEAN NOT NULL DEFAULT false,
+  apply_error TEXT NOT NULL DEFAULT ''
 );
 
-CREATE INDEX ON payments (email);
-CREATE INDEX ON payments (user_id);
\ No newline at end of file
+CREATE INDEX ON reconciliation_discrepancies (run_id, id);